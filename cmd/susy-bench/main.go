@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"math/rand"
 	"net"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
@@ -14,7 +16,7 @@ var (
 	host        = flag.String("h", "localhost:7379", "SusyDB host address")
 	concurrency = flag.Int("c", 50, "Number of concurrent connections")
 	requests    = flag.Int("n", 100000, "Total number of requests")
-	workload    = flag.String("test", "mixed", "Workload type: set, get, mixed, setex, incr, hash")
+	workload    = flag.String("test", "mixed", "Workload type: set, get, mixed, setex, incr, hash, publish, spublish")
 )
 
 func main() {
@@ -53,7 +55,7 @@ func main() {
 
 				t0 := time.Now()
 				fmt.Fprint(conn, cmd)
-				_, err = reader.ReadString('\n')
+				err = readReply(reader)
 				if err != nil {
 					return
 				}
@@ -85,6 +87,37 @@ func populateKeys(count int) {
 	for i := 0; i < count; i++ {
 		key := fmt.Sprintf("key:%d", i)
 		fmt.Fprintf(conn, "SET %s value_payload\r\n", key)
-		reader.ReadString('\n')
+		readReply(reader)
 	}
 }
+
+// readReply consumes one complete RESP reply, including bulk payloads
+// and nested arrays, so the next read starts at the following reply.
+func readReply(reader *bufio.Reader) error {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return err
+	}
+	if len(line) < 3 {
+		return nil
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	switch line[0] {
+	case '$':
+		if err != nil || n < 0 {
+			return nil
+		}
+		_, err = reader.Discard(n + 2)
+		return err
+	case '*':
+		if err != nil {
+			return nil
+		}
+		for i := 0; i < n; i++ {
+			if err := readReply(reader); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -28,6 +28,9 @@ func getCommand(workloadType string, key string, j int) string {
 	case "publish":
 		// Publish to a static channel 'bench_chan'
 		return fmt.Sprintf("PUBLISH bench_chan %s\r\n", val)
+	case "spublish":
+		// Spread over many shard channels, so publishes hit different Hub shards
+		return fmt.Sprintf("SPUBLISH bench_%s %s\r\n", key, val)
 	default:
 		return ""
 	}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Syed-Suhaan/SusyDB/pkg/resp"
+)
+
+// formatReply renders a reply as redis-cli does on a terminal: bulk
+// strings quoted, simple strings bare, typed integers, errors and nils,
+// and numbered array elements with nested arrays indented under them.
+func formatReply(v interface{}) string {
+	var b strings.Builder
+	writeReply(&b, v, "")
+	return b.String()
+}
+
+// writeReply writes v, indenting the lines after the first by indent.
+func writeReply(b *strings.Builder, v interface{}, indent string) {
+	switch v := v.(type) {
+	case nil:
+		b.WriteString("(nil)\n")
+	case resp.Status:
+		b.WriteString(string(v) + "\n")
+	case string:
+		b.WriteString(quote(v) + "\n")
+	case int64:
+		fmt.Fprintf(b, "(integer) %d\n", v)
+	case resp.Error:
+		b.WriteString("(error) " + string(v) + "\n")
+	case []interface{}:
+		if len(v) == 0 {
+			b.WriteString("(empty array)\n")
+			return
+		}
+		width := len(strconv.Itoa(len(v)))
+		for i, item := range v {
+			label := fmt.Sprintf("%*d) ", width, i+1)
+			if i > 0 {
+				b.WriteString(indent)
+			}
+			b.WriteString(label)
+			writeReply(b, item, indent+strings.Repeat(" ", len(label)))
+		}
+	default:
+		fmt.Fprintf(b, "%v\n", v)
+	}
+}
+
+// formatRaw renders a reply for scripts (-raw, or when stdout is not a
+// terminal): strings and integers as they are, one array element per line,
+// and nil as an empty line.
+func formatRaw(v interface{}) string {
+	var b strings.Builder
+	writeRaw(&b, v)
+	return b.String()
+}
+
+func writeRaw(b *strings.Builder, v interface{}) {
+	switch v := v.(type) {
+	case nil:
+		b.WriteString("\n")
+	case []interface{}:
+		for _, item := range v {
+			writeRaw(b, item)
+		}
+	case resp.Error:
+		b.WriteString(string(v) + "\n")
+	default:
+		fmt.Fprintf(b, "%v\n", v)
+	}
+}
+
+// quote puts s in double quotes, escaping quotes, backslashes and bytes
+// that are not printable ASCII so binary values stay on one line.
+func quote(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '"' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c == '\n':
+			b.WriteString(`\n`)
+		case c == '\r':
+			b.WriteString(`\r`)
+		case c == '\t':
+			b.WriteString(`\t`)
+		case c < ' ' || c > '~':
+			fmt.Fprintf(&b, `\x%02x`, c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// splitArgs splits a command line into arguments as redis-cli does.
+// Double quotes allow \n, \r, \t, \", \\ and \xHH escapes; single quotes
+// take everything literally except \'. A closing quote must be followed by
+// a space or the end of the line.
+func splitArgs(line string) ([]string, error) {
+	var args []string
+	i := 0
+	for {
+		for i < len(line) && (line[i] == ' ' || line[i] == '\t') {
+			i++
+		}
+		if i == len(line) {
+			return args, nil
+		}
+		var arg strings.Builder
+		inDouble, inSingle := false, false
+		for ; i < len(line); i++ {
+			c := line[i]
+			switch {
+			case inDouble && c == '\\' && i+3 < len(line) && line[i+1] == 'x' && isHex(line[i+2]) && isHex(line[i+3]):
+				n, _ := strconv.ParseUint(line[i+2:i+4], 16, 8)
+				arg.WriteByte(byte(n))
+				i += 3
+			case inDouble && c == '\\' && i+1 < len(line):
+				i++
+				switch line[i] {
+				case 'n':
+					arg.WriteByte('\n')
+				case 'r':
+					arg.WriteByte('\r')
+				case 't':
+					arg.WriteByte('\t')
+				default:
+					arg.WriteByte(line[i])
+				}
+			case inDouble && c == '"', inSingle && c == '\'':
+				if i+1 < len(line) && line[i+1] != ' ' && line[i+1] != '\t' {
+					return nil, fmt.Errorf("closing quote must be followed by a space")
+				}
+				inDouble, inSingle = false, false
+				i++
+				goto done
+			case inSingle && c == '\\' && i+1 < len(line) && line[i+1] == '\'':
+				arg.WriteByte('\'')
+				i++
+			case inDouble || inSingle:
+				arg.WriteByte(c)
+			case c == ' ' || c == '\t':
+				goto done
+			case c == '"' && arg.Len() == 0:
+				inDouble = true
+			case c == '\'' && arg.Len() == 0:
+				inSingle = true
+			default:
+				arg.WriteByte(c)
+			}
+		}
+		if inDouble || inSingle {
+			return nil, fmt.Errorf("unbalanced quotes")
+		}
+	done:
+		args = append(args, arg.String())
+	}
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
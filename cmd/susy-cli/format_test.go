@@ -0,0 +1,78 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Syed-Suhaan/SusyDB/pkg/resp"
+)
+
+func TestFormatReply(t *testing.T) {
+	tests := []struct {
+		name  string
+		reply interface{}
+		want  string
+	}{
+		{"status", resp.Status("OK"), "OK\n"},
+		{"bulk string", "OK", "\"OK\"\n"},
+		{"binary string", "a\"b\\\n\x00\xff", "\"a\\\"b\\\\\\n\\x00\\xff\"\n"},
+		{"integer", int64(-7), "(integer) -7\n"},
+		{"nil", nil, "(nil)\n"},
+		{"error", resp.Error("ERR unknown command"), "(error) ERR unknown command\n"},
+		{"empty array", []interface{}{}, "(empty array)\n"},
+		{"array", []interface{}{"a", nil, int64(1)}, "1) \"a\"\n2) (nil)\n3) (integer) 1\n"},
+		{"nested array", []interface{}{"0", []interface{}{"k1", []interface{}{}, "k2"}},
+			"1) \"0\"\n2) 1) \"k1\"\n   2) (empty array)\n   3) \"k2\"\n"},
+		{"wide array", []interface{}{int64(1), int64(2), int64(3), int64(4), int64(5), int64(6), int64(7), int64(8), int64(9), []interface{}{"x", "y"}},
+			" 1) (integer) 1\n 2) (integer) 2\n 3) (integer) 3\n 4) (integer) 4\n 5) (integer) 5\n" +
+				" 6) (integer) 6\n 7) (integer) 7\n 8) (integer) 8\n 9) (integer) 9\n10) 1) \"x\"\n    2) \"y\"\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := formatReply(tt.reply); got != tt.want {
+				t.Errorf("formatReply() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatRaw(t *testing.T) {
+	reply := []interface{}{resp.Status("OK"), "v", nil, int64(3), []interface{}{"a"}, resp.Error("ERR x")}
+	if got, want := formatRaw(reply), "OK\nv\n\n3\na\nERR x\n"; got != want {
+		t.Errorf("formatRaw() = %q, want %q", got, want)
+	}
+}
+
+func TestSplitArgs(t *testing.T) {
+	tests := []struct {
+		line string
+		want []string
+	}{
+		{"", nil},
+		{"  GET   key  ", []string{"GET", "key"}},
+		{"SET k \"hello world\"", []string{"SET", "k", "hello world"}},
+		{`SET k "a\"b\\c\n\t\r"`, []string{"SET", "k", "a\"b\\c\n\t\r"}},
+		{`SET k "\x41\x7a\xzz"`, []string{"SET", "k", "Azxzz"}}, // \x without hex digits is a plain x
+		{`SET k 'it\'s "raw" \n'`, []string{"SET", "k", `it's "raw" \n`}},
+		{`SET k ""`, []string{"SET", "k", ""}},
+		{`SET a"b c`, []string{"SET", `a"b`, "c"}},
+	}
+	for _, tt := range tests {
+		got, err := splitArgs(tt.line)
+		if err != nil {
+			t.Errorf("splitArgs(%q) error = %v", tt.line, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitArgs(%q) = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestSplitArgsErrors(t *testing.T) {
+	for _, line := range []string{`SET k "open`, `SET k 'open`, `SET k "a"b`, `SET k 'a'b`} {
+		if _, err := splitArgs(line); err == nil {
+			t.Errorf("splitArgs(%q) error = nil, want an error", line)
+		}
+	}
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+const historyMax = 1000 // Lines kept in memory and in the history file
+
+// errInterrupted is returned by readLine when the user presses Ctrl-C.
+var errInterrupted = errors.New("interrupted")
+
+// lineEditor reads lines from the terminal with Emacs-style editing keys
+// and a history browsed with the arrow keys. When stdin is not a terminal
+// it reads plain lines.
+type lineEditor struct {
+	in       *bufio.Reader
+	out      io.Writer
+	fd       int
+	history  []string
+	histFile string // Appended to as lines are entered; "" for none
+}
+
+func newLineEditor(histFile string) *lineEditor {
+	e := &lineEditor{in: bufio.NewReader(os.Stdin), out: os.Stdout, fd: int(os.Stdin.Fd()), histFile: histFile}
+	e.loadHistory()
+	return e
+}
+
+// readLine shows prompt and returns the line entered, without its newline.
+// It returns io.EOF on Ctrl-D at an empty line or the end of input.
+func (e *lineEditor) readLine(prompt string) (string, error) {
+	fmt.Fprint(e.out, prompt)
+	restore, err := rawMode(e.fd)
+	if err != nil {
+		line, err := e.in.ReadString('\n')
+		if err != nil && line == "" {
+			return "", err
+		}
+		return strings.TrimRight(line, "\r\n"), nil
+	}
+	defer restore()
+
+	var line []rune
+	pos := 0
+	hist := len(e.history) // Index in history; len(history) is the new line
+	pending := ""          // The new line, kept while browsing the history
+	refresh := func() {
+		fmt.Fprintf(e.out, "\r%s%s\x1b[K", prompt, string(line))
+		if n := len(line) - pos; n > 0 {
+			fmt.Fprintf(e.out, "\x1b[%dD", n)
+		}
+	}
+	browse := func(to int) {
+		if to < 0 || to > len(e.history) {
+			return
+		}
+		if hist == len(e.history) {
+			pending = string(line)
+		}
+		hist = to
+		if hist == len(e.history) {
+			line = []rune(pending)
+		} else {
+			line = []rune(e.history[hist])
+		}
+		pos = len(line)
+		refresh()
+	}
+
+	for {
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			return "", err
+		}
+		switch r {
+		case '\r', '\n':
+			fmt.Fprint(e.out, "\r\n")
+			return string(line), nil
+		case 3: // Ctrl-C
+			fmt.Fprint(e.out, "^C\r\n")
+			return "", errInterrupted
+		case 4: // Ctrl-D
+			if len(line) == 0 {
+				fmt.Fprint(e.out, "\r\n")
+				return "", io.EOF
+			}
+			if pos < len(line) {
+				line = append(line[:pos], line[pos+1:]...)
+			}
+		case 127, 8: // Backspace
+			if pos > 0 {
+				line = append(line[:pos-1], line[pos:]...)
+				pos--
+			}
+		case 1: // Ctrl-A
+			pos = 0
+		case 5: // Ctrl-E
+			pos = len(line)
+		case 2: // Ctrl-B
+			pos = max(pos-1, 0)
+		case 6: // Ctrl-F
+			pos = min(pos+1, len(line))
+		case 11: // Ctrl-K
+			line = line[:pos]
+		case 21: // Ctrl-U
+			line = line[pos:]
+			pos = 0
+		case 23: // Ctrl-W deletes the word before the cursor
+			start := pos
+			for start > 0 && line[start-1] == ' ' {
+				start--
+			}
+			for start > 0 && line[start-1] != ' ' {
+				start--
+			}
+			line = append(line[:start], line[pos:]...)
+			pos = start
+		case 12: // Ctrl-L
+			fmt.Fprint(e.out, "\x1b[H\x1b[2J")
+		case 16: // Ctrl-P
+			browse(hist - 1)
+			continue
+		case 14: // Ctrl-N
+			browse(hist + 1)
+			continue
+		case 27: // Escape sequence
+			switch e.escape() {
+			case 'A':
+				browse(hist - 1)
+				continue
+			case 'B':
+				browse(hist + 1)
+				continue
+			case 'C':
+				pos = min(pos+1, len(line))
+			case 'D':
+				pos = max(pos-1, 0)
+			case 'H':
+				pos = 0
+			case 'F':
+				pos = len(line)
+			case 'X': // Delete
+				if pos < len(line) {
+					line = append(line[:pos], line[pos+1:]...)
+				}
+			}
+		default:
+			if r < 32 {
+				continue
+			}
+			line = append(line[:pos], append([]rune{r}, line[pos:]...)...)
+			pos++
+		}
+		refresh()
+	}
+}
+
+// escape reads the rest of an escape sequence and returns its key: A-D for
+// the arrows, H and F for Home and End, X for Delete, or 0.
+func (e *lineEditor) escape() byte {
+	b, err := e.in.ReadByte()
+	if err != nil || (b != '[' && b != 'O') {
+		return 0
+	}
+	b, err = e.in.ReadByte()
+	if err != nil {
+		return 0
+	}
+	if b < '0' || b > '9' {
+		return b
+	}
+	// ESC [ n ~
+	n := b
+	for b >= '0' && b <= '9' {
+		if b, err = e.in.ReadByte(); err != nil {
+			return 0
+		}
+	}
+	switch n {
+	case '1', '7':
+		return 'H'
+	case '4', '8':
+		return 'F'
+	case '3':
+		return 'X'
+	}
+	return 0
+}
+
+// addHistory records line, unless it repeats the previous one.
+func (e *lineEditor) addHistory(line string) {
+	if line == "" || (len(e.history) > 0 && e.history[len(e.history)-1] == line) {
+		return
+	}
+	e.history = append(e.history, line)
+	if len(e.history) > historyMax {
+		e.history = e.history[len(e.history)-historyMax:]
+	}
+	if e.histFile == "" {
+		return
+	}
+	f, err := os.OpenFile(e.histFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	fmt.Fprintln(f, line)
+}
+
+// loadHistory reads the history file, then rewrites it if it grew past
+// historyMax lines.
+func (e *lineEditor) loadHistory() {
+	if e.histFile == "" {
+		return
+	}
+	data, err := os.ReadFile(e.histFile)
+	if err != nil {
+		return
+	}
+	lines := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	if len(lines) > historyMax {
+		lines = lines[len(lines)-historyMax:]
+		os.WriteFile(e.histFile, []byte(strings.Join(lines, "\n")+"\n"), 0o600)
+	}
+	for _, line := range lines {
+		if line != "" {
+			e.history = append(e.history, line)
+		}
+	}
+}
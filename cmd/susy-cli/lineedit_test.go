@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestHistory(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "history")
+	e := &lineEditor{histFile: file}
+	for _, line := range []string{"GET a", "GET a", "SET a 1", "", "GET a"} {
+		e.addHistory(line)
+	}
+	want := []string{"GET a", "SET a 1", "GET a"}
+	if !reflect.DeepEqual(e.history, want) {
+		t.Errorf("history = %q, want %q", e.history, want)
+	}
+
+	reloaded := &lineEditor{histFile: file}
+	reloaded.loadHistory()
+	if !reflect.DeepEqual(reloaded.history, want) {
+		t.Errorf("reloaded history = %q, want %q", reloaded.history, want)
+	}
+}
+
+func TestHistoryTrimmed(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "history")
+	var b strings.Builder
+	for i := 0; i < historyMax+10; i++ {
+		fmt.Fprintf(&b, "GET %d\n", i)
+	}
+	os.WriteFile(file, []byte(b.String()), 0o600)
+
+	e := &lineEditor{histFile: file}
+	e.loadHistory()
+	if len(e.history) != historyMax || e.history[0] != "GET 10" {
+		t.Errorf("loaded %d lines starting with %q, want %d starting with GET 10", len(e.history), e.history[0], historyMax)
+	}
+	data, _ := os.ReadFile(file)
+	if lines := strings.Count(string(data), "\n"); lines != historyMax {
+		t.Errorf("history file has %d lines after loading, want %d", lines, historyMax)
+	}
+}
+
+func TestReadLineWithoutTerminal(t *testing.T) {
+	// Input that is not a terminal is read line by line, without editing
+	e := &lineEditor{in: bufio.NewReader(strings.NewReader("PING\r\nGET a")), out: io.Discard, fd: -1}
+	for _, want := range []string{"PING", "GET a"} {
+		if line, err := e.readLine("> "); err != nil || line != want {
+			t.Errorf("readLine() = %q, %v, want %q", line, err, want)
+		}
+	}
+	if _, err := e.readLine("> "); err != io.EOF {
+		t.Errorf("readLine() at the end error = %v, want io.EOF", err)
+	}
+}
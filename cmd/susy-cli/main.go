@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/Syed-Suhaan/SusyDB/pkg/client"
+)
+
+func main() {
+	host := flag.String("h", "localhost:7379", "SusyDB host address")
+	raw := flag.Bool("raw", false, "Print replies raw, as when stdout is not a terminal")
+	pipeMode := flag.Bool("pipe", false, "Send the commands on stdin (RESP or inline) for bulk loading")
+	scan := flag.Bool("scan", false, "List the keys with SCAN")
+	pattern := flag.String("pattern", "", "Glob pattern of the keys listed by -scan")
+	count := flag.Int("count", 100, "Keys visited per SCAN call by -scan and -bigkeys")
+	big := flag.Bool("bigkeys", false, "Find the biggest key of each type")
+	lat := flag.Bool("latency", false, "Sample the round trip time with PING until Ctrl-C")
+	interval := flag.Duration("interval", 10*time.Millisecond, "Time between -latency samples")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: susy-cli [flags] [command [arg ...]]\n\n")
+		fmt.Fprintf(os.Stderr, "Without a command, susy-cli starts an interactive prompt.\n\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	ctx := context.Background()
+	c := client.New(client.Options{Addr: *host, Timeout: time.Minute})
+	defer c.Close()
+
+	var err error
+	switch {
+	case *pipeMode:
+		var errs int
+		if errs, err = pipe(*host, os.Stdin); err == nil && errs > 0 {
+			os.Exit(1)
+		}
+	case *scan:
+		err = scanKeys(ctx, c, *pattern, *count)
+	case *big:
+		err = bigKeys(ctx, c, *count)
+	case *lat:
+		err = latency(c, *interval)
+	default:
+		p := printer{raw: *raw || !isTerminal(int(os.Stdout.Fd()))}
+		cn := &conn{addr: *host}
+		defer cn.close()
+		if flag.NArg() > 0 {
+			err = run(cn, p, flag.Args())
+		} else {
+			repl(cn, p)
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "susy-cli: %v\n", err)
+		os.Exit(1)
+	}
+}
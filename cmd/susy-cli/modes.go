@@ -0,0 +1,248 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Syed-Suhaan/SusyDB/pkg/client"
+	"github.com/Syed-Suhaan/SusyDB/pkg/resp"
+)
+
+// pipe sends the commands on stdin, RESP arrays or inline lines, as fast
+// as the server takes them, and reads the replies concurrently. It returns
+// the number of error replies.
+func pipe(addr string, in io.Reader) (int, error) {
+	nc, err := net.Dial("tcp", addr)
+	if err != nil {
+		return 0, err
+	}
+	defer nc.Close()
+
+	type result struct {
+		sent int
+		err  error
+	}
+	written := make(chan result, 1)
+	go func() {
+		w := bufio.NewWriterSize(nc, 64*1024)
+		n, err := writeCommands(w, bufio.NewReader(in))
+		if err == nil {
+			err = w.Flush()
+		}
+		written <- result{n, err}
+	}()
+	replies := make(chan interface{}, 1024)
+	readErr := make(chan error, 1)
+	go func() {
+		rd := bufio.NewReader(nc)
+		for {
+			v, err := resp.ReadReply(rd)
+			if err != nil {
+				readErr <- err
+				return
+			}
+			replies <- v
+		}
+	}()
+
+	received, errs, total := 0, 0, -1
+	for total < 0 || received < total {
+		select {
+		case w := <-written:
+			if w.err != nil {
+				return errs, w.err
+			}
+			total = w.sent
+			fmt.Fprintln(os.Stderr, "All data transferred. Waiting for the last reply...")
+		case v := <-replies:
+			received++
+			if e, ok := v.(resp.Error); ok {
+				if errs++; errs <= 10 {
+					fmt.Fprintln(os.Stderr, e)
+				}
+			}
+		case err := <-readErr:
+			return errs, err
+		}
+	}
+	fmt.Fprintf(os.Stderr, "errors: %d, replies: %d\n", errs, received)
+	return errs, nil
+}
+
+// writeCommands copies commands from r to w as RESP arrays and returns how
+// many it wrote.
+func writeCommands(w *bufio.Writer, r *bufio.Reader) (int, error) {
+	var buf []byte
+	n := 0
+	for {
+		b, err := r.Peek(1)
+		if err == io.EOF {
+			return n, nil
+		} else if err != nil {
+			return n, err
+		}
+		var args []string
+		if b[0] == '*' {
+			if args, err = resp.ReadCommand(r); err != nil {
+				return n, err
+			}
+		} else {
+			line, err := r.ReadString('\n')
+			if err != nil && err != io.EOF {
+				return n, err
+			}
+			if args, err = splitArgs(strings.TrimSpace(line)); err != nil {
+				return n, fmt.Errorf("line %q: %v", strings.TrimSpace(line), err)
+			}
+			if len(args) == 0 {
+				continue
+			}
+		}
+		buf = resp.AppendCommand(buf[:0], args)
+		if _, err := w.Write(buf); err != nil {
+			return n, err
+		}
+		n++
+	}
+}
+
+// scanKeys prints every key matching pattern, one per line.
+func scanKeys(ctx context.Context, c *client.Client, pattern string, count int) error {
+	cursor := uint64(0)
+	for {
+		page, err := c.Scan(ctx, cursor, pattern, count).Result()
+		if err != nil {
+			return err
+		}
+		for _, key := range page.Keys {
+			fmt.Println(key)
+		}
+		if cursor = page.Cursor; cursor == 0 {
+			return nil
+		}
+	}
+}
+
+// typeStats are the bigkeys findings for one type.
+type typeStats struct {
+	keys, bytes int64
+	biggest     string
+	size        int64
+}
+
+// bigKeys scans the keyspace and reports the largest key of each type by
+// its DUMP payload size, which tracks memory use across types.
+func bigKeys(ctx context.Context, c *client.Client, count int) error {
+	fmt.Println("# Scanning the entire keyspace to find biggest keys per type.")
+	fmt.Println("# Sizes are the serialized (DUMP) size of each value.")
+	fmt.Println()
+
+	stats := make(map[string]*typeStats)
+	sampled := int64(0)
+	cursor := uint64(0)
+	for {
+		page, err := c.Scan(ctx, cursor, "", count).Result()
+		if err != nil {
+			return err
+		}
+		types := make([]*client.Result[string], len(page.Keys))
+		dumps := make([]*client.Result[string], len(page.Keys))
+		// A key deleted since the scan fails; a lost connection fails the
+		// next Scan
+		c.Pipelined(ctx, func(p *client.Pipeline) error {
+			for i, key := range page.Keys {
+				types[i] = p.Type(ctx, key)
+				dumps[i] = p.Dump(ctx, key)
+			}
+			return nil
+		})
+		for i, key := range page.Keys {
+			typ, dump := types[i].Val(), dumps[i].Val()
+			if typ == "none" || types[i].Err() != nil || dumps[i].Err() != nil {
+				continue
+			}
+			sampled++
+			s := stats[typ]
+			if s == nil {
+				s = &typeStats{}
+				stats[typ] = s
+			}
+			size := int64(len(dump))
+			s.keys++
+			s.bytes += size
+			if size > s.size {
+				s.biggest, s.size = key, size
+				fmt.Printf("Biggest %-9s found so far %s with %d bytes\n", typ, quote(key), size)
+			}
+		}
+		if cursor = page.Cursor; cursor == 0 {
+			break
+		}
+	}
+
+	names := make([]string, 0, len(stats))
+	for typ := range stats {
+		names = append(names, typ)
+	}
+	sort.Strings(names)
+	fmt.Printf("\n-------- summary -------\n\nSampled %d keys in the keyspace!\n\n", sampled)
+	for _, typ := range names {
+		s := stats[typ]
+		fmt.Printf("Biggest %-9s found %s has %d bytes\n", typ, quote(s.biggest), s.size)
+	}
+	fmt.Println()
+	for _, typ := range names {
+		s := stats[typ]
+		fmt.Printf("%d %s keys with %d bytes (%.2f%% of keys, avg size %.2f)\n",
+			s.keys, typ, s.bytes, 100*float64(s.keys)/float64(sampled), float64(s.bytes)/float64(s.keys))
+	}
+	return nil
+}
+
+// latency pings the server every interval and keeps one line updated with
+// the round trip times in milliseconds until Ctrl-C.
+func latency(c *client.Client, interval time.Duration) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	var fastest, slowest, total time.Duration
+	samples := 0
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		start := time.Now()
+		if err := c.Ping(ctx).Err(); err != nil {
+			if ctx.Err() != nil {
+				fmt.Println()
+				return nil
+			}
+			return err
+		}
+		rtt := time.Since(start)
+		if samples == 0 || rtt < fastest {
+			fastest = rtt
+		}
+		slowest = max(slowest, rtt)
+		total += rtt
+		samples++
+		fmt.Printf("\rmin: %.3f, max: %.3f, avg: %.3f (%d samples)\x1b[K",
+			ms(fastest), ms(slowest), ms(total/time.Duration(samples)), samples)
+
+		select {
+		case <-ctx.Done():
+			fmt.Println()
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func ms(d time.Duration) float64 { return float64(d) / float64(time.Millisecond) }
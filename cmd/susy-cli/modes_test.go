@@ -0,0 +1,40 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Syed-Suhaan/SusyDB/pkg/resp"
+)
+
+func TestWriteCommands(t *testing.T) {
+	input := "SET a 1\n\n*2\r\n$3\r\nGET\r\n$1\r\na\r\nSET b \"two words\"\r\nINCR n"
+	var out bytes.Buffer
+	w := bufio.NewWriter(&out)
+	n, err := writeCommands(w, bufio.NewReader(strings.NewReader(input)))
+	if err != nil {
+		t.Fatalf("writeCommands() error = %v", err)
+	}
+	w.Flush()
+	want := [][]string{{"SET", "a", "1"}, {"GET", "a"}, {"SET", "b", "two words"}, {"INCR", "n"}}
+	if n != len(want) {
+		t.Errorf("writeCommands() = %d, want %d", n, len(want))
+	}
+	rd := bufio.NewReader(&out)
+	for _, cmd := range want {
+		got, err := resp.ReadCommand(rd)
+		if err != nil {
+			t.Fatalf("ReadCommand() error = %v", err)
+		}
+		if !reflect.DeepEqual(got, cmd) {
+			t.Errorf("command = %q, want %q", got, cmd)
+		}
+	}
+
+	if _, err := writeCommands(w, bufio.NewReader(strings.NewReader("SET k \"open\n"))); err == nil {
+		t.Error("writeCommands() with unbalanced quotes error = nil, want an error")
+	}
+}
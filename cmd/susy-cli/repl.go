@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Syed-Suhaan/SusyDB/pkg/resp"
+)
+
+// conn is the CLI's connection, redialed on the next command after it
+// fails.
+type conn struct {
+	addr string
+	nc   net.Conn
+	rd   *bufio.Reader
+}
+
+func (c *conn) connect() error {
+	if c.nc != nil {
+		return nil
+	}
+	nc, err := net.DialTimeout("tcp", c.addr, 5*time.Second)
+	if err != nil {
+		return err
+	}
+	c.nc, c.rd = nc, bufio.NewReader(nc)
+	return nil
+}
+
+func (c *conn) close() {
+	if c.nc != nil {
+		c.nc.Close()
+		c.nc = nil
+	}
+}
+
+// do sends a command and reads its reply.
+func (c *conn) do(args []string) (interface{}, error) {
+	if err := c.connect(); err != nil {
+		return nil, err
+	}
+	if _, err := c.nc.Write(resp.AppendCommand(nil, args)); err != nil {
+		c.close()
+		return nil, err
+	}
+	return c.read()
+}
+
+// read reads a reply, keeping simple strings apart from bulk strings.
+func (c *conn) read() (interface{}, error) {
+	v, err := resp.ReadStatusReply(c.rd)
+	if err != nil {
+		c.close()
+	}
+	return v, err
+}
+
+// printer writes replies pretty-printed or raw.
+type printer struct {
+	raw bool
+}
+
+func (p printer) print(v interface{}) {
+	if p.raw {
+		fmt.Print(formatRaw(v))
+	} else {
+		fmt.Print(formatReply(v))
+	}
+}
+
+// subscribeCommands put the connection in subscribed mode.
+var subscribeCommands = map[string]bool{
+	"SUBSCRIBE": true, "PSUBSCRIBE": true, "SSUBSCRIBE": true, "RSUBSCRIBE": true,
+}
+
+// run executes one command line's arguments and prints the reply. A
+// subscribe command keeps printing messages until Ctrl-C.
+func run(c *conn, p printer, args []string) error {
+	v, err := c.do(args)
+	if err != nil {
+		return err
+	}
+	p.print(v)
+	if !subscribeCommands[strings.ToUpper(args[0])] {
+		return nil
+	}
+	if _, isErr := v.(resp.Error); isErr {
+		return nil
+	}
+	return subscribed(c, p)
+}
+
+// subscribed prints each push until Ctrl-C, which drops the connection to
+// leave subscribed mode.
+func subscribed(c *conn, p printer) error {
+	fmt.Fprintln(os.Stderr, "Reading messages... (press Ctrl-C to quit)")
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+	interrupted := make(chan struct{})
+	stop := make(chan struct{})
+	defer close(stop)
+	nc := c.nc
+	go func() {
+		select {
+		case <-interrupt:
+			close(interrupted)
+			nc.Close()
+		case <-stop:
+		}
+	}()
+
+	for {
+		v, err := c.read()
+		if err != nil {
+			select {
+			case <-interrupted:
+				fmt.Println()
+				c.connect() // Back to a fresh connection for the prompt
+				return nil
+			default:
+				return err
+			}
+		}
+		p.print(v)
+	}
+}
+
+// repl reads commands from the terminal until Ctrl-D or quit.
+func repl(c *conn, p printer) {
+	interactive := isTerminal(int(os.Stdin.Fd()))
+	histFile := ""
+	if home, err := os.UserHomeDir(); err == nil && interactive {
+		histFile = filepath.Join(home, ".susycli_history")
+	}
+	editor := newLineEditor(histFile)
+	if err := c.connect(); err != nil {
+		fmt.Printf("Could not connect to SusyDB at %s: %v\n", c.addr, err)
+	}
+
+	for {
+		prompt := ""
+		if interactive {
+			prompt = c.addr + "> "
+			if c.nc == nil {
+				prompt = "not connected> "
+			}
+		}
+		line, err := editor.readLine(prompt)
+		if err == errInterrupted {
+			continue
+		}
+		if err != nil {
+			if err != io.EOF {
+				fmt.Println(err)
+			}
+			return
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		editor.addHistory(line)
+
+		args, err := splitArgs(line)
+		if err != nil {
+			fmt.Printf("Invalid argument(s): %v\n", err)
+			continue
+		}
+		switch strings.ToLower(args[0]) {
+		case "quit", "exit":
+			return
+		case "clear":
+			fmt.Print("\x1b[H\x1b[2J")
+			continue
+		}
+		if err := run(c, p, args); err != nil {
+			fmt.Printf("Could not connect to SusyDB at %s: %v\n", c.addr, err)
+		}
+	}
+}
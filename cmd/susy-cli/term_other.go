@@ -0,0 +1,13 @@
+//go:build !linux && !darwin
+
+package main
+
+import "errors"
+
+// Elsewhere the REPL reads plain lines, without editing or history keys.
+
+func rawMode(fd int) (restore func(), err error) {
+	return nil, errors.New("line editing is not supported on this platform")
+}
+
+func isTerminal(fd int) bool { return false }
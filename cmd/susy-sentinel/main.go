@@ -0,0 +1,45 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Syed-Suhaan/SusyDB/internal/sentinel"
+)
+
+func main() {
+	addr := flag.String("addr", ":26379", "Sentinel address")
+	name := flag.String("name", "mymaster", "Name clients look the primary up by")
+	primary := flag.String("primary", "localhost:7379", "Address of the primary to monitor")
+	quorum := flag.Int("quorum", 2, "Sentinels that must agree the primary is down")
+	downAfter := flag.Duration("down-after", 5*time.Second, "Silence after which an instance is considered down")
+	failoverTimeout := flag.Duration("failover-timeout", time.Minute, "Longest a promotion may take")
+	peers := flag.String("peers", "", "Comma-separated addresses of the other sentinels")
+	flag.Parse()
+
+	cfg := sentinel.Config{
+		Name:            *name,
+		Primary:         *primary,
+		Quorum:          *quorum,
+		DownAfter:       *downAfter,
+		FailoverTimeout: *failoverTimeout,
+	}
+	if *peers != "" {
+		cfg.Peers = strings.Split(*peers, ",")
+	}
+
+	listener, err := net.Listen("tcp", *addr)
+	if err != nil {
+		fmt.Printf("Failed to bind to port %s: %v\n", *addr, err)
+		os.Exit(1)
+	}
+	fmt.Printf("🛡️  Sentinel monitoring %s at %s (quorum %d) on %s\n", *name, *primary, *quorum, *addr)
+	if err := sentinel.New(cfg).Serve(listener); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
@@ -3,22 +3,99 @@ package main
 import (
 	"flag"
 	"fmt"
+	"os"
+	"strings"
+	"time"
 
+	"github.com/Syed-Suhaan/SusyDB/internal/raft"
 	"github.com/Syed-Suhaan/SusyDB/internal/server"
 	"github.com/Syed-Suhaan/SusyDB/pkg/core"
 )
 
 func main() {
 	addr := flag.String("addr", ":7379", "Server address")
+	pubsubBuffer := flag.Int("pubsub-buffer", 100, "Messages queued per subscriber")
+	pubsubPolicy := flag.String("pubsub-policy", "drop-newest", "When a subscriber's buffer is full: drop-newest, drop-oldest, disconnect or block")
+	pubsubBlock := flag.Duration("pubsub-block-timeout", 100*time.Millisecond, "Longest a publish waits for a full subscriber under -pubsub-policy=block")
+	replicaOf := flag.String("replicaof", "", "Run as a read-only replica of the primary at host:port")
+	cluster := flag.Bool("cluster", false, "Run as a cluster node; build the cluster with CLUSTER MEET and CLUSTER ADDSLOTS")
+	raftID := flag.String("raft-id", "", "Run as this member of a Raft group; writes commit through the replicated log")
+	raftDir := flag.String("raft-dir", "", "Directory for the Raft log and snapshots (default raft-<id>)")
+	raftPeers := flag.String("raft-peers", "", "Initial Raft members as id=host:port,...; empty to join an existing group with RAFT ADDNODE")
+	raftFollowerReads := flag.Bool("raft-follower-reads", false, "Serve reads on Raft followers using the leader's read index")
 	flag.Parse()
 
+	if *raftID != "" && (*cluster || *replicaOf != "") {
+		fmt.Println("-raft-id cannot be combined with -cluster or -replicaof")
+		os.Exit(2)
+	}
+
+	policy, err := core.ParseSlowConsumerPolicy(*pubsubPolicy)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(2)
+	}
+
 	// 1. Initialize the Store
 	store := core.NewKVStore()
+	store.Hub = core.NewHubWithOptions(core.HubOptions{
+		BufferSize:   *pubsubBuffer,
+		Policy:       policy,
+		BlockTimeout: *pubsubBlock,
+	})
 
 	// 2. Start the Garbage Collector
 	fmt.Println("🧹 Starting Background Garbage Collector...")
 	store.StartGC()
 
-	// 3. Start the TCP Server
+	// 3. Follow the primary, once the server below is listening
+	if *replicaOf != "" {
+		fmt.Printf("🔁 Replicating from %s\n", *replicaOf)
+		server.ReplicaOf(store, *replicaOf)
+	}
+
+	// 4. Join the Raft group, restoring the store from its snapshot
+	if *raftID != "" {
+		peers, err := parsePeers(*raftPeers)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(2)
+		}
+		dir := *raftDir
+		if dir == "" {
+			dir = "raft-" + *raftID
+		}
+		fmt.Printf("🗳️  Raft member %s (log in %s)\n", *raftID, dir)
+		err = server.EnableRaft(store, raft.Config{ID: *raftID, Dir: dir, Peers: peers, FollowerReads: *raftFollowerReads})
+		if err != nil {
+			fmt.Println("Failed to start Raft:", err)
+			os.Exit(1)
+		}
+	}
+
+	// 5. Join no cluster yet: nodes start empty and are introduced with
+	// CLUSTER MEET
+	if *cluster {
+		fmt.Println("🧩 Cluster mode enabled")
+		server.EnableCluster(store)
+	}
+
+	// 6. Start the TCP Server
 	server.Start(store, *addr)
 }
+
+// parsePeers parses -raft-peers: id=host:port pairs separated by commas.
+func parsePeers(s string) (map[string]string, error) {
+	peers := make(map[string]string)
+	if s == "" {
+		return peers, nil
+	}
+	for _, peer := range strings.Split(s, ",") {
+		id, addr, ok := strings.Cut(peer, "=")
+		if !ok || id == "" || addr == "" {
+			return nil, fmt.Errorf("invalid -raft-peers entry %q, want id=host:port", peer)
+		}
+		peers[id] = addr
+	}
+	return peers, nil
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"time"
 
@@ -40,4 +41,15 @@ func main() {
 	} else {
 		fmt.Println("Volatile key failed to expire!")
 	}
+
+	// 6. In-process event bus
+	fmt.Println("6. Subscribing to 'orders.*'...")
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	sub := store.Hub.PSubscribeContext(ctx, "orders.*")
+	defer sub.Close()
+
+	store.Hub.Publish("orders.created", "order #42")
+	msg := <-sub.C()
+	fmt.Printf("   Received %q on %s\n", msg.Payload, msg.Channel)
 }
@@ -0,0 +1,90 @@
+package raft
+
+import "fmt"
+
+// kickApply wakes the apply loop. Must be called while holding mu.
+func (n *Node) kickApply() {
+	select {
+	case n.applyKick <- struct{}{}:
+	default:
+	}
+}
+
+// applyLoop applies committed entries in order, hands results to the
+// proposers waiting for them and takes a snapshot every SnapshotThreshold
+// entries.
+func (n *Node) applyLoop() {
+	for {
+		select {
+		case <-n.closed:
+			return
+		case <-n.applyKick:
+		}
+		n.applyCommitted()
+	}
+}
+
+// applyCommitted applies every committed entry not applied yet.
+func (n *Node) applyCommitted() {
+	n.applyMu.Lock()
+	defer n.applyMu.Unlock()
+	for {
+		n.mu.Lock()
+		if n.applied >= n.commit {
+			n.mu.Unlock()
+			return
+		}
+		base := n.log[0].Index
+		end := min(n.commit, n.applied+maxBatch)
+		entries := append([]Entry(nil), n.log[n.applied+1-base:end+1-base]...)
+		n.mu.Unlock()
+
+		replies := make([][]byte, len(entries))
+		for i, e := range entries {
+			if e.Type == EntryCommand {
+				replies[i] = n.sm.Apply(e.Cmd)
+			}
+		}
+
+		n.mu.Lock()
+		n.applied = end
+		for i, e := range entries {
+			if w, ok := n.waiters[e.Index]; ok {
+				delete(n.waiters, e.Index)
+				if w.term == e.Term {
+					w.done <- result{reply: replies[i]}
+				} else {
+					w.done <- result{err: ErrLost}
+				}
+			}
+		}
+		due := n.applied-n.log[0].Index >= n.cfg.SnapshotThreshold
+		n.notify()
+		n.mu.Unlock()
+		if due {
+			n.snapshot()
+		}
+	}
+}
+
+// snapshot saves the state machine as of the last applied entry and drops
+// the log up to it. Must be called while holding applyMu.
+func (n *Node) snapshot() {
+	n.mu.Lock()
+	meta := snapshotMeta{index: n.applied, term: n.termAt(n.applied), members: n.membersAt(n.applied)}
+	n.mu.Unlock()
+
+	if err := n.st.saveSnapshot(meta, n.sm.Snapshot); err != nil {
+		fmt.Printf("Raft: saving a snapshot: %v\n", err)
+		return
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.log = append([]Entry(nil), n.log[meta.index-n.log[0].Index:]...)
+	n.log[0] = Entry{Index: meta.index, Term: meta.term}
+	n.snapMembers = meta.members
+	if err := n.st.rewriteLog(n.log[1:]); err != nil {
+		fmt.Printf("Raft: compacting the log: %v\n", err)
+	}
+}
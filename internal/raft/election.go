@@ -0,0 +1,133 @@
+package raft
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// run starts an election whenever the election timer fires on a member
+// that is not the leader.
+func (n *Node) run() {
+	ticker := time.NewTicker(n.cfg.HeartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-n.closed:
+			return
+		case <-ticker.C:
+		}
+		n.mu.Lock()
+		if _, member := n.members[n.cfg.ID]; member && n.role != leader && time.Now().After(n.electionAt) {
+			n.startElection()
+		}
+		n.mu.Unlock()
+	}
+}
+
+// resetElectionTimer pushes the next election out by a random 1-2
+// election timeouts, so nodes rarely stand at the same time.
+// Must be called while holding mu.
+func (n *Node) resetElectionTimer() {
+	timeout := n.cfg.ElectionTimeout + time.Duration(rand.Int63n(int64(n.cfg.ElectionTimeout)))
+	n.electionAt = time.Now().Add(timeout)
+}
+
+// startElection makes this node a candidate in a new term and asks the
+// other members for their votes. Must be called while holding mu.
+func (n *Node) startElection() {
+	n.role = candidate
+	n.term++
+	n.votedFor = n.cfg.ID
+	n.leader = ""
+	n.saveState()
+	n.resetElectionTimer()
+	fmt.Printf("Raft: %s starting an election for term %d\n", n.cfg.ID, n.term)
+
+	req := &VoteRequest{Term: n.term, Candidate: n.cfg.ID, LastIndex: n.lastIndex(), LastTerm: n.lastTerm()}
+	members := copyMembers(n.members)
+	votes := 1
+	if votes > len(members)/2 {
+		n.becomeLeader()
+		return
+	}
+	for id, addr := range members {
+		if id == n.cfg.ID {
+			continue
+		}
+		go func() {
+			resp, err := n.tr.RequestVote(addr, req)
+			if err != nil {
+				return
+			}
+			n.mu.Lock()
+			defer n.mu.Unlock()
+			if resp.Term > n.term {
+				n.stepDown(resp.Term)
+				return
+			}
+			if n.role != candidate || n.term != req.Term || !resp.Granted {
+				return
+			}
+			if votes++; votes > len(members)/2 {
+				n.becomeLeader()
+			}
+		}()
+	}
+}
+
+// becomeLeader takes over as leader and appends a no-op entry, whose
+// commit also commits everything before it. Must be called while holding mu.
+func (n *Node) becomeLeader() {
+	fmt.Printf("Raft: %s is the leader for term %d\n", n.cfg.ID, n.term)
+	n.role = leader
+	n.leader = n.cfg.ID
+	n.peers = make(map[string]*peer)
+	n.appendEntries([]Entry{{Index: n.lastIndex() + 1, Term: n.term, Type: EntryNoop}})
+	n.syncPeers()
+	n.advanceCommit()
+	n.notify()
+}
+
+// stepDown returns to follower, moving to term if it is newer.
+// Must be called while holding mu.
+func (n *Node) stepDown(term uint64) {
+	if term > n.term {
+		n.term = term
+		n.votedFor = ""
+		n.saveState()
+	}
+	if n.role != follower {
+		n.role = follower
+		n.peers = nil
+		n.resetElectionTimer()
+	}
+	n.notify()
+}
+
+// HandleRequestVote answers a candidate. The vote goes to the first
+// candidate of a term whose log is at least as up to date as ours. A node
+// that heard from a live leader within the election timeout ignores the
+// request, so a removed member cannot disrupt the group.
+func (n *Node) HandleRequestVote(req *VoteRequest) *VoteResponse {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if req.Term > n.term && (n.role == leader || (n.leader != "" && time.Since(n.lastContact) < n.cfg.ElectionTimeout)) {
+		return &VoteResponse{Term: n.term}
+	}
+	if req.Term < n.term {
+		return &VoteResponse{Term: n.term}
+	}
+	if req.Term > n.term {
+		n.stepDown(req.Term)
+		n.leader = ""
+	}
+	upToDate := req.LastTerm > n.lastTerm() || (req.LastTerm == n.lastTerm() && req.LastIndex >= n.lastIndex())
+	if (n.votedFor == "" || n.votedFor == req.Candidate) && upToDate {
+		n.votedFor = req.Candidate
+		n.saveState()
+		n.resetElectionTimer()
+		return &VoteResponse{Term: n.term, Granted: true}
+	}
+	return &VoteResponse{Term: n.term}
+}
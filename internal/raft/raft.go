@@ -0,0 +1,621 @@
+// Package raft replicates a log of commands across a group of nodes with
+// the Raft consensus algorithm: leader election, log replication,
+// snapshots and single-server membership changes. The state machine that
+// applies the commands and the transport that carries the RPCs are
+// supplied by the caller.
+package raft
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+// EntryType tells what a log entry carries.
+type EntryType uint8
+
+const (
+	EntryCommand EntryType = iota // A state machine command
+	EntryConfig                   // A new membership, as id, addr pairs
+	EntryNoop                     // Appended by a new leader to commit its term
+)
+
+// Entry is a log entry.
+type Entry struct {
+	Index, Term uint64
+	Type        EntryType
+	Cmd         []string
+}
+
+// StateMachine applies committed commands. Apply is called once per
+// command, in log order, on every node; its result is returned to the
+// proposer. Snapshot and Restore save and replace the whole state, and
+// are never called concurrently with Apply.
+type StateMachine interface {
+	Apply(cmd []string) []byte
+	Snapshot(w io.Writer) error
+	Restore(r io.Reader) error
+}
+
+// Transport sends RPCs to the node at addr.
+type Transport interface {
+	AppendEntries(addr string, req *AppendEntriesRequest) (*AppendEntriesResponse, error)
+	RequestVote(addr string, req *VoteRequest) (*VoteResponse, error)
+	InstallSnapshot(addr string, req *SnapshotRequest) (*SnapshotResponse, error)
+	// ReadIndex asks the leader for an index that covers every write
+	// acknowledged before the call.
+	ReadIndex(addr string) (uint64, error)
+}
+
+// AppendEntriesRequest replicates entries and carries the heartbeat.
+type AppendEntriesRequest struct {
+	Term      uint64
+	Leader    string
+	PrevIndex uint64
+	PrevTerm  uint64
+	Commit    uint64
+	Entries   []Entry
+}
+
+// AppendEntriesResponse answers AppendEntries. On failure LastIndex hints
+// where the leader should retry from.
+type AppendEntriesResponse struct {
+	Term      uint64
+	Success   bool
+	LastIndex uint64
+}
+
+// VoteRequest asks for a vote in an election.
+type VoteRequest struct {
+	Term      uint64
+	Candidate string
+	LastIndex uint64
+	LastTerm  uint64
+}
+
+// VoteResponse answers RequestVote.
+type VoteResponse struct {
+	Term    uint64
+	Granted bool
+}
+
+// SnapshotRequest sends a follower the leader's snapshot, for a follower
+// that needs entries the leader no longer has.
+type SnapshotRequest struct {
+	Term     uint64
+	Leader   string
+	Index    uint64
+	LastTerm uint64
+	Members  map[string]string
+	Data     []byte
+}
+
+// SnapshotResponse answers InstallSnapshot.
+type SnapshotResponse struct {
+	Term uint64
+}
+
+// Config configures a node.
+type Config struct {
+	ID  string
+	Dir string // Holds the node's log, vote and snapshot
+
+	// Peers is the initial membership, ID to address, including this
+	// node. It is only used the first time a group starts; a node joining
+	// an existing group starts with none and is added by the leader.
+	Peers map[string]string
+
+	ElectionTimeout   time.Duration // Default 1s; elections start after 1-2x this
+	HeartbeatInterval time.Duration // Default ElectionTimeout/10
+	SnapshotThreshold uint64        // Entries applied between snapshots, default 8192
+
+	// FollowerReads lets ReadIndex succeed on followers, which ask the
+	// leader for its commit index instead of redirecting the client.
+	FollowerReads bool
+}
+
+var (
+	// ErrLost is returned for a proposal a new leader overwrote before it
+	// was committed; it was not applied and may be retried.
+	ErrLost = errors.New("TRYAGAIN raft entry was overwritten by a new leader")
+	// ErrClosed is returned once the node is closed.
+	ErrClosed = errors.New("ERR raft node is closed")
+)
+
+// NotLeaderError is returned by calls that only the leader can serve.
+type NotLeaderError struct {
+	Leader string // The leader's address, empty if unknown
+}
+
+func (e *NotLeaderError) Error() string {
+	if e.Leader == "" {
+		return "CLUSTERDOWN no raft leader elected"
+	}
+	return "NOTLEADER " + e.Leader
+}
+
+type role int
+
+const (
+	follower role = iota
+	candidate
+	leader
+)
+
+func (r role) String() string {
+	return [...]string{"follower", "candidate", "leader"}[r]
+}
+
+// maxBatch caps the entries sent in one AppendEntries.
+const maxBatch = 512
+
+// Node is a member of a Raft group.
+type Node struct {
+	cfg Config
+	sm  StateMachine
+	tr  Transport
+	st  *storage
+
+	// applyMu is held while the state machine is applied to, snapshotted
+	// or restored. It is taken before mu.
+	applyMu sync.Mutex
+
+	mu          sync.Mutex
+	role        role
+	term        uint64
+	votedFor    string
+	leader      string // ID of the current leader, if known
+	lastContact time.Time
+	electionAt  time.Time
+
+	// log[0] only holds the index and term of the last entry covered by
+	// the snapshot; log[i] has index log[0].Index+i.
+	log         []Entry
+	snapMembers map[string]string // Membership as of the snapshot
+	members     map[string]string // Membership in the latest config entry
+	configIndex uint64            // Index of that entry
+	commit      uint64
+	applied     uint64
+
+	peers   map[string]*peer // Replication state, on the leader
+	readSeq uint64           // Heartbeat round that ReadIndex waits for
+	waiters map[uint64]*waiter
+
+	changed   chan struct{} // Closed and replaced whenever state advances
+	applyKick chan struct{}
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+// peer is the leader's view of a follower.
+type peer struct {
+	id, addr string
+	next     uint64 // Next entry to send
+	match    uint64 // Highest entry known replicated
+	acked    uint64 // Highest readSeq the follower answered
+	kick     chan struct{}
+}
+
+// waiter is a proposer waiting for its entry to be applied.
+type waiter struct {
+	term uint64
+	done chan result
+}
+
+type result struct {
+	reply []byte
+	err   error
+}
+
+// New starts a node, restoring the state machine from the node's snapshot
+// and replaying nothing until the group confirms what is committed.
+func New(cfg Config, sm StateMachine, tr Transport) (*Node, error) {
+	if cfg.ElectionTimeout <= 0 {
+		cfg.ElectionTimeout = time.Second
+	}
+	if cfg.HeartbeatInterval <= 0 {
+		cfg.HeartbeatInterval = cfg.ElectionTimeout / 10
+	}
+	if cfg.SnapshotThreshold == 0 {
+		cfg.SnapshotThreshold = 8192
+	}
+	st, err := openStorage(cfg.Dir)
+	if err != nil {
+		return nil, err
+	}
+	n := &Node{
+		cfg:         cfg,
+		sm:          sm,
+		tr:          tr,
+		st:          st,
+		log:         []Entry{{}},
+		snapMembers: map[string]string{},
+		waiters:     make(map[uint64]*waiter),
+		changed:     make(chan struct{}),
+		applyKick:   make(chan struct{}, 1),
+		closed:      make(chan struct{}),
+	}
+	if err := n.restore(); err != nil {
+		st.close()
+		return nil, err
+	}
+	n.resetElectionTimer()
+	go n.run()
+	go n.applyLoop()
+	return n, nil
+}
+
+// restore loads the durable state from storage.
+func (n *Node) restore() error {
+	var err error
+	if n.term, n.votedFor, err = n.st.loadState(); err != nil {
+		return err
+	}
+	meta, r, ok, err := n.st.openSnapshot()
+	if err != nil {
+		return err
+	}
+	if ok {
+		err = n.sm.Restore(r)
+		r.Close()
+		if err != nil {
+			return fmt.Errorf("raft: restoring snapshot: %w", err)
+		}
+		n.log[0] = Entry{Index: meta.index, Term: meta.term}
+		n.snapMembers = meta.members
+		n.commit, n.applied = meta.index, meta.index
+	}
+
+	entries, err := n.st.loadLog()
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		// Entries a snapshot already covers survive a crash before the
+		// log is rewritten
+		if e.Index <= n.log[0].Index {
+			continue
+		}
+		if e.Index != n.lastIndex()+1 {
+			return errBadStorage
+		}
+		n.log = append(n.log, e)
+	}
+	if !ok && len(n.log) == 1 && len(n.cfg.Peers) > 0 {
+		// Every initial member writes the same first entry
+		bootstrap := Entry{Index: 1, Type: EntryConfig, Cmd: flattenMembers(n.cfg.Peers)}
+		if err := n.st.appendLog([]Entry{bootstrap}); err != nil {
+			return err
+		}
+		n.log = append(n.log, bootstrap)
+	}
+	n.updateMembers()
+	return nil
+}
+
+// Close stops the node. Pending calls fail with ErrClosed.
+func (n *Node) Close() error {
+	n.closeOnce.Do(func() { close(n.closed) })
+	n.applyMu.Lock()
+	defer n.applyMu.Unlock()
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.st.close()
+}
+
+// Propose appends a command to the log and returns the state machine's
+// result once it is committed and applied. Only the leader accepts
+// proposals.
+func (n *Node) Propose(ctx context.Context, cmd []string) ([]byte, error) {
+	return n.propose(ctx, EntryCommand, cmd, nil)
+}
+
+// AddNode adds a node to the group. Like RemoveNode it changes one member
+// at a time, waiting for an earlier change to be committed first.
+func (n *Node) AddNode(ctx context.Context, id, addr string) error {
+	_, err := n.propose(ctx, EntryConfig, nil, func(members map[string]string) error {
+		if _, ok := members[id]; ok {
+			return fmt.Errorf("ERR node %s is already a member", id)
+		}
+		members[id] = addr
+		return nil
+	})
+	return err
+}
+
+// RemoveNode removes a node from the group. A leader that removes itself
+// steps down once the change is committed.
+func (n *Node) RemoveNode(ctx context.Context, id string) error {
+	_, err := n.propose(ctx, EntryConfig, nil, func(members map[string]string) error {
+		if _, ok := members[id]; !ok {
+			return fmt.Errorf("ERR node %s is not a member", id)
+		}
+		delete(members, id)
+		return nil
+	})
+	return err
+}
+
+// propose appends an entry as the leader and waits for it to be applied.
+// For config entries, change edits a copy of the current membership.
+func (n *Node) propose(ctx context.Context, typ EntryType, cmd []string, change func(map[string]string) error) ([]byte, error) {
+	n.mu.Lock()
+	if n.role != leader {
+		n.mu.Unlock()
+		return nil, n.notLeader()
+	}
+	if typ == EntryConfig {
+		// One change at a time, and only once the leader has committed an
+		// entry of its own term
+		term := n.term
+		err := n.waitLocked(ctx, func() bool {
+			return n.role != leader || n.term != term || (n.configIndex <= n.commit && n.termAt(n.commit) == term)
+		})
+		if err == nil && (n.role != leader || n.term != term) {
+			err = n.notLeader()
+		}
+		if err != nil {
+			n.mu.Unlock()
+			return nil, err
+		}
+		members := copyMembers(n.members)
+		if err := change(members); err != nil {
+			n.mu.Unlock()
+			return nil, err
+		}
+		cmd = flattenMembers(members)
+	}
+	e := Entry{Index: n.lastIndex() + 1, Term: n.term, Type: typ, Cmd: cmd}
+	if err := n.appendEntries([]Entry{e}); err != nil {
+		n.mu.Unlock()
+		return nil, err
+	}
+	w := &waiter{term: e.Term, done: make(chan result, 1)}
+	n.waiters[e.Index] = w
+	n.syncPeers()
+	n.kickPeers()
+	n.advanceCommit()
+	n.mu.Unlock()
+
+	select {
+	case r := <-w.done:
+		return r.reply, r.err
+	case <-ctx.Done():
+		n.mu.Lock()
+		delete(n.waiters, e.Index)
+		n.mu.Unlock()
+		return nil, ctx.Err()
+	case <-n.closed:
+		return nil, ErrClosed
+	}
+}
+
+// ReadIndex waits until this node's state machine reflects every write
+// committed before the call, so a read served afterwards is linearizable.
+// The leader confirms it still leads with a heartbeat round; a follower,
+// if FollowerReads is set, asks the leader for the index instead.
+func (n *Node) ReadIndex(ctx context.Context) error {
+	n.mu.Lock()
+	var index uint64
+	if n.role == leader {
+		// Until an entry of its own term commits, a new leader does not
+		// know the latest commit index
+		term := n.term
+		if err := n.waitLocked(ctx, func() bool { return n.role != leader || n.termAt(n.commit) == term }); err != nil {
+			n.mu.Unlock()
+			return err
+		}
+		index = n.commit
+		n.readSeq++
+		seq := n.readSeq
+		n.kickPeers()
+		if err := n.waitLocked(ctx, func() bool { return n.role != leader || n.term != term || n.quorumAcked(seq) }); err != nil {
+			n.mu.Unlock()
+			return err
+		}
+		if n.role != leader || n.term != term {
+			n.mu.Unlock()
+			return n.notLeader()
+		}
+	} else {
+		addr := n.members[n.leader]
+		if !n.cfg.FollowerReads || addr == "" {
+			n.mu.Unlock()
+			return n.notLeader()
+		}
+		n.mu.Unlock()
+		var err error
+		if index, err = n.tr.ReadIndex(addr); err != nil {
+			return err
+		}
+		n.mu.Lock()
+	}
+	err := n.waitLocked(ctx, func() bool { return n.applied >= index })
+	n.mu.Unlock()
+	return err
+}
+
+// LeaderReadIndex serves a follower's ReadIndex: it runs the leader's
+// read-index round and returns the index the follower must reach.
+func (n *Node) LeaderReadIndex(ctx context.Context) (uint64, error) {
+	n.mu.Lock()
+	if n.role != leader {
+		n.mu.Unlock()
+		return 0, n.notLeader()
+	}
+	n.mu.Unlock()
+	if err := n.ReadIndex(ctx); err != nil {
+		return 0, err
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.applied, nil
+}
+
+// waitLocked waits, releasing mu meanwhile, until cond holds.
+// Must be called while holding mu.
+func (n *Node) waitLocked(ctx context.Context, cond func() bool) error {
+	for !cond() {
+		changed := n.changed
+		n.mu.Unlock()
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			n.mu.Lock()
+			return ctx.Err()
+		case <-n.closed:
+			n.mu.Lock()
+			return ErrClosed
+		}
+		n.mu.Lock()
+	}
+	return nil
+}
+
+// notify wakes everything waiting in waitLocked.
+// Must be called while holding mu.
+func (n *Node) notify() {
+	close(n.changed)
+	n.changed = make(chan struct{})
+}
+
+// notLeader returns the error that redirects a client to the leader.
+// Must be called while holding mu.
+func (n *Node) notLeader() error {
+	return &NotLeaderError{Leader: n.members[n.leader]}
+}
+
+// Status is a snapshot of a node's state, for INFO.
+type Status struct {
+	ID            string
+	Role          string
+	Term          uint64
+	Leader        string // ID
+	LeaderAddr    string
+	LastIndex     uint64
+	Commit        uint64
+	Applied       uint64
+	SnapshotIndex uint64
+	Members       map[string]string
+}
+
+// Status returns the node's current state.
+func (n *Node) Status() Status {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return Status{
+		ID:            n.cfg.ID,
+		Role:          n.role.String(),
+		Term:          n.term,
+		Leader:        n.leader,
+		LeaderAddr:    n.members[n.leader],
+		LastIndex:     n.lastIndex(),
+		Commit:        n.commit,
+		Applied:       n.applied,
+		SnapshotIndex: n.log[0].Index,
+		Members:       copyMembers(n.members),
+	}
+}
+
+// lastIndex returns the index of the last entry.
+// Must be called while holding mu, like the other log helpers.
+func (n *Node) lastIndex() uint64 { return n.log[0].Index + uint64(len(n.log)) - 1 }
+
+func (n *Node) lastTerm() uint64 { return n.log[len(n.log)-1].Term }
+
+// termAt returns the term of the entry at index, or 0 if the log does not
+// hold it.
+func (n *Node) termAt(index uint64) uint64 {
+	if index < n.log[0].Index || index > n.lastIndex() {
+		return 0
+	}
+	return n.log[index-n.log[0].Index].Term
+}
+
+// appendEntries durably appends entries to the log.
+func (n *Node) appendEntries(entries []Entry) error {
+	if err := n.st.appendLog(entries); err != nil {
+		fmt.Printf("Raft: writing the log: %v\n", err)
+		return err
+	}
+	n.log = append(n.log, entries...)
+	for _, e := range entries {
+		if e.Type == EntryConfig {
+			n.updateMembers()
+			break
+		}
+	}
+	return nil
+}
+
+// truncate removes the entries from index on.
+func (n *Node) truncate(index uint64) error {
+	n.log = n.log[:index-n.log[0].Index]
+	n.updateMembers()
+	return n.st.rewriteLog(n.log[1:])
+}
+
+// updateMembers takes the membership from the latest config entry.
+func (n *Node) updateMembers() {
+	for i := len(n.log) - 1; i > 0; i-- {
+		if n.log[i].Type == EntryConfig {
+			n.members, n.configIndex = parseMembers(n.log[i].Cmd), n.log[i].Index
+			return
+		}
+	}
+	n.members, n.configIndex = copyMembers(n.snapMembers), n.log[0].Index
+}
+
+// membersAt returns the membership in force at index.
+func (n *Node) membersAt(index uint64) map[string]string {
+	for i := index - n.log[0].Index; i > 0; i-- {
+		if n.log[i].Type == EntryConfig {
+			return parseMembers(n.log[i].Cmd)
+		}
+	}
+	return copyMembers(n.snapMembers)
+}
+
+// saveState durably records the term and vote.
+func (n *Node) saveState() {
+	if err := n.st.saveState(n.term, n.votedFor); err != nil {
+		fmt.Printf("Raft: saving the term: %v\n", err)
+	}
+}
+
+func flattenMembers(members map[string]string) []string {
+	var flat []string
+	for _, id := range sortedIDs(members) {
+		flat = append(flat, id, members[id])
+	}
+	return flat
+}
+
+func parseMembers(flat []string) map[string]string {
+	members := make(map[string]string)
+	for i := 0; i+1 < len(flat); i += 2 {
+		members[flat[i]] = flat[i+1]
+	}
+	return members
+}
+
+func copyMembers(members map[string]string) map[string]string {
+	c := make(map[string]string, len(members))
+	for id, addr := range members {
+		c[id] = addr
+	}
+	return c
+}
+
+func sortedIDs(members map[string]string) []string {
+	ids := make([]string, 0, len(members))
+	for id := range members {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
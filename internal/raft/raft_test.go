@@ -0,0 +1,362 @@
+package raft
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// kv is a state machine holding SET key value commands.
+type kv struct {
+	mu   sync.Mutex
+	data map[string]string
+}
+
+func newKV() *kv { return &kv{data: make(map[string]string)} }
+
+func (m *kv) Apply(cmd []string) []byte {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.data[cmd[1]] = cmd[2]
+	return []byte("OK " + cmd[1])
+}
+
+func (m *kv) Snapshot(w io.Writer) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for k, v := range m.data {
+		fmt.Fprintf(w, "%s=%s\n", k, v)
+	}
+	return nil
+}
+
+func (m *kv) Restore(r io.Reader) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.data = make(map[string]string)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		k, v, _ := strings.Cut(scanner.Text(), "=")
+		m.data[k] = v
+	}
+	return scanner.Err()
+}
+
+func (m *kv) get(k string) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.data[k]
+}
+
+// network delivers RPCs between in-process nodes, which can be cut off.
+type network struct {
+	mu    sync.Mutex
+	nodes map[string]*Node
+	down  map[string]bool
+}
+
+var errUnreachable = errors.New("unreachable")
+
+func (net *network) node(addr string) (*Node, error) {
+	net.mu.Lock()
+	defer net.mu.Unlock()
+	n := net.nodes[addr]
+	if n == nil || net.down[addr] {
+		return nil, errUnreachable
+	}
+	return n, nil
+}
+
+// transport is one node's view of the network; a node that is down can
+// neither send nor receive.
+type transport struct {
+	net  *network
+	from string
+}
+
+func (t *transport) target(addr string) (*Node, error) {
+	if _, err := t.net.node(t.from); err != nil {
+		return nil, err
+	}
+	return t.net.node(addr)
+}
+
+func (t *transport) AppendEntries(addr string, req *AppendEntriesRequest) (*AppendEntriesResponse, error) {
+	n, err := t.target(addr)
+	if err != nil {
+		return nil, err
+	}
+	return n.HandleAppendEntries(req), nil
+}
+
+func (t *transport) RequestVote(addr string, req *VoteRequest) (*VoteResponse, error) {
+	n, err := t.target(addr)
+	if err != nil {
+		return nil, err
+	}
+	return n.HandleRequestVote(req), nil
+}
+
+func (t *transport) InstallSnapshot(addr string, req *SnapshotRequest) (*SnapshotResponse, error) {
+	n, err := t.target(addr)
+	if err != nil {
+		return nil, err
+	}
+	return n.HandleInstallSnapshot(req), nil
+}
+
+func (t *transport) ReadIndex(addr string) (uint64, error) {
+	n, err := t.target(addr)
+	if err != nil {
+		return 0, err
+	}
+	return n.LeaderReadIndex(context.Background())
+}
+
+// group is a set of in-process nodes for a test.
+type group struct {
+	t    *testing.T
+	net  *network
+	dirs map[string]string
+	sms  map[string]*kv
+}
+
+func newGroup(t *testing.T, ids ...string) *group {
+	g := &group{t: t, net: &network{nodes: map[string]*Node{}, down: map[string]bool{}},
+		dirs: map[string]string{}, sms: map[string]*kv{}}
+	peers := make(map[string]string)
+	for _, id := range ids {
+		peers[id] = id
+	}
+	for _, id := range ids {
+		g.start(id, peers)
+	}
+	return g
+}
+
+// start starts (or restarts) node id with its directory from before.
+func (g *group) start(id string, peers map[string]string) *Node {
+	g.t.Helper()
+	if g.dirs[id] == "" {
+		g.dirs[id] = g.t.TempDir()
+	}
+	g.sms[id] = newKV()
+	n, err := New(Config{
+		ID: id, Dir: g.dirs[id], Peers: peers,
+		ElectionTimeout: 100 * time.Millisecond, HeartbeatInterval: 10 * time.Millisecond,
+		SnapshotThreshold: 16, FollowerReads: true,
+	}, g.sms[id], &transport{net: g.net, from: id})
+	if err != nil {
+		g.t.Fatalf("New(%s) error = %v", id, err)
+	}
+	g.t.Cleanup(func() { n.Close() })
+	g.net.mu.Lock()
+	g.net.nodes[id] = n
+	g.net.mu.Unlock()
+	return n
+}
+
+func (g *group) stop(id string) {
+	g.net.mu.Lock()
+	n := g.net.nodes[id]
+	delete(g.net.nodes, id)
+	g.net.mu.Unlock()
+	n.Close()
+}
+
+func (g *group) setDown(id string, down bool) {
+	g.net.mu.Lock()
+	defer g.net.mu.Unlock()
+	g.net.down[id] = down
+}
+
+// leader waits for a single reachable leader and returns its ID.
+func (g *group) leader() string {
+	g.t.Helper()
+	var id string
+	waitFor(g.t, "a leader", func() bool {
+		g.net.mu.Lock()
+		defer g.net.mu.Unlock()
+		id = ""
+		for nid, n := range g.net.nodes {
+			if s := n.Status(); s.Role == "leader" && !g.net.down[nid] {
+				if id != "" {
+					return false
+				}
+				id = nid
+			}
+		}
+		return id != ""
+	})
+	return id
+}
+
+func (g *group) node(id string) *Node {
+	g.net.mu.Lock()
+	defer g.net.mu.Unlock()
+	return g.net.nodes[id]
+}
+
+func (g *group) set(id, key, value string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	reply, err := g.node(id).Propose(ctx, []string{"SET", key, value})
+	if err == nil && string(reply) != "OK "+key {
+		return fmt.Errorf("reply %q", reply)
+	}
+	return err
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestElectionAndReplication(t *testing.T) {
+	g := newGroup(t, "a", "b", "c")
+	lead := g.leader()
+	if err := g.set(lead, "k", "v1"); err != nil {
+		t.Fatalf("Propose() error = %v", err)
+	}
+	for id, sm := range g.sms {
+		waitFor(t, id+" to apply", func() bool { return sm.get("k") == "v1" })
+	}
+
+	var follower string
+	for id := range g.sms {
+		if id != lead {
+			follower = id
+			break
+		}
+	}
+	err := g.set(follower, "k", "v2")
+	var notLeader *NotLeaderError
+	if !errors.As(err, &notLeader) || notLeader.Leader != lead {
+		t.Errorf("Propose() on a follower error = %v, want NOTLEADER %s", err, lead)
+	}
+
+	// The leader fails; the others elect a new one and keep the data
+	g.setDown(lead, true)
+	next := g.leader()
+	if next == lead {
+		t.Fatalf("leader after failure = %s, want a new one", next)
+	}
+	if err := g.set(next, "k", "v3"); err != nil {
+		t.Fatalf("Propose() on the new leader error = %v", err)
+	}
+	g.setDown(lead, false)
+	waitFor(t, "the old leader to catch up", func() bool { return g.sms[lead].get("k") == "v3" })
+	if s := g.node(lead).Status(); s.Role != "follower" {
+		t.Errorf("old leader role = %s, want follower", s.Role)
+	}
+}
+
+func TestReadIndex(t *testing.T) {
+	g := newGroup(t, "a", "b", "c")
+	lead := g.leader()
+	if err := g.set(lead, "k", "v"); err != nil {
+		t.Fatalf("Propose() error = %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	for id, sm := range g.sms {
+		if err := g.node(id).ReadIndex(ctx); err != nil {
+			t.Fatalf("ReadIndex() on %s error = %v", id, err)
+		}
+		// ReadIndex returns only once the write is applied locally
+		if got := sm.get("k"); got != "v" {
+			t.Errorf("%s k after ReadIndex = %q, want v", id, got)
+		}
+	}
+
+	// A leader cut off from the majority cannot confirm a read
+	g.setDown(lead, true)
+	short, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	if err := g.node(lead).ReadIndex(short); err == nil {
+		t.Errorf("ReadIndex() on an isolated leader error = nil, want failure")
+	}
+}
+
+func TestSnapshotAndRestart(t *testing.T) {
+	g := newGroup(t, "a", "b", "c")
+	lead := g.leader()
+	var lagging string
+	for id := range g.sms {
+		if id != lead {
+			lagging = id
+			break
+		}
+	}
+	g.stop(lagging)
+	for i := 0; i < 40; i++ {
+		if err := g.set(lead, fmt.Sprintf("k%d", i), "v"); err != nil {
+			t.Fatalf("Propose() error = %v", err)
+		}
+	}
+	waitFor(t, "a snapshot", func() bool { return g.node(lead).Status().SnapshotIndex > 0 })
+
+	// The lagging node needs entries the log dropped, so it gets the snapshot
+	g.start(lagging, nil)
+	waitFor(t, "the lagging node to catch up", func() bool { return g.sms[lagging].get("k39") == "v" })
+
+	// A restarted node rebuilds its state from its snapshot and log
+	g.stop(lagging)
+	restarted := g.start(lagging, nil)
+	if got := g.sms[lagging].get("k0"); got != "v" {
+		t.Errorf("k0 after restart = %q, want v", got)
+	}
+	if s := restarted.Status(); len(s.Members) != 3 {
+		t.Errorf("members after restart = %v, want 3", s.Members)
+	}
+}
+
+func TestMembershipChange(t *testing.T) {
+	g := newGroup(t, "a", "b", "c")
+	lead := g.leader()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	g.start("d", nil) // Joins with no membership
+	if err := g.node(lead).AddNode(ctx, "d", "d"); err != nil {
+		t.Fatalf("AddNode() error = %v", err)
+	}
+	if err := g.set(lead, "k", "v"); err != nil {
+		t.Fatalf("Propose() error = %v", err)
+	}
+	waitFor(t, "the new node to apply", func() bool { return g.sms["d"].get("k") == "v" })
+	if s := g.node("d").Status(); len(s.Members) != 4 {
+		t.Errorf("new node members = %v, want 4", s.Members)
+	}
+	if err := g.node(lead).AddNode(ctx, "d", "d"); err == nil {
+		t.Errorf("AddNode() of a member error = nil, want an error")
+	}
+
+	// Removing the leader makes it step down; the rest carry on
+	if err := g.node(lead).RemoveNode(ctx, lead); err != nil {
+		t.Fatalf("RemoveNode() error = %v", err)
+	}
+	g.setDown(lead, true)
+	next := g.leader()
+	if next == lead {
+		t.Fatalf("leader after removal = %s", next)
+	}
+	if s := g.node(next).Status(); len(s.Members) != 3 {
+		t.Errorf("members after removal = %v, want 3", s.Members)
+	}
+	if err := g.set(next, "k", "v2"); err != nil {
+		t.Errorf("Propose() after removal error = %v", err)
+	}
+}
@@ -0,0 +1,296 @@
+package raft
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"time"
+)
+
+// syncPeers starts replicating to new members and forgets removed ones,
+// whose goroutines then exit. Must be called while holding mu.
+func (n *Node) syncPeers() {
+	if n.role != leader {
+		return
+	}
+	for id, addr := range n.members {
+		if p, ok := n.peers[id]; (!ok || p.addr != addr) && id != n.cfg.ID {
+			p := &peer{id: id, addr: addr, next: n.lastIndex() + 1, kick: make(chan struct{}, 1)}
+			n.peers[id] = p
+			go n.replicateTo(p, n.term)
+		}
+	}
+	for id := range n.peers {
+		if _, ok := n.members[id]; !ok {
+			delete(n.peers, id)
+		}
+	}
+}
+
+// kickPeers makes every replication goroutine send now.
+// Must be called while holding mu.
+func (n *Node) kickPeers() {
+	for _, p := range n.peers {
+		select {
+		case p.kick <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// replicateTo sends p entries, or the snapshot when it needs entries the
+// log no longer has, until this node stops leading in term. With nothing
+// to send it sends a heartbeat every HeartbeatInterval.
+func (n *Node) replicateTo(p *peer, term uint64) {
+	ticker := time.NewTicker(n.cfg.HeartbeatInterval)
+	defer ticker.Stop()
+	for {
+		n.mu.Lock()
+		if n.role != leader || n.term != term || n.peers[p.id] != p {
+			n.mu.Unlock()
+			return
+		}
+		more, err := n.sendTo(p, term)
+		n.mu.Unlock()
+		if more && err == nil {
+			continue
+		}
+		select {
+		case <-p.kick:
+		case <-ticker.C:
+		case <-n.closed:
+			return
+		}
+	}
+}
+
+// sendTo sends p one AppendEntries or InstallSnapshot, releasing mu for
+// the round trip, and reports whether p is still behind.
+// Must be called while holding mu.
+func (n *Node) sendTo(p *peer, term uint64) (bool, error) {
+	seq := n.readSeq
+	if p.next <= n.log[0].Index {
+		return n.sendSnapshot(p, term, seq)
+	}
+
+	prev := p.next - 1
+	end := min(n.lastIndex(), prev+maxBatch)
+	req := &AppendEntriesRequest{
+		Term:      term,
+		Leader:    n.cfg.ID,
+		PrevIndex: prev,
+		PrevTerm:  n.termAt(prev),
+		Commit:    n.commit,
+		Entries:   append([]Entry(nil), n.log[p.next-n.log[0].Index:end-n.log[0].Index+1]...),
+	}
+	n.mu.Unlock()
+	resp, err := n.tr.AppendEntries(p.addr, req)
+	n.mu.Lock()
+	if err != nil {
+		return false, err
+	}
+	if resp.Term > n.term {
+		n.stepDown(resp.Term)
+		return false, nil
+	}
+	if n.role != leader || n.term != term {
+		return false, nil
+	}
+	// Any answer in our term confirms we still lead
+	p.acked = max(p.acked, seq)
+	if resp.Success {
+		p.match = max(p.match, end)
+		p.next = p.match + 1
+		n.advanceCommit()
+	} else {
+		p.next = max(1, min(p.next-1, resp.LastIndex+1))
+	}
+	n.notify()
+	return p.next <= n.lastIndex(), nil
+}
+
+// sendSnapshot sends p the snapshot. Must be called while holding mu.
+func (n *Node) sendSnapshot(p *peer, term, seq uint64) (bool, error) {
+	n.mu.Unlock()
+	meta, r, ok, err := n.st.openSnapshot()
+	var data []byte
+	if err == nil && ok {
+		data, err = io.ReadAll(r)
+		r.Close()
+	}
+	if err != nil || !ok {
+		n.mu.Lock()
+		return false, fmt.Errorf("raft: reading the snapshot: %v", err)
+	}
+	resp, err := n.tr.InstallSnapshot(p.addr, &SnapshotRequest{
+		Term: term, Leader: n.cfg.ID, Index: meta.index, LastTerm: meta.term, Members: meta.members, Data: data,
+	})
+	n.mu.Lock()
+	if err != nil {
+		return false, err
+	}
+	if resp.Term > n.term {
+		n.stepDown(resp.Term)
+		return false, nil
+	}
+	if n.role != leader || n.term != term {
+		return false, nil
+	}
+	p.acked = max(p.acked, seq)
+	p.match = max(p.match, meta.index)
+	p.next = p.match + 1
+	n.advanceCommit()
+	n.notify()
+	return p.next <= n.lastIndex(), nil
+}
+
+// advanceCommit commits the highest entry of the current term that a
+// majority holds. A leader that committed its own removal steps down.
+// Must be called while holding mu.
+func (n *Node) advanceCommit() {
+	if n.role != leader {
+		return
+	}
+	for index := n.lastIndex(); index > n.commit && n.termAt(index) == n.term; index-- {
+		if n.quorum(func(p *peer) bool { return p.match >= index }) {
+			n.commit = index
+			n.kickApply()
+			n.notify()
+			break
+		}
+	}
+	if _, member := n.members[n.cfg.ID]; !member && n.commit >= n.configIndex {
+		fmt.Printf("Raft: %s left the group\n", n.cfg.ID)
+		n.role, n.leader, n.peers = follower, "", nil
+		n.notify()
+	}
+}
+
+// quorumAcked reports whether a majority answered heartbeat round seq.
+// Must be called while holding mu.
+func (n *Node) quorumAcked(seq uint64) bool {
+	return n.quorum(func(p *peer) bool { return p.acked >= seq })
+}
+
+// quorum reports whether ok holds for a majority of the members, this node
+// counting as true. Must be called while holding mu.
+func (n *Node) quorum(ok func(p *peer) bool) bool {
+	count := 0
+	for id := range n.members {
+		if id == n.cfg.ID {
+			count++
+		} else if p := n.peers[id]; p != nil && ok(p) {
+			count++
+		}
+	}
+	return count > len(n.members)/2
+}
+
+// HandleAppendEntries accepts entries from the leader, after checking that
+// our log matches the leader's up to the entry before them. Conflicting
+// entries are replaced.
+func (n *Node) HandleAppendEntries(req *AppendEntriesRequest) *AppendEntriesResponse {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if req.Term < n.term {
+		return &AppendEntriesResponse{Term: n.term, LastIndex: n.lastIndex()}
+	}
+	if req.Term > n.term || n.role != follower {
+		n.stepDown(req.Term)
+	}
+	n.leader, n.lastContact = req.Leader, time.Now()
+	n.resetElectionTimer()
+
+	fail := &AppendEntriesResponse{Term: n.term, LastIndex: n.lastIndex()}
+	if req.PrevIndex > n.lastIndex() {
+		return fail
+	}
+	if req.PrevIndex >= n.log[0].Index {
+		if term := n.termAt(req.PrevIndex); term != req.PrevTerm {
+			// Skip back over the whole conflicting term at once
+			index := req.PrevIndex
+			for index-1 > n.log[0].Index && n.termAt(index-1) == term {
+				index--
+			}
+			fail.LastIndex = index - 1
+			return fail
+		}
+	}
+
+	var fresh []Entry
+	for i, e := range req.Entries {
+		if e.Index <= n.log[0].Index {
+			continue // Already in the snapshot
+		}
+		if e.Index <= n.lastIndex() {
+			if n.termAt(e.Index) == e.Term {
+				continue
+			}
+			if err := n.truncate(e.Index); err != nil {
+				return fail
+			}
+		}
+		fresh = req.Entries[i:]
+		break
+	}
+	if len(fresh) > 0 {
+		if err := n.appendEntries(fresh); err != nil {
+			fail.LastIndex = n.lastIndex()
+			return fail
+		}
+	}
+	if last := req.PrevIndex + uint64(len(req.Entries)); req.Commit > n.commit {
+		n.commit = max(n.commit, min(req.Commit, last))
+		n.kickApply()
+	}
+	n.notify()
+	return &AppendEntriesResponse{Term: n.term, Success: true, LastIndex: n.lastIndex()}
+}
+
+// HandleInstallSnapshot replaces the state machine with the leader's
+// snapshot. Log entries after the snapshot are kept if they match it.
+func (n *Node) HandleInstallSnapshot(req *SnapshotRequest) *SnapshotResponse {
+	n.applyMu.Lock()
+	defer n.applyMu.Unlock()
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if req.Term < n.term {
+		return &SnapshotResponse{Term: n.term}
+	}
+	if req.Term > n.term || n.role != follower {
+		n.stepDown(req.Term)
+	}
+	n.leader, n.lastContact = req.Leader, time.Now()
+	n.resetElectionTimer()
+	if req.Index <= n.applied {
+		return &SnapshotResponse{Term: n.term}
+	}
+
+	meta := snapshotMeta{index: req.Index, term: req.LastTerm, members: req.Members}
+	err := n.st.saveSnapshot(meta, func(w io.Writer) error {
+		_, err := w.Write(req.Data)
+		return err
+	})
+	if err == nil {
+		err = n.sm.Restore(bytes.NewReader(req.Data))
+	}
+	if err != nil {
+		fmt.Printf("Raft: installing the snapshot: %v\n", err)
+		return &SnapshotResponse{Term: n.term}
+	}
+	if n.termAt(req.Index) == req.LastTerm && req.Index <= n.lastIndex() {
+		n.log = append([]Entry(nil), n.log[req.Index-n.log[0].Index:]...)
+	} else {
+		n.log = []Entry{{}}
+	}
+	n.log[0] = Entry{Index: req.Index, Term: req.LastTerm}
+	n.snapMembers = copyMembers(req.Members)
+	n.updateMembers()
+	if err := n.st.rewriteLog(n.log[1:]); err != nil {
+		fmt.Printf("Raft: rewriting the log: %v\n", err)
+	}
+	n.commit = max(n.commit, req.Index)
+	n.applied = req.Index
+	n.notify()
+	return &SnapshotResponse{Term: n.term}
+}
@@ -0,0 +1,305 @@
+package raft
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// A node keeps three files in its directory:
+//
+//	state     "term vote", replaced atomically on every change
+//	log       one record per entry: length, then index term type argc (len arg)...
+//	snapshot  "SUSYRAFT" index term members (id addr)..., then the state
+//	          machine's snapshot
+//
+// Integers are uvarints and strings are length-prefixed.
+const (
+	stateFile    = "state"
+	logFile      = "log"
+	snapshotFile = "snapshot"
+	snapMagic    = "SUSYRAFT"
+)
+
+var errBadStorage = errors.New("raft: corrupt storage")
+
+// snapshotMeta describes the log prefix a snapshot replaces.
+type snapshotMeta struct {
+	index, term uint64
+	members     map[string]string
+}
+
+// storage is a node's durable state.
+type storage struct {
+	dir string
+	log *os.File
+}
+
+// openStorage opens or creates the files in dir.
+func openStorage(dir string) (*storage, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	log, err := os.OpenFile(filepath.Join(dir, logFile), os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &storage{dir: dir, log: log}, nil
+}
+
+func (s *storage) close() error { return s.log.Close() }
+
+// loadState returns the saved term and vote.
+func (s *storage) loadState() (uint64, string, error) {
+	data, err := os.ReadFile(filepath.Join(s.dir, stateFile))
+	if os.IsNotExist(err) {
+		return 0, "", nil
+	} else if err != nil {
+		return 0, "", err
+	}
+	termStr, vote, _ := strings.Cut(strings.TrimSpace(string(data)), " ")
+	term, err := strconv.ParseUint(termStr, 10, 64)
+	if err != nil {
+		return 0, "", errBadStorage
+	}
+	return term, vote, nil
+}
+
+// saveState durably records the term and vote.
+func (s *storage) saveState(term uint64, vote string) error {
+	return s.replace(stateFile, func(w io.Writer) error {
+		_, err := fmt.Fprintf(w, "%d %s\n", term, vote)
+		return err
+	})
+}
+
+// replace writes a file through a temporary one, so a crash leaves either
+// the old or the new file.
+func (s *storage) replace(name string, write func(w io.Writer) error) error {
+	tmp, err := os.CreateTemp(s.dir, name+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	w := bufio.NewWriter(tmp)
+	err = write(w)
+	if err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(s.dir, name))
+}
+
+// loadLog reads every entry in the log. A record cut short by a crash
+// ends the log.
+func (s *storage) loadLog() ([]Entry, error) {
+	if _, err := s.log.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	r := bufio.NewReader(s.log)
+	var entries []Entry
+	for {
+		n, err := binary.ReadUvarint(r)
+		if err == io.EOF {
+			return entries, nil
+		}
+		record := make([]byte, n)
+		if err == nil {
+			_, err = io.ReadFull(r, record)
+		}
+		if err != nil {
+			// A torn final record was never acknowledged; drop it
+			return entries, s.rewriteLog(entries)
+		}
+		e, err := decodeEntry(record)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+}
+
+// appendLog durably appends entries to the log.
+func (s *storage) appendLog(entries []Entry) error {
+	var buf []byte
+	for _, e := range entries {
+		record := encodeEntry(e)
+		buf = binary.AppendUvarint(buf, uint64(len(record)))
+		buf = append(buf, record...)
+	}
+	if _, err := s.log.Write(buf); err != nil {
+		return err
+	}
+	return s.log.Sync()
+}
+
+// rewriteLog replaces the log with entries, after a conflicting suffix is
+// removed or a snapshot makes a prefix unnecessary.
+func (s *storage) rewriteLog(entries []Entry) error {
+	err := s.replace(logFile, func(w io.Writer) error {
+		for _, e := range entries {
+			record := encodeEntry(e)
+			if _, err := w.Write(binary.AppendUvarint(nil, uint64(len(record)))); err != nil {
+				return err
+			}
+			if _, err := w.Write(record); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	s.log.Close()
+	s.log, err = os.OpenFile(filepath.Join(s.dir, logFile), os.O_RDWR|os.O_APPEND, 0o644)
+	return err
+}
+
+// saveSnapshot durably replaces the snapshot; write supplies the state
+// machine's part.
+func (s *storage) saveSnapshot(meta snapshotMeta, write func(w io.Writer) error) error {
+	return s.replace(snapshotFile, func(w io.Writer) error {
+		buf := append([]byte(snapMagic), binary.AppendUvarint(nil, meta.index)...)
+		buf = binary.AppendUvarint(buf, meta.term)
+		buf = appendMembers(buf, meta.members)
+		if _, err := w.Write(buf); err != nil {
+			return err
+		}
+		return write(w)
+	})
+}
+
+// openSnapshot returns the snapshot's metadata and a reader positioned at
+// the state machine's part, or ok false if there is no snapshot.
+func (s *storage) openSnapshot() (meta snapshotMeta, r io.ReadCloser, ok bool, err error) {
+	f, err := os.Open(filepath.Join(s.dir, snapshotFile))
+	if os.IsNotExist(err) {
+		return meta, nil, false, nil
+	} else if err != nil {
+		return meta, nil, false, err
+	}
+	br := bufio.NewReader(f)
+	magic := make([]byte, len(snapMagic))
+	if _, err := io.ReadFull(br, magic); err != nil || string(magic) != snapMagic {
+		f.Close()
+		return meta, nil, false, errBadStorage
+	}
+	d := &decoder{r: br}
+	meta.index, meta.term = d.uvarint(), d.uvarint()
+	meta.members = d.members()
+	if d.err != nil {
+		f.Close()
+		return meta, nil, false, errBadStorage
+	}
+	return meta, struct {
+		io.Reader
+		io.Closer
+	}{br, f}, true, nil
+}
+
+// encodeEntry serializes an entry without its length prefix.
+func encodeEntry(e Entry) []byte {
+	buf := binary.AppendUvarint(nil, e.Index)
+	buf = binary.AppendUvarint(buf, e.Term)
+	buf = append(buf, byte(e.Type))
+	buf = binary.AppendUvarint(buf, uint64(len(e.Cmd)))
+	for _, arg := range e.Cmd {
+		buf = appendString(buf, arg)
+	}
+	return buf
+}
+
+func decodeEntry(record []byte) (Entry, error) {
+	d := &decoder{r: bufio.NewReader(bytes.NewReader(record))}
+	e := Entry{Index: d.uvarint(), Term: d.uvarint(), Type: EntryType(d.byte())}
+	argc := d.uvarint()
+	if d.err == nil && argc > uint64(len(record)) {
+		return e, errBadStorage
+	}
+	for i := uint64(0); i < argc && d.err == nil; i++ {
+		e.Cmd = append(e.Cmd, d.string())
+	}
+	if d.err != nil {
+		return e, errBadStorage
+	}
+	return e, nil
+}
+
+func appendString(buf []byte, s string) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(s)))
+	return append(buf, s...)
+}
+
+// appendMembers encodes a membership as a count and id, addr pairs.
+func appendMembers(buf []byte, members map[string]string) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(members)))
+	for _, id := range sortedIDs(members) {
+		buf = appendString(buf, id)
+		buf = appendString(buf, members[id])
+	}
+	return buf
+}
+
+// decoder reads the encoding above, remembering the first error.
+type decoder struct {
+	r   *bufio.Reader
+	err error
+}
+
+func (d *decoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	v, err := binary.ReadUvarint(d.r)
+	d.err = err
+	return v
+}
+
+func (d *decoder) byte() byte {
+	if d.err != nil {
+		return 0
+	}
+	b, err := d.r.ReadByte()
+	d.err = err
+	return b
+}
+
+func (d *decoder) string() string {
+	n := d.uvarint()
+	if d.err != nil {
+		return ""
+	}
+	if n > 1<<30 {
+		d.err = errBadStorage
+		return ""
+	}
+	buf := make([]byte, n)
+	_, d.err = io.ReadFull(d.r, buf)
+	return string(buf)
+}
+
+func (d *decoder) members() map[string]string {
+	n := d.uvarint()
+	members := make(map[string]string)
+	for i := uint64(0); i < n && d.err == nil; i++ {
+		id := d.string()
+		members[id] = d.string()
+	}
+	return members
+}
@@ -0,0 +1,197 @@
+package sentinel
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	errNoSuchMaster = errors.New("ERR No such master with that name")
+	errSyntax       = errors.New("ERR syntax error")
+)
+
+// command executes a client command and returns its reply.
+func (s *Sentinel) command(parts []string) interface{} {
+	switch strings.ToUpper(parts[0]) {
+	case "PING":
+		return "PONG"
+	case "INFO":
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		status := "ok"
+		if s.odown {
+			status = "odown"
+		} else if s.sdown(s.primary) {
+			status = "sdown"
+		}
+		return fmt.Sprintf("# Sentinel\r\nsentinel_masters:1\r\nmaster0:name=%s,status=%s,address=%s,slaves=%d,sentinels=%d\r\n",
+			s.cfg.Name, status, s.primary.addr, len(s.replicas), len(s.peers)+1)
+	case "SENTINEL":
+		if len(parts) < 2 {
+			return errors.New("ERR wrong number of arguments for 'sentinel' command")
+		}
+		return s.sentinelCommand(strings.ToUpper(parts[1]), parts[2:])
+	}
+	return errors.New("ERR unknown command")
+}
+
+// sentinelCommand implements the SENTINEL subcommands.
+func (s *Sentinel) sentinelCommand(sub string, args []string) interface{} {
+	if sub == "MASTERS" {
+		if len(args) != 0 {
+			return errSyntax
+		}
+		return []interface{}{s.primaryState()}
+	}
+	if sub == "HELLO" {
+		if len(args) != 1 {
+			return errSyntax
+		}
+		if err := s.receiveHello(args[0]); err != nil {
+			return err
+		}
+		return "OK"
+	}
+	if sub == "IS-MASTER-DOWN-BY-ADDR" {
+		if len(args) != 4 {
+			return errSyntax
+		}
+		epoch, err := strconv.ParseInt(args[2], 10, 64)
+		if err != nil {
+			return errors.New("ERR value is not an integer or out of range")
+		}
+		return s.isPrimaryDown(normalize(net.JoinHostPort(args[0], args[1])), epoch, args[3])
+	}
+
+	// The rest take the primary's name
+	if len(args) != 1 {
+		return errSyntax
+	}
+	if args[0] != s.cfg.Name {
+		if sub == "GET-MASTER-ADDR-BY-NAME" {
+			return nil
+		}
+		return errNoSuchMaster
+	}
+	switch sub {
+	case "GET-MASTER-ADDR-BY-NAME":
+		host, port, _ := net.SplitHostPort(s.PrimaryAddr())
+		return []string{host, port}
+	case "MASTER":
+		return s.primaryState()
+	case "REPLICAS", "SLAVES":
+		return s.replicaStates()
+	case "SENTINELS":
+		return s.peerStates()
+	case "FAILOVER":
+		return s.forceFailover()
+	}
+	return fmt.Errorf("ERR unknown subcommand '%s'", sub)
+}
+
+// primaryState describes the primary as a flat field/value list.
+func (s *Sentinel) primaryState() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	flags := "master"
+	if s.sdown(s.primary) {
+		flags += ",s_down"
+	}
+	if s.odown {
+		flags += ",o_down"
+	}
+	if s.failingOver {
+		flags += ",failover_in_progress"
+	}
+	host, port, _ := net.SplitHostPort(s.primary.addr)
+	return []string{
+		"name", s.cfg.Name, "ip", host, "port", port, "flags", flags,
+		"last-ok-ping-reply", strconv.FormatInt(time.Since(s.primary.lastOK).Milliseconds(), 10),
+		"num-slaves", strconv.Itoa(len(s.replicas)),
+		"num-other-sentinels", strconv.Itoa(len(s.peers)),
+		"quorum", strconv.Itoa(s.cfg.Quorum),
+		"config-epoch", strconv.FormatInt(s.configEpoch, 10),
+		"down-after-milliseconds", strconv.FormatInt(s.cfg.DownAfter.Milliseconds(), 10),
+		"failover-timeout", strconv.FormatInt(s.cfg.FailoverTimeout.Milliseconds(), 10),
+	}
+}
+
+// replicaStates describes every known replica, sorted by address.
+func (s *Sentinel) replicaStates() []interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	addrs := make([]string, 0, len(s.replicas))
+	for addr := range s.replicas {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+	states := make([]interface{}, 0, len(addrs))
+	for _, addr := range addrs {
+		inst := s.replicas[addr]
+		flags := "slave"
+		if s.sdown(inst) {
+			flags += ",s_down"
+		}
+		link := "err"
+		if inst.linkUp {
+			link = "ok"
+		}
+		host, port, _ := net.SplitHostPort(addr)
+		masterHost, masterPort, _ := net.SplitHostPort(inst.primaryAddr)
+		states = append(states, []string{
+			"name", addr, "ip", host, "port", port, "flags", flags,
+			"last-ok-ping-reply", strconv.FormatInt(time.Since(inst.lastOK).Milliseconds(), 10),
+			"master-link-status", link,
+			"master-host", masterHost, "master-port", masterPort,
+			"slave-repl-offset", strconv.FormatInt(inst.offset, 10),
+		})
+	}
+	return states
+}
+
+// peerStates describes the other sentinels, sorted by address.
+func (s *Sentinel) peerStates() []interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	addrs := make([]string, 0, len(s.peers))
+	for addr := range s.peers {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+	states := make([]interface{}, 0, len(addrs))
+	for _, addr := range addrs {
+		p := s.peers[addr]
+		host, port, _ := net.SplitHostPort(addr)
+		lastHello := int64(-1)
+		if !p.lastHello.IsZero() {
+			lastHello = time.Since(p.lastHello).Milliseconds()
+		}
+		states = append(states, []string{
+			"name", p.runID, "ip", host, "port", port, "runid", p.runID,
+			"last-hello-message", strconv.FormatInt(lastHello, 10),
+		})
+	}
+	return states
+}
+
+// forceFailover implements SENTINEL FAILOVER: fail over now, as if the
+// primary were down, without asking the other sentinels.
+func (s *Sentinel) forceFailover() interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.failingOver {
+		return errors.New("INPROG Failover already in progress")
+	}
+	if s.bestReplica() == nil {
+		return errors.New("NOGOODSLAVE No suitable replica to promote")
+	}
+	s.failingOver = true
+	s.failoverAt = time.Now()
+	go s.failover(s.primary.addr, true)
+	return "OK"
+}
@@ -0,0 +1,216 @@
+package sentinel
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"strconv"
+	"time"
+)
+
+// watchPrimary moves a failed primary towards failover: subjectively down
+// here, objectively down once a quorum of sentinels agrees, and then
+// failed over by whichever sentinel a majority elects.
+func (s *Sentinel) watchPrimary() {
+	s.mu.Lock()
+	if s.failingOver {
+		s.mu.Unlock()
+		return
+	}
+	if !s.sdown(s.primary) {
+		if s.odown {
+			fmt.Printf("-odown %s %s\n", s.cfg.Name, s.primary.addr)
+			s.odown = false
+		}
+		s.electAt = time.Time{}
+		s.mu.Unlock()
+		return
+	}
+	addr := s.primary.addr
+	s.mu.Unlock()
+
+	down := 1
+	for _, reply := range s.askPeers(addr, 0, "*") {
+		if reply.down {
+			down++
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if down < s.cfg.Quorum || s.primary.addr != addr {
+		if s.odown {
+			fmt.Printf("-odown %s %s\n", s.cfg.Name, addr)
+		}
+		s.odown = false
+		return
+	}
+	if !s.odown {
+		fmt.Printf("+odown %s %s #quorum %d/%d\n", s.cfg.Name, addr, down, s.cfg.Quorum)
+		s.odown = true
+	}
+	// Sentinels wait a random moment before standing for election, so they
+	// rarely split the vote, and longer after an election they took part in
+	if s.electAt.IsZero() {
+		s.electAt = time.Now().Add(rand.N(min(time.Second, s.cfg.DownAfter)))
+	}
+	if time.Now().Before(s.electAt) || time.Since(s.failoverAt) < 2*s.cfg.FailoverTimeout {
+		return
+	}
+	s.failingOver = true
+	s.failoverAt = time.Now()
+	go s.failover(addr, false)
+}
+
+// peerReply is a peer's answer to SENTINEL is-master-down-by-addr.
+type peerReply struct {
+	down        bool
+	leader      string
+	leaderEpoch int64
+}
+
+// askPeers asks every peer whether it sees the primary at addr as down
+// and, unless runID is "*", for its vote for runID in epoch.
+func (s *Sentinel) askPeers(addr string, epoch int64, runID string) []peerReply {
+	host, port, _ := net.SplitHostPort(addr)
+	cmd := []string{"SENTINEL", "IS-MASTER-DOWN-BY-ADDR", host, port, strconv.FormatInt(epoch, 10), runID}
+	results := make(chan peerReply, len(s.peers))
+	s.eachPeer(func(p *peer) {
+		replies, err := p.do(s.timeout, cmd)
+		if err != nil {
+			return
+		}
+		items, ok := replies[0].([]interface{})
+		if !ok || len(items) != 3 {
+			return
+		}
+		down, _ := items[0].(int64)
+		leader, _ := items[1].(string)
+		leaderEpoch, _ := items[2].(int64)
+		results <- peerReply{down: down == 1, leader: leader, leaderEpoch: leaderEpoch}
+	})
+	close(results)
+	var replies []peerReply
+	for r := range results {
+		replies = append(replies, r)
+	}
+	return replies
+}
+
+// isPrimaryDown answers a peer's SENTINEL is-master-down-by-addr: whether
+// addr is our primary and down, and who we vote for. We vote for the first
+// sentinel that asks in each epoch.
+func (s *Sentinel) isPrimaryDown(addr string, epoch int64, runID string) []interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	down := int64(0)
+	if addr == s.primary.addr && s.sdown(s.primary) {
+		down = 1
+	}
+	if runID == "*" {
+		return []interface{}{down, "*", int64(0)}
+	}
+	s.currentEpoch = max(s.currentEpoch, epoch)
+	if s.votedEpoch < epoch {
+		s.votedEpoch, s.votedFor = epoch, runID
+		if runID != s.runID {
+			s.failoverAt = time.Now()
+		}
+		fmt.Printf("+vote-for-leader %s %d\n", runID, epoch)
+	}
+	return []interface{}{down, s.votedFor, s.votedEpoch}
+}
+
+// failover runs an election for a new epoch and, if we win or the failover
+// is forced, promotes the best replica of the primary at addr and points
+// the others, the old primary included, at it.
+func (s *Sentinel) failover(addr string, forced bool) {
+	defer func() {
+		s.mu.Lock()
+		s.failingOver = false
+		s.mu.Unlock()
+	}()
+
+	s.mu.Lock()
+	s.currentEpoch++
+	epoch := s.currentEpoch
+	s.votedEpoch, s.votedFor = epoch, s.runID
+	needed := max(s.cfg.Quorum, (len(s.peers)+1)/2+1)
+	s.mu.Unlock()
+	fmt.Printf("+try-failover %s %s epoch %d\n", s.cfg.Name, addr, epoch)
+
+	if !forced {
+		votes := 1
+		for _, reply := range s.askPeers(addr, epoch, s.runID) {
+			if reply.leader == s.runID && reply.leaderEpoch == epoch {
+				votes++
+			}
+		}
+		if votes < needed {
+			fmt.Printf("-failover-abort-not-elected %s %d/%d votes\n", s.cfg.Name, votes, needed)
+			return
+		}
+		fmt.Printf("+elected-leader %s epoch %d\n", s.cfg.Name, epoch)
+	}
+
+	s.mu.Lock()
+	candidate := s.bestReplica()
+	var offset int64
+	if candidate != nil {
+		offset = candidate.offset
+	}
+	s.mu.Unlock()
+	if candidate == nil {
+		fmt.Printf("-failover-abort-no-good-slave %s\n", s.cfg.Name)
+		return
+	}
+	fmt.Printf("+selected-slave %s %s offset %d\n", s.cfg.Name, candidate.addr, offset)
+	if _, err := command(candidate.addr, s.timeout, "REPLICAOF", "NO", "ONE"); err != nil {
+		fmt.Printf("-failover-abort-promotion %s %v\n", candidate.addr, err)
+		return
+	}
+	if !s.waitRole(candidate.addr, "master") {
+		fmt.Printf("-failover-abort-timeout %s\n", candidate.addr)
+		return
+	}
+
+	s.mu.Lock()
+	if s.primary.addr != addr || s.configEpoch >= epoch {
+		s.mu.Unlock()
+		return
+	}
+	s.configEpoch = epoch
+	s.switchPrimary(candidate.addr)
+	var others []string
+	for other := range s.replicas {
+		others = append(others, other)
+	}
+	s.mu.Unlock()
+	s.sendHellos()
+
+	host, port, _ := net.SplitHostPort(candidate.addr)
+	for _, other := range others {
+		if _, err := command(other, s.timeout, "REPLICAOF", host, port); err == nil {
+			fmt.Printf("+slave-reconf-sent %s %s\n", s.cfg.Name, other)
+		}
+	}
+	fmt.Printf("+failover-end %s %s\n", s.cfg.Name, candidate.addr)
+}
+
+// waitRole polls addr until INFO reports role, for up to FailoverTimeout.
+func (s *Sentinel) waitRole(addr, role string) bool {
+	deadline := time.Now().Add(s.cfg.FailoverTimeout)
+	for time.Now().Before(deadline) {
+		if reply, err := command(addr, s.timeout, "INFO", "replication"); err == nil {
+			if info, _ := reply.(string); parseInfo(info)["role"] == role {
+				return true
+			}
+		}
+		select {
+		case <-s.closed:
+			return false
+		case <-time.After(s.period):
+		}
+	}
+	return false
+}
@@ -0,0 +1,266 @@
+package sentinel
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// monitor runs the sentinel's periodic work until Close.
+func (s *Sentinel) monitor() {
+	ticker := time.NewTicker(s.period)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.closed:
+			return
+		case <-ticker.C:
+			s.tick()
+		}
+	}
+}
+
+// tick checks every instance, tells the peers our configuration, puts
+// stray replicas back under the primary and watches for a failed primary.
+func (s *Sentinel) tick() {
+	s.mu.Lock()
+	instances := []*instance{s.primary}
+	for _, inst := range s.replicas {
+		instances = append(instances, inst)
+	}
+	s.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, inst := range instances {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.check(inst)
+		}()
+	}
+	wg.Wait()
+
+	s.discoverReplicas()
+	s.sendHellos()
+	s.reconfigureStrays()
+	s.watchPrimary()
+}
+
+// check PINGs an instance and records what its INFO replication reports.
+func (s *Sentinel) check(inst *instance) {
+	replies, err := inst.do(s.timeout, []string{"PING"}, []string{"INFO", "replication"})
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err != nil {
+		return
+	}
+	if pong, _ := replies[0].(string); pong == "PONG" {
+		inst.lastOK = time.Now()
+	}
+	info, _ := replies[1].(string)
+	fields := parseInfo(info)
+	inst.role = fields["role"]
+	inst.replicas = inst.replicas[:0]
+	if inst.role == "slave" {
+		inst.primaryAddr = normalize(net.JoinHostPort(fields["master_host"], fields["master_port"]))
+		inst.linkUp = fields["master_link_status"] == "up"
+		inst.offset, _ = strconv.ParseInt(fields["slave_repl_offset"], 10, 64)
+		return
+	}
+	inst.primaryAddr, inst.linkUp = "", false
+	inst.offset, _ = strconv.ParseInt(fields["master_repl_offset"], 10, 64)
+	for i := 0; ; i++ {
+		replica, ok := fields["slave"+strconv.Itoa(i)]
+		if !ok {
+			break
+		}
+		var ip, port string
+		for _, kv := range strings.Split(replica, ",") {
+			k, v, _ := strings.Cut(kv, "=")
+			switch k {
+			case "ip":
+				ip = v
+			case "port":
+				port = v
+			}
+		}
+		inst.replicas = append(inst.replicas, normalize(net.JoinHostPort(ip, port)))
+	}
+}
+
+// parseInfo splits INFO output into its fields.
+func parseInfo(info string) map[string]string {
+	fields := make(map[string]string)
+	for _, line := range strings.Split(info, "\r\n") {
+		if k, v, ok := strings.Cut(line, ":"); ok && !strings.HasPrefix(line, "#") {
+			fields[k] = v
+		}
+	}
+	return fields
+}
+
+// discoverReplicas starts monitoring replicas the primary reports.
+func (s *Sentinel) discoverReplicas() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, addr := range s.primary.replicas {
+		if _, ok := s.replicas[addr]; !ok && addr != s.primary.addr {
+			fmt.Printf("+slave %s %s\n", s.cfg.Name, addr)
+			s.replicas[addr] = &instance{link: link{addr: addr}}
+		}
+	}
+}
+
+// hello is the configuration sentinels exchange:
+// ip,port,runid,current_epoch,name,primary_ip,primary_port,config_epoch.
+func (s *Sentinel) hello() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	host, port, _ := net.SplitHostPort(s.addr)
+	phost, pport, _ := net.SplitHostPort(s.primary.addr)
+	return strings.Join([]string{host, port, s.runID, strconv.FormatInt(s.currentEpoch, 10),
+		s.cfg.Name, phost, pport, strconv.FormatInt(s.configEpoch, 10)}, ",")
+}
+
+// sendHellos sends our hello to every peer.
+func (s *Sentinel) sendHellos() {
+	hello := []string{"SENTINEL", "HELLO", s.hello()}
+	s.eachPeer(func(p *peer) {
+		p.do(s.timeout, hello)
+	})
+}
+
+// receiveHello handles a peer's hello. A configuration with a newer epoch
+// wins: its primary replaces ours, which is demoted to a replica.
+func (s *Sentinel) receiveHello(hello string) error {
+	f := strings.Split(hello, ",")
+	if len(f) != 8 {
+		return fmt.Errorf("ERR invalid hello %q", hello)
+	}
+	currentEpoch, err1 := strconv.ParseInt(f[3], 10, 64)
+	configEpoch, err2 := strconv.ParseInt(f[7], 10, 64)
+	if err1 != nil || err2 != nil {
+		return fmt.Errorf("ERR invalid hello %q", hello)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if p, ok := s.peers[normalize(net.JoinHostPort(f[0], f[1]))]; ok {
+		p.runID, p.lastHello = f[2], time.Now()
+	}
+	s.currentEpoch = max(s.currentEpoch, currentEpoch)
+	primary := normalize(net.JoinHostPort(f[5], f[6]))
+	if f[4] == s.cfg.Name && configEpoch > s.configEpoch && primary != s.primary.addr {
+		fmt.Printf("+config-update-from sentinel %s:%s %s %s epoch %d\n", f[0], f[1], s.cfg.Name, primary, configEpoch)
+		s.switchPrimary(primary)
+		s.configEpoch = configEpoch
+	} else if f[4] == s.cfg.Name {
+		s.configEpoch = max(s.configEpoch, configEpoch)
+	}
+	return nil
+}
+
+// switchPrimary makes addr the primary and the old primary a replica.
+// Must be called while holding mu.
+func (s *Sentinel) switchPrimary(addr string) {
+	old := s.primary
+	next, ok := s.replicas[addr]
+	if !ok {
+		next = &instance{link: link{addr: addr}}
+	}
+	delete(s.replicas, addr)
+	// The new primary gets a fresh DownAfter before it can be failed over
+	next.lastOK = time.Now()
+	next.strayed = time.Time{}
+	s.primary = next
+	old.strayed = time.Time{}
+	s.replicas[old.addr] = old
+	s.odown = false
+	s.electAt = time.Time{}
+	fmt.Printf("+switch-master %s %s %s\n", s.cfg.Name, old.addr, addr)
+}
+
+// eachPeer runs fn for every peer concurrently and waits for them.
+func (s *Sentinel) eachPeer(fn func(p *peer)) {
+	s.mu.Lock()
+	peers := make([]*peer, 0, len(s.peers))
+	for _, p := range s.peers {
+		peers = append(peers, p)
+	}
+	s.mu.Unlock()
+	var wg sync.WaitGroup
+	for _, p := range peers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			fn(p)
+		}()
+	}
+	wg.Wait()
+}
+
+// reconfigureStrays points replicas at the primary when they follow
+// another node or act as primaries themselves, as a restarted old primary
+// does. A replica is left alone for a few periods first, so a promotion by
+// a peer is not undone before its hello arrives, and never while the
+// primary is down.
+func (s *Sentinel) reconfigureStrays() {
+	s.mu.Lock()
+	if s.failingOver || s.sdown(s.primary) {
+		s.mu.Unlock()
+		return
+	}
+	primary := s.primary.addr
+	var strays []*instance
+	for _, inst := range s.replicas {
+		if s.sdown(inst) || inst.role == "" || (inst.role == "slave" && inst.primaryAddr == primary) {
+			inst.strayed = time.Time{}
+			continue
+		}
+		if inst.strayed.IsZero() {
+			inst.strayed = time.Now()
+		}
+		if time.Since(inst.strayed) > 8*s.period {
+			inst.strayed = time.Time{}
+			strays = append(strays, inst)
+		}
+	}
+	s.mu.Unlock()
+
+	host, port, _ := net.SplitHostPort(primary)
+	for _, inst := range strays {
+		fmt.Printf("+fix-slave-config %s %s\n", s.cfg.Name, inst.addr)
+		command(inst.addr, s.timeout, "REPLICAOF", host, port)
+	}
+}
+
+// sdown reports whether an instance is subjectively down: it has not
+// answered a PING for DownAfter. Must be called while holding mu.
+func (s *Sentinel) sdown(inst *instance) bool {
+	return time.Since(inst.lastOK) > s.cfg.DownAfter
+}
+
+// bestReplica returns the reachable replica with the most of the stream,
+// or nil. Must be called while holding mu.
+func (s *Sentinel) bestReplica() *instance {
+	var candidates []*instance
+	for _, inst := range s.replicas {
+		if !s.sdown(inst) && inst.role == "slave" {
+			candidates = append(candidates, inst)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].offset != candidates[j].offset {
+			return candidates[i].offset > candidates[j].offset
+		}
+		return candidates[i].addr < candidates[j].addr
+	})
+	return candidates[0]
+}
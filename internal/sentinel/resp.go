@@ -0,0 +1,105 @@
+package sentinel
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/Syed-Suhaan/SusyDB/pkg/resp"
+)
+
+// link is a client connection to a data node or a peer sentinel, dialed on
+// first use and dropped after any error.
+type link struct {
+	addr string
+
+	mu     sync.Mutex // Serializes round trips
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// do sends cmds as one pipeline and returns their replies. An error reply
+// is returned as a resp.Error value, not as err.
+func (l *link) do(timeout time.Duration, cmds ...[]string) ([]interface{}, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.conn == nil {
+		conn, err := net.DialTimeout("tcp", l.addr, timeout)
+		if err != nil {
+			return nil, err
+		}
+		l.conn, l.reader = conn, bufio.NewReader(conn)
+	}
+	l.conn.SetDeadline(time.Now().Add(timeout))
+
+	var buf []byte
+	for _, cmd := range cmds {
+		buf = resp.AppendCommand(buf, cmd)
+	}
+	replies := make([]interface{}, 0, len(cmds))
+	_, err := l.conn.Write(buf)
+	for err == nil && len(replies) < len(cmds) {
+		var reply interface{}
+		if reply, err = resp.ReadReply(l.reader); err == nil {
+			replies = append(replies, reply)
+		}
+	}
+	if err != nil {
+		l.conn.Close()
+		l.conn, l.reader = nil, nil
+		return nil, err
+	}
+	return replies, nil
+}
+
+func (l *link) close() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.conn != nil {
+		l.conn.Close()
+		l.conn, l.reader = nil, nil
+	}
+}
+
+// command sends one command to addr on a fresh connection.
+func command(addr string, timeout time.Duration, args ...string) (interface{}, error) {
+	l := &link{addr: addr}
+	defer l.close()
+	replies, err := l.do(timeout, args)
+	if err != nil {
+		return nil, err
+	}
+	if rerr, ok := replies[0].(resp.Error); ok {
+		return nil, rerr
+	}
+	return replies[0], nil
+}
+
+// appendReply encodes a reply for a sentinel client: strings as bulk
+// strings, string slices and nested slices as arrays, nil as a null array.
+// A value of any other type is a bug in the command that returned it; it is
+// written as an error reply, so the client still gets a well-formed reply
+// and the sentinel keeps running.
+func appendReply(buf []byte, v interface{}) []byte {
+	switch v := v.(type) {
+	case nil:
+		return append(buf, "*-1\r\n"...)
+	case string:
+		return fmt.Appendf(buf, "$%d\r\n%s\r\n", len(v), v)
+	case int64:
+		return fmt.Appendf(buf, ":%d\r\n", v)
+	case error:
+		return fmt.Appendf(buf, "-%s\r\n", v)
+	case []string:
+		return resp.AppendCommand(buf, v)
+	case []interface{}:
+		buf = fmt.Appendf(buf, "*%d\r\n", len(v))
+		for _, item := range v {
+			buf = appendReply(buf, item)
+		}
+		return buf
+	}
+	return fmt.Appendf(buf, "-ERR sentinel cannot encode a %T reply\r\n", v)
+}
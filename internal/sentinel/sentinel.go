@@ -0,0 +1,189 @@
+// Package sentinel monitors a SusyDB primary and its replicas and, together
+// with its peer sentinels, promotes the most up-to-date replica when the
+// primary fails. Clients ask any sentinel where the primary is with
+// SENTINEL get-master-addr-by-name.
+package sentinel
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Syed-Suhaan/SusyDB/internal/server"
+)
+
+// Config describes the primary a sentinel watches and its peers.
+type Config struct {
+	Name            string        // Name clients look the primary up by
+	Primary         string        // host:port of the primary
+	Quorum          int           // Sentinels that must agree the primary is down
+	DownAfter       time.Duration // Silence after which an instance is down
+	FailoverTimeout time.Duration // Longest a promotion may take
+	Peers           []string      // host:port of the other sentinels
+}
+
+// Sentinel watches one primary. Create it with New and run it with Serve.
+type Sentinel struct {
+	cfg     Config
+	runID   string
+	period  time.Duration // Between checks, hellos and failover steps
+	timeout time.Duration // For each network round trip
+
+	mu           sync.Mutex
+	addr         string // Our own address, announced to peers
+	primary      *instance
+	replicas     map[string]*instance
+	peers        map[string]*peer
+	currentEpoch int64
+	configEpoch  int64 // Epoch of the failover that chose the primary
+	votedEpoch   int64
+	votedFor     string
+	odown        bool
+	electAt      time.Time // When to try an election; zero if none is due
+	failoverAt   time.Time // Last election we started or voted in
+	failingOver  bool
+
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+// instance is a data node: the primary or one of its replicas.
+type instance struct {
+	link
+	lastOK      time.Time // Last PING answered
+	role        string    // "master" or "slave", from INFO
+	primaryAddr string    // The primary a replica follows
+	linkUp      bool
+	offset      int64
+	replicas    []string  // Replicas a primary reports
+	strayed     time.Time // Since when a replica follows the wrong primary
+}
+
+// peer is another sentinel watching the same primary.
+type peer struct {
+	link
+	runID     string
+	lastHello time.Time
+}
+
+// New returns a sentinel for cfg. Zero fields get defaults: a quorum of
+// one, 30 seconds for DownAfter and 3 minutes for FailoverTimeout.
+func New(cfg Config) *Sentinel {
+	if cfg.Quorum <= 0 {
+		cfg.Quorum = 1
+	}
+	if cfg.DownAfter <= 0 {
+		cfg.DownAfter = 30 * time.Second
+	}
+	if cfg.FailoverTimeout <= 0 {
+		cfg.FailoverTimeout = 3 * time.Minute
+	}
+	id := make([]byte, 20)
+	rand.Read(id)
+	period := max(min(time.Second, cfg.DownAfter/4), 10*time.Millisecond)
+	s := &Sentinel{
+		cfg:      cfg,
+		runID:    hex.EncodeToString(id),
+		period:   period,
+		timeout:  max(2*period, 250*time.Millisecond),
+		primary:  &instance{link: link{addr: normalize(cfg.Primary)}, lastOK: time.Now()},
+		replicas: make(map[string]*instance),
+		peers:    make(map[string]*peer),
+		closed:   make(chan struct{}),
+	}
+	for _, addr := range cfg.Peers {
+		addr = normalize(addr)
+		s.peers[addr] = &peer{link: link{addr: addr}}
+	}
+	return s
+}
+
+// normalize resolves the host of addr, so that "localhost:7379" and
+// "127.0.0.1:7379" name the same instance.
+func normalize(addr string) string {
+	if tcp, err := net.ResolveTCPAddr("tcp", addr); err == nil && tcp.IP != nil {
+		return tcp.String()
+	}
+	return addr
+}
+
+// Serve monitors the primary and answers clients on listener until Close.
+func (s *Sentinel) Serve(listener net.Listener) error {
+	s.mu.Lock()
+	s.addr = normalize(listener.Addr().String())
+	s.mu.Unlock()
+	go s.monitor()
+	go func() {
+		<-s.closed
+		listener.Close()
+	}()
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			fmt.Printf("Connection error: %v\n", err)
+			continue
+		}
+		go s.handleClient(conn)
+	}
+}
+
+// Close stops the sentinel.
+func (s *Sentinel) Close() {
+	s.closeOnce.Do(func() { close(s.closed) })
+}
+
+// PrimaryAddr returns the address of the current primary.
+func (s *Sentinel) PrimaryAddr() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.primary.addr
+}
+
+func (s *Sentinel) handleClient(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	for {
+		conn.SetDeadline(time.Now().Add(5 * time.Minute))
+		parts, err := readCommand(reader)
+		if err != nil {
+			if err != io.EOF {
+				conn.Write([]byte("-ERR Protocol error: " + err.Error() + "\r\n"))
+			}
+			return
+		}
+		if len(parts) == 0 {
+			continue
+		}
+		if strings.EqualFold(parts[0], "QUIT") {
+			conn.Write([]byte("+OK\r\n"))
+			return
+		}
+		conn.Write(appendReply(nil, s.command(parts)))
+	}
+}
+
+// readCommand reads a RESP command, or an inline one from telnet.
+func readCommand(reader *bufio.Reader) ([]string, error) {
+	peek, err := reader.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+	if peek[0] == '*' {
+		return server.ParseRESP(reader)
+	}
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(line), nil
+}
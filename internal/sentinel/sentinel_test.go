@@ -0,0 +1,219 @@
+package sentinel
+
+import (
+	"net"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// buildBinaries builds susydb and susy-sentinel into a temporary directory.
+func buildBinaries(t *testing.T) string {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("builds and runs local processes")
+	}
+	dir := t.TempDir()
+	build := exec.Command("go", "build", "-o", dir, "../../cmd/susydb", "../../cmd/susy-sentinel")
+	if out, err := build.CombinedOutput(); err != nil {
+		t.Fatalf("go build: %v\n%s", err, out)
+	}
+	return dir
+}
+
+// freeAddr returns a local address nothing listens on.
+func freeAddr(t *testing.T) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	defer listener.Close()
+	return listener.Addr().String()
+}
+
+// process is a running susydb or susy-sentinel.
+type process struct {
+	addr string
+	cmd  *exec.Cmd
+}
+
+// start runs a binary from dir listening on addr until the test ends.
+func start(t *testing.T, dir, binary, addr string, args ...string) *process {
+	t.Helper()
+	cmd := exec.Command(filepath.Join(dir, binary), append([]string{"-addr", addr}, args...)...)
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("starting %s: %v", binary, err)
+	}
+	p := &process{addr: addr, cmd: cmd}
+	t.Cleanup(p.kill)
+	waitFor(t, binary+" to listen", func() bool {
+		_, err := command(addr, time.Second, "PING")
+		return err == nil
+	})
+	return p
+}
+
+func (p *process) kill() {
+	p.cmd.Process.Kill()
+	p.cmd.Wait()
+}
+
+// waitFor polls cond until it holds or fifteen seconds pass.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(15 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// infoField returns a field of addr's INFO replication, or "".
+func infoField(addr, name string) string {
+	reply, err := command(addr, time.Second, "INFO", "replication")
+	if err != nil {
+		return ""
+	}
+	info, _ := reply.(string)
+	return parseInfo(info)[name]
+}
+
+// primaryOf asks a sentinel for the primary's address.
+func primaryOf(addr string) string {
+	reply, err := command(addr, time.Second, "SENTINEL", "get-master-addr-by-name", "mymaster")
+	if items, ok := reply.([]interface{}); err == nil && ok && len(items) == 2 {
+		host, _ := items[0].(string)
+		port, _ := items[1].(string)
+		return net.JoinHostPort(host, port)
+	}
+	return ""
+}
+
+func TestFailover(t *testing.T) {
+	dir := buildBinaries(t)
+	primary := start(t, dir, "susydb", freeAddr(t))
+	replicas := []*process{
+		start(t, dir, "susydb", freeAddr(t), "-replicaof", primary.addr),
+		start(t, dir, "susydb", freeAddr(t), "-replicaof", primary.addr),
+	}
+	if _, err := command(primary.addr, time.Second, "SET", "k", "v1"); err != nil {
+		t.Fatalf("SET on primary: %v", err)
+	}
+	for _, r := range replicas {
+		waitFor(t, "replica sync", func() bool {
+			return infoField(r.addr, "master_link_status") == "up" &&
+				infoField(r.addr, "slave_repl_offset") == infoField(primary.addr, "master_repl_offset")
+		})
+	}
+
+	sentinelAddrs := []string{freeAddr(t), freeAddr(t), freeAddr(t)}
+	for i, addr := range sentinelAddrs {
+		var peers []string
+		for j, peer := range sentinelAddrs {
+			if j != i {
+				peers = append(peers, peer)
+			}
+		}
+		start(t, dir, "susy-sentinel", addr, "-primary", primary.addr, "-quorum", "2",
+			"-down-after", "500ms", "-failover-timeout", "3s", "-peers", strings.Join(peers, ","))
+	}
+	for _, addr := range sentinelAddrs {
+		if got := primaryOf(addr); got != primary.addr {
+			t.Fatalf("sentinel %s primary = %q, want %q", addr, got, primary.addr)
+		}
+		waitFor(t, "replica discovery", func() bool {
+			reply, _ := command(addr, time.Second, "SENTINEL", "replicas", "mymaster")
+			items, _ := reply.([]interface{})
+			return len(items) == 2
+		})
+	}
+
+	primary.kill()
+	var promoted string
+	waitFor(t, "failover", func() bool {
+		promoted = primaryOf(sentinelAddrs[0])
+		for _, addr := range sentinelAddrs {
+			if got := primaryOf(addr); got == primary.addr || got != promoted {
+				return false
+			}
+		}
+		return true
+	})
+	if promoted != replicas[0].addr && promoted != replicas[1].addr {
+		t.Fatalf("promoted %q, want one of the replicas", promoted)
+	}
+	if got := infoField(promoted, "role"); got != "master" {
+		t.Errorf("promoted role = %q, want master", got)
+	}
+	if got, err := command(promoted, time.Second, "GET", "k"); err != nil || got != "v1" {
+		t.Errorf("GET k on promoted = %v, %v, want v1", got, err)
+	}
+
+	other := replicas[0].addr
+	if other == promoted {
+		other = replicas[1].addr
+	}
+	_, promotedPort, _ := net.SplitHostPort(promoted)
+	waitFor(t, "the other replica to follow the new primary", func() bool {
+		return infoField(other, "master_port") == promotedPort && infoField(other, "master_link_status") == "up"
+	})
+	command(promoted, time.Second, "SET", "k", "v2")
+	waitFor(t, "a write on the new primary to replicate", func() bool {
+		got, _ := command(other, time.Second, "GET", "k")
+		return got == "v2"
+	})
+
+	// The old primary comes back empty and is made a replica
+	restarted := start(t, dir, "susydb", primary.addr)
+	waitFor(t, "the old primary to rejoin as a replica", func() bool {
+		return infoField(restarted.addr, "master_port") == promotedPort &&
+			infoField(restarted.addr, "master_link_status") == "up"
+	})
+	if got, _ := command(restarted.addr, time.Second, "GET", "k"); got != "v2" {
+		t.Errorf("GET k on the old primary = %v, want v2", got)
+	}
+}
+
+func TestIsPrimaryDownVotes(t *testing.T) {
+	s := New(Config{Name: "mymaster", Primary: "127.0.0.1:1", DownAfter: time.Second})
+	s.primary.lastOK = time.Now().Add(-time.Minute)
+
+	tests := []struct {
+		epoch int64
+		runID string
+		want  []interface{}
+	}{
+		{0, "*", []interface{}{int64(1), "*", int64(0)}},
+		{1, "a", []interface{}{int64(1), "a", int64(1)}},
+		{1, "b", []interface{}{int64(1), "a", int64(1)}}, // One vote per epoch
+		{2, "b", []interface{}{int64(1), "b", int64(2)}},
+	}
+	for _, tt := range tests {
+		got := s.isPrimaryDown("127.0.0.1:1", tt.epoch, tt.runID)
+		if stringify(got) != stringify(tt.want) {
+			t.Errorf("isPrimaryDown(%d, %s) = %v, want %v", tt.epoch, tt.runID, got, tt.want)
+		}
+	}
+	if s.currentEpoch != 2 {
+		t.Errorf("currentEpoch = %d, want 2", s.currentEpoch)
+	}
+	if got := s.isPrimaryDown("127.0.0.1:2", 0, "*"); got[0] != int64(0) {
+		t.Errorf("isPrimaryDown(unknown address) down = %v, want 0", got[0])
+	}
+}
+
+func stringify(v []interface{}) string {
+	return string(appendReply(nil, v))
+}
+
+func TestAppendReplyUnknownType(t *testing.T) {
+	got := stringify([]interface{}{"a", 1.5})
+	if want := "*2\r\n$1\r\na\r\n-ERR sentinel cannot encode a float64 reply\r\n"; got != want {
+		t.Errorf("appendReply() = %q, want %q", got, want)
+	}
+}
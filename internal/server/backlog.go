@@ -0,0 +1,51 @@
+package server
+
+// replBacklog is a ring buffer holding the newest bytes of the replication
+// stream, so a replica that reconnects can be sent just what it missed.
+type replBacklog struct {
+	buf     []byte
+	next    int   // Index the next byte is written at
+	histlen int   // Bytes held, at most len(buf)
+	end     int64 // Stream offset just past the newest byte
+}
+
+// newReplBacklog returns an empty backlog of size bytes whose stream
+// continues from offset.
+func newReplBacklog(size int, offset int64) *replBacklog {
+	return &replBacklog{buf: make([]byte, size), end: offset}
+}
+
+// start returns the stream offset of the oldest byte held.
+func (b *replBacklog) start() int64 {
+	return b.end - int64(b.histlen)
+}
+
+// write appends stream bytes, overwriting the oldest once the buffer is full.
+func (b *replBacklog) write(data []byte) {
+	b.end += int64(len(data))
+	size := len(b.buf)
+	if len(data) >= size {
+		copy(b.buf, data[len(data)-size:])
+		b.next = 0
+		b.histlen = size
+		return
+	}
+	n := copy(b.buf[b.next:], data)
+	copy(b.buf, data[n:])
+	b.next = (b.next + len(data)) % size
+	b.histlen = min(b.histlen+len(data), size)
+}
+
+// since returns a copy of the stream from offset from to the newest byte,
+// or false if part of it was already overwritten.
+func (b *replBacklog) since(from int64) ([]byte, bool) {
+	if from < b.start() || from > b.end {
+		return nil, false
+	}
+	n := int(b.end - from)
+	out := make([]byte, n)
+	pos := (b.next - n + len(b.buf)) % len(b.buf)
+	copied := copy(out, b.buf[pos:min(pos+n, len(b.buf))])
+	copy(out[copied:], b.buf[:n-copied])
+	return out, true
+}
@@ -0,0 +1,560 @@
+package server
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Syed-Suhaan/SusyDB/pkg/core"
+)
+
+const (
+	clusterGossipPeriod = time.Second      // Nodes exchange state this often
+	clusterNodeTimeout  = 15 * time.Second // A silent node is flagged fail?
+	clusterForgetTTL    = time.Minute      // CLUSTER FORGET bans a node this long
+)
+
+// keySpec locates a command's keys: the first and last argument (negative
+// counts from the end) and the step between keys.
+type keySpec struct{ first, last, step int }
+
+// keySpecs lists the commands that take keys. The cluster routes them by
+// their keys' slot; commands missing here (CONFIG, PUBLISH, FT.SEARCH, ...)
+// run on whichever node receives them.
+var keySpecs = map[string]keySpec{
+	"SET": {1, 1, 1}, "SETEX": {1, 1, 1}, "GET": {1, 1, 1}, "INCR": {1, 1, 1}, "INCRBY": {1, 1, 1}, "DEL": {1, 1, 1}, "TYPE": {1, 1, 1},
+	"MGET": {1, -1, 1}, "MSET": {1, -1, 2},
+	"HSET": {1, 1, 1}, "HGET": {1, 1, 1}, "HGETALL": {1, 1, 1}, "HDEL": {1, 1, 1},
+	"SETBIT": {1, 1, 1}, "GETBIT": {1, 1, 1}, "BITCOUNT": {1, 1, 1}, "BITPOS": {1, 1, 1}, "BITOP": {2, -1, 1}, "BITFIELD": {1, 1, 1},
+	"PFADD": {1, 1, 1}, "PFCOUNT": {1, -1, 1}, "PFMERGE": {1, -1, 1},
+	"BF.RESERVE": {1, 1, 1}, "BF.ADD": {1, 1, 1}, "BF.MADD": {1, 1, 1}, "BF.EXISTS": {1, 1, 1}, "BF.MEXISTS": {1, 1, 1},
+	"CF.RESERVE": {1, 1, 1}, "CF.ADD": {1, 1, 1}, "CF.ADDNX": {1, 1, 1}, "CF.DEL": {1, 1, 1}, "CF.EXISTS": {1, 1, 1},
+	"CMS.INITBYDIM": {1, 1, 1}, "CMS.INITBYPROB": {1, 1, 1}, "CMS.INCRBY": {1, 1, 1}, "CMS.QUERY": {1, 1, 1},
+	"GEOADD": {1, 1, 1}, "GEOPOS": {1, 1, 1}, "GEODIST": {1, 1, 1}, "GEOHASH": {1, 1, 1}, "GEOSEARCH": {1, 1, 1},
+	"JSON.SET": {1, 1, 1}, "JSON.GET": {1, 1, 1}, "JSON.DEL": {1, 1, 1}, "JSON.NUMINCRBY": {1, 1, 1}, "JSON.ARRAPPEND": {1, 1, 1},
+	"TS.CREATE": {1, 1, 1}, "TS.ADD": {1, 1, 1}, "TS.MADD": {1, -1, 3}, "TS.RANGE": {1, 1, 1}, "TS.CREATERULE": {1, 2, 1},
+	"DUMP": {1, 1, 1}, "RESTORE": {1, 1, 1}, "RESTORE-ASKING": {1, 1, 1},
+	"SPUBLISH": {1, 1, 1}, "SSUBSCRIBE": {1, -1, 1},
+}
+
+// commandKeys returns the keys of a command.
+func commandKeys(cmdName string, parts []string) []string {
+	spec, ok := keySpecs[cmdName]
+	if !ok || len(parts) <= spec.first {
+		return nil
+	}
+	last := spec.last
+	if last < 0 {
+		last += len(parts)
+	}
+	var keys []string
+	for i := spec.first; i <= last && i < len(parts); i += spec.step {
+		keys = append(keys, parts[i])
+	}
+	return keys
+}
+
+// clusterNode is a node of the cluster as this node sees it.
+type clusterNode struct {
+	id       string
+	addr     string // ip:port clients connect to
+	epoch    int64  // Config epoch; the highest claim on a slot wins
+	lastPong time.Time
+}
+
+// cluster is a node's view of the cluster: every node and which node
+// serves each of the SlotCount hash slots.
+type cluster struct {
+	store *core.KVStore
+	kick  chan struct{} // Asks the gossip loop to gossip now
+
+	mu           sync.RWMutex
+	myself       *clusterNode
+	myIP         string // As seen by the other nodes
+	nodes        map[string]*clusterNode
+	slots        [core.SlotCount]*clusterNode
+	migrating    map[int]*clusterNode
+	importing    map[int]*clusterNode
+	currentEpoch int64
+	forgotten    map[string]time.Time // Nodes gossip may not re-add yet
+
+	// migrateMu keeps MIGRATE from racing with commands on migrating
+	// slots: they hold it for reading, MIGRATE exclusively.
+	migrateMu sync.RWMutex
+}
+
+var clusters sync.Map // *core.KVStore -> *cluster
+
+// EnableCluster turns on cluster mode for the server serving store. The
+// node starts with no slots and knows only itself; CLUSTER ADDSLOTS and
+// CLUSTER MEET build the cluster.
+func EnableCluster(store *core.KVStore) {
+	id := make([]byte, 20)
+	rand.Read(id)
+	myself := &clusterNode{id: hex.EncodeToString(id)}
+	c := &cluster{
+		store:     store,
+		kick:      make(chan struct{}, 1),
+		myself:    myself,
+		myIP:      "127.0.0.1",
+		nodes:     map[string]*clusterNode{myself.id: myself},
+		migrating: make(map[int]*clusterNode),
+		importing: make(map[int]*clusterNode),
+		forgotten: make(map[string]time.Time),
+	}
+	if _, loaded := clusters.LoadOrStore(store, c); !loaded {
+		go c.gossipLoop()
+	}
+}
+
+// clusterOf returns the cluster state of the server serving store, or nil
+// outside cluster mode.
+func clusterOf(store *core.KVStore) *cluster {
+	if c, ok := clusters.Load(store); ok {
+		return c.(*cluster)
+	}
+	return nil
+}
+
+// myAddr returns the address other nodes and clients reach us at.
+// Must be called while holding mu.
+func (c *cluster) myAddr() string {
+	port := replicationOf(c.store).port.Load()
+	return net.JoinHostPort(c.myIP, strconv.FormatInt(port, 10))
+}
+
+// addrOf returns the address of node. Must be called while holding mu.
+func (c *cluster) addrOf(node *clusterNode) string {
+	if node == c.myself {
+		return c.myAddr()
+	}
+	return node.addr
+}
+
+// forget drops a node from our view; gossip will not bring it back for a
+// minute, by which time the other nodes should have forgotten it too.
+func (c *cluster) forget(id string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	node, ok := c.nodes[id]
+	if !ok {
+		return fmt.Errorf("ERR Unknown node %s", id)
+	}
+	if node == c.myself {
+		return fmt.Errorf("ERR I tried hard but I can't forget myself...")
+	}
+	delete(c.nodes, id)
+	for slot, owner := range c.slots {
+		if owner == node {
+			c.slots[slot] = nil
+		}
+	}
+	for slot, other := range c.migrating {
+		if other == node {
+			delete(c.migrating, slot)
+		}
+	}
+	for slot, other := range c.importing {
+		if other == node {
+			delete(c.importing, slot)
+		}
+	}
+	c.forgotten[id] = time.Now().Add(clusterForgetTTL)
+	return nil
+}
+
+// known reports whether gossip may add or update node id.
+// Must be called while holding mu.
+func (c *cluster) known(id string) bool {
+	if until, ok := c.forgotten[id]; ok {
+		if time.Now().Before(until) {
+			return false
+		}
+		delete(c.forgotten, id)
+	}
+	return true
+}
+
+// route decides whether this node serves a command. It returns a
+// redirection or error reply, or nil and a function to call once the
+// command has run.
+func (c *cluster) route(client *clientConn, cmdName string, parts []string) ([]byte, func()) {
+	if cmdName == "MIGRATE" {
+		client.asking = false
+		// Taken before the replication gate, like the read lock in routeKeys
+		c.migrateMu.Lock()
+		return nil, c.migrateMu.Unlock
+	}
+	return c.routeKeys(client, cmdName, commandKeys(cmdName, parts))
+}
+
+// routeKeys is route for a command, or a whole transaction, that touches
+// keys. It takes the migration read lock at most once, so EXEC can hold it
+// for every queued command without reentering it.
+func (c *cluster) routeKeys(client *clientConn, cmdName string, keys []string) ([]byte, func()) {
+	asking := client.asking
+	if cmdName != "ASKING" {
+		client.asking = false
+	}
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	slot := core.KeySlot(keys[0])
+	for _, key := range keys[1:] {
+		if core.KeySlot(key) != slot {
+			return []byte("-CROSSSLOT Keys in request don't hash to the same slot\r\n"), nil
+		}
+	}
+
+	// Addresses change under mu as gossip arrives, so the ones a redirect
+	// may name are copied while it is held.
+	var ownerAddr, migratingAddr string
+	c.mu.RLock()
+	owner, migrating, importing := c.slots[slot], c.migrating[slot], c.importing[slot]
+	myself := c.myself
+	if owner != nil {
+		ownerAddr = c.addrOf(owner)
+	}
+	if migrating != nil {
+		migratingAddr = c.addrOf(migrating)
+	}
+	c.mu.RUnlock()
+
+	switch {
+	case owner == myself && migrating != nil && cmdName != "SPUBLISH" && cmdName != "SSUBSCRIBE":
+		// Keys that already moved are served by the target. Shard
+		// channels hold no keys and stay here until the slot moves.
+		c.migrateMu.RLock()
+		if found := c.store.Exists(keys...); found < len(keys) {
+			c.migrateMu.RUnlock()
+			if found > 0 {
+				return []byte("-TRYAGAIN Multiple keys request during rehashing of slot\r\n"), nil
+			}
+			return []byte(fmt.Sprintf("-ASK %d %s\r\n", slot, migratingAddr)), nil
+		}
+		return nil, c.migrateMu.RUnlock
+	case owner == myself:
+		return nil, nil
+	case importing != nil && (asking || cmdName == "RESTORE-ASKING"):
+		return nil, nil
+	case owner == nil:
+		return []byte("-CLUSTERDOWN Hash slot not served\r\n"), nil
+	}
+	return []byte(fmt.Sprintf("-MOVED %d %s\r\n", slot, ownerAddr)), nil
+}
+
+// gossipArgs describes this node to another:
+// id addr config-epoch current-epoch slots [id addr ...], where slots is a
+// list of ranges like 0-5460,5462 or "-" for none, followed by every other
+// node we know.
+func (c *cluster) gossipArgs() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	args := []string{c.myself.id, c.myAddr(), strconv.FormatInt(c.myself.epoch, 10),
+		strconv.FormatInt(c.currentEpoch, 10), formatSlots(c.slotsOf(c.myself))}
+	for _, node := range c.nodes {
+		if node != c.myself {
+			args = append(args, node.id, node.addr)
+		}
+	}
+	return args
+}
+
+// absorb updates our view from another node's gossipArgs. A node's claim
+// on a slot wins over a claim with a lower config epoch; a node that stops
+// claiming a slot we think it serves leaves it unassigned.
+func (c *cluster) absorb(args []string) error {
+	if len(args) < 5 || len(args)%2 == 0 {
+		return fmt.Errorf("ERR invalid gossip")
+	}
+	epoch, err1 := strconv.ParseInt(args[2], 10, 64)
+	currentEpoch, err2 := strconv.ParseInt(args[3], 10, 64)
+	claimed, err3 := parseSlots(args[4])
+	if err1 != nil || err2 != nil || err3 != nil {
+		return fmt.Errorf("ERR invalid gossip")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	id := args[0]
+	if id == c.myself.id || !c.known(id) {
+		return nil
+	}
+	node := c.nodes[id]
+	if node == nil {
+		node = &clusterNode{id: id}
+		c.nodes[id] = node
+		fmt.Printf("Cluster: node %s at %s joined\n", id, args[1])
+	}
+	node.addr, node.epoch, node.lastPong = args[1], epoch, time.Now()
+	c.currentEpoch = max(c.currentEpoch, currentEpoch, epoch)
+
+	// Two nodes never keep the same config epoch: the one with the smaller
+	// ID moves to a new one, so conflicting claims have a clear winner
+	if epoch == c.myself.epoch && c.myself.id < id {
+		c.currentEpoch++
+		c.myself.epoch = c.currentEpoch
+	}
+
+	isClaimed := make(map[int]bool, len(claimed))
+	for _, slot := range claimed {
+		isClaimed[slot] = true
+		if owner := c.slots[slot]; owner != node && (owner == nil || node.epoch > owner.epoch) {
+			if owner == c.myself {
+				fmt.Printf("Cluster: slot %d moved to %s\n", slot, id)
+			}
+			c.slots[slot] = node
+			if c.importing[slot] == node {
+				delete(c.importing, slot)
+			}
+		}
+	}
+	for slot, owner := range c.slots {
+		if owner == node && !isClaimed[slot] {
+			c.slots[slot] = nil
+		}
+	}
+
+	for i := 5; i+1 < len(args); i += 2 {
+		if _, ok := c.nodes[args[i]]; !ok && args[i] != c.myself.id && c.known(args[i]) {
+			c.nodes[args[i]] = &clusterNode{id: args[i], addr: args[i+1]}
+		}
+	}
+	return nil
+}
+
+// slotsOf returns the slots node serves, in order.
+// Must be called while holding mu.
+func (c *cluster) slotsOf(node *clusterNode) []int {
+	var slots []int
+	for slot, owner := range c.slots {
+		if owner == node {
+			slots = append(slots, slot)
+		}
+	}
+	return slots
+}
+
+// formatSlots renders sorted slots as ranges: "0-5460,5462", or "-".
+func formatSlots(slots []int) string {
+	if len(slots) == 0 {
+		return "-"
+	}
+	var ranges []string
+	for _, r := range slotRanges(slots) {
+		if r[0] == r[1] {
+			ranges = append(ranges, strconv.Itoa(r[0]))
+		} else {
+			ranges = append(ranges, fmt.Sprintf("%d-%d", r[0], r[1]))
+		}
+	}
+	return strings.Join(ranges, ",")
+}
+
+// slotRanges groups sorted slots into [start, end] ranges.
+func slotRanges(slots []int) [][2]int {
+	var ranges [][2]int
+	for _, slot := range slots {
+		if n := len(ranges); n > 0 && ranges[n-1][1] == slot-1 {
+			ranges[n-1][1] = slot
+		} else {
+			ranges = append(ranges, [2]int{slot, slot})
+		}
+	}
+	return ranges
+}
+
+// parseSlots is the inverse of formatSlots.
+func parseSlots(s string) ([]int, error) {
+	if s == "-" {
+		return nil, nil
+	}
+	var slots []int
+	for _, r := range strings.Split(s, ",") {
+		lo, hi, isRange := strings.Cut(r, "-")
+		start, err := parseSlot(lo)
+		if err != nil {
+			return nil, err
+		}
+		end := start
+		if isRange {
+			if end, err = parseSlot(hi); err != nil {
+				return nil, err
+			}
+		}
+		for slot := start; slot <= end; slot++ {
+			slots = append(slots, slot)
+		}
+	}
+	return slots, nil
+}
+
+// parseSlot parses a slot number.
+func parseSlot(s string) (int, error) {
+	slot, err := strconv.Atoi(s)
+	if err != nil || slot < 0 || slot >= core.SlotCount {
+		return 0, fmt.Errorf("ERR Invalid or out of range slot")
+	}
+	return slot, nil
+}
+
+// gossipLoop exchanges state with every known node each period, and
+// whenever kicked after a local change.
+func (c *cluster) gossipLoop() {
+	<-replicationOf(c.store).listening
+	ticker := time.NewTicker(clusterGossipPeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-c.kick:
+		}
+		c.mu.RLock()
+		nodes := make([]*clusterNode, 0, len(c.nodes))
+		for _, node := range c.nodes {
+			if node != c.myself {
+				nodes = append(nodes, node)
+			}
+		}
+		c.mu.RUnlock()
+
+		args := append([]string{"CLUSTER", "GOSSIP"}, c.gossipArgs()...)
+		var wg sync.WaitGroup
+		for _, node := range nodes {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				c.gossipWith(node, args)
+			}()
+		}
+		wg.Wait()
+	}
+}
+
+// kickGossip makes the gossip loop spread a local change right away.
+func (c *cluster) kickGossip() {
+	select {
+	case c.kick <- struct{}{}:
+	default:
+	}
+}
+
+// gossipWith sends our state to node and absorbs its reply.
+func (c *cluster) gossipWith(node *clusterNode, args []string) {
+	c.mu.RLock()
+	addr := node.addr
+	c.mu.RUnlock()
+	conn, err := net.DialTimeout("tcp", addr, clusterGossipPeriod)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	if reply, err := exchangeGossip(conn, bufio.NewReader(conn), args); err == nil {
+		c.absorb(reply)
+	}
+}
+
+// exchangeGossip sends a CLUSTER GOSSIP or MEET command and reads the
+// other node's gossipArgs.
+func exchangeGossip(conn net.Conn, reader *bufio.Reader, args []string) ([]string, error) {
+	conn.SetDeadline(time.Now().Add(clusterGossipPeriod))
+	if _, err := conn.Write(bulkStringArray(args)); err != nil {
+		return nil, err
+	}
+	if peek, err := reader.Peek(1); err != nil {
+		return nil, err
+	} else if peek[0] == '-' {
+		line, _ := readLine(reader)
+		return nil, fmt.Errorf("%s", line[1:])
+	}
+	return ParseRESP(reader)
+}
+
+// meet introduces this node to the node at addr.
+func (c *cluster) meet(addr string) error {
+	conn, err := net.DialTimeout("tcp", addr, clusterGossipPeriod)
+	if err != nil {
+		return fmt.Errorf("ERR Invalid node address specified: %s", addr)
+	}
+	defer conn.Close()
+	c.learnIP(conn)
+	reply, err := exchangeGossip(conn, bufio.NewReader(conn), append([]string{"CLUSTER", "GOSSIP"}, c.gossipArgs()...))
+	if err != nil {
+		return fmt.Errorf("ERR %s: %v", addr, err)
+	}
+	if err := c.absorb(reply); err != nil {
+		return err
+	}
+	c.kickGossip()
+	return nil
+}
+
+// learnIP records the IP other nodes reach us at, from a connection
+// between us.
+func (c *cluster) learnIP(conn net.Conn) {
+	if tcp, ok := conn.LocalAddr().(*net.TCPAddr); ok {
+		c.mu.Lock()
+		c.myIP = tcp.IP.String()
+		c.mu.Unlock()
+	}
+}
+
+// nodesInfo renders CLUSTER NODES.
+func (c *cluster) nodesInfo() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	nodes := make([]*clusterNode, 0, len(c.nodes))
+	for _, node := range c.nodes {
+		nodes = append(nodes, node)
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].id < nodes[j].id })
+
+	var b strings.Builder
+	for _, node := range nodes {
+		addr, flags, pong := node.addr, "master", node.lastPong.UnixMilli()
+		if node == c.myself {
+			addr, flags, pong = c.myAddr(), "myself,master", 0
+		} else if time.Since(node.lastPong) > clusterNodeTimeout {
+			flags += ",fail?"
+		}
+		link := "connected"
+		if node != c.myself && time.Since(node.lastPong) > 2*clusterGossipPeriod {
+			link = "disconnected"
+		}
+		_, port, _ := net.SplitHostPort(addr)
+		fmt.Fprintf(&b, "%s %s@%s %s - 0 %d %d %s", node.id, addr, port, flags, max(pong, 0), node.epoch, link)
+		for _, r := range slotRanges(c.slotsOf(node)) {
+			if r[0] == r[1] {
+				fmt.Fprintf(&b, " %d", r[0])
+			} else {
+				fmt.Fprintf(&b, " %d-%d", r[0], r[1])
+			}
+		}
+		if node == c.myself {
+			for _, slot := range sortedSlots(c.migrating) {
+				fmt.Fprintf(&b, " [%d->-%s]", slot, c.migrating[slot].id)
+			}
+			for _, slot := range sortedSlots(c.importing) {
+				fmt.Fprintf(&b, " [%d-<-%s]", slot, c.importing[slot].id)
+			}
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+func sortedSlots(m map[int]*clusterNode) []int {
+	slots := make([]int, 0, len(m))
+	for slot := range m {
+		slots = append(slots, slot)
+	}
+	sort.Ints(slots)
+	return slots
+}
@@ -0,0 +1,229 @@
+package server
+
+import (
+	"bufio"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/Syed-Suhaan/SusyDB/pkg/core"
+)
+
+func TestCommandKeys(t *testing.T) {
+	tests := []struct {
+		parts []string
+		want  string
+	}{
+		{[]string{"GET", "k"}, "k"},
+		{[]string{"BITOP", "AND", "dest", "a", "b"}, "dest a b"},
+		{[]string{"PFCOUNT", "a", "b"}, "a b"},
+		{[]string{"TS.MADD", "a", "1", "1", "b", "2", "2"}, "a b"},
+		{[]string{"TS.CREATERULE", "src", "dst", "AGGREGATION", "avg", "10"}, "src dst"},
+		{[]string{"PING"}, ""},
+		{[]string{"GET"}, ""},
+	}
+	for _, tt := range tests {
+		if got := strings.Join(commandKeys(tt.parts[0], tt.parts), " "); got != tt.want {
+			t.Errorf("commandKeys(%v) = %q, want %q", tt.parts, got, tt.want)
+		}
+	}
+}
+
+func TestSlotRanges(t *testing.T) {
+	slots, err := parseSlots("0-2,5,7-8")
+	if err != nil {
+		t.Fatalf("parseSlots() error = %v", err)
+	}
+	if got := formatSlots(slots); got != "0-2,5,7-8" {
+		t.Errorf("formatSlots(parseSlots()) = %q, want %q", got, "0-2,5,7-8")
+	}
+	if got := formatSlots(nil); got != "-" {
+		t.Errorf("formatSlots(nil) = %q, want -", got)
+	}
+	if _, err := parseSlots("16384"); err == nil {
+		t.Errorf("parseSlots(16384) error = nil, want out of range")
+	}
+}
+
+// clusterNodeT is a cluster node started for a test.
+type clusterNodeT struct {
+	store  *core.KVStore
+	addr   string
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// do sends a command and returns the first line of the reply.
+func (n *clusterNodeT) do(t *testing.T, args ...string) string {
+	t.Helper()
+	n.conn.Write(bulkStringArray(args))
+	line, err := n.reader.ReadString('\n')
+	if err != nil {
+		t.Fatalf("%v: %v", args, err)
+	}
+	if strings.HasPrefix(line, "$") && line != "$-1\r\n" {
+		value, _ := n.reader.ReadString('\n')
+		return value
+	}
+	return line
+}
+
+func (n *clusterNodeT) id() string { return clusterOf(n.store).myself.id }
+
+// startCluster starts three nodes splitting the slots evenly and waits
+// until they all agree on the layout.
+func startCluster(t *testing.T) []*clusterNodeT {
+	t.Helper()
+	ranges := [][2]string{{"0", "5460"}, {"5461", "10922"}, {"10923", "16383"}}
+	nodes := make([]*clusterNodeT, 3)
+	for i := range nodes {
+		store, addr := startServer(t)
+		EnableCluster(store)
+		conn, reader := dialServer(t, addr)
+		nodes[i] = &clusterNodeT{store: store, addr: addr, conn: conn, reader: reader}
+		if got := nodes[i].do(t, "CLUSTER", "ADDSLOTSRANGE", ranges[i][0], ranges[i][1]); got != "+OK\r\n" {
+			t.Fatalf("CLUSTER ADDSLOTSRANGE = %q, want +OK", got)
+		}
+	}
+	for _, n := range nodes[1:] {
+		host, port, _ := net.SplitHostPort(n.addr)
+		if got := nodes[0].do(t, "CLUSTER", "MEET", host, port); got != "+OK\r\n" {
+			t.Fatalf("CLUSTER MEET = %q, want +OK", got)
+		}
+	}
+	for _, n := range nodes {
+		waitFor(t, "the cluster to converge", func() bool {
+			info := clusterOf(n.store).info()
+			return strings.Contains(info, "cluster_state:ok") && strings.Contains(info, "cluster_known_nodes:3")
+		})
+	}
+	return nodes
+}
+
+func TestClusterRedirects(t *testing.T) {
+	nodes := startCluster(t)
+	a, c := nodes[0], nodes[2]
+
+	if got := a.do(t, "CLUSTER", "KEYSLOT", "foo"); got != ":12182\r\n" {
+		t.Errorf("CLUSTER KEYSLOT foo = %q, want :12182", got)
+	}
+	if got := a.do(t, "SET", "foo", "bar"); got != "-MOVED 12182 "+c.addr+"\r\n" {
+		t.Errorf("SET foo on the wrong node = %q, want MOVED to %s", got, c.addr)
+	}
+	if got := c.do(t, "SET", "foo", "bar"); got != "+OK\r\n" {
+		t.Errorf("SET foo on its node = %q, want +OK", got)
+	}
+	if got := c.do(t, "PFCOUNT", "foo", "bar"); !strings.HasPrefix(got, "-CROSSSLOT") {
+		t.Errorf("PFCOUNT across slots = %q, want CROSSSLOT", got)
+	}
+	if got := c.do(t, "PFCOUNT", "{foo}.a", "{foo}.b"); got != ":0\r\n" {
+		t.Errorf("PFCOUNT with a shared hashtag = %q, want :0", got)
+	}
+
+	// A transaction's keys must share a slot even when each is served here
+	var other string
+	for i := 0; ; i++ {
+		other = "k" + strconv.Itoa(i)
+		if slot := core.KeySlot(other); slot >= 10923 && slot != 12182 {
+			break
+		}
+	}
+	for _, cmd := range [][]string{{"MULTI"}, {"SET", "foo", "1"}, {"SET", other, "1"}} {
+		c.do(t, cmd...)
+	}
+	if got := c.do(t, "EXEC"); !strings.HasPrefix(got, "-CROSSSLOT") {
+		t.Errorf("EXEC across slots = %q, want CROSSSLOT", got)
+	}
+	if got := c.do(t, "GET", "foo"); got != "bar\r\n" {
+		t.Errorf("GET foo after a refused EXEC = %q, want bar", got)
+	}
+
+	if got := a.do(t, "PING"); got != "+PONG\r\n" {
+		t.Errorf("PING = %q, want +PONG", got)
+	}
+
+	a.conn.Write(bulkStringArray([]string{"CLUSTER", "SLOTS"}))
+	reply, err := readReplyLines(a.reader, 1+3*7)
+	if err != nil {
+		t.Fatalf("CLUSTER SLOTS: %v", err)
+	}
+	_, port, _ := net.SplitHostPort(c.addr)
+	if !strings.Contains(reply, ":10923\r\n:16383\r\n*3\r\n$9\r\n127.0.0.1\r\n:"+port+"\r\n") {
+		t.Errorf("CLUSTER SLOTS = %q, want the last range on %s", reply, c.addr)
+	}
+}
+
+// readReplyLines reads n lines of a reply, bulk string bodies included.
+func readReplyLines(reader *bufio.Reader, n int) (string, error) {
+	var b strings.Builder
+	for i := 0; i < n; i++ {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return b.String(), err
+		}
+		b.WriteString(line)
+		if strings.HasPrefix(line, "$") {
+			n++
+		}
+	}
+	return b.String(), nil
+}
+
+func TestClusterMigration(t *testing.T) {
+	nodes := startCluster(t)
+	a, b, c := nodes[0], nodes[1], nodes[2]
+	const slot = "3300" // "b" and "{b}x", served by a
+	a.do(t, "SET", "b", "1")
+	a.do(t, "SET", "{b}x", "2")
+
+	if got := b.do(t, "CLUSTER", "SETSLOT", slot, "IMPORTING", a.id()); got != "+OK\r\n" {
+		t.Fatalf("SETSLOT IMPORTING = %q", got)
+	}
+	if got := a.do(t, "CLUSTER", "SETSLOT", slot, "MIGRATING", b.id()); got != "+OK\r\n" {
+		t.Fatalf("SETSLOT MIGRATING = %q", got)
+	}
+	host, port, _ := net.SplitHostPort(b.addr)
+	if got := a.do(t, "MIGRATE", host, port, "", "0", "1000", "KEYS", "b"); got != "+OK\r\n" {
+		t.Fatalf("MIGRATE = %q, want +OK", got)
+	}
+
+	// Moved keys are asked for on the target; the rest are still here
+	if got := a.do(t, "GET", "b"); got != "-ASK "+slot+" "+b.addr+"\r\n" {
+		t.Errorf("GET b after MIGRATE = %q, want ASK to %s", got, b.addr)
+	}
+	if got := a.do(t, "GET", "{b}x"); got != "2\r\n" {
+		t.Errorf("GET {b}x = %q, want 2", got)
+	}
+	if got := b.do(t, "GET", "b"); got != "-MOVED "+slot+" "+a.addr+"\r\n" {
+		t.Errorf("GET b on the target without ASKING = %q, want MOVED to %s", got, a.addr)
+	}
+	b.do(t, "ASKING")
+	if got := b.do(t, "GET", "b"); got != "1\r\n" {
+		t.Errorf("GET b after ASKING = %q, want 1", got)
+	}
+
+	if got := a.do(t, "MIGRATE", host, port, "{b}x", "0", "1000"); got != "+OK\r\n" {
+		t.Fatalf("MIGRATE {b}x = %q, want +OK", got)
+	}
+	if got := a.do(t, "MIGRATE", host, port, "{b}x", "0", "1000"); got != "+NOKEY\r\n" {
+		t.Errorf("MIGRATE of a moved key = %q, want +NOKEY", got)
+	}
+	for _, n := range []*clusterNodeT{b, a} {
+		if got := n.do(t, "CLUSTER", "SETSLOT", slot, "NODE", b.id()); got != "+OK\r\n" {
+			t.Fatalf("SETSLOT NODE = %q", got)
+		}
+	}
+	if got := b.do(t, "CLUSTER", "COUNTKEYSINSLOT", slot); got != ":2\r\n" {
+		t.Errorf("COUNTKEYSINSLOT on the target = %q, want :2", got)
+	}
+	waitFor(t, "the new owner to spread", func() bool {
+		return c.do(t, "GET", "b") == "-MOVED "+slot+" "+b.addr+"\r\n"
+	})
+	if got := b.do(t, "GET", "{b}x"); got != "2\r\n" {
+		t.Errorf("GET {b}x on the new owner = %q, want 2", got)
+	}
+	if !strings.Contains(clusterOf(a.store).nodesInfo(), b.id()+" "+b.addr+"@"+port+" master - 0") {
+		t.Errorf("CLUSTER NODES = %q, want %s listed", clusterOf(a.store).nodesInfo(), b.id())
+	}
+}
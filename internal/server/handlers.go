@@ -12,18 +12,100 @@ type CommandHandler func(conn net.Conn, store *core.KVStore, parts []string) []b
 
 // Handlers map maps command strings to their handler functions.
 var Handlers = map[string]CommandHandler{
-	"SET":       handleSet,
-	"SETEX":     handleSetEx,
-	"GET":       handleGet,
-	"INCR":      handleIncr,
-	"INCRBY":    handleIncrBy,
-	"HSET":      handleHSet,
-	"HGET":      handleHGet,
-	"HGETALL":   handleHGetAll,
-	"HDEL":      handleHDel,
-	"DEL":       handleDel,
-	"INFO":      handleInfo,
-	"PING":      handlePing,
-	"PUBLISH":   handlePublish,
-	"SUBSCRIBE": handleSubscribe,
+	"SET":      handleSet,
+	"SETEX":    handleSetEx,
+	"GET":      handleGet,
+	"MGET":     handleMGet,
+	"MSET":     handleMSet,
+	"INCR":     handleIncr,
+	"INCRBY":   handleIncrBy,
+	"HSET":     handleHSet,
+	"HGET":     handleHGet,
+	"HGETALL":  handleHGetAll,
+	"HDEL":     handleHDel,
+	"DEL":      handleDel,
+	"TYPE":     handleType,
+	"SCAN":     handleScan,
+	"SETBIT":   handleSetBit,
+	"GETBIT":   handleGetBit,
+	"BITCOUNT": handleBitCount,
+	"BITPOS":   handleBitPos,
+	"BITOP":    handleBitOp,
+	"BITFIELD": handleBitField,
+	"PFADD":    handlePFAdd,
+	"PFCOUNT":  handlePFCount,
+	"PFMERGE":  handlePFMerge,
+
+	"BF.RESERVE":     handleBFReserve,
+	"BF.ADD":         handleBFAdd,
+	"BF.MADD":        handleBFMAdd,
+	"BF.EXISTS":      handleBFExists,
+	"BF.MEXISTS":     handleBFMExists,
+	"CF.RESERVE":     handleCFReserve,
+	"CF.ADD":         handleCFAdd,
+	"CF.ADDNX":       handleCFAddNX,
+	"CF.DEL":         handleCFDel,
+	"CF.EXISTS":      handleCFExists,
+	"CMS.INITBYDIM":  handleCMSInitByDim,
+	"CMS.INITBYPROB": handleCMSInitByProb,
+	"CMS.INCRBY":     handleCMSIncrBy,
+	"CMS.QUERY":      handleCMSQuery,
+
+	"GEOADD":    handleGeoAdd,
+	"GEOPOS":    handleGeoPos,
+	"GEODIST":   handleGeoDist,
+	"GEOHASH":   handleGeoHash,
+	"GEOSEARCH": handleGeoSearch,
+
+	"JSON.SET":       handleJSONSet,
+	"JSON.GET":       handleJSONGet,
+	"JSON.DEL":       handleJSONDel,
+	"JSON.NUMINCRBY": handleJSONNumIncrBy,
+	"JSON.ARRAPPEND": handleJSONArrAppend,
+
+	"TS.CREATE":     handleTSCreate,
+	"TS.ADD":        handleTSAdd,
+	"TS.MADD":       handleTSMAdd,
+	"TS.RANGE":      handleTSRange,
+	"TS.CREATERULE": handleTSCreateRule,
+
+	"FT.CREATE":    handleFTCreate,
+	"FT.DROPINDEX": handleFTDropIndex,
+	"FT.SEARCH":    handleFTSearch,
+
+	"CONFIG":       handleConfig,
+	"INFO":         handleInfo,
+	"PING":         handlePing,
+	"QUIT":         handleQuit,
+	"RESET":        handleReset,
+	"PUBLISH":      handlePublish,
+	"SPUBLISH":     handleSPublish,
+	"PUBSUB":       handlePubSub,
+	"RETAIN":       handleRetain,
+	"RSUBSCRIBE":   handleRSubscribe,
+	"SUBSCRIBE":    handleSubscribe,
+	"UNSUBSCRIBE":  handleUnsubscribe,
+	"PSUBSCRIBE":   handlePSubscribe,
+	"PUNSUBSCRIBE": handleUnsubscribe,
+	"SSUBSCRIBE":   handleSSubscribe,
+	"SUNSUBSCRIBE": handleUnsubscribe,
+
+	"MULTI":   handleMulti,
+	"EXEC":    handleExec,
+	"DISCARD": handleDiscard,
+
+	"REPLICAOF": handleReplicaOf,
+	"SLAVEOF":   handleReplicaOf,
+	"REPLCONF":  handleReplConf,
+	"SYNC":      handleSync,
+	"PSYNC":     handlePSync,
+
+	"CLUSTER":        handleCluster,
+	"ASKING":         handleAsking,
+	"DUMP":           handleDump,
+	"RESTORE":        handleRestore,
+	"RESTORE-ASKING": handleRestore,
+	"MIGRATE":        handleMigrate,
+
+	"RAFT": handleRaft,
 }
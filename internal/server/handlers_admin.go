@@ -1,17 +1,168 @@
 package server
 
 import (
+	"errors"
 	"fmt"
 	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/Syed-Suhaan/SusyDB/pkg/core"
 )
 
+// handleInfo implements INFO [section ...]. Without a section every
+// section is returned.
 func handleInfo(conn net.Conn, store *core.KVStore, parts []string) []byte {
-	info := store.Info()
+	info := store.Info() + "\r\n" + replicationOf(store).info()
+	if rs := raftOf(store); rs != nil {
+		info += "\r\n" + rs.info()
+	}
+	if len(parts) > 1 && !strings.EqualFold(parts[1], "all") && !strings.EqualFold(parts[1], "everything") {
+		var selected []string
+		for _, section := range strings.Split(info, "\r\n\r\n") {
+			name, _, _ := strings.Cut(strings.TrimPrefix(section, "# "), "\r\n")
+			for _, want := range parts[1:] {
+				if strings.EqualFold(name, want) {
+					selected = append(selected, strings.TrimSuffix(section, "\r\n")+"\r\n")
+					break
+				}
+			}
+		}
+		info = strings.Join(selected, "\r\n")
+	}
 	return []byte(fmt.Sprintf("$%d\r\n%s\r\n", len(info), info))
 }
 
 func handlePing(conn net.Conn, store *core.KVStore, parts []string) []byte {
 	return []byte("+PONG\r\n")
 }
+
+// handleQuit replies OK and closes the connection.
+func handleQuit(conn net.Conn, store *core.KVStore, parts []string) []byte {
+	conn.Write([]byte("+OK\r\n"))
+	conn.Close()
+	return nil
+}
+
+// handleReset returns the connection to its default state, dropping a
+// transaction in progress. Subscribed mode is left in subscribedCommand.
+func handleReset(conn net.Conn, store *core.KVStore, parts []string) []byte {
+	if cc, ok := conn.(*clientConn); ok {
+		cc.discardTx()
+	}
+	return []byte("+RESET\r\n")
+}
+
+// configParam is a setting exposed through CONFIG GET and CONFIG SET.
+type configParam struct {
+	get func(store *core.KVStore) string
+	set func(store *core.KVStore, value string) error
+}
+
+var configParams = map[string]configParam{
+	"notify-keyspace-events": {
+		get: (*core.KVStore).NotifyKeyspaceEvents,
+		set: (*core.KVStore).SetNotifyKeyspaceEvents,
+	},
+	"replica-read-only": {
+		get: func(store *core.KVStore) string {
+			if replicationOf(store).writable.Load() {
+				return "no"
+			}
+			return "yes"
+		},
+		set: func(store *core.KVStore, value string) error {
+			switch strings.ToLower(value) {
+			case "yes":
+				replicationOf(store).writable.Store(false)
+			case "no":
+				replicationOf(store).writable.Store(true)
+			default:
+				return errors.New("ERR argument must be 'yes' or 'no'")
+			}
+			return nil
+		},
+	},
+	"repl-backlog-size": {
+		get: func(store *core.KVStore) string {
+			r := replicationOf(store)
+			r.gate.RLock()
+			defer r.gate.RUnlock()
+			return strconv.Itoa(r.backlogSize)
+		},
+		set: func(store *core.KVStore, value string) error {
+			size, err := strconv.Atoi(value)
+			if err != nil || size <= 0 {
+				return errors.New("ERR repl-backlog-size must be a positive number of bytes")
+			}
+			replicationOf(store).setBacklogSize(size)
+			return nil
+		},
+	},
+	"repl-backlog-ttl": {
+		get: func(store *core.KVStore) string {
+			r := replicationOf(store)
+			r.gate.RLock()
+			defer r.gate.RUnlock()
+			return strconv.Itoa(int(r.backlogTTL / time.Second))
+		},
+		set: func(store *core.KVStore, value string) error {
+			seconds, err := strconv.Atoi(value)
+			if err != nil || seconds < 0 {
+				return errors.New("ERR repl-backlog-ttl must be a non-negative number of seconds")
+			}
+			r := replicationOf(store)
+			r.gate.Lock()
+			r.backlogTTL = time.Duration(seconds) * time.Second
+			r.gate.Unlock()
+			if seconds > 0 {
+				time.AfterFunc(r.backlogTTL, r.expireBacklog)
+			}
+			return nil
+		},
+	},
+}
+
+// handleConfig implements CONFIG GET pattern [pattern ...] and
+// CONFIG SET parameter value.
+func handleConfig(conn net.Conn, store *core.KVStore, parts []string) []byte {
+	if len(parts) < 2 {
+		return []byte("-ERR wrong number of arguments for 'config' command\r\n")
+	}
+	switch strings.ToUpper(parts[1]) {
+	case "GET":
+		if len(parts) < 3 {
+			return []byte("-ERR wrong number of arguments for 'config|get' command\r\n")
+		}
+		var names []string
+		for name := range configParams {
+			for _, pattern := range parts[2:] {
+				if core.GlobMatch(strings.ToLower(pattern), name) {
+					names = append(names, name)
+					break
+				}
+			}
+		}
+		sort.Strings(names)
+		values := make([]string, 0, 2*len(names))
+		for _, name := range names {
+			values = append(values, name, configParams[name].get(store))
+		}
+		return bulkStringArray(values)
+	case "SET":
+		if len(parts) != 4 {
+			return []byte("-ERR wrong number of arguments for 'config|set' command\r\n")
+		}
+		param, ok := configParams[strings.ToLower(parts[2])]
+		if !ok {
+			return []byte("-ERR Unknown option or number of arguments for CONFIG SET - '" + parts[2] + "'\r\n")
+		}
+		if err := param.set(store, parts[3]); err != nil {
+			return errorReply(err)
+		}
+		return []byte("+OK\r\n")
+	}
+	return []byte("-ERR unknown subcommand '" + parts[1] + "'\r\n")
+}
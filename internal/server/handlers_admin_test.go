@@ -0,0 +1,26 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/Syed-Suhaan/SusyDB/pkg/core"
+)
+
+func TestConfigKeyspaceEvents(t *testing.T) {
+	store := core.NewKVStore()
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"CONFIG", "SET", "notify-keyspace-events", "KEg$"}, "+OK\r\n"},
+		{[]string{"CONFIG", "GET", "notify-*"}, "*2\r\n$22\r\nnotify-keyspace-events\r\n$4\r\ng$KE\r\n"},
+		{[]string{"CONFIG", "GET", "nope"}, "*0\r\n"},
+		{[]string{"CONFIG", "SET", "notify-keyspace-events", "Z"}, "-ERR Invalid event class character 'Z'\r\n"},
+		{[]string{"CONFIG", "SET", "nope", "1"}, "-ERR Unknown option or number of arguments for CONFIG SET - 'nope'\r\n"},
+	}
+	for _, tt := range tests {
+		if got := string(handleConfig(nil, store, tt.args)); got != tt.want {
+			t.Errorf("%v = %q, want %q", tt.args, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,259 @@
+package server
+
+import (
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/Syed-Suhaan/SusyDB/pkg/core"
+)
+
+func handleSetBit(conn net.Conn, store *core.KVStore, parts []string) []byte {
+	if len(parts) != 4 {
+		return []byte("-ERR wrong number of arguments for 'setbit' command\r\n")
+	}
+	offset, err := strconv.ParseUint(parts[2], 10, 64)
+	if err != nil {
+		return errorReply(core.ErrBitOffset)
+	}
+	value, err := strconv.Atoi(parts[3])
+	if err != nil {
+		return errorReply(core.ErrBitValue)
+	}
+	old, err := store.SetBit(parts[1], offset, value)
+	if err != nil {
+		return errorReply(err)
+	}
+	return integerReply(int64(old))
+}
+
+func handleGetBit(conn net.Conn, store *core.KVStore, parts []string) []byte {
+	if len(parts) != 3 {
+		return []byte("-ERR wrong number of arguments for 'getbit' command\r\n")
+	}
+	offset, err := strconv.ParseUint(parts[2], 10, 64)
+	if err != nil {
+		return errorReply(core.ErrBitOffset)
+	}
+	bit, err := store.GetBit(parts[1], offset)
+	if err != nil {
+		return errorReply(err)
+	}
+	return integerReply(int64(bit))
+}
+
+func handleBitCount(conn net.Conn, store *core.KVStore, parts []string) []byte {
+	if len(parts) < 2 {
+		return []byte("-ERR wrong number of arguments for 'bitcount' command\r\n")
+	}
+	var r *core.BitRange
+	switch len(parts) {
+	case 2:
+	case 4, 5:
+		var ok bool
+		r, ok = parseBitRange(parts[2:])
+		if !ok {
+			return []byte("-ERR syntax error\r\n")
+		}
+	default:
+		return []byte("-ERR syntax error\r\n")
+	}
+	count, err := store.BitCount(parts[1], r)
+	if err != nil {
+		return errorReply(err)
+	}
+	return integerReply(count)
+}
+
+func handleBitPos(conn net.Conn, store *core.KVStore, parts []string) []byte {
+	if len(parts) < 3 || len(parts) > 6 {
+		return []byte("-ERR wrong number of arguments for 'bitpos' command\r\n")
+	}
+	bit, err := strconv.Atoi(parts[2])
+	if err != nil {
+		return []byte("-ERR value is not an integer or out of range\r\n")
+	}
+	var r *core.BitRange
+	if len(parts) == 4 {
+		start, err := strconv.ParseInt(parts[3], 10, 64)
+		if err != nil {
+			return []byte("-ERR value is not an integer or out of range\r\n")
+		}
+		r = &core.BitRange{Start: start, End: -1, NoEnd: true}
+	} else if len(parts) > 4 {
+		var ok bool
+		r, ok = parseBitRange(parts[3:])
+		if !ok {
+			return []byte("-ERR syntax error\r\n")
+		}
+	}
+	pos, err := store.BitPos(parts[1], bit, r)
+	if err != nil {
+		return errorReply(err)
+	}
+	return integerReply(pos)
+}
+
+// parseBitRange parses "start end [BYTE|BIT]".
+func parseBitRange(args []string) (*core.BitRange, bool) {
+	start, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		return nil, false
+	}
+	end, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		return nil, false
+	}
+	r := &core.BitRange{Start: start, End: end}
+	if len(args) == 3 {
+		switch strings.ToUpper(args[2]) {
+		case "BYTE":
+		case "BIT":
+			r.BitMode = true
+		default:
+			return nil, false
+		}
+	}
+	return r, true
+}
+
+func handleBitOp(conn net.Conn, store *core.KVStore, parts []string) []byte {
+	if len(parts) < 4 {
+		return []byte("-ERR wrong number of arguments for 'bitop' command\r\n")
+	}
+	var op core.BitOperation
+	switch strings.ToUpper(parts[1]) {
+	case "AND":
+		op = core.BitAnd
+	case "OR":
+		op = core.BitOr
+	case "XOR":
+		op = core.BitXor
+	case "NOT":
+		op = core.BitNot
+	default:
+		return []byte("-ERR syntax error\r\n")
+	}
+	length, err := store.BitOp(op, parts[2], parts[3:]...)
+	if err != nil {
+		return errorReply(err)
+	}
+	return integerReply(length)
+}
+
+func handleBitField(conn net.Conn, store *core.KVStore, parts []string) []byte {
+	if len(parts) < 2 {
+		return []byte("-ERR wrong number of arguments for 'bitfield' command\r\n")
+	}
+
+	var ops []core.BitFieldOp
+	overflow := core.OverflowWrap
+	for i := 2; i < len(parts); {
+		sub := strings.ToUpper(parts[i])
+		switch sub {
+		case "OVERFLOW":
+			if i+1 >= len(parts) {
+				return []byte("-ERR syntax error\r\n")
+			}
+			switch strings.ToUpper(parts[i+1]) {
+			case "WRAP":
+				overflow = core.OverflowWrap
+			case "SAT":
+				overflow = core.OverflowSat
+			case "FAIL":
+				overflow = core.OverflowFail
+			default:
+				return []byte("-ERR Invalid OVERFLOW type specified\r\n")
+			}
+			i += 2
+			continue
+		case "GET", "SET", "INCRBY":
+		default:
+			return []byte("-ERR syntax error\r\n")
+		}
+
+		argc := 3
+		if sub == "GET" {
+			argc = 2
+		}
+		if i+argc >= len(parts) {
+			return []byte("-ERR syntax error\r\n")
+		}
+
+		signed, width, ok := parseBitFieldType(parts[i+1])
+		if !ok {
+			return errorReply(core.ErrBitFieldType)
+		}
+		offset, ok := parseBitFieldOffset(parts[i+2], width)
+		if !ok {
+			return errorReply(core.ErrBitOffset)
+		}
+		op := core.BitFieldOp{Signed: signed, Width: width, Offset: offset, Overflow: overflow}
+		switch sub {
+		case "GET":
+			op.Kind = core.BitFieldGet
+		case "SET":
+			op.Kind = core.BitFieldSet
+		case "INCRBY":
+			op.Kind = core.BitFieldIncrBy
+		}
+		if sub != "GET" {
+			value, err := strconv.ParseInt(parts[i+3], 10, 64)
+			if err != nil {
+				return []byte("-ERR value is not an integer or out of range\r\n")
+			}
+			op.Value = value
+		}
+		ops = append(ops, op)
+		i += argc + 1
+	}
+
+	results, err := store.BitField(parts[1], ops)
+	if err != nil {
+		return errorReply(err)
+	}
+	buf := appendArrayHeader(nil, len(results))
+	for _, res := range results {
+		if res.Nil {
+			buf = append(buf, "$-1\r\n"...)
+		} else {
+			buf = appendInteger(buf, res.Value)
+		}
+	}
+	return buf
+}
+
+// parseBitFieldType parses integer types such as "i16" or "u8".
+func parseBitFieldType(s string) (signed bool, width int, ok bool) {
+	if len(s) < 2 {
+		return false, 0, false
+	}
+	switch s[0] {
+	case 'i', 'I':
+		signed = true
+	case 'u', 'U':
+	default:
+		return false, 0, false
+	}
+	width, err := strconv.Atoi(s[1:])
+	if err != nil || width < 1 || width > 64 || (!signed && width > 63) {
+		return false, 0, false
+	}
+	return signed, width, true
+}
+
+// parseBitFieldOffset parses a bit offset, where "#N" means N*width.
+func parseBitFieldOffset(s string, width int) (uint64, bool) {
+	multiply := strings.HasPrefix(s, "#")
+	if multiply {
+		s = s[1:]
+	}
+	offset, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	if multiply {
+		offset *= uint64(width)
+	}
+	return offset, offset <= core.MaxBitOffset
+}
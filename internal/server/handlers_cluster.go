@@ -0,0 +1,446 @@
+package server
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Syed-Suhaan/SusyDB/pkg/core"
+)
+
+var errClusterDisabled = errors.New("ERR This instance has cluster support disabled")
+
+// handleCluster implements the CLUSTER subcommands.
+func handleCluster(conn net.Conn, store *core.KVStore, parts []string) []byte {
+	if len(parts) < 2 {
+		return []byte("-ERR wrong number of arguments for 'cluster' command\r\n")
+	}
+	c := clusterOf(store)
+	if c == nil {
+		return errorReply(errClusterDisabled)
+	}
+	sub, args := strings.ToUpper(parts[1]), parts[2:]
+	switch sub {
+	case "INFO":
+		return bulkString(c.info())
+	case "MYID":
+		return bulkString(c.myself.id)
+	case "NODES":
+		return bulkString(c.nodesInfo())
+	case "SLOTS":
+		return c.slotsReply()
+	case "KEYSLOT":
+		if len(args) != 1 {
+			break
+		}
+		return integerReply(int64(core.KeySlot(args[0])))
+	case "COUNTKEYSINSLOT":
+		if len(args) != 1 {
+			break
+		}
+		slot, err := parseSlot(args[0])
+		if err != nil {
+			return errorReply(err)
+		}
+		return integerReply(int64(store.CountKeysInSlot(slot)))
+	case "GETKEYSINSLOT":
+		if len(args) != 2 {
+			break
+		}
+		slot, err := parseSlot(args[0])
+		if err != nil {
+			return errorReply(err)
+		}
+		count, err := strconv.Atoi(args[1])
+		if err != nil || count < 0 {
+			return []byte("-ERR Invalid number of keys\r\n")
+		}
+		return bulkStringArray(store.KeysInSlot(slot, count))
+	case "ADDSLOTS", "DELSLOTS", "ADDSLOTSRANGE", "DELSLOTSRANGE":
+		if len(args) == 0 || (strings.HasSuffix(sub, "RANGE") && len(args)%2 != 0) {
+			break
+		}
+		slots, err := parseSlotArgs(args, strings.HasSuffix(sub, "RANGE"))
+		if err != nil {
+			return errorReply(err)
+		}
+		if err := c.assignSlots(slots, strings.HasPrefix(sub, "ADD")); err != nil {
+			return errorReply(err)
+		}
+		return []byte("+OK\r\n")
+	case "MEET":
+		if len(args) < 2 {
+			break
+		}
+		if err := c.meet(net.JoinHostPort(args[0], args[1])); err != nil {
+			return errorReply(err)
+		}
+		return []byte("+OK\r\n")
+	case "FORGET":
+		if len(args) != 1 {
+			break
+		}
+		if err := c.forget(args[0]); err != nil {
+			return errorReply(err)
+		}
+		return []byte("+OK\r\n")
+	case "SETSLOT":
+		if len(args) < 2 {
+			break
+		}
+		if err := c.setSlot(args); err != nil {
+			return errorReply(err)
+		}
+		return []byte("+OK\r\n")
+	case "GOSSIP":
+		c.learnIP(conn)
+		if err := c.absorb(args); err != nil {
+			return errorReply(err)
+		}
+		return bulkStringArray(c.gossipArgs())
+	default:
+		return []byte(fmt.Sprintf("-ERR unknown subcommand '%s'\r\n", parts[1]))
+	}
+	return []byte(fmt.Sprintf("-ERR wrong number of arguments for 'cluster|%s' command\r\n", strings.ToLower(sub)))
+}
+
+// parseSlotArgs parses slots, or start and end pairs for the RANGE forms.
+func parseSlotArgs(args []string, ranges bool) ([]int, error) {
+	var slots []int
+	step := 1
+	if ranges {
+		step = 2
+	}
+	for i := 0; i < len(args); i += step {
+		start, err := parseSlot(args[i])
+		if err != nil {
+			return nil, err
+		}
+		end := start
+		if ranges {
+			if end, err = parseSlot(args[i+1]); err != nil {
+				return nil, err
+			}
+			if end < start {
+				return nil, fmt.Errorf("ERR start slot number %d is greater than end slot number %d", start, end)
+			}
+		}
+		for slot := start; slot <= end; slot++ {
+			slots = append(slots, slot)
+		}
+	}
+	return slots, nil
+}
+
+// assignSlots claims slots for this node, or with add unset releases
+// them. Nothing changes unless every slot can be.
+func (c *cluster) assignSlots(slots []int, add bool) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, slot := range slots {
+		if add && c.slots[slot] != nil {
+			return fmt.Errorf("ERR Slot %d is already busy", slot)
+		}
+		if !add && c.slots[slot] == nil {
+			return fmt.Errorf("ERR Slot %d is already unassigned", slot)
+		}
+	}
+	for _, slot := range slots {
+		if add {
+			c.slots[slot] = c.myself
+			delete(c.importing, slot)
+		} else {
+			c.slots[slot] = nil
+		}
+	}
+	c.kickGossip()
+	return nil
+}
+
+// setSlot implements CLUSTER SETSLOT slot IMPORTING|MIGRATING|NODE node-id
+// and CLUSTER SETSLOT slot STABLE. Assigning a slot to this node takes a
+// new config epoch, so the claim wins over the old owner's.
+func (c *cluster) setSlot(args []string) error {
+	slot, err := parseSlot(args[0])
+	if err != nil {
+		return err
+	}
+	action := strings.ToUpper(args[1])
+	if action == "STABLE" {
+		c.mu.Lock()
+		delete(c.migrating, slot)
+		delete(c.importing, slot)
+		c.mu.Unlock()
+		return nil
+	}
+	if len(args) != 3 {
+		return errors.New("ERR syntax error")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	node, ok := c.nodes[args[2]]
+	if !ok {
+		return fmt.Errorf("ERR I don't know about node %s", args[2])
+	}
+	switch action {
+	case "MIGRATING":
+		if c.slots[slot] != c.myself {
+			return fmt.Errorf("ERR I'm not the owner of hash slot %d", slot)
+		}
+		if node == c.myself {
+			return errors.New("ERR I can't migrate a slot to myself")
+		}
+		c.migrating[slot] = node
+	case "IMPORTING":
+		if c.slots[slot] == c.myself {
+			return fmt.Errorf("ERR I'm already the owner of hash slot %d", slot)
+		}
+		if node == c.myself {
+			return errors.New("ERR I can't import a slot from myself")
+		}
+		c.importing[slot] = node
+	case "NODE":
+		if c.slots[slot] == c.myself && node != c.myself && c.store.CountKeysInSlot(slot) > 0 {
+			return fmt.Errorf("ERR Can't assign hashslot %d to a different node while I still hold keys for this hash slot.", slot)
+		}
+		delete(c.migrating, slot)
+		delete(c.importing, slot)
+		c.slots[slot] = node
+		if node == c.myself {
+			c.currentEpoch++
+			c.myself.epoch = c.currentEpoch
+		}
+		c.kickGossip()
+	default:
+		return errors.New("ERR Invalid CLUSTER SETSLOT action or number of arguments")
+	}
+	return nil
+}
+
+// info renders CLUSTER INFO.
+func (c *cluster) info() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	assigned := 0
+	sized := make(map[*clusterNode]bool)
+	for _, owner := range c.slots {
+		if owner != nil {
+			assigned++
+			sized[owner] = true
+		}
+	}
+	state := "fail"
+	if assigned == core.SlotCount {
+		state = "ok"
+	}
+	return fmt.Sprintf("cluster_enabled:1\r\ncluster_state:%s\r\ncluster_slots_assigned:%d\r\ncluster_slots_ok:%d\r\n"+
+		"cluster_known_nodes:%d\r\ncluster_size:%d\r\ncluster_current_epoch:%d\r\ncluster_my_epoch:%d\r\n",
+		state, assigned, assigned, len(c.nodes), len(sized), c.currentEpoch, c.myself.epoch)
+}
+
+// slotsReply renders CLUSTER SLOTS: one [start, end, [ip, port, id]]
+// entry per range of slots served by the same node.
+func (c *cluster) slotsReply() []byte {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	type entry struct {
+		start, end int
+		node       *clusterNode
+	}
+	var entries []entry
+	for slot, owner := range c.slots {
+		if owner == nil {
+			continue
+		}
+		if n := len(entries); n > 0 && entries[n-1].node == owner && entries[n-1].end == slot-1 {
+			entries[n-1].end = slot
+		} else {
+			entries = append(entries, entry{slot, slot, owner})
+		}
+	}
+
+	buf := appendArrayHeader(nil, len(entries))
+	for _, e := range entries {
+		host, port, _ := net.SplitHostPort(c.addrOf(e.node))
+		portNum, _ := strconv.Atoi(port)
+		buf = appendArrayHeader(buf, 3)
+		buf = appendInteger(buf, int64(e.start))
+		buf = appendInteger(buf, int64(e.end))
+		buf = appendArrayHeader(buf, 3)
+		buf = appendBulkString(buf, host)
+		buf = appendInteger(buf, int64(portNum))
+		buf = appendBulkString(buf, e.node.id)
+	}
+	return buf
+}
+
+// handleAsking implements ASKING: the next command may use a slot this
+// node is importing, as an -ASK redirect tells clients to do.
+func handleAsking(conn net.Conn, store *core.KVStore, parts []string) []byte {
+	if clusterOf(store) == nil {
+		return errorReply(errClusterDisabled)
+	}
+	if cc, ok := conn.(*clientConn); ok {
+		cc.asking = true
+	}
+	return []byte("+OK\r\n")
+}
+
+// handleDump implements DUMP key: the value serialized for RESTORE, or nil.
+func handleDump(conn net.Conn, store *core.KVStore, parts []string) []byte {
+	if len(parts) != 2 {
+		return []byte("-ERR wrong number of arguments for 'dump' command\r\n")
+	}
+	payload, _, ok := store.Dump(parts[1])
+	if !ok {
+		return []byte("$-1\r\n")
+	}
+	return bulkString(string(payload))
+}
+
+// handleRestore implements RESTORE key ttl payload [REPLACE] [ABSTTL]. The
+// ttl is in milliseconds, or a Unix time in milliseconds with ABSTTL; zero
+// means no expiry. RESTORE-ASKING is the same command, sent by MIGRATE.
+func handleRestore(conn net.Conn, store *core.KVStore, parts []string) []byte {
+	if len(parts) < 4 {
+		return []byte("-ERR wrong number of arguments for 'restore' command\r\n")
+	}
+	ms, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil || ms < 0 {
+		return []byte("-ERR Invalid TTL value, must be >= 0\r\n")
+	}
+	var replace, absTTL bool
+	for _, opt := range parts[4:] {
+		switch strings.ToUpper(opt) {
+		case "REPLACE":
+			replace = true
+		case "ABSTTL":
+			absTTL = true
+		default:
+			return []byte("-ERR syntax error\r\n")
+		}
+	}
+	ttl := time.Duration(ms) * time.Millisecond
+	if absTTL && ms > 0 {
+		if ttl = time.Until(time.UnixMilli(ms)); ttl <= 0 {
+			// Already expired: nothing to create
+			store.Delete(parts[1])
+			return []byte("+OK\r\n")
+		}
+	}
+	if err := store.Restore(parts[1], []byte(parts[3]), ttl, replace); err != nil {
+		return errorReply(err)
+	}
+	return []byte("+OK\r\n")
+}
+
+// migrateArgs is a parsed MIGRATE command.
+type migrateArgs struct {
+	addr    string
+	keys    []string
+	timeout time.Duration
+	copy    bool
+	replace bool
+}
+
+// parseMigrate parses MIGRATE host port key|"" db timeout [COPY] [REPLACE]
+// [KEYS key ...]. Only database 0 exists.
+func parseMigrate(parts []string) (migrateArgs, error) {
+	var m migrateArgs
+	if len(parts) < 6 {
+		return m, errors.New("ERR wrong number of arguments for 'migrate' command")
+	}
+	m.addr = net.JoinHostPort(parts[1], parts[2])
+	if parts[4] != "0" {
+		return m, errors.New("ERR DB index is out of range")
+	}
+	ms, err := strconv.ParseInt(parts[5], 10, 64)
+	if err != nil || ms < 0 {
+		return m, errors.New("ERR value is not an integer or out of range")
+	}
+	m.timeout = time.Duration(ms) * time.Millisecond
+	if m.timeout == 0 {
+		m.timeout = time.Second
+	}
+	for i := 6; i < len(parts); i++ {
+		switch strings.ToUpper(parts[i]) {
+		case "COPY":
+			m.copy = true
+		case "REPLACE":
+			m.replace = true
+		case "KEYS":
+			if parts[3] != "" {
+				return m, errors.New("ERR When using MIGRATE KEYS option, the key argument must be set to the empty string")
+			}
+			m.keys = parts[i+1:]
+			i = len(parts)
+		default:
+			return m, errors.New("ERR syntax error")
+		}
+	}
+	if parts[3] != "" {
+		m.keys = []string{parts[3]}
+	}
+	return m, nil
+}
+
+// handleMigrate implements MIGRATE: it sends the keys to another server
+// with RESTORE-ASKING, in one pipeline, and deletes them here once the
+// target has them unless COPY is given. Keys that do not exist are
+// skipped; if none do, the reply is +NOKEY.
+func handleMigrate(conn net.Conn, store *core.KVStore, parts []string) []byte {
+	m, err := parseMigrate(parts)
+	if err != nil {
+		return errorReply(err)
+	}
+
+	var buf []byte
+	var keys []string
+	for _, key := range m.keys {
+		payload, ttl, ok := store.Dump(key)
+		if !ok {
+			continue
+		}
+		cmd := []string{"RESTORE-ASKING", key, strconv.FormatInt(ttl.Milliseconds(), 10), string(payload)}
+		if m.replace {
+			cmd = append(cmd, "REPLACE")
+		}
+		buf = append(buf, bulkStringArray(cmd)...)
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return []byte("+NOKEY\r\n")
+	}
+
+	target, err := net.DialTimeout("tcp", m.addr, m.timeout)
+	if err != nil {
+		return []byte(fmt.Sprintf("-IOERR error or timeout connecting to the client: %v\r\n", err))
+	}
+	defer target.Close()
+	target.SetDeadline(time.Now().Add(m.timeout))
+	if _, err := target.Write(buf); err != nil {
+		return []byte(fmt.Sprintf("-IOERR error or timeout writing to target instance: %v\r\n", err))
+	}
+	reader := bufio.NewReader(target)
+	var failed string
+	for _, key := range keys {
+		line, err := readLine(reader)
+		if err != nil {
+			return []byte(fmt.Sprintf("-IOERR error or timeout reading to target instance: %v\r\n", err))
+		}
+		if strings.HasPrefix(line, "-") {
+			failed = line[1:]
+		} else if !m.copy {
+			store.Delete(key)
+		}
+	}
+	if failed != "" {
+		return []byte(fmt.Sprintf("-ERR Target instance replied with error: %s\r\n", failed))
+	}
+	return []byte("+OK\r\n")
+}
@@ -0,0 +1,259 @@
+package server
+
+import (
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/Syed-Suhaan/SusyDB/pkg/core"
+)
+
+func handleGeoAdd(conn net.Conn, store *core.KVStore, parts []string) []byte {
+	if len(parts) < 5 {
+		return []byte("-ERR wrong number of arguments for 'geoadd' command\r\n")
+	}
+	var opts core.GeoAddOptions
+	i := 2
+	for ; i < len(parts); i++ {
+		switch strings.ToUpper(parts[i]) {
+		case "NX":
+			opts.NX = true
+			continue
+		case "XX":
+			opts.XX = true
+			continue
+		case "CH":
+			opts.CH = true
+			continue
+		}
+		break
+	}
+	if (len(parts)-i)%3 != 0 || i == len(parts) {
+		return []byte("-ERR syntax error\r\n")
+	}
+
+	members := make([]core.GeoMember, 0, (len(parts)-i)/3)
+	for ; i < len(parts); i += 3 {
+		lon, err1 := strconv.ParseFloat(parts[i], 64)
+		lat, err2 := strconv.ParseFloat(parts[i+1], 64)
+		if err1 != nil || err2 != nil {
+			return []byte("-ERR value is not a valid float\r\n")
+		}
+		members = append(members, core.GeoMember{Member: parts[i+2], Longitude: lon, Latitude: lat})
+	}
+
+	n, err := store.GeoAdd(parts[1], members, opts)
+	if err != nil {
+		return errorReply(err)
+	}
+	return integerReply(int64(n))
+}
+
+func handleGeoPos(conn net.Conn, store *core.KVStore, parts []string) []byte {
+	if len(parts) < 2 {
+		return []byte("-ERR wrong number of arguments for 'geopos' command\r\n")
+	}
+	positions, err := store.GeoPos(parts[1], parts[2:]...)
+	if err != nil {
+		return errorReply(err)
+	}
+	buf := appendArrayHeader(nil, len(positions))
+	for _, pos := range positions {
+		if pos == nil {
+			buf = append(buf, "*-1\r\n"...)
+			continue
+		}
+		buf = appendCoordinates(buf, pos.Longitude, pos.Latitude)
+	}
+	return buf
+}
+
+func handleGeoDist(conn net.Conn, store *core.KVStore, parts []string) []byte {
+	if len(parts) != 4 && len(parts) != 5 {
+		return []byte("-ERR wrong number of arguments for 'geodist' command\r\n")
+	}
+	unit := 1.0
+	if len(parts) == 5 {
+		var ok bool
+		if unit, ok = geoUnit(parts[4]); !ok {
+			return []byte("-ERR unsupported unit provided. please use M, KM, FT, MI\r\n")
+		}
+	}
+	dist, ok, err := store.GeoDist(parts[1], parts[2], parts[3])
+	if err != nil {
+		return errorReply(err)
+	}
+	if !ok {
+		return []byte("$-1\r\n")
+	}
+	return bulkString(strconv.FormatFloat(dist/unit, 'f', 4, 64))
+}
+
+func handleGeoHash(conn net.Conn, store *core.KVStore, parts []string) []byte {
+	if len(parts) < 2 {
+		return []byte("-ERR wrong number of arguments for 'geohash' command\r\n")
+	}
+	hashes, err := store.GeoHash(parts[1], parts[2:]...)
+	if err != nil {
+		return errorReply(err)
+	}
+	buf := appendArrayHeader(nil, len(hashes))
+	for _, h := range hashes {
+		if h == "" {
+			buf = append(buf, "$-1\r\n"...)
+			continue
+		}
+		buf = appendBulkString(buf, h)
+	}
+	return buf
+}
+
+// handleGeoSearch implements
+// GEOSEARCH key FROMMEMBER member|FROMLONLAT lon lat BYRADIUS r unit|BYBOX w h unit
+// [ASC|DESC] [COUNT n [ANY]] [WITHCOORD] [WITHDIST] [WITHHASH]
+func handleGeoSearch(conn net.Conn, store *core.KVStore, parts []string) []byte {
+	if len(parts) < 6 {
+		return []byte("-ERR wrong number of arguments for 'geosearch' command\r\n")
+	}
+	var q core.GeoSearchQuery
+	var withCoord, withDist, withHash, hasFrom, hasBy bool
+	unit := 1.0 // Replies use the unit of the query
+	syntaxErr := []byte("-ERR syntax error\r\n")
+
+	for i := 2; i < len(parts); i++ {
+		switch strings.ToUpper(parts[i]) {
+		case "FROMMEMBER":
+			if hasFrom || i+1 >= len(parts) {
+				return syntaxErr
+			}
+			q.FromMember, q.Member, hasFrom = true, parts[i+1], true
+			i++
+		case "FROMLONLAT":
+			if hasFrom || i+2 >= len(parts) {
+				return syntaxErr
+			}
+			lon, err1 := strconv.ParseFloat(parts[i+1], 64)
+			lat, err2 := strconv.ParseFloat(parts[i+2], 64)
+			if err1 != nil || err2 != nil {
+				return []byte("-ERR value is not a valid float\r\n")
+			}
+			q.Longitude, q.Latitude, hasFrom = lon, lat, true
+			i += 2
+		case "BYRADIUS":
+			if hasBy || i+2 >= len(parts) {
+				return syntaxErr
+			}
+			radius, err := strconv.ParseFloat(parts[i+1], 64)
+			var ok bool
+			unit, ok = geoUnit(parts[i+2])
+			if err != nil || radius < 0 {
+				return []byte("-ERR need numeric radius\r\n")
+			}
+			if !ok {
+				return []byte("-ERR unsupported unit provided. please use M, KM, FT, MI\r\n")
+			}
+			q.Radius, hasBy = radius*unit, true
+			i += 2
+		case "BYBOX":
+			if hasBy || i+3 >= len(parts) {
+				return syntaxErr
+			}
+			width, err1 := strconv.ParseFloat(parts[i+1], 64)
+			height, err2 := strconv.ParseFloat(parts[i+2], 64)
+			var ok bool
+			unit, ok = geoUnit(parts[i+3])
+			if err1 != nil || err2 != nil || width < 0 || height < 0 {
+				return []byte("-ERR need numeric width and height\r\n")
+			}
+			if !ok {
+				return []byte("-ERR unsupported unit provided. please use M, KM, FT, MI\r\n")
+			}
+			q.ByBox, q.Width, q.Height, hasBy = true, width*unit, height*unit, true
+			i += 3
+		case "ASC":
+			q.Sort = core.GeoSortAsc
+		case "DESC":
+			q.Sort = core.GeoSortDesc
+		case "COUNT":
+			if i+1 >= len(parts) {
+				return syntaxErr
+			}
+			count, err := strconv.Atoi(parts[i+1])
+			if err != nil || count <= 0 {
+				return []byte("-ERR COUNT must be > 0\r\n")
+			}
+			q.Count = count
+			i++
+			if i+1 < len(parts) && strings.ToUpper(parts[i+1]) == "ANY" {
+				q.Any = true
+				i++
+			}
+		case "WITHCOORD":
+			withCoord = true
+		case "WITHDIST":
+			withDist = true
+		case "WITHHASH":
+			withHash = true
+		default:
+			return syntaxErr
+		}
+	}
+	if !hasFrom {
+		return []byte("-ERR exactly one of FROMMEMBER or FROMLONLAT can be specified for GEOSEARCH\r\n")
+	}
+	if !hasBy {
+		return []byte("-ERR exactly one of BYRADIUS and BYBOX can be specified for GEOSEARCH\r\n")
+	}
+
+	results, err := store.GeoSearch(parts[1], q)
+	if err != nil {
+		return errorReply(err)
+	}
+
+	buf := appendArrayHeader(nil, len(results))
+	for _, r := range results {
+		if !withCoord && !withDist && !withHash {
+			buf = appendBulkString(buf, r.Member)
+			continue
+		}
+		n := 1
+		for _, with := range []bool{withDist, withHash, withCoord} {
+			if with {
+				n++
+			}
+		}
+		buf = appendArrayHeader(buf, n)
+		buf = appendBulkString(buf, r.Member)
+		if withDist {
+			buf = appendBulkString(buf, strconv.FormatFloat(r.Distance/unit, 'f', 4, 64))
+		}
+		if withHash {
+			buf = appendInteger(buf, int64(r.Hash))
+		}
+		if withCoord {
+			buf = appendCoordinates(buf, r.Longitude, r.Latitude)
+		}
+	}
+	return buf
+}
+
+// geoUnit returns the number of meters in one unit.
+func geoUnit(unit string) (float64, bool) {
+	switch strings.ToLower(unit) {
+	case "m":
+		return 1, true
+	case "km":
+		return 1000, true
+	case "ft":
+		return 0.3048, true
+	case "mi":
+		return 1609.34, true
+	}
+	return 0, false
+}
+
+func appendCoordinates(buf []byte, lon, lat float64) []byte {
+	buf = appendArrayHeader(buf, 2)
+	buf = appendBulkString(buf, strconv.FormatFloat(lon, 'f', -1, 64))
+	return appendBulkString(buf, strconv.FormatFloat(lat, 'f', -1, 64))
+}
@@ -1,7 +1,6 @@
 package server
 
 import (
-	"fmt"
 	"net"
 	"strings"
 
@@ -34,7 +33,7 @@ func handleHGet(conn net.Conn, store *core.KVStore, parts []string) []byte {
 	} else if !ok {
 		return []byte("$-1\r\n")
 	}
-	return []byte(fmt.Sprintf("%s\r\n", val))
+	return bulkString(val)
 }
 
 func handleHGetAll(conn net.Conn, store *core.KVStore, parts []string) []byte {
@@ -48,11 +47,11 @@ func handleHGetAll(conn net.Conn, store *core.KVStore, parts []string) []byte {
 	} else if !ok {
 		return []byte("*0\r\n")
 	}
-	var sb strings.Builder
+	values := make([]string, 0, len(hash)*2)
 	for k, v := range hash {
-		sb.WriteString(fmt.Sprintf("%s\n%s\n", k, v))
+		values = append(values, k, v)
 	}
-	return []byte(sb.String())
+	return bulkStringArray(values)
 }
 
 func handleHDel(conn net.Conn, store *core.KVStore, parts []string) []byte {
@@ -0,0 +1,41 @@
+package server
+
+import (
+	"net"
+
+	"github.com/Syed-Suhaan/SusyDB/pkg/core"
+)
+
+func handlePFAdd(conn net.Conn, store *core.KVStore, parts []string) []byte {
+	if len(parts) < 2 {
+		return []byte("-ERR wrong number of arguments for 'pfadd' command\r\n")
+	}
+	changed, err := store.PFAdd(parts[1], parts[2:]...)
+	if err != nil {
+		return errorReply(err)
+	} else if changed {
+		return []byte(":1\r\n")
+	}
+	return []byte(":0\r\n")
+}
+
+func handlePFCount(conn net.Conn, store *core.KVStore, parts []string) []byte {
+	if len(parts) < 2 {
+		return []byte("-ERR wrong number of arguments for 'pfcount' command\r\n")
+	}
+	count, err := store.PFCount(parts[1:]...)
+	if err != nil {
+		return errorReply(err)
+	}
+	return integerReply(count)
+}
+
+func handlePFMerge(conn net.Conn, store *core.KVStore, parts []string) []byte {
+	if len(parts) < 2 {
+		return []byte("-ERR wrong number of arguments for 'pfmerge' command\r\n")
+	}
+	if err := store.PFMerge(parts[1], parts[2:]...); err != nil {
+		return errorReply(err)
+	}
+	return []byte("+OK\r\n")
+}
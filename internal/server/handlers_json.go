@@ -0,0 +1,96 @@
+package server
+
+import (
+	"net"
+	"strings"
+
+	"github.com/Syed-Suhaan/SusyDB/pkg/core"
+)
+
+func handleJSONSet(conn net.Conn, store *core.KVStore, parts []string) []byte {
+	if len(parts) != 4 && len(parts) != 5 {
+		return []byte("-ERR wrong number of arguments for 'json.set' command\r\n")
+	}
+	var opts core.JSONSetOptions
+	if len(parts) == 5 {
+		switch strings.ToUpper(parts[4]) {
+		case "NX":
+			opts.NX = true
+		case "XX":
+			opts.XX = true
+		default:
+			return []byte("-ERR syntax error\r\n")
+		}
+	}
+	ok, err := store.JSONSet(parts[1], parts[2], parts[3], opts)
+	if err != nil {
+		return errorReply(err)
+	}
+	if !ok {
+		return []byte("$-1\r\n")
+	}
+	return []byte("+OK\r\n")
+}
+
+func handleJSONGet(conn net.Conn, store *core.KVStore, parts []string) []byte {
+	if len(parts) < 2 {
+		return []byte("-ERR wrong number of arguments for 'json.get' command\r\n")
+	}
+	val, ok, err := store.JSONGet(parts[1], parts[2:]...)
+	if err != nil {
+		return errorReply(err)
+	}
+	if !ok {
+		return []byte("$-1\r\n")
+	}
+	return bulkString(val)
+}
+
+func handleJSONDel(conn net.Conn, store *core.KVStore, parts []string) []byte {
+	if len(parts) != 2 && len(parts) != 3 {
+		return []byte("-ERR wrong number of arguments for 'json.del' command\r\n")
+	}
+	path := "$"
+	if len(parts) == 3 {
+		path = parts[2]
+	}
+	n, err := store.JSONDel(parts[1], path)
+	if err != nil {
+		return errorReply(err)
+	}
+	return integerReply(int64(n))
+}
+
+func handleJSONNumIncrBy(conn net.Conn, store *core.KVStore, parts []string) []byte {
+	if len(parts) != 4 {
+		return []byte("-ERR wrong number of arguments for 'json.numincrby' command\r\n")
+	}
+	val, err := store.JSONNumIncrBy(parts[1], parts[2], parts[3])
+	if err != nil {
+		return errorReply(err)
+	}
+	return bulkString(val)
+}
+
+func handleJSONArrAppend(conn net.Conn, store *core.KVStore, parts []string) []byte {
+	if len(parts) < 4 {
+		return []byte("-ERR wrong number of arguments for 'json.arrappend' command\r\n")
+	}
+	lengths, err := store.JSONArrAppend(parts[1], parts[2], parts[3:]...)
+	if err != nil {
+		return errorReply(err)
+	}
+	// Legacy paths reply with a single length, JSONPath with one per match
+	if !strings.HasPrefix(parts[2], "$") {
+		return integerReply(int64(lengths[0]))
+	}
+	buf := appendArrayHeader(nil, len(lengths))
+	for _, n := range lengths {
+		if n < 0 {
+			buf = append(buf, "$-1\r\n"...)
+			continue
+		}
+		buf = appendInteger(buf, int64(n))
+	}
+	return buf
+}
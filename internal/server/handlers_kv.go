@@ -49,7 +49,39 @@ func handleGet(conn net.Conn, store *core.KVStore, parts []string) []byte {
 	} else if !ok {
 		return []byte("$-1\r\n")
 	}
-	return []byte(fmt.Sprintf("%s\r\n", val))
+	return bulkString(val)
+}
+
+// handleMGet implements MGET key [key ...]. A key that is missing or not a
+// string reads as null.
+func handleMGet(conn net.Conn, store *core.KVStore, parts []string) []byte {
+	if len(parts) < 2 {
+		return []byte("-ERR wrong number of arguments for 'mget' command\r\n")
+	}
+	buf := appendArrayHeader(nil, len(parts)-1)
+	for _, key := range parts[1:] {
+		if val, ok, err := store.Get(key); err == nil && ok {
+			buf = appendBulkString(buf, val)
+		} else {
+			buf = append(buf, "$-1\r\n"...)
+		}
+	}
+	return buf
+}
+
+// handleMSet implements MSET key value [key value ...]. Each key is set on
+// its own, so a concurrent reader may see some of the keys set and not
+// others.
+func handleMSet(conn net.Conn, store *core.KVStore, parts []string) []byte {
+	if len(parts) < 3 || len(parts)%2 == 0 {
+		return []byte("-ERR wrong number of arguments for 'mset' command\r\n")
+	}
+	for i := 1; i < len(parts); i += 2 {
+		if err := store.Set(parts[i], parts[i+1], 0); err != nil {
+			return []byte("-ERR " + err.Error() + "\r\n")
+		}
+	}
+	return []byte("+OK\r\n")
 }
 
 func handleIncr(conn net.Conn, store *core.KVStore, parts []string) []byte {
@@ -90,3 +122,50 @@ func handleDel(conn net.Conn, store *core.KVStore, parts []string) []byte {
 	store.Delete(key)
 	return []byte("+OK\r\n")
 }
+
+// handleType implements TYPE key.
+func handleType(conn net.Conn, store *core.KVStore, parts []string) []byte {
+	if len(parts) != 2 {
+		return []byte("-ERR wrong number of arguments for 'type' command\r\n")
+	}
+	return []byte("+" + store.Type(parts[1]) + "\r\n")
+}
+
+// handleScan implements SCAN cursor [MATCH pattern] [COUNT count] [TYPE type].
+// MATCH and TYPE filter the keys visited, so a call can return fewer than
+// count keys, or none, before the cursor comes back to 0.
+func handleScan(conn net.Conn, store *core.KVStore, parts []string) []byte {
+	if len(parts) < 2 || len(parts)%2 != 0 {
+		return []byte("-ERR wrong number of arguments for 'scan' command\r\n")
+	}
+	cursor, err := strconv.ParseUint(parts[1], 10, 64)
+	if err != nil {
+		return []byte("-ERR invalid cursor\r\n")
+	}
+	pattern, keyType, count := "", "", 10
+	for i := 2; i < len(parts); i += 2 {
+		switch strings.ToUpper(parts[i]) {
+		case "MATCH":
+			pattern = parts[i+1]
+		case "COUNT":
+			if count, err = strconv.Atoi(parts[i+1]); err != nil || count < 1 {
+				return []byte("-ERR value is not an integer or out of range\r\n")
+			}
+		case "TYPE":
+			keyType = parts[i+1]
+		default:
+			return []byte("-ERR syntax error\r\n")
+		}
+	}
+
+	next, keys := store.Scan(cursor, count)
+	matched := keys[:0]
+	for _, key := range keys {
+		if (pattern == "" || core.GlobMatch(pattern, key)) && (keyType == "" || strings.EqualFold(store.Type(key), keyType)) {
+			matched = append(matched, key)
+		}
+	}
+	buf := appendArrayHeader(nil, 2)
+	buf = appendBulkString(buf, strconv.FormatUint(next, 10))
+	return append(buf, bulkStringArray(matched)...)
+}
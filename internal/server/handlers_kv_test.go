@@ -0,0 +1,57 @@
+package server
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/Syed-Suhaan/SusyDB/pkg/core"
+	"github.com/Syed-Suhaan/SusyDB/pkg/resp"
+)
+
+func TestMGetMSet(t *testing.T) {
+	store := core.NewKVStore()
+	store.HSet("h", "f", "v")
+	conn, reader := pipeClient(t, store)
+
+	conn.Write([]byte("MSET a 1 b 2\r\n"))
+	expectReply(t, reader, "+OK\r\n")
+	conn.Write([]byte("MGET a missing h b\r\n"))
+	expectReply(t, reader, "*4\r\n$1\r\n1\r\n$-1\r\n$-1\r\n$1\r\n2\r\n")
+
+	conn.Write([]byte("MSET a 1 b\r\n"))
+	expectReply(t, reader, "-ERR wrong number of arguments for 'mset' command\r\n")
+}
+
+func TestScanAndType(t *testing.T) {
+	store := core.NewKVStore()
+	store.Set("user:1", "a", 0)
+	store.Set("user:2", "b", 0)
+	store.HSet("user:3", "f", "v")
+	store.Set("order:1", "c", 0)
+	conn, reader := pipeClient(t, store)
+
+	conn.Write([]byte("TYPE user:3\r\n"))
+	expectReply(t, reader, "+hash\r\n")
+	conn.Write([]byte("TYPE nope\r\n"))
+	expectReply(t, reader, "+none\r\n")
+
+	// All four keys fit in one call, so the cursor comes back as 0
+	conn.Write([]byte("SCAN 0 MATCH user:* TYPE string COUNT 100\r\n"))
+	v, err := resp.ReadReply(reader)
+	if err != nil {
+		t.Fatalf("reading SCAN reply: %v", err)
+	}
+	reply, _ := v.([]interface{})
+	if len(reply) != 2 || reply[0] != "0" {
+		t.Fatalf("SCAN reply = %v", v)
+	}
+	keys, _ := reply[1].([]interface{})
+	sort.Slice(keys, func(i, j int) bool { return keys[i].(string) < keys[j].(string) })
+	if !reflect.DeepEqual(keys, []interface{}{"user:1", "user:2"}) {
+		t.Errorf("SCAN keys = %v, want user:1 and user:2", keys)
+	}
+
+	conn.Write([]byte("SCAN x\r\n"))
+	expectReply(t, reader, "-ERR invalid cursor\r\n")
+}
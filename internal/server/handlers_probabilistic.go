@@ -0,0 +1,258 @@
+package server
+
+import (
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/Syed-Suhaan/SusyDB/pkg/core"
+)
+
+func handleBFReserve(conn net.Conn, store *core.KVStore, parts []string) []byte {
+	if len(parts) < 4 {
+		return []byte("-ERR wrong number of arguments for 'bf.reserve' command\r\n")
+	}
+	cfg := core.DefaultBloomConfig()
+	var err error
+	if cfg.ErrorRate, err = strconv.ParseFloat(parts[2], 64); err != nil {
+		return []byte("-ERR bad error rate\r\n")
+	}
+	if cfg.Capacity, err = strconv.ParseUint(parts[3], 10, 64); err != nil {
+		return []byte("-ERR bad capacity\r\n")
+	}
+	for i := 4; i < len(parts); i++ {
+		switch strings.ToUpper(parts[i]) {
+		case "NONSCALING":
+			cfg.NonScaling = true
+		case "EXPANSION":
+			if i+1 >= len(parts) {
+				return []byte("-ERR syntax error\r\n")
+			}
+			expansion, err := strconv.ParseUint(parts[i+1], 10, 32)
+			if err != nil {
+				return []byte("-ERR bad expansion\r\n")
+			}
+			cfg.Expansion = uint(expansion)
+			i++
+		default:
+			return []byte("-ERR syntax error\r\n")
+		}
+	}
+	if err := store.BFReserve(parts[1], cfg); err != nil {
+		return errorReply(err)
+	}
+	return []byte("+OK\r\n")
+}
+
+func handleBFAdd(conn net.Conn, store *core.KVStore, parts []string) []byte {
+	if len(parts) != 3 {
+		return []byte("-ERR wrong number of arguments for 'bf.add' command\r\n")
+	}
+	added, err := store.BFAdd(parts[1], parts[2])
+	if err != nil {
+		return errorReply(err)
+	}
+	return boolReply(added[0])
+}
+
+func handleBFMAdd(conn net.Conn, store *core.KVStore, parts []string) []byte {
+	if len(parts) < 3 {
+		return []byte("-ERR wrong number of arguments for 'bf.madd' command\r\n")
+	}
+	added, err := store.BFAdd(parts[1], parts[2:]...)
+	if err != nil {
+		return errorReply(err)
+	}
+	return boolArray(added)
+}
+
+func handleBFExists(conn net.Conn, store *core.KVStore, parts []string) []byte {
+	if len(parts) != 3 {
+		return []byte("-ERR wrong number of arguments for 'bf.exists' command\r\n")
+	}
+	found, err := store.BFExists(parts[1], parts[2])
+	if err != nil {
+		return errorReply(err)
+	}
+	return boolReply(found[0])
+}
+
+func handleBFMExists(conn net.Conn, store *core.KVStore, parts []string) []byte {
+	if len(parts) < 3 {
+		return []byte("-ERR wrong number of arguments for 'bf.mexists' command\r\n")
+	}
+	found, err := store.BFExists(parts[1], parts[2:]...)
+	if err != nil {
+		return errorReply(err)
+	}
+	return boolArray(found)
+}
+
+func handleCFReserve(conn net.Conn, store *core.KVStore, parts []string) []byte {
+	if len(parts) < 3 {
+		return []byte("-ERR wrong number of arguments for 'cf.reserve' command\r\n")
+	}
+	cfg := core.DefaultCuckooConfig()
+	var err error
+	if cfg.Capacity, err = strconv.ParseUint(parts[2], 10, 64); err != nil {
+		return []byte("-ERR Bad capacity\r\n")
+	}
+	for i := 3; i < len(parts); i += 2 {
+		if i+1 >= len(parts) {
+			return []byte("-ERR syntax error\r\n")
+		}
+		n, err := strconv.Atoi(parts[i+1])
+		if err != nil || n < 0 {
+			return []byte("-ERR value is not an integer or out of range\r\n")
+		}
+		switch strings.ToUpper(parts[i]) {
+		case "BUCKETSIZE":
+			cfg.BucketSize = n
+		case "MAXITERATIONS":
+			cfg.MaxIterations = n
+		case "EXPANSION":
+			cfg.Expansion = uint(n)
+		default:
+			return []byte("-ERR syntax error\r\n")
+		}
+	}
+	if err := store.CFReserve(parts[1], cfg); err != nil {
+		return errorReply(err)
+	}
+	return []byte("+OK\r\n")
+}
+
+func handleCFAdd(conn net.Conn, store *core.KVStore, parts []string) []byte {
+	if len(parts) != 3 {
+		return []byte("-ERR wrong number of arguments for 'cf.add' command\r\n")
+	}
+	if err := store.CFAdd(parts[1], parts[2]); err != nil {
+		return errorReply(err)
+	}
+	return []byte(":1\r\n")
+}
+
+func handleCFAddNX(conn net.Conn, store *core.KVStore, parts []string) []byte {
+	if len(parts) != 3 {
+		return []byte("-ERR wrong number of arguments for 'cf.addnx' command\r\n")
+	}
+	added, err := store.CFAddNX(parts[1], parts[2])
+	if err != nil {
+		return errorReply(err)
+	}
+	return boolReply(added)
+}
+
+func handleCFDel(conn net.Conn, store *core.KVStore, parts []string) []byte {
+	if len(parts) != 3 {
+		return []byte("-ERR wrong number of arguments for 'cf.del' command\r\n")
+	}
+	deleted, err := store.CFDel(parts[1], parts[2])
+	if err != nil {
+		return errorReply(err)
+	}
+	return boolReply(deleted)
+}
+
+func handleCFExists(conn net.Conn, store *core.KVStore, parts []string) []byte {
+	if len(parts) != 3 {
+		return []byte("-ERR wrong number of arguments for 'cf.exists' command\r\n")
+	}
+	found, err := store.CFExists(parts[1], parts[2])
+	if err != nil {
+		return errorReply(err)
+	}
+	return boolReply(found)
+}
+
+func handleCMSInitByDim(conn net.Conn, store *core.KVStore, parts []string) []byte {
+	if len(parts) != 4 {
+		return []byte("-ERR wrong number of arguments for 'cms.initbydim' command\r\n")
+	}
+	width, err1 := strconv.ParseUint(parts[2], 10, 64)
+	depth, err2 := strconv.ParseUint(parts[3], 10, 64)
+	if err1 != nil || err2 != nil {
+		return []byte("-ERR CMS: invalid width/depth\r\n")
+	}
+	if err := store.CMSInitByDim(parts[1], width, depth); err != nil {
+		return errorReply(err)
+	}
+	return []byte("+OK\r\n")
+}
+
+func handleCMSInitByProb(conn net.Conn, store *core.KVStore, parts []string) []byte {
+	if len(parts) != 4 {
+		return []byte("-ERR wrong number of arguments for 'cms.initbyprob' command\r\n")
+	}
+	errorRate, err := strconv.ParseFloat(parts[2], 64)
+	if err != nil {
+		return []byte("-ERR CMS: invalid overestimation value\r\n")
+	}
+	probability, err := strconv.ParseFloat(parts[3], 64)
+	if err != nil {
+		return []byte("-ERR CMS: invalid prob value\r\n")
+	}
+	if err := store.CMSInitByProb(parts[1], errorRate, probability); err != nil {
+		return errorReply(err)
+	}
+	return []byte("+OK\r\n")
+}
+
+func handleCMSIncrBy(conn net.Conn, store *core.KVStore, parts []string) []byte {
+	if len(parts) < 4 || len(parts)%2 != 0 {
+		return []byte("-ERR wrong number of arguments for 'cms.incrby' command\r\n")
+	}
+	incrs := make([]core.CMSIncrement, 0, (len(parts)-2)/2)
+	for i := 2; i < len(parts); i += 2 {
+		amount, err := strconv.ParseUint(parts[i+1], 10, 64)
+		if err != nil {
+			return []byte("-ERR CMS: Cannot parse number\r\n")
+		}
+		incrs = append(incrs, core.CMSIncrement{Item: parts[i], Amount: amount})
+	}
+	counts, err := store.CMSIncrBy(parts[1], incrs)
+	if err != nil {
+		return errorReply(err)
+	}
+	return uintArray(counts)
+}
+
+func handleCMSQuery(conn net.Conn, store *core.KVStore, parts []string) []byte {
+	if len(parts) < 3 {
+		return []byte("-ERR wrong number of arguments for 'cms.query' command\r\n")
+	}
+	counts, err := store.CMSQuery(parts[1], parts[2:]...)
+	if err != nil {
+		return errorReply(err)
+	}
+	return uintArray(counts)
+}
+
+func boolReply(b bool) []byte {
+	if b {
+		return []byte(":1\r\n")
+	}
+	return []byte(":0\r\n")
+}
+
+func boolArray(values []bool) []byte {
+	buf := appendArrayHeader(nil, len(values))
+	for _, b := range values {
+		if b {
+			buf = append(buf, ":1\r\n"...)
+		} else {
+			buf = append(buf, ":0\r\n"...)
+		}
+	}
+	return buf
+}
+
+func uintArray(values []uint64) []byte {
+	buf := appendArrayHeader(nil, len(values))
+	for _, v := range values {
+		buf = append(buf, ':')
+		buf = strconv.AppendUint(buf, v, 10)
+		buf = append(buf, '\r', '\n')
+	}
+	return buf
+}
@@ -3,7 +3,9 @@ package server
 import (
 	"fmt"
 	"net"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/Syed-Suhaan/SusyDB/pkg/core"
 )
@@ -21,36 +23,410 @@ func handlePublish(conn net.Conn, store *core.KVStore, parts []string) []byte {
 	return []byte(fmt.Sprintf(":%d\r\n", count))
 }
 
+// handleSPublish implements SPUBLISH shard-channel message. Shard channels
+// are separate from PUBLISH channels and only reach SSUBSCRIBE clients.
+func handleSPublish(conn net.Conn, store *core.KVStore, parts []string) []byte {
+	if len(parts) < 3 {
+		return []byte("-ERR wrong number of arguments for 'spublish' command\r\n")
+	}
+	count := store.Hub.SPublish(parts[1], strings.Join(parts[2:], " "))
+	return integerReply(int64(count))
+}
+
+// handleSubscribe implements SUBSCRIBE channel [channel ...]. The connection
+// stays in subscribed mode until the client unsubscribes from everything or
+// disconnects; its subscriptions are removed either way.
 func handleSubscribe(conn net.Conn, store *core.KVStore, parts []string) []byte {
 	if len(parts) < 2 {
 		return []byte("-ERR wrong number of arguments for 'subscribe' command\r\n")
 	}
-	channel := parts[1]
+	return enterSubscribed(conn, store, parts)
+}
 
-	// Register subscription
-	subCh := store.Hub.Subscribe(channel)
-	// Optionally, we could clean up on exit using defer/Unsubscribe.
-	// For simplicity, we just drop the channel on the implementation side (Hub weak ref or GC).
-	// In a robust implementation, we would register a "close callback".
-
-	// Acknowledge subscription
-	// Redis format: *3\r\n$9\r\nsubscribe\r\n$channel_len\r\nchannel\r\n:1\r\n
-	// Simplified: just say subscribed
-	conn.Write([]byte(fmt.Sprintf("*3\r\n$9\r\nsubscribe\r\n$%d\r\n%s\r\n:1\r\n", len(channel), channel)))
-
-	// Blocking loop
-	for msg := range subCh {
-		// Redis Pub/Sub message format:
-		// *3\r\n$7\r\nmessage\r\n$channel_len\r\nchannel\r\n$msg_len\r\nmessage\r\n
-		response := fmt.Sprintf("*3\r\n$7\r\nmessage\r\n$%d\r\n%s\r\n$%d\r\n%s\r\n",
-			len(channel), channel, len(msg), msg)
-
-		_, err := conn.Write([]byte(response))
-		if err != nil {
-			// Connection likely closed
-			return nil
+// handlePSubscribe implements PSUBSCRIBE pattern [pattern ...], using Redis
+// glob patterns.
+func handlePSubscribe(conn net.Conn, store *core.KVStore, parts []string) []byte {
+	if len(parts) < 2 {
+		return []byte("-ERR wrong number of arguments for 'psubscribe' command\r\n")
+	}
+	return enterSubscribed(conn, store, parts)
+}
+
+// handleSSubscribe implements SSUBSCRIBE shard-channel [shard-channel ...].
+// Messages arrive as [smessage, channel, payload].
+func handleSSubscribe(conn net.Conn, store *core.KVStore, parts []string) []byte {
+	if len(parts) < 2 {
+		return []byte("-ERR wrong number of arguments for 'ssubscribe' command\r\n")
+	}
+	return enterSubscribed(conn, store, parts)
+}
+
+// handleRSubscribe implements RSUBSCRIBE channel FROM offset | SINCE unix-ms,
+// which subscribes to a retained channel and first replays the retained
+// messages from that point. Messages on the channel are then sent as
+// [rmessage, channel, offset, payload]; if messages after the requested
+// point were trimmed, a [gap, channel, first-retained-offset] push comes
+// before the replay.
+func handleRSubscribe(conn net.Conn, store *core.KVStore, parts []string) []byte {
+	if len(parts) != 4 {
+		return []byte("-ERR wrong number of arguments for 'rsubscribe' command\r\n")
+	}
+	if _, errResp := parseReplayFrom(parts[2:]); errResp != nil {
+		return errResp
+	}
+	return enterSubscribed(conn, store, parts)
+}
+
+// parseReplayFrom parses "FROM offset" or "SINCE unix-ms".
+func parseReplayFrom(args []string) (core.ReplayFrom, []byte) {
+	n, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil || n < 0 {
+		return core.ReplayFrom{}, []byte("-ERR value is not an integer or out of range\r\n")
+	}
+	switch strings.ToUpper(args[0]) {
+	case "FROM":
+		if n == 0 {
+			return core.ReplayFrom{}, []byte("-ERR offsets start at 1\r\n")
+		}
+		return core.ReplayFrom{Offset: uint64(n)}, nil
+	case "SINCE":
+		return core.ReplayFrom{Since: time.UnixMilli(n)}, nil
+	}
+	return core.ReplayFrom{}, []byte("-ERR syntax error\r\n")
+}
+
+// handleRetain implements RETAIN channel [MAXLEN n] [MAXAGE ms], which keeps
+// the channel's recent messages for RSUBSCRIBE, and RETAIN channel OFF.
+func handleRetain(conn net.Conn, store *core.KVStore, parts []string) []byte {
+	if len(parts) < 3 {
+		return []byte("-ERR wrong number of arguments for 'retain' command\r\n")
+	}
+	if len(parts) == 3 && strings.ToUpper(parts[2]) == "OFF" {
+		store.Hub.StopRetaining(parts[1])
+		return []byte("+OK\r\n")
+	}
+	var opts core.RetentionOptions
+	for i := 2; i < len(parts); i += 2 {
+		if i+1 >= len(parts) {
+			return []byte("-ERR syntax error\r\n")
+		}
+		n, err := strconv.Atoi(parts[i+1])
+		if err != nil || n <= 0 {
+			return []byte("-ERR value is not an integer or out of range\r\n")
 		}
+		switch strings.ToUpper(parts[i]) {
+		case "MAXLEN":
+			opts.MaxLen = n
+		case "MAXAGE":
+			opts.MaxAge = time.Duration(n) * time.Millisecond
+		default:
+			return []byte("-ERR syntax error\r\n")
+		}
+	}
+	if err := store.Hub.Retain(parts[1], opts); err != nil {
+		return errorReply(err)
+	}
+	return []byte("+OK\r\n")
+}
+
+// handleUnsubscribe answers UNSUBSCRIBE, PUNSUBSCRIBE and SUNSUBSCRIBE from a client that
+// is not subscribed to anything.
+func handleUnsubscribe(conn net.Conn, store *core.KVStore, parts []string) []byte {
+	kind := strings.ToLower(parts[0])
+	if len(parts) == 1 {
+		return emptySubscriptionReply(kind, 0)
 	}
+	return subscriptionReply(kind, parts[1:], make([]int, len(parts)-1))
+}
+
+// enterSubscribed runs the subscribing command in parts and serves the
+// connection in subscribed mode.
+func enterSubscribed(conn net.Conn, store *core.KVStore, parts []string) []byte {
+	sub := store.Hub.NewSubscriber()
+	defer store.Hub.RemoveSubscriber(sub)
 
+	reply, subscribed := subscribedCommand(store, sub, parts)
+	if !subscribed {
+		return reply
+	}
+	if _, err := conn.Write(reply); err != nil {
+		return nil
+	}
+	subscribedLoop(conn, store, sub)
 	return nil
 }
+
+// subscribedLoop serves a subscribed client. Published messages are written
+// as they arrive while a reader goroutine feeds in the client's commands one
+// at a time, so it never reads past the command that ends subscribed mode.
+// As in Redis, subscribed clients have no idle timeout: a listener may go
+// quiet for as long as its channels do. It returns once the client has no
+// subscriptions left, sent QUIT or RESET, or the connection is gone, and
+// never leaves the reader goroutine running. If the connection failed, it
+// is closed before returning, so handleClient never keeps serving a client
+// whose subscriptions were dropped.
+func subscribedLoop(conn net.Conn, store *core.KVStore, sub *core.Subscriber) {
+	commands := make(chan []string)
+	next := make(chan bool)
+	conn.SetReadDeadline(time.Time{})
+	go func() {
+		defer close(commands)
+		reader := connReader(conn)
+		for {
+			parts, err := readCommand(reader)
+			if err != nil {
+				return
+			}
+			if len(parts) == 0 {
+				continue
+			}
+			commands <- parts
+			if !<-next {
+				return
+			}
+		}
+	}()
+
+	// stop closes the connection, which ends a pending read, and releases
+	// a reader waiting to hand over a command.
+	stop := func() {
+		conn.Close()
+		for range commands {
+			next <- false
+		}
+	}
+
+	write := func(reply []byte) error {
+		conn.SetWriteDeadline(idleDeadline())
+		_, err := conn.Write(reply)
+		return err
+	}
+
+	for {
+		select {
+		case msg, ok := <-sub.Messages():
+			if !ok {
+				// Disconnected by the slow consumer policy
+				stop()
+				return
+			}
+			if err := write(messageReply(msg)); err != nil {
+				stop()
+				return
+			}
+		case parts, ok := <-commands:
+			if !ok {
+				// The read failed
+				conn.Close()
+				return
+			}
+			if strings.ToUpper(parts[0]) == "QUIT" {
+				write([]byte("+OK\r\n"))
+				next <- false
+				stop()
+				return
+			}
+			reply, subscribed := subscribedCommand(store, sub, parts)
+			if err := write(reply); err != nil {
+				next <- false
+				stop()
+				return
+			}
+			next <- subscribed
+			if !subscribed {
+				return
+			}
+		}
+	}
+}
+
+// handlePubSub implements the PUBSUB introspection subcommands:
+// CHANNELS [pattern], NUMSUB [channel ...], NUMPAT, SHARDCHANNELS [pattern],
+// SHARDNUMSUB [channel ...], and STATS [channel ...], which reports
+// delivered and dropped counts per channel.
+func handlePubSub(conn net.Conn, store *core.KVStore, parts []string) []byte {
+	if len(parts) < 2 {
+		return []byte("-ERR wrong number of arguments for 'pubsub' command\r\n")
+	}
+	args := parts[2:]
+	switch strings.ToUpper(parts[1]) {
+	case "CHANNELS", "SHARDCHANNELS":
+		if len(args) > 1 {
+			return []byte("-ERR wrong number of arguments for 'pubsub|" + strings.ToLower(parts[1]) + "' command\r\n")
+		}
+		pattern := ""
+		if len(args) == 1 {
+			pattern = args[0]
+		}
+		if strings.EqualFold(parts[1], "SHARDCHANNELS") {
+			return bulkStringArray(store.Hub.ShardChannels(pattern))
+		}
+		return bulkStringArray(store.Hub.Channels(pattern))
+	case "NUMSUB", "SHARDNUMSUB":
+		counts := store.Hub.NumSub(args...)
+		if strings.EqualFold(parts[1], "SHARDNUMSUB") {
+			counts = store.Hub.ShardNumSub(args...)
+		}
+		buf := appendArrayHeader(nil, 2*len(args))
+		for i, channel := range args {
+			buf = appendBulkString(buf, channel)
+			buf = appendInteger(buf, int64(counts[i]))
+		}
+		return buf
+	case "NUMPAT":
+		return integerReply(int64(store.Hub.NumPat()))
+	case "STATS":
+		buf := appendArrayHeader(nil, len(args))
+		for _, channel := range args {
+			delivered, dropped, _ := store.Hub.ChannelStats(channel)
+			buf = appendArrayHeader(buf, 5)
+			buf = appendBulkString(buf, channel)
+			buf = appendBulkString(buf, "delivered")
+			buf = appendInteger(buf, int64(delivered))
+			buf = appendBulkString(buf, "dropped")
+			buf = appendInteger(buf, int64(dropped))
+		}
+		return buf
+	}
+	return []byte("-ERR unknown subcommand '" + parts[1] + "'\r\n")
+}
+
+// subscribedCommand runs a command sent in subscribed mode and reports
+// whether the client stays in subscribed mode. Like Redis over RESP2, only
+// the subscription commands, PING, QUIT and RESET are accepted; QUIT is
+// handled by subscribedLoop since it closes the connection.
+func subscribedCommand(store *core.KVStore, sub *core.Subscriber, parts []string) ([]byte, bool) {
+	var names []string
+	var counts []int
+	switch cmd := strings.ToUpper(parts[0]); cmd {
+	case "SUBSCRIBE", "PSUBSCRIBE", "SSUBSCRIBE":
+		if len(parts) < 2 {
+			return []byte("-ERR wrong number of arguments for '" + strings.ToLower(cmd) + "' command\r\n"), true
+		}
+		names = parts[1:]
+		switch cmd {
+		case "SUBSCRIBE":
+			counts = store.Hub.AddChannels(sub, names...)
+		case "PSUBSCRIBE":
+			counts = store.Hub.AddPatterns(sub, names...)
+		default:
+			counts = store.Hub.AddShardChannels(sub, names...)
+		}
+	case "RSUBSCRIBE":
+		if len(parts) != 4 {
+			return []byte("-ERR wrong number of arguments for 'rsubscribe' command\r\n"), true
+		}
+		from, errResp := parseReplayFrom(parts[2:])
+		if errResp != nil {
+			return errResp, true
+		}
+		replay, err := store.Hub.Resume(sub, parts[1], from)
+		if err != nil {
+			return errorReply(err), isSubscribed(store, sub)
+		}
+		count := store.Hub.SubscriptionCount(sub)
+		reply := subscriptionReply("rsubscribe", parts[1:2], []int{count})
+		if replay.Gap {
+			reply = appendArrayHeader(reply, 3)
+			reply = appendBulkString(reply, "gap")
+			reply = appendBulkString(reply, parts[1])
+			reply = appendInteger(reply, int64(replay.FirstOffset))
+		}
+		for _, msg := range replay.Messages {
+			reply = append(reply, messageReply(msg)...)
+		}
+		return reply, isSubscribed(store, sub)
+	case "UNSUBSCRIBE":
+		names, counts = store.Hub.RemoveChannels(sub, parts[1:]...)
+	case "PUNSUBSCRIBE":
+		names, counts = store.Hub.RemovePatterns(sub, parts[1:]...)
+	case "SUNSUBSCRIBE":
+		names, counts = store.Hub.RemoveShardChannels(sub, parts[1:]...)
+	case "PING":
+		if len(parts) > 2 {
+			return []byte("-ERR wrong number of arguments for 'ping' command\r\n"), true
+		}
+		message := ""
+		if len(parts) == 2 {
+			message = parts[1]
+		}
+		return bulkStringArray([]string{"pong", message}), true
+	case "RESET":
+		store.Hub.RemoveChannels(sub)
+		store.Hub.RemovePatterns(sub)
+		store.Hub.RemoveShardChannels(sub)
+		return []byte("+RESET\r\n"), false
+	default:
+		return []byte(fmt.Sprintf("-ERR Can't execute '%s': only (P|R|S)SUBSCRIBE / (P|S)UNSUBSCRIBE / PING / QUIT / RESET are allowed in this context\r\n",
+			strings.ToLower(cmd))), true
+	}
+
+	if len(names) == 0 {
+		// Nothing of this kind to drop; report what is left of the other kind
+		remaining := store.Hub.SubscriptionCount(sub)
+		if strings.EqualFold(parts[0], "SUNSUBSCRIBE") {
+			remaining = store.Hub.ShardSubscriptionCount(sub)
+		}
+		return emptySubscriptionReply(strings.ToLower(parts[0]), remaining), isSubscribed(store, sub)
+	}
+	return subscriptionReply(strings.ToLower(parts[0]), names, counts), isSubscribed(store, sub)
+}
+
+// isSubscribed reports whether sub has any channel, pattern or shard
+// channel left. Replies count shard channels separately, so their counts
+// alone don't tell.
+func isSubscribed(store *core.KVStore, sub *core.Subscriber) bool {
+	return store.Hub.SubscriptionCount(sub)+store.Hub.ShardSubscriptionCount(sub) > 0
+}
+
+// subscriptionReply builds one [kind, channel, count] push per channel.
+func subscriptionReply(kind string, channels []string, counts []int) []byte {
+	var buf []byte
+	for i, channel := range channels {
+		buf = appendArrayHeader(buf, 3)
+		buf = appendBulkString(buf, kind)
+		buf = appendBulkString(buf, channel)
+		buf = appendInteger(buf, int64(counts[i]))
+	}
+	return buf
+}
+
+// emptySubscriptionReply is the reply to an unsubscribe with nothing to
+// drop: the channel is null and count is the number of subscriptions left.
+func emptySubscriptionReply(kind string, count int) []byte {
+	buf := appendArrayHeader(nil, 3)
+	buf = appendBulkString(buf, kind)
+	buf = append(buf, "$-1\r\n"...)
+	return appendInteger(buf, int64(count))
+}
+
+// messageReply builds the [message, channel, payload] push for msg,
+// [pmessage, pattern, channel, payload] for a pattern match,
+// [rmessage, channel, offset, payload] on a resumed retained channel, or
+// [smessage, channel, payload] on a shard channel.
+func messageReply(msg core.Message) []byte {
+	if msg.Sharded {
+		buf := appendArrayHeader(nil, 3)
+		buf = appendBulkString(buf, "smessage")
+		buf = appendBulkString(buf, msg.Channel)
+		return appendBulkString(buf, msg.Payload)
+	}
+	if msg.Offset > 0 {
+		buf := appendArrayHeader(nil, 4)
+		buf = appendBulkString(buf, "rmessage")
+		buf = appendBulkString(buf, msg.Channel)
+		buf = appendInteger(buf, int64(msg.Offset))
+		return appendBulkString(buf, msg.Payload)
+	}
+	if msg.Pattern != "" {
+		buf := appendArrayHeader(nil, 4)
+		buf = appendBulkString(buf, "pmessage")
+		buf = appendBulkString(buf, msg.Pattern)
+		buf = appendBulkString(buf, msg.Channel)
+		return appendBulkString(buf, msg.Payload)
+	}
+	buf := appendArrayHeader(nil, 3)
+	buf = appendBulkString(buf, "message")
+	buf = appendBulkString(buf, msg.Channel)
+	return appendBulkString(buf, msg.Payload)
+}
@@ -0,0 +1,245 @@
+package server
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/Syed-Suhaan/SusyDB/pkg/core"
+)
+
+// pipeClient connects a client to handleClient over an in-memory pipe.
+func pipeClient(t *testing.T, store *core.KVStore) (net.Conn, *bufio.Reader) {
+	t.Helper()
+	client, server := net.Pipe()
+	go handleClient(server, store)
+	t.Cleanup(func() { client.Close() })
+	client.SetDeadline(time.Now().Add(5 * time.Second))
+	return client, bufio.NewReader(client)
+}
+
+// expectReply reads exactly len(want) bytes and compares them with want.
+func expectReply(t *testing.T, reader *bufio.Reader, want string) {
+	t.Helper()
+	got := make([]byte, len(want))
+	if _, err := io.ReadFull(reader, got); err != nil {
+		t.Fatalf("reading reply: %v (got %q, want %q)", err, got, want)
+	}
+	if string(got) != want {
+		t.Fatalf("reply = %q, want %q", got, want)
+	}
+}
+
+func TestSubscribeUnsubscribe(t *testing.T) {
+	store := core.NewKVStore()
+	conn, reader := pipeClient(t, store)
+
+	conn.Write([]byte("SUBSCRIBE a b\r\n"))
+	expectReply(t, reader, "*3\r\n$9\r\nsubscribe\r\n$1\r\na\r\n:1\r\n*3\r\n$9\r\nsubscribe\r\n$1\r\nb\r\n:2\r\n")
+
+	if n := store.Hub.Publish("b", "hello"); n != 1 {
+		t.Fatalf("Publish() = %d, want 1", n)
+	}
+	expectReply(t, reader, "*3\r\n$7\r\nmessage\r\n$1\r\nb\r\n$5\r\nhello\r\n")
+
+	conn.Write([]byte("SUBSCRIBE c\r\n"))
+	expectReply(t, reader, "*3\r\n$9\r\nsubscribe\r\n$1\r\nc\r\n:3\r\n")
+	conn.Write([]byte("UNSUBSCRIBE a\r\n"))
+	expectReply(t, reader, "*3\r\n$11\r\nunsubscribe\r\n$1\r\na\r\n:2\r\n")
+	conn.Write([]byte("UNSUBSCRIBE\r\n"))
+	expectReply(t, reader, "*3\r\n$11\r\nunsubscribe\r\n$1\r\nb\r\n:1\r\n*3\r\n$11\r\nunsubscribe\r\n$1\r\nc\r\n:0\r\n")
+
+	// Back in normal mode
+	conn.Write([]byte("PING\r\n"))
+	expectReply(t, reader, "+PONG\r\n")
+	if n := store.Hub.Publish("b", "hello"); n != 0 {
+		t.Errorf("Publish() after UNSUBSCRIBE = %d, want 0", n)
+	}
+}
+
+func TestSubscribeCleanupOnDisconnect(t *testing.T) {
+	store := core.NewKVStore()
+	conn, reader := pipeClient(t, store)
+
+	conn.Write([]byte("SUBSCRIBE a\r\n"))
+	expectReply(t, reader, "*3\r\n$9\r\nsubscribe\r\n$1\r\na\r\n:1\r\n")
+	conn.Close()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for store.Hub.Publish("a", "x") != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("subscription still active after the client disconnected")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestPSubscribe(t *testing.T) {
+	store := core.NewKVStore()
+	conn, reader := pipeClient(t, store)
+
+	conn.Write([]byte("PSUBSCRIBE orders.*\r\n"))
+	expectReply(t, reader, "*3\r\n$10\r\npsubscribe\r\n$8\r\norders.*\r\n:1\r\n")
+	store.Hub.Publish("orders.1", "new")
+	expectReply(t, reader, "*4\r\n$8\r\npmessage\r\n$8\r\norders.*\r\n$8\r\norders.1\r\n$3\r\nnew\r\n")
+
+	// No channel subscriptions to drop; the pattern keeps the client subscribed
+	conn.Write([]byte("UNSUBSCRIBE\r\n"))
+	expectReply(t, reader, "*3\r\n$11\r\nunsubscribe\r\n$-1\r\n:1\r\n")
+	conn.Write([]byte("PUNSUBSCRIBE\r\n"))
+	expectReply(t, reader, "*3\r\n$12\r\npunsubscribe\r\n$8\r\norders.*\r\n:0\r\n")
+	conn.Write([]byte("PING\r\n"))
+	expectReply(t, reader, "+PONG\r\n")
+}
+
+func TestSubscribedModeCommands(t *testing.T) {
+	store := core.NewKVStore()
+	conn, reader := pipeClient(t, store)
+
+	conn.Write([]byte("SUBSCRIBE a\r\n"))
+	expectReply(t, reader, "*3\r\n$9\r\nsubscribe\r\n$1\r\na\r\n:1\r\n")
+	conn.Write([]byte("PING\r\n"))
+	expectReply(t, reader, "*2\r\n$4\r\npong\r\n$0\r\n\r\n")
+	conn.Write([]byte("PING hi\r\n"))
+	expectReply(t, reader, "*2\r\n$4\r\npong\r\n$2\r\nhi\r\n")
+	conn.Write([]byte("GET a\r\n"))
+	expectReply(t, reader, "-ERR Can't execute 'get': only (P|R|S)SUBSCRIBE / (P|S)UNSUBSCRIBE / PING / QUIT / RESET are allowed in this context\r\n")
+
+	// RESET drops every subscription and leaves subscribed mode
+	conn.Write([]byte("PSUBSCRIBE b*\r\n"))
+	expectReply(t, reader, "*3\r\n$10\r\npsubscribe\r\n$2\r\nb*\r\n:2\r\n")
+	conn.Write([]byte("RESET\r\n"))
+	expectReply(t, reader, "+RESET\r\n")
+	if n := store.Hub.Publish("a", "x") + store.Hub.Publish("bb", "x"); n != 0 {
+		t.Errorf("Publish() after RESET = %d deliveries, want 0", n)
+	}
+	conn.Write([]byte("PING\r\n"))
+	expectReply(t, reader, "+PONG\r\n")
+
+	conn.Write([]byte("SUBSCRIBE a\r\n"))
+	expectReply(t, reader, "*3\r\n$9\r\nsubscribe\r\n$1\r\na\r\n:1\r\n")
+	conn.Write([]byte("QUIT\r\n"))
+	expectReply(t, reader, "+OK\r\n")
+	if _, err := reader.ReadByte(); err != io.EOF {
+		t.Errorf("read after QUIT = %v, want EOF", err)
+	}
+}
+
+func TestSubscribedClientNoIdleTimeout(t *testing.T) {
+	defer clientTimeout.Store(clientTimeout.Swap(int64(50 * time.Millisecond)))
+	store := core.NewKVStore()
+	conn, reader := pipeClient(t, store)
+
+	conn.Write([]byte("SUBSCRIBE a\r\n"))
+	expectReply(t, reader, "*3\r\n$9\r\nsubscribe\r\n$1\r\na\r\n:1\r\n")
+	time.Sleep(200 * time.Millisecond)
+	if n := store.Hub.Publish("a", "x"); n != 1 {
+		t.Fatalf("Publish() after idling = %d deliveries, want 1", n)
+	}
+	expectReply(t, reader, "*3\r\n$7\r\nmessage\r\n$1\r\na\r\n$1\r\nx\r\n")
+
+	// Once it leaves subscribed mode the timeout applies again and the
+	// connection is closed, not left open without its subscriptions.
+	conn.Write([]byte("UNSUBSCRIBE\r\n"))
+	expectReply(t, reader, "*3\r\n$11\r\nunsubscribe\r\n$1\r\na\r\n:0\r\n")
+	if _, err := reader.ReadByte(); err != io.EOF {
+		t.Errorf("read from idle client = %v, want EOF", err)
+	}
+}
+
+func TestPubSubIntrospection(t *testing.T) {
+	store := core.NewKVStore()
+	sub := store.Hub.NewSubscriber()
+	store.Hub.AddChannels(sub, "news", "sports")
+	store.Hub.AddPatterns(sub, "n*")
+	store.Hub.AddShardChannels(sub, "orders")
+	store.Hub.Publish("news", "x")
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"PUBSUB", "CHANNELS"}, "*2\r\n$4\r\nnews\r\n$6\r\nsports\r\n"},
+		{[]string{"PUBSUB", "CHANNELS", "s*"}, "*1\r\n$6\r\nsports\r\n"},
+		{[]string{"PUBSUB", "NUMSUB", "news", "nope"}, "*4\r\n$4\r\nnews\r\n:1\r\n$4\r\nnope\r\n:0\r\n"},
+		{[]string{"PUBSUB", "NUMPAT"}, ":1\r\n"},
+		{[]string{"PUBSUB", "SHARDCHANNELS"}, "*1\r\n$6\r\norders\r\n"},
+		{[]string{"PUBSUB", "SHARDNUMSUB", "orders", "news"}, "*4\r\n$6\r\norders\r\n:1\r\n$4\r\nnews\r\n:0\r\n"},
+		{[]string{"PUBSUB", "STATS", "news"}, "*1\r\n*5\r\n$4\r\nnews\r\n$9\r\ndelivered\r\n:1\r\n$7\r\ndropped\r\n:0\r\n"},
+		{[]string{"PUBSUB", "NOPE"}, "-ERR unknown subcommand 'NOPE'\r\n"},
+	}
+	for _, tt := range tests {
+		if got := string(handlePubSub(nil, store, tt.args)); got != tt.want {
+			t.Errorf("%v = %q, want %q", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestSlowSubscriberDisconnected(t *testing.T) {
+	store := core.NewKVStore()
+	store.Hub = core.NewHubWithOptions(core.HubOptions{BufferSize: 1, Policy: core.Disconnect})
+	conn, reader := pipeClient(t, store)
+
+	conn.Write([]byte("SUBSCRIBE a\r\n"))
+	expectReply(t, reader, "*3\r\n$9\r\nsubscribe\r\n$1\r\na\r\n:1\r\n")
+	// The pipe is unbuffered and nobody reads, so the buffer fills up
+	for store.Hub.Stats().Disconnected == 0 {
+		store.Hub.Publish("a", "x")
+	}
+	if _, err := io.ReadAll(reader); err != nil {
+		t.Errorf("reading until the server closes the connection: %v", err)
+	}
+}
+
+func TestRSubscribe(t *testing.T) {
+	store := core.NewKVStore()
+	if got := string(handleRetain(nil, store, []string{"RETAIN", "orders", "MAXLEN", "2"})); got != "+OK\r\n" {
+		t.Fatalf("RETAIN = %q", got)
+	}
+	for _, msg := range []string{"a", "b", "c"} {
+		store.Hub.Publish("orders", msg)
+	}
+	conn, reader := pipeClient(t, store)
+
+	conn.Write([]byte("RSUBSCRIBE nope FROM 1\r\n"))
+	expectReply(t, reader, "-ERR channel is not retained\r\n")
+
+	conn.Write([]byte("RSUBSCRIBE orders FROM 1\r\n"))
+	expectReply(t, reader, "*3\r\n$10\r\nrsubscribe\r\n$6\r\norders\r\n:1\r\n"+
+		"*3\r\n$3\r\ngap\r\n$6\r\norders\r\n:2\r\n"+
+		"*4\r\n$8\r\nrmessage\r\n$6\r\norders\r\n:2\r\n$1\r\nb\r\n"+
+		"*4\r\n$8\r\nrmessage\r\n$6\r\norders\r\n:3\r\n$1\r\nc\r\n")
+	store.Hub.Publish("orders", "d")
+	expectReply(t, reader, "*4\r\n$8\r\nrmessage\r\n$6\r\norders\r\n:4\r\n$1\r\nd\r\n")
+}
+
+func TestSSubscribe(t *testing.T) {
+	store := core.NewKVStore()
+	conn, reader := pipeClient(t, store)
+
+	conn.Write([]byte("SSUBSCRIBE a b\r\n"))
+	expectReply(t, reader, "*3\r\n$10\r\nssubscribe\r\n$1\r\na\r\n:1\r\n*3\r\n$10\r\nssubscribe\r\n$1\r\nb\r\n:2\r\n")
+	if got := string(handleSPublish(nil, store, []string{"SPUBLISH", "a", "hi"})); got != ":1\r\n" {
+		t.Fatalf("SPUBLISH = %q, want :1", got)
+	}
+	expectReply(t, reader, "*3\r\n$8\r\nsmessage\r\n$1\r\na\r\n$2\r\nhi\r\n")
+	if n := store.Hub.Publish("a", "hi"); n != 0 {
+		t.Errorf("Publish() to a shard channel's name = %d, want 0", n)
+	}
+
+	// Replies count shard channels apart from channels and patterns
+	conn.Write([]byte("SUBSCRIBE c\r\n"))
+	expectReply(t, reader, "*3\r\n$9\r\nsubscribe\r\n$1\r\nc\r\n:1\r\n")
+	conn.Write([]byte("SUNSUBSCRIBE\r\n"))
+	expectReply(t, reader, "*3\r\n$12\r\nsunsubscribe\r\n$1\r\na\r\n:1\r\n*3\r\n$12\r\nsunsubscribe\r\n$1\r\nb\r\n:0\r\n")
+	conn.Write([]byte("SUNSUBSCRIBE\r\n"))
+	expectReply(t, reader, "*3\r\n$12\r\nsunsubscribe\r\n$-1\r\n:0\r\n")
+	conn.Write([]byte("UNSUBSCRIBE c\r\n"))
+	expectReply(t, reader, "*3\r\n$11\r\nunsubscribe\r\n$1\r\nc\r\n:0\r\n")
+
+	// Back in normal mode
+	conn.Write([]byte("PING\r\n"))
+	expectReply(t, reader, "+PONG\r\n")
+}
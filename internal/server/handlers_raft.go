@@ -0,0 +1,104 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/Syed-Suhaan/SusyDB/internal/raft"
+	"github.com/Syed-Suhaan/SusyDB/pkg/core"
+)
+
+// handleRaft implements the RAFT subcommands: INFO, ADDNODE id host:port
+// and REMOVENODE id for operators, and the RPCs members send each other.
+func handleRaft(conn net.Conn, store *core.KVStore, parts []string) []byte {
+	if len(parts) < 2 {
+		return []byte("-ERR wrong number of arguments for 'raft' command\r\n")
+	}
+	rs := raftOf(store)
+	if rs == nil {
+		return errorReply(errRaftDisabled)
+	}
+	sub, args := strings.ToUpper(parts[1]), parts[2:]
+	switch sub {
+	case "INFO":
+		return bulkString(rs.info())
+	case "ADDNODE", "REMOVENODE":
+		if (sub == "ADDNODE" && len(args) != 2) || (sub == "REMOVENODE" && len(args) != 1) {
+			break
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), raftCommitTimeout)
+		defer cancel()
+		var err error
+		if sub == "ADDNODE" {
+			err = rs.node.AddNode(ctx, args[0], args[1])
+		} else {
+			err = rs.node.RemoveNode(ctx, args[0])
+		}
+		if err != nil {
+			return raftWriteErrorReply(err)
+		}
+		return []byte("+OK\r\n")
+	case "APPENDENTRIES":
+		if len(args) < 5 {
+			break
+		}
+		v, err := parseUints([]string{args[0], args[2], args[3], args[4]}, 4)
+		if err != nil {
+			return errorReply(err)
+		}
+		entries, err := parseEntries(args[5:])
+		if err != nil {
+			return errorReply(err)
+		}
+		resp := rs.node.HandleAppendEntries(&raft.AppendEntriesRequest{
+			Term: v[0], Leader: args[1], PrevIndex: v[1], PrevTerm: v[2], Commit: v[3], Entries: entries,
+		})
+		return bulkStringArray([]string{u64(resp.Term), boolFlag(resp.Success), u64(resp.LastIndex)})
+	case "REQUESTVOTE":
+		if len(args) != 4 {
+			break
+		}
+		v, err := parseUints([]string{args[0], args[2], args[3]}, 3)
+		if err != nil {
+			return errorReply(err)
+		}
+		resp := rs.node.HandleRequestVote(&raft.VoteRequest{Term: v[0], Candidate: args[1], LastIndex: v[1], LastTerm: v[2]})
+		return bulkStringArray([]string{u64(resp.Term), boolFlag(resp.Granted)})
+	case "INSTALLSNAPSHOT":
+		if len(args) < 5 || len(args)%2 == 0 {
+			break
+		}
+		v, err := parseUints([]string{args[0], args[2], args[3]}, 3)
+		if err != nil {
+			return errorReply(err)
+		}
+		members := make(map[string]string)
+		for i := 5; i+1 < len(args); i += 2 {
+			members[args[i]] = args[i+1]
+		}
+		resp := rs.node.HandleInstallSnapshot(&raft.SnapshotRequest{
+			Term: v[0], Leader: args[1], Index: v[1], LastTerm: v[2], Members: members, Data: []byte(args[4]),
+		})
+		return bulkStringArray([]string{u64(resp.Term)})
+	case "READINDEX":
+		ctx, cancel := context.WithTimeout(context.Background(), raftCommitTimeout)
+		defer cancel()
+		index, err := rs.node.LeaderReadIndex(ctx)
+		if err != nil {
+			return raftErrorReply(err)
+		}
+		return bulkStringArray([]string{u64(index)})
+	default:
+		return []byte(fmt.Sprintf("-ERR unknown subcommand '%s'\r\n", parts[1]))
+	}
+	return []byte(fmt.Sprintf("-ERR wrong number of arguments for 'raft|%s' command\r\n", strings.ToLower(sub)))
+}
+
+func boolFlag(b bool) string {
+	if b {
+		return "1"
+	}
+	return "0"
+}
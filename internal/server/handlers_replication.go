@@ -0,0 +1,98 @@
+package server
+
+import (
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/Syed-Suhaan/SusyDB/pkg/core"
+)
+
+// handleReplicaOf implements REPLICAOF host port, which turns the server
+// into a read-only replica of that primary, and REPLICAOF NO ONE, which
+// promotes it back to a primary keeping its data.
+func handleReplicaOf(conn net.Conn, store *core.KVStore, parts []string) []byte {
+	if len(parts) != 3 {
+		return []byte("-ERR wrong number of arguments for 'replicaof' command\r\n")
+	}
+	if strings.EqualFold(parts[1], "NO") && strings.EqualFold(parts[2], "ONE") {
+		ReplicaOf(store, "")
+		return []byte("+OK\r\n")
+	}
+	port, err := strconv.Atoi(parts[2])
+	if err != nil || port <= 0 || port > 65535 {
+		return []byte("-ERR Invalid master port\r\n")
+	}
+	addr := net.JoinHostPort(parts[1], parts[2])
+	r := replicationOf(store)
+	r.mu.Lock()
+	same := r.link != nil && r.link.addr == addr
+	r.mu.Unlock()
+	if same {
+		return []byte("+OK Already connected to specified master\r\n")
+	}
+	ReplicaOf(store, addr)
+	return []byte("+OK\r\n")
+}
+
+// handleReplConf implements REPLCONF option value [option value ...], sent
+// by replicas. listening-port records the port the replica serves clients
+// on, for INFO; other options are accepted and ignored. ACKs arrive on the
+// replication stream and are read by the SYNC loop.
+func handleReplConf(conn net.Conn, store *core.KVStore, parts []string) []byte {
+	if len(parts) < 3 || len(parts)%2 == 0 {
+		return []byte("-ERR wrong number of arguments for 'replconf' command\r\n")
+	}
+	for i := 1; i < len(parts); i += 2 {
+		if strings.EqualFold(parts[i], "listening-port") {
+			port, err := strconv.Atoi(parts[i+1])
+			if err != nil {
+				return []byte("-ERR value is not an integer or out of range\r\n")
+			}
+			if cc, ok := conn.(*clientConn); ok {
+				cc.replicaPort = port
+			}
+		}
+	}
+	return []byte("+OK\r\n")
+}
+
+// handleSync implements SYNC, the full sync of replicas that predate
+// PSYNC: the connection receives the snapshot as a bulk string and then
+// every write as a RESP command.
+func handleSync(conn net.Conn, store *core.KVStore, parts []string) []byte {
+	return serveReplicaConn(conn, store, false, "", 0)
+}
+
+// handlePSync implements PSYNC replid offset. A replica that followed this
+// stream, or the one this server followed before it was promoted, gets
+// +CONTINUE and only the writes after offset, as long as the backlog still
+// holds them. Otherwise it gets +FULLRESYNC replid offset and a snapshot.
+// Either way the stream follows, and the replica reports its progress with
+// REPLCONF ACK offset. PSYNC ? -1 asks for a full sync.
+func handlePSync(conn net.Conn, store *core.KVStore, parts []string) []byte {
+	if len(parts) != 3 {
+		return []byte("-ERR wrong number of arguments for 'psync' command\r\n")
+	}
+	offset, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return []byte("-ERR value is not an integer or out of range\r\n")
+	}
+	return serveReplicaConn(conn, store, true, parts[1], offset-1)
+}
+
+// serveReplicaConn turns a client connection into a replica's link.
+func serveReplicaConn(conn net.Conn, store *core.KVStore, psync bool, replID string, from int64) []byte {
+	cc, ok := conn.(*clientConn)
+	if !ok {
+		return []byte("-ERR SYNC needs a client connection\r\n")
+	}
+	r := replicationOf(store)
+	rc, initial, err := r.attachReplica(conn, cc.replicaPort, psync, replID, from)
+	if err != nil {
+		return errorReply(err)
+	}
+	defer r.detachReplica(rc)
+	r.serveReplica(rc, cc.reader, initial)
+	return nil
+}
@@ -0,0 +1,299 @@
+package server
+
+import (
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Syed-Suhaan/SusyDB/pkg/core"
+)
+
+// handleFTCreate implements
+// FT.CREATE index [ON HASH] [PREFIX n prefix ...] SCHEMA field TAG|NUMERIC [field type ...]
+func handleFTCreate(conn net.Conn, store *core.KVStore, parts []string) []byte {
+	if len(parts) < 5 {
+		return []byte("-ERR wrong number of arguments for 'ft.create' command\r\n")
+	}
+	schema := core.IndexSchema{Name: parts[1]}
+	i := 2
+	for ; i < len(parts); i++ {
+		switch strings.ToUpper(parts[i]) {
+		case "ON":
+			if i+1 >= len(parts) || strings.ToUpper(parts[i+1]) != "HASH" {
+				return []byte("-ERR only ON HASH is supported\r\n")
+			}
+			i++
+			continue
+		case "PREFIX":
+			if i+1 >= len(parts) {
+				return []byte("-ERR syntax error\r\n")
+			}
+			n, err := strconv.Atoi(parts[i+1])
+			if err != nil || n < 0 || i+1+n >= len(parts) {
+				return []byte("-ERR bad PREFIX count\r\n")
+			}
+			schema.Prefixes = append(schema.Prefixes, parts[i+2:i+2+n]...)
+			i += 1 + n
+			continue
+		case "SCHEMA":
+		default:
+			return []byte("-ERR syntax error\r\n")
+		}
+		break
+	}
+	if i >= len(parts) {
+		return []byte("-ERR missing SCHEMA\r\n")
+	}
+
+	fields, errResp := parseSchemaFields(parts[i+1:])
+	if errResp != nil {
+		return errResp
+	}
+	schema.Fields = fields
+	if err := store.CreateIndex(schema); err != nil {
+		return errorReply(err)
+	}
+	return []byte("+OK\r\n")
+}
+
+// parseSchemaFields parses the field list after SCHEMA:
+// field TAG | field NUMERIC | field VECTOR FLAT|HNSW nargs [attribute value ...]
+func parseSchemaFields(args []string) ([]core.IndexField, []byte) {
+	var fields []core.IndexField
+	for i := 0; i < len(args); i += 2 {
+		if i+1 >= len(args) {
+			return nil, []byte("-ERR syntax error\r\n")
+		}
+		field := core.IndexField{Name: args[i]}
+		switch strings.ToUpper(args[i+1]) {
+		case "TAG":
+			field.Type = core.IndexTag
+		case "NUMERIC":
+			field.Type = core.IndexNumeric
+		case "VECTOR":
+			opts, n, errResp := parseVectorOptions(args[i+2:])
+			if errResp != nil {
+				return nil, errResp
+			}
+			field.Type, field.Vector = core.IndexVector, opts
+			i += n
+		default:
+			return nil, []byte("-ERR unsupported field type '" + args[i+1] + "'\r\n")
+		}
+		fields = append(fields, field)
+	}
+	if len(fields) == 0 {
+		return nil, []byte("-ERR empty SCHEMA\r\n")
+	}
+	return fields, nil
+}
+
+// parseVectorOptions parses "FLAT|HNSW nargs [attribute value ...]" and
+// returns the number of arguments consumed.
+func parseVectorOptions(args []string) (*core.VectorOptions, int, []byte) {
+	if len(args) < 2 {
+		return nil, 0, []byte("-ERR syntax error\r\n")
+	}
+	opts := &core.VectorOptions{}
+	switch strings.ToUpper(args[0]) {
+	case "FLAT":
+		opts.Algorithm = core.VectorFlat
+	case "HNSW":
+		opts.Algorithm = core.VectorHNSW
+	default:
+		return nil, 0, []byte("-ERR unknown vector algorithm '" + args[0] + "'\r\n")
+	}
+	nargs, err := strconv.Atoi(args[1])
+	if err != nil || nargs < 0 || nargs%2 != 0 || 2+nargs > len(args) {
+		return nil, 0, []byte("-ERR bad vector attribute count\r\n")
+	}
+
+	for i := 2; i < 2+nargs; i += 2 {
+		name, value := strings.ToUpper(args[i]), args[i+1]
+		switch name {
+		case "TYPE":
+			if strings.ToUpper(value) != "FLOAT32" {
+				return nil, 0, []byte("-ERR only FLOAT32 vectors are supported\r\n")
+			}
+			continue
+		case "DISTANCE_METRIC":
+			switch strings.ToUpper(value) {
+			case "L2":
+				opts.Metric = core.VectorL2
+			case "COSINE":
+				opts.Metric = core.VectorCosine
+			case "IP":
+				opts.Metric = core.VectorIP
+			default:
+				return nil, 0, []byte("-ERR unknown distance metric '" + value + "'\r\n")
+			}
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			return nil, 0, []byte("-ERR bad value for vector attribute " + name + "\r\n")
+		}
+		switch name {
+		case "DIM":
+			opts.Dim = n
+		case "M":
+			opts.M = n
+		case "EF_CONSTRUCTION":
+			opts.EFConstruction = n
+		case "EF_RUNTIME":
+			opts.EFRuntime = n
+		default:
+			return nil, 0, []byte("-ERR unknown vector attribute " + name + "\r\n")
+		}
+	}
+	return opts, 2 + nargs, nil
+}
+
+func handleFTDropIndex(conn net.Conn, store *core.KVStore, parts []string) []byte {
+	if len(parts) != 2 {
+		return []byte("-ERR wrong number of arguments for 'ft.dropindex' command\r\n")
+	}
+	if err := store.DropIndex(parts[1]); err != nil {
+		return errorReply(err)
+	}
+	return []byte("+OK\r\n")
+}
+
+// handleFTSearch implements
+// FT.SEARCH index query [NOCONTENT] [PARAMS n name value ...] [LIMIT offset num].
+// The reply is the total match count followed by each key and, unless
+// NOCONTENT is given, its fields. KNN queries ("filter=>[KNN k @field $param]")
+// also return the distance in a __<field>_score field.
+func handleFTSearch(conn net.Conn, store *core.KVStore, parts []string) []byte {
+	if len(parts) < 3 {
+		return []byte("-ERR wrong number of arguments for 'ft.search' command\r\n")
+	}
+	offset, limit := 0, 10
+	noContent := false
+	params := make(map[string]string)
+	for i := 3; i < len(parts); i++ {
+		switch strings.ToUpper(parts[i]) {
+		case "NOCONTENT":
+			noContent = true
+		case "LIMIT":
+			if i+2 >= len(parts) {
+				return []byte("-ERR syntax error\r\n")
+			}
+			var err1, err2 error
+			offset, err1 = strconv.Atoi(parts[i+1])
+			limit, err2 = strconv.Atoi(parts[i+2])
+			if err1 != nil || err2 != nil || offset < 0 || limit < 0 {
+				return []byte("-ERR bad LIMIT\r\n")
+			}
+			i += 2
+		case "PARAMS":
+			if i+1 >= len(parts) {
+				return []byte("-ERR syntax error\r\n")
+			}
+			n, err := strconv.Atoi(parts[i+1])
+			if err != nil || n < 0 || n%2 != 0 || i+1+n >= len(parts) {
+				return []byte("-ERR bad PARAMS count\r\n")
+			}
+			for j := i + 2; j < i+2+n; j += 2 {
+				params[parts[j]] = parts[j+1]
+			}
+			i += 1 + n
+		default:
+			return []byte("-ERR syntax error\r\n")
+		}
+	}
+
+	if filter, knn, ok := strings.Cut(parts[2], "=>"); ok {
+		return vectorSearch(store, parts[1], filter, knn, params, offset, limit, noContent)
+	}
+
+	clauses, err := core.ParseQuery(parts[2])
+	if err != nil {
+		return errorReply(err)
+	}
+	result, err := store.Search(parts[1], clauses, offset, limit)
+	if err != nil {
+		return errorReply(err)
+	}
+	return searchReply(result.Total, result.Docs, noContent)
+}
+
+// vectorSearch runs the KNN part of a query, "[KNN k @field $param]",
+// restricted to the hashes matching filter.
+func vectorSearch(store *core.KVStore, index, filter, knn string, params map[string]string, offset, limit int, noContent bool) []byte {
+	knn = strings.TrimSpace(knn)
+	if !strings.HasPrefix(knn, "[") || !strings.HasSuffix(knn, "]") {
+		return []byte("-ERR Syntax error in KNN query\r\n")
+	}
+	args := strings.Fields(knn[1 : len(knn)-1])
+	if len(args) != 4 || strings.ToUpper(args[0]) != "KNN" || !strings.HasPrefix(args[2], "@") || !strings.HasPrefix(args[3], "$") {
+		return []byte("-ERR Syntax error in KNN query\r\n")
+	}
+	k, err := strconv.Atoi(args[1])
+	if err != nil || k < 0 {
+		return []byte("-ERR bad KNN count\r\n")
+	}
+	field := args[2][1:]
+	blob, ok := params[args[3][1:]]
+	if !ok {
+		return []byte("-ERR No such parameter '" + args[3][1:] + "'\r\n")
+	}
+	vec, err := core.DecodeVector([]byte(blob))
+	if err != nil {
+		return errorReply(err)
+	}
+
+	filter = strings.TrimSpace(filter)
+	if strings.HasPrefix(filter, "(") && strings.HasSuffix(filter, ")") {
+		filter = filter[1 : len(filter)-1]
+	}
+	clauses, err := core.ParseQuery(filter)
+	if err != nil {
+		return errorReply(err)
+	}
+
+	docs, err := store.VectorSearch(index, field, vec, k, clauses)
+	if err != nil {
+		return errorReply(err)
+	}
+	total := len(docs)
+	for _, doc := range docs {
+		doc.Fields["__"+field+"_score"] = strconv.FormatFloat(doc.Score, 'g', -1, 32)
+	}
+	if offset >= len(docs) {
+		docs = nil
+	} else {
+		docs = docs[offset:]
+	}
+	if len(docs) > limit {
+		docs = docs[:limit]
+	}
+	return searchReply(total, docs, noContent)
+}
+
+func searchReply(total int, docs []core.SearchDoc, noContent bool) []byte {
+	n := 1 + len(docs)
+	if !noContent {
+		n += len(docs)
+	}
+	buf := appendArrayHeader(nil, n)
+	buf = appendInteger(buf, int64(total))
+	for _, doc := range docs {
+		buf = appendBulkString(buf, doc.Key)
+		if noContent {
+			continue
+		}
+		names := make([]string, 0, len(doc.Fields))
+		for name := range doc.Fields {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		buf = appendArrayHeader(buf, 2*len(names))
+		for _, name := range names {
+			buf = appendBulkString(buf, name)
+			buf = appendBulkString(buf, doc.Fields[name])
+		}
+	}
+	return buf
+}
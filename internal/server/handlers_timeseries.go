@@ -0,0 +1,217 @@
+package server
+
+import (
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Syed-Suhaan/SusyDB/pkg/core"
+)
+
+func handleTSCreate(conn net.Conn, store *core.KVStore, parts []string) []byte {
+	if len(parts) < 2 {
+		return []byte("-ERR wrong number of arguments for 'ts.create' command\r\n")
+	}
+	opts, errResp := parseTSCreateOptions(parts[2:])
+	if errResp != nil {
+		return errResp
+	}
+	if err := store.TSCreate(parts[1], opts); err != nil {
+		return errorReply(err)
+	}
+	return []byte("+OK\r\n")
+}
+
+// handleTSAdd implements TS.ADD key timestamp|* value [RETENTION ms].
+// The options only apply when the series is created by this call.
+func handleTSAdd(conn net.Conn, store *core.KVStore, parts []string) []byte {
+	if len(parts) < 4 {
+		return []byte("-ERR wrong number of arguments for 'ts.add' command\r\n")
+	}
+	opts, errResp := parseTSCreateOptions(parts[4:])
+	if errResp != nil {
+		return errResp
+	}
+	ts, value, errResp := parseTSSample(parts[2], parts[3])
+	if errResp != nil {
+		return errResp
+	}
+	added, err := store.TSAdd(parts[1], ts, value, opts)
+	if err != nil {
+		return errorReply(err)
+	}
+	return integerReply(added)
+}
+
+// handleTSMAdd implements TS.MADD key timestamp value [key timestamp value ...].
+// Each sample succeeds or fails on its own, and the series must exist.
+func handleTSMAdd(conn net.Conn, store *core.KVStore, parts []string) []byte {
+	if len(parts) < 4 || (len(parts)-1)%3 != 0 {
+		return []byte("-ERR wrong number of arguments for 'ts.madd' command\r\n")
+	}
+	n := (len(parts) - 1) / 3
+	samples := make([]core.TSKeySample, 0, n)
+	parseErrs := make([][]byte, n)
+	for i := 0; i < n; i++ {
+		ts, value, errResp := parseTSSample(parts[3*i+2], parts[3*i+3])
+		if errResp != nil {
+			parseErrs[i] = errResp
+			continue
+		}
+		samples = append(samples, core.TSKeySample{Key: parts[3*i+1], TSSample: core.TSSample{Timestamp: ts, Value: value}})
+	}
+	errs := store.TSMAdd(samples)
+
+	buf := appendArrayHeader(nil, n)
+	for i := 0; i < n; i++ {
+		if parseErrs[i] != nil {
+			buf = append(buf, parseErrs[i]...)
+			continue
+		}
+		err, ts := errs[0], samples[0].Timestamp
+		errs, samples = errs[1:], samples[1:]
+		if err != nil {
+			buf = append(buf, errorReply(err)...)
+			continue
+		}
+		buf = appendInteger(buf, ts)
+	}
+	return buf
+}
+
+// handleTSRange implements
+// TS.RANGE key from|- to|+ [COUNT n] [AGGREGATION avg|min|max|sum|count bucket]
+func handleTSRange(conn net.Conn, store *core.KVStore, parts []string) []byte {
+	if len(parts) < 4 {
+		return []byte("-ERR wrong number of arguments for 'ts.range' command\r\n")
+	}
+	from, ok1 := parseTSBound(parts[2], math.MinInt64)
+	to, ok2 := parseTSBound(parts[3], math.MaxInt64)
+	if !ok1 || !ok2 {
+		return []byte("-ERR TSDB: invalid timestamp\r\n")
+	}
+
+	var opts core.TSRangeOptions
+	for i := 4; i < len(parts); i++ {
+		switch strings.ToUpper(parts[i]) {
+		case "COUNT":
+			if i+1 >= len(parts) {
+				return []byte("-ERR syntax error\r\n")
+			}
+			count, err := strconv.Atoi(parts[i+1])
+			if err != nil || count <= 0 {
+				return []byte("-ERR TSDB: invalid COUNT value\r\n")
+			}
+			opts.Count = count
+			i++
+		case "AGGREGATION":
+			if i+2 >= len(parts) {
+				return []byte("-ERR syntax error\r\n")
+			}
+			agg, duration, errResp := parseTSAggregation(parts[i+1], parts[i+2])
+			if errResp != nil {
+				return errResp
+			}
+			opts.Aggregation, opts.BucketDuration = agg, duration
+			i += 2
+		default:
+			return []byte("-ERR syntax error\r\n")
+		}
+	}
+
+	samples, err := store.TSRange(parts[1], from, to, opts)
+	if err != nil {
+		return errorReply(err)
+	}
+	buf := appendArrayHeader(nil, len(samples))
+	for _, s := range samples {
+		buf = appendArrayHeader(buf, 2)
+		buf = appendInteger(buf, s.Timestamp)
+		buf = appendBulkString(buf, strconv.FormatFloat(s.Value, 'f', -1, 64))
+	}
+	return buf
+}
+
+// handleTSCreateRule implements TS.CREATERULE source dest AGGREGATION type bucket.
+func handleTSCreateRule(conn net.Conn, store *core.KVStore, parts []string) []byte {
+	if len(parts) != 6 {
+		return []byte("-ERR wrong number of arguments for 'ts.createrule' command\r\n")
+	}
+	if strings.ToUpper(parts[3]) != "AGGREGATION" {
+		return []byte("-ERR syntax error\r\n")
+	}
+	agg, duration, errResp := parseTSAggregation(parts[4], parts[5])
+	if errResp != nil {
+		return errResp
+	}
+	if err := store.TSCreateRule(parts[1], parts[2], agg, duration); err != nil {
+		return errorReply(err)
+	}
+	return []byte("+OK\r\n")
+}
+
+func parseTSCreateOptions(args []string) (core.TSCreateOptions, []byte) {
+	var opts core.TSCreateOptions
+	for i := 0; i < len(args); i++ {
+		if strings.ToUpper(args[i]) != "RETENTION" || i+1 >= len(args) {
+			return opts, []byte("-ERR syntax error\r\n")
+		}
+		retention, err := strconv.ParseInt(args[i+1], 10, 64)
+		if err != nil || retention < 0 {
+			return opts, []byte("-ERR TSDB: invalid RETENTION value\r\n")
+		}
+		opts.Retention = retention
+		i++
+	}
+	return opts, nil
+}
+
+func parseTSSample(timestamp, value string) (int64, float64, []byte) {
+	var ts int64
+	if timestamp == "*" {
+		ts = time.Now().UnixMilli()
+	} else {
+		var err error
+		if ts, err = strconv.ParseInt(timestamp, 10, 64); err != nil || ts < 0 {
+			return 0, 0, []byte("-ERR TSDB: invalid timestamp\r\n")
+		}
+	}
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsNaN(v) {
+		return 0, 0, []byte("-ERR TSDB: invalid value\r\n")
+	}
+	return ts, v, nil
+}
+
+func parseTSBound(s string, open int64) (int64, bool) {
+	if s == "-" || s == "+" {
+		return open, true
+	}
+	ts, err := strconv.ParseInt(s, 10, 64)
+	return ts, err == nil
+}
+
+func parseTSAggregation(name, bucket string) (core.TSAggregation, int64, []byte) {
+	var agg core.TSAggregation
+	switch strings.ToLower(name) {
+	case "avg":
+		agg = core.TSAggAvg
+	case "min":
+		agg = core.TSAggMin
+	case "max":
+		agg = core.TSAggMax
+	case "sum":
+		agg = core.TSAggSum
+	case "count":
+		agg = core.TSAggCount
+	default:
+		return 0, 0, []byte("-ERR TSDB: unknown aggregation type\r\n")
+	}
+	duration, err := strconv.ParseInt(bucket, 10, 64)
+	if err != nil || duration <= 0 {
+		return 0, 0, []byte("-ERR TSDB: bucketDuration must be greater than zero\r\n")
+	}
+	return agg, duration, nil
+}
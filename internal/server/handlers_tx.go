@@ -0,0 +1,184 @@
+package server
+
+import (
+	"net"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/Syed-Suhaan/SusyDB/pkg/core"
+)
+
+// txForbidden are the commands refused inside MULTI: they take over the
+// connection, talk to other servers or hold locks of their own.
+var txForbidden = map[string]bool{
+	"SUBSCRIBE": true, "PSUBSCRIBE": true, "SSUBSCRIBE": true, "RSUBSCRIBE": true,
+	"SYNC": true, "PSYNC": true, "REPLICAOF": true, "SLAVEOF": true, "MIGRATE": true,
+}
+
+// txControl are the commands that run at once after MULTI instead of
+// being queued.
+var txControl = map[string]bool{"MULTI": true, "EXEC": true, "DISCARD": true, "RESET": true, "QUIT": true}
+
+// txLock isolates transactions per key, using the same stripes (by key
+// hash) as replication. Commands that touch the dataset read-lock the
+// stripes of their keys and EXEC write-locks the stripes of every key it
+// queued, so no other client sees or changes those keys halfway through a
+// transaction while commands on other keys run alongside it. Commands
+// without keys, or whose effects reach past the keys they name (see
+// keyStripes), lock every stripe. Stripes are always locked in ascending
+// order.
+type txLock struct {
+	stripes [replStripes]sync.RWMutex
+}
+
+var txLocks sync.Map // *core.KVStore -> *txLock
+
+func txLockOf(store *core.KVStore) *txLock {
+	if l, ok := txLocks.Load(store); ok {
+		return l.(*txLock)
+	}
+	l, _ := txLocks.LoadOrStore(store, new(txLock))
+	return l.(*txLock)
+}
+
+// allStripes lists every stripe, for commands that can't be pinned to keys.
+var allStripes = func() []int {
+	stripes := make([]int, replStripes)
+	for i := range stripes {
+		stripes[i] = i
+	}
+	return stripes
+}()
+
+// txStripes returns the sorted stripes that cmds lock.
+func txStripes(cmds [][]string) []int {
+	var stripes []int
+	for _, cmd := range cmds {
+		cmdName := strings.ToUpper(cmd[0])
+		if !touchesData(cmdName) {
+			continue
+		}
+		s := keyStripes(cmdName, cmd)
+		if s == nil {
+			return allStripes
+		}
+		stripes = append(stripes, s...)
+	}
+	sort.Ints(stripes)
+	return slices.Compact(stripes)
+}
+
+// rlock read-locks stripes and returns the function that unlocks them.
+func (l *txLock) rlock(stripes []int) func() {
+	for _, i := range stripes {
+		l.stripes[i].RLock()
+	}
+	return func() {
+		for _, i := range stripes {
+			l.stripes[i].RUnlock()
+		}
+	}
+}
+
+// lock write-locks stripes and returns the function that unlocks them.
+func (l *txLock) lock(stripes []int) func() {
+	for _, i := range stripes {
+		l.stripes[i].Lock()
+	}
+	return func() {
+		for _, i := range stripes {
+			l.stripes[i].Unlock()
+		}
+	}
+}
+
+// touchesData reports whether a command reads or writes the dataset.
+func touchesData(cmdName string) bool {
+	return writeCommands[cmdName] || raftRead(cmdName)
+}
+
+// queue adds a command sent after MULTI to the transaction. Commands that
+// can't run in a transaction are refused, and then make EXEC fail.
+func (c *clientConn) queue(cmdName string, parts []string) []byte {
+	if txForbidden[cmdName] {
+		c.txFailed = true
+		return []byte("-ERR Command not allowed inside a transaction\r\n")
+	}
+	c.queued = append(c.queued, parts)
+	return []byte("+QUEUED\r\n")
+}
+
+// discardTx leaves MULTI, dropping the queued commands.
+func (c *clientConn) discardTx() {
+	c.multi, c.queued, c.txFailed = false, nil, false
+}
+
+// handleMulti implements MULTI: the connection's next commands are queued
+// and run together by EXEC.
+func handleMulti(conn net.Conn, store *core.KVStore, parts []string) []byte {
+	cc, ok := conn.(*clientConn)
+	if !ok {
+		return []byte("-ERR MULTI is not allowed here\r\n")
+	}
+	if cc.multi {
+		return []byte("-ERR MULTI calls can not be nested\r\n")
+	}
+	cc.multi = true
+	return []byte("+OK\r\n")
+}
+
+// handleDiscard implements DISCARD, which drops the queued commands.
+func handleDiscard(conn net.Conn, store *core.KVStore, parts []string) []byte {
+	cc, ok := conn.(*clientConn)
+	if !ok || !cc.multi {
+		return []byte("-ERR DISCARD without MULTI\r\n")
+	}
+	cc.discardTx()
+	return []byte("+OK\r\n")
+}
+
+// handleExec implements EXEC: it runs the queued commands with the
+// stripes of their keys locked, so they take effect together, and replies
+// with an array of their replies. A command refused while queueing aborts
+// the whole transaction, and so does a cluster redirect for its keys, which
+// must all hash to one slot. Replicas apply the commands one by one as they
+// arrive.
+func handleExec(conn net.Conn, store *core.KVStore, parts []string) []byte {
+	cc, ok := conn.(*clientConn)
+	if !ok || !cc.multi {
+		return []byte("-ERR EXEC without MULTI\r\n")
+	}
+	queued, failed := cc.queued, cc.txFailed
+	cc.discardTx()
+	if failed {
+		return []byte("-EXECABORT Transaction discarded because of previous errors.\r\n")
+	}
+
+	if c := clusterOf(store); c != nil {
+		// Slots may have moved since the commands were queued. The route
+		// guard is held until every command has run, so MIGRATE can't move
+		// the keys halfway through.
+		var keys []string
+		for _, cmd := range queued {
+			keys = append(keys, commandKeys(strings.ToUpper(cmd[0]), cmd)...)
+		}
+		redirect, done := c.routeKeys(cc, "EXEC", keys)
+		if redirect != nil {
+			return redirect
+		}
+		if done != nil {
+			defer done()
+		}
+	}
+
+	unlock := txLockOf(store).lock(txStripes(queued))
+	defer unlock()
+	buf := appendArrayHeader(nil, len(queued))
+	for _, cmd := range queued {
+		cmdName := strings.ToUpper(cmd[0])
+		buf = append(buf, execute(cc, store, cmdName, streamHandlers[cmdName], cmd)...)
+	}
+	return buf
+}
@@ -0,0 +1,101 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Syed-Suhaan/SusyDB/pkg/core"
+)
+
+func TestMultiExec(t *testing.T) {
+	store := core.NewKVStore()
+	conn, reader := pipeClient(t, store)
+
+	conn.Write([]byte("MULTI\r\n"))
+	expectReply(t, reader, "+OK\r\n")
+	conn.Write([]byte("SET a 1\r\n"))
+	expectReply(t, reader, "+QUEUED\r\n")
+	conn.Write([]byte("INCR a\r\n"))
+	expectReply(t, reader, "+QUEUED\r\n")
+	conn.Write([]byte("HGET a f\r\n"))
+	expectReply(t, reader, "+QUEUED\r\n")
+	if _, ok, _ := store.Get("a"); ok {
+		t.Fatal("queued SET ran before EXEC")
+	}
+	// A failing command doesn't stop the others
+	conn.Write([]byte("GET a\r\n"))
+	expectReply(t, reader, "+QUEUED\r\n")
+	conn.Write([]byte("EXEC\r\n"))
+	expectReply(t, reader, "*4\r\n+OK\r\n:2\r\n-WARN WRONGTYPE Operation against a key holding the wrong kind of value\r\n$1\r\n2\r\n")
+
+	conn.Write([]byte("EXEC\r\n"))
+	expectReply(t, reader, "-ERR EXEC without MULTI\r\n")
+}
+
+func TestMultiDiscardAndAbort(t *testing.T) {
+	store := core.NewKVStore()
+	conn, reader := pipeClient(t, store)
+
+	conn.Write([]byte("MULTI\r\n"))
+	expectReply(t, reader, "+OK\r\n")
+	conn.Write([]byte("SET a 1\r\n"))
+	expectReply(t, reader, "+QUEUED\r\n")
+	conn.Write([]byte("MULTI\r\n"))
+	expectReply(t, reader, "-ERR MULTI calls can not be nested\r\n")
+	conn.Write([]byte("DISCARD\r\n"))
+	expectReply(t, reader, "+OK\r\n")
+	if _, ok, _ := store.Get("a"); ok {
+		t.Error("discarded SET ran")
+	}
+
+	// A refused command makes EXEC run nothing
+	conn.Write([]byte("MULTI\r\n"))
+	expectReply(t, reader, "+OK\r\n")
+	conn.Write([]byte("SET a 1\r\n"))
+	expectReply(t, reader, "+QUEUED\r\n")
+	conn.Write([]byte("NOPE\r\n"))
+	expectReply(t, reader, "-ERR unknown command\r\n")
+	conn.Write([]byte("SUBSCRIBE c\r\n"))
+	expectReply(t, reader, "-ERR Command not allowed inside a transaction\r\n")
+	conn.Write([]byte("EXEC\r\n"))
+	expectReply(t, reader, "-EXECABORT Transaction discarded because of previous errors.\r\n")
+	if _, ok, _ := store.Get("a"); ok {
+		t.Error("SET of an aborted transaction ran")
+	}
+
+	conn.Write([]byte("DISCARD\r\n"))
+	expectReply(t, reader, "-ERR DISCARD without MULTI\r\n")
+}
+
+func TestTxLockIsPerKey(t *testing.T) {
+	store := core.NewKVStore()
+	conn, reader := pipeClient(t, store)
+	if keyStripes("SET", []string{"SET", "a"})[0] == keyStripes("SET", []string{"SET", "b"})[0] {
+		t.Fatal("a and b share a stripe")
+	}
+	if got := txStripes([][]string{{"SET", "a", "1"}, {"SCAN", "0"}}); len(got) != replStripes {
+		t.Errorf("txStripes() with SCAN locks %d stripes, want all %d", len(got), replStripes)
+	}
+
+	// Stand in for an EXEC running on a
+	unlock := txLockOf(store).lock(txStripes([][]string{{"SET", "a", "1"}, {"PING"}}))
+	conn.Write([]byte("SET b 1\r\n"))
+	expectReply(t, reader, "+OK\r\n")
+
+	conn.Write([]byte("SET a 2\r\n"))
+	replied := make(chan struct{})
+	go func() {
+		defer close(replied)
+		expectReply(t, reader, "+OK\r\n")
+	}()
+	select {
+	case <-replied:
+		t.Fatal("SET a ran while a transaction held its key")
+	case <-time.After(50 * time.Millisecond):
+	}
+	unlock()
+	<-replied
+	if v, _, _ := store.Get("a"); v != "2" {
+		t.Errorf("Get(a) = %q, want 2", v)
+	}
+}
@@ -2,10 +2,9 @@ package server
 
 import (
 	"bufio"
-	"fmt"
-	"io"
-	"strconv"
 	"strings"
+
+	"github.com/Syed-Suhaan/SusyDB/pkg/resp"
 )
 
 // parseCommand parses a raw command string into parts, respecting quoted strings.
@@ -61,50 +60,7 @@ func parseCommand(input string) []string {
 
 // ParseRESP parses a RESP array from the reader.
 // Format: *<count>\r\n$<len>\r\n<content>\r\n...
+// Bulk string payloads are read by length, so arguments are binary-safe.
 func ParseRESP(reader *bufio.Reader) ([]string, error) {
-	// Read array header: *<count>\r\n
-	line, err := reader.ReadString('\n')
-	if err != nil {
-		return nil, err
-	}
-	line = strings.TrimSpace(line)
-	if len(line) == 0 || line[0] != '*' {
-		return nil, fmt.Errorf("invalid RESP array header: %s", line)
-	}
-
-	count, err := strconv.Atoi(line[1:])
-	if err != nil {
-		return nil, fmt.Errorf("invalid RESP array count: %v", err)
-	}
-
-	args := make([]string, 0, count)
-	for i := 0; i < count; i++ {
-		// Read bulk string header: $<length>\r\n
-		line, err = reader.ReadString('\n')
-		if err != nil {
-			return nil, err
-		}
-		line = strings.TrimSpace(line)
-		if len(line) == 0 || line[0] != '$' {
-			return nil, fmt.Errorf("invalid RESP bulk string header: %s", line)
-		}
-
-		length, err := strconv.Atoi(line[1:])
-		if err != nil {
-			return nil, fmt.Errorf("invalid RESP bulk string length: %v", err)
-		}
-
-		// Read data (plus \r\n)
-		// We read length + 2 bytes
-		data := make([]byte, length+2)
-		_, err = io.ReadFull(reader, data)
-		if err != nil {
-			return nil, err
-		}
-
-		// Trim \r\n from data
-		args = append(args, string(data[:length]))
-	}
-
-	return args, nil
+	return resp.ReadCommand(reader)
 }
@@ -0,0 +1,346 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Syed-Suhaan/SusyDB/internal/raft"
+	"github.com/Syed-Suhaan/SusyDB/pkg/core"
+)
+
+const (
+	raftCommitTimeout = 5 * time.Second // Longest a client waits for a commit or read index
+	raftDialTimeout   = time.Second
+	raftRPCTimeout    = 10 * time.Second // Generous, as snapshots travel in one RPC
+)
+
+// raftExcluded are the commands that bypass consensus by design and are
+// refused in Raft mode. Transactions would need the whole batch logged as
+// one entry.
+var raftExcluded = map[string]bool{
+	"REPLICAOF": true, "SLAVEOF": true, "SYNC": true, "PSYNC": true, "MIGRATE": true,
+	"MULTI": true, "EXEC": true, "DISCARD": true,
+}
+
+var errRaftDisabled = errors.New("ERR This instance has raft support disabled")
+
+// raftServer is a server's Raft state: every write goes through node's log
+// and is applied to the store from there, on every member.
+type raftServer struct {
+	node *raft.Node
+}
+
+var rafts sync.Map // *core.KVStore -> *raftServer
+
+// EnableRaft makes the server serving store a member of a Raft group.
+// Writes are then only accepted on the leader, and return once a majority
+// has them; reads wait for the read index first. Followers redirect
+// clients to the leader with -NOTLEADER host:port, or serve reads
+// themselves if cfg.FollowerReads is set. The store is restored from the
+// node's snapshot in cfg.Dir.
+func EnableRaft(store *core.KVStore, cfg raft.Config) error {
+	if clusterOf(store) != nil {
+		return errors.New("raft mode and cluster mode are exclusive")
+	}
+	node, err := raft.New(cfg, raftMachine{store}, &raftTransport{idle: make(map[string][]*raftConn)})
+	if err != nil {
+		return err
+	}
+	if _, loaded := rafts.LoadOrStore(store, &raftServer{node: node}); loaded {
+		node.Close()
+		return errors.New("raft mode is already enabled")
+	}
+	return nil
+}
+
+// raftOf returns the Raft state of the server serving store, or nil
+// outside Raft mode.
+func raftOf(store *core.KVStore) *raftServer {
+	if rs, ok := rafts.Load(store); ok {
+		return rs.(*raftServer)
+	}
+	return nil
+}
+
+// execute runs a client command in Raft mode. Writes are proposed to the
+// log and answered with the reply from applying them here; reads wait
+// for the read index; other commands run directly.
+func (rs *raftServer) execute(conn net.Conn, store *core.KVStore, cmdName string, handler CommandHandler, parts []string) []byte {
+	switch {
+	case raftExcluded[cmdName]:
+		return []byte(fmt.Sprintf("-ERR %s is not available in raft mode\r\n", strings.ToLower(cmdName)))
+	case writeCommands[cmdName]:
+		ctx, cancel := context.WithTimeout(context.Background(), raftCommitTimeout)
+		defer cancel()
+		reply, err := rs.node.Propose(ctx, raftCommand(parts))
+		if err != nil {
+			return raftWriteErrorReply(err)
+		}
+		return reply
+	case raftRead(cmdName):
+		ctx, cancel := context.WithTimeout(context.Background(), raftCommitTimeout)
+		defer cancel()
+		if err := rs.node.ReadIndex(ctx); err != nil {
+			return raftErrorReply(err)
+		}
+	}
+	return handler(conn, store, parts)
+}
+
+// raftErrorReply encodes an error from the Raft node while waiting for a
+// read index, which is safe to retry.
+func raftErrorReply(err error) []byte {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return []byte("-TRYAGAIN raft read index timed out\r\n")
+	}
+	return errorReply(err)
+}
+
+// raftWriteErrorReply encodes an error from proposing an entry. A timeout
+// leaves the entry in the leader's log, where it may still commit and be
+// applied, so unlike a lost entry it is not answered with TRYAGAIN: a blind
+// retry could apply the write twice. The client has to check the outcome
+// itself.
+func raftWriteErrorReply(err error) []byte {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return []byte("-ERR raft commit timed out, the write may still be applied\r\n")
+	}
+	return errorReply(err)
+}
+
+// raftRead reports whether a command reads the dataset.
+func raftRead(cmdName string) bool {
+	if cmdName == "FT.SEARCH" || cmdName == "SCAN" {
+		return true
+	}
+	_, hasKeys := keySpecs[cmdName]
+	return hasKeys && !writeCommands[cmdName] && cmdName != "SPUBLISH" && cmdName != "SSUBSCRIBE"
+}
+
+// raftCommand returns the command to log for a write. Every member
+// applies it, so TS.ADD and TS.MADD "*" timestamps are fixed by the leader
+// first. Relative TTLs, as with replication, start when each member
+// applies the command.
+func raftCommand(parts []string) []string {
+	var positions []int
+	switch strings.ToUpper(parts[0]) {
+	case "TS.ADD":
+		positions = []int{2}
+	case "TS.MADD":
+		for pos := 2; pos < len(parts); pos += 3 {
+			positions = append(positions, pos)
+		}
+	default:
+		return parts
+	}
+	cmd := append([]string(nil), parts...)
+	now := strconv.FormatInt(time.Now().UnixMilli(), 10)
+	for _, pos := range positions {
+		if pos < len(cmd) && cmd[pos] == "*" {
+			cmd[pos] = now
+		}
+	}
+	return cmd
+}
+
+// info renders the "# Raft" INFO section.
+func (rs *raftServer) info() string {
+	s := rs.node.Status()
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Raft\r\nraft_id:%s\r\nraft_role:%s\r\nraft_term:%d\r\nraft_leader_id:%s\r\nraft_leader_addr:%s\r\n",
+		s.ID, s.Role, s.Term, s.Leader, s.LeaderAddr)
+	fmt.Fprintf(&b, "raft_last_index:%d\r\nraft_commit_index:%d\r\nraft_applied_index:%d\r\nraft_snapshot_index:%d\r\nraft_members:%d\r\n",
+		s.LastIndex, s.Commit, s.Applied, s.SnapshotIndex, len(s.Members))
+	ids := make([]string, 0, len(s.Members))
+	for id := range s.Members {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for i, id := range ids {
+		fmt.Fprintf(&b, "member%d:id=%s,addr=%s\r\n", i, id, s.Members[id])
+	}
+	return b.String()
+}
+
+// raftMachine applies committed commands to the store through Handlers,
+// and snapshots it with the persistence format.
+type raftMachine struct {
+	store *core.KVStore
+}
+
+func (m raftMachine) Apply(cmd []string) []byte {
+	handler, ok := streamHandlers[strings.ToUpper(cmd[0])]
+	if !ok {
+		return []byte("-ERR unknown command\r\n")
+	}
+	return handler(nil, m.store, cmd)
+}
+
+func (m raftMachine) Snapshot(w io.Writer) error { return m.store.WriteSnapshot(w) }
+
+func (m raftMachine) Restore(r io.Reader) error { return m.store.LoadSnapshot(r) }
+
+// raftTransport carries Raft RPCs as RAFT commands on the members' client
+// ports, keeping idle connections for reuse.
+type raftTransport struct {
+	mu   sync.Mutex
+	idle map[string][]*raftConn
+}
+
+type raftConn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// call sends args to addr and returns the array reply.
+func (t *raftTransport) call(addr string, args []string) ([]string, error) {
+	t.mu.Lock()
+	var c *raftConn
+	if conns := t.idle[addr]; len(conns) > 0 {
+		c, t.idle[addr] = conns[len(conns)-1], conns[:len(conns)-1]
+	}
+	t.mu.Unlock()
+	if c == nil {
+		conn, err := net.DialTimeout("tcp", addr, raftDialTimeout)
+		if err != nil {
+			return nil, err
+		}
+		c = &raftConn{conn: conn, reader: bufio.NewReader(conn)}
+	}
+
+	c.conn.SetDeadline(time.Now().Add(raftRPCTimeout))
+	reply, err := exchangeRaft(c, args)
+	if _, ok := err.(raftReplyError); err != nil && !ok {
+		c.conn.Close()
+		return nil, err
+	}
+	t.mu.Lock()
+	t.idle[addr] = append(t.idle[addr], c)
+	t.mu.Unlock()
+	return reply, err
+}
+
+// raftReplyError is an error reply from a member, after which the
+// connection is still usable.
+type raftReplyError string
+
+func (e raftReplyError) Error() string { return string(e) }
+
+// exchangeRaft sends args and reads the array or error reply.
+func exchangeRaft(c *raftConn, args []string) ([]string, error) {
+	if _, err := c.conn.Write(bulkStringArray(args)); err != nil {
+		return nil, err
+	}
+	peek, err := c.reader.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+	if peek[0] == '-' {
+		line, err := readLine(c.reader)
+		if err != nil {
+			return nil, err
+		}
+		return nil, raftReplyError(line[1:])
+	}
+	return ParseRESP(c.reader)
+}
+
+func (t *raftTransport) AppendEntries(addr string, req *raft.AppendEntriesRequest) (*raft.AppendEntriesResponse, error) {
+	args := []string{"RAFT", "APPENDENTRIES", u64(req.Term), req.Leader, u64(req.PrevIndex), u64(req.PrevTerm), u64(req.Commit)}
+	for _, e := range req.Entries {
+		args = append(args, u64(e.Index), u64(e.Term), strconv.Itoa(int(e.Type)), strconv.Itoa(len(e.Cmd)))
+		args = append(args, e.Cmd...)
+	}
+	reply, err := t.call(addr, args)
+	if err != nil {
+		return nil, err
+	}
+	v, err := parseUints(reply, 3)
+	if err != nil {
+		return nil, err
+	}
+	return &raft.AppendEntriesResponse{Term: v[0], Success: v[1] == 1, LastIndex: v[2]}, nil
+}
+
+func (t *raftTransport) RequestVote(addr string, req *raft.VoteRequest) (*raft.VoteResponse, error) {
+	reply, err := t.call(addr, []string{"RAFT", "REQUESTVOTE", u64(req.Term), req.Candidate, u64(req.LastIndex), u64(req.LastTerm)})
+	if err != nil {
+		return nil, err
+	}
+	v, err := parseUints(reply, 2)
+	if err != nil {
+		return nil, err
+	}
+	return &raft.VoteResponse{Term: v[0], Granted: v[1] == 1}, nil
+}
+
+func (t *raftTransport) InstallSnapshot(addr string, req *raft.SnapshotRequest) (*raft.SnapshotResponse, error) {
+	args := []string{"RAFT", "INSTALLSNAPSHOT", u64(req.Term), req.Leader, u64(req.Index), u64(req.LastTerm), string(req.Data)}
+	for id, memberAddr := range req.Members {
+		args = append(args, id, memberAddr)
+	}
+	reply, err := t.call(addr, args)
+	if err != nil {
+		return nil, err
+	}
+	v, err := parseUints(reply, 1)
+	if err != nil {
+		return nil, err
+	}
+	return &raft.SnapshotResponse{Term: v[0]}, nil
+}
+
+func (t *raftTransport) ReadIndex(addr string) (uint64, error) {
+	reply, err := t.call(addr, []string{"RAFT", "READINDEX"})
+	if err != nil {
+		return 0, err
+	}
+	v, err := parseUints(reply, 1)
+	if err != nil {
+		return 0, err
+	}
+	return v[0], nil
+}
+
+func u64(v uint64) string { return strconv.FormatUint(v, 10) }
+
+// parseUints parses the first n values of args.
+func parseUints(args []string, n int) ([]uint64, error) {
+	if len(args) < n {
+		return nil, errors.New("ERR invalid raft message")
+	}
+	v := make([]uint64, n)
+	for i := range v {
+		var err error
+		if v[i], err = strconv.ParseUint(args[i], 10, 64); err != nil {
+			return nil, errors.New("ERR invalid raft message")
+		}
+	}
+	return v, nil
+}
+
+// parseEntries decodes the entries of a RAFT APPENDENTRIES command.
+func parseEntries(args []string) ([]raft.Entry, error) {
+	var entries []raft.Entry
+	for len(args) > 0 {
+		v, err := parseUints(args, 4)
+		if err != nil || uint64(len(args)-4) < v[3] {
+			return nil, errors.New("ERR invalid raft message")
+		}
+		entries = append(entries, raft.Entry{
+			Index: v[0], Term: v[1], Type: raft.EntryType(v[2]),
+			Cmd: append([]string(nil), args[4:4+v[3]]...),
+		})
+		args = args[4+v[3]:]
+	}
+	return entries, nil
+}
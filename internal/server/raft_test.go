@@ -0,0 +1,112 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Syed-Suhaan/SusyDB/internal/raft"
+	"github.com/Syed-Suhaan/SusyDB/pkg/core"
+)
+
+// startRaftServer starts a server in Raft mode; peers is the initial
+// membership, or nil to join later.
+func startRaftServer(t *testing.T, id string, listener net.Listener, peers map[string]string) *core.KVStore {
+	t.Helper()
+	store := core.NewKVStore()
+	replicationOf(store).setListenPort(listener.Addr().(*net.TCPAddr).Port)
+	err := EnableRaft(store, raft.Config{
+		ID: id, Dir: t.TempDir(), Peers: peers,
+		ElectionTimeout: 200 * time.Millisecond, FollowerReads: true,
+	})
+	if err != nil {
+		t.Fatalf("EnableRaft() error = %v", err)
+	}
+	go serve(listener, store)
+	t.Cleanup(func() {
+		listener.Close()
+		raftOf(store).node.Close()
+	})
+	return store
+}
+
+func TestRaftMode(t *testing.T) {
+	listeners := make([]net.Listener, 3)
+	peers := make(map[string]string)
+	for i := range listeners {
+		var err error
+		if listeners[i], err = net.Listen("tcp", "127.0.0.1:0"); err != nil {
+			t.Fatalf("Listen() error = %v", err)
+		}
+		peers["n"+strconv.Itoa(i)] = listeners[i].Addr().String()
+	}
+	stores := make([]*core.KVStore, 3)
+	for i, l := range listeners {
+		stores[i] = startRaftServer(t, "n"+strconv.Itoa(i), l, peers)
+	}
+
+	var leader, follower int
+	waitFor(t, "a leader", func() bool {
+		for i, store := range stores {
+			if raftOf(store).node.Status().Role == "leader" {
+				leader, follower = i, (i+1)%3
+				return true
+			}
+		}
+		return false
+	})
+	lconn, lreader := dialServer(t, listeners[leader].Addr().String())
+	fconn, freader := dialServer(t, listeners[follower].Addr().String())
+
+	lconn.Write([]byte("SET lock owner-1\r\n"))
+	expectReply(t, lreader, "+OK\r\n")
+	// Followers serve reads at the leader's read index, so the write is there
+	fconn.Write([]byte("GET lock\r\n"))
+	expectReply(t, freader, "$7\r\nowner-1\r\n")
+	fconn.Write([]byte("SET lock owner-2\r\n"))
+	expectReply(t, freader, "-NOTLEADER "+listeners[leader].Addr().String()+"\r\n")
+	fconn.Write([]byte("REPLICAOF NO ONE\r\n"))
+	expectReply(t, freader, "-ERR replicaof is not available in raft mode\r\n")
+
+	lconn.Write([]byte("TS.ADD temp * 21\r\n"))
+	stamp, _ := lreader.ReadString('\n')
+	for _, store := range stores {
+		waitFor(t, "the sample on every member", func() bool {
+			samples, _ := store.TSRange("temp", 0, 1<<62, core.TSRangeOptions{})
+			return len(samples) == 1 && ":"+strconv.FormatInt(samples[0].Timestamp, 10)+"\r\n" == stamp
+		})
+	}
+
+	// A new member joins empty and receives the log
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	joined := startRaftServer(t, "n3", listener, nil)
+	lconn.Write(bulkStringArray([]string{"RAFT", "ADDNODE", "n3", listener.Addr().String()}))
+	expectReply(t, lreader, "+OK\r\n")
+	waitFor(t, "the new member to catch up", func() bool {
+		v, _, _ := joined.Get("lock")
+		return v == "owner-1"
+	})
+	if got := raftOf(joined).info(); !strings.Contains(got, "raft_members:4") {
+		t.Errorf("new member INFO = %q, want 4 members", got)
+	}
+}
+
+func TestRaftTimeoutReplies(t *testing.T) {
+	err := fmt.Errorf("propose: %w", context.DeadlineExceeded)
+	if got := string(raftWriteErrorReply(err)); strings.HasPrefix(got, "-TRYAGAIN") {
+		t.Errorf("raftWriteErrorReply(timeout) = %q, want a reply that doesn't invite a retry", got)
+	}
+	if got := string(raftWriteErrorReply(raft.ErrLost)); !strings.HasPrefix(got, "-TRYAGAIN") {
+		t.Errorf("raftWriteErrorReply(ErrLost) = %q, want TRYAGAIN", got)
+	}
+	if got := string(raftErrorReply(err)); !strings.HasPrefix(got, "-TRYAGAIN") {
+		t.Errorf("raftErrorReply(timeout) = %q, want TRYAGAIN for a read", got)
+	}
+}
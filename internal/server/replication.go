@@ -0,0 +1,822 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"net"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Syed-Suhaan/SusyDB/pkg/core"
+)
+
+const (
+	replPingPeriod = 10 * time.Second // Primary PINGs idle replicas this often
+	replAckPeriod  = time.Second      // Replicas report their offset this often
+	replTimeout    = 60 * time.Second // A silent link is dropped after this
+	replRetryDelay = time.Second      // Pause before a replica reconnects
+
+	// replBufferLimit caps the commands queued for one replica. A replica
+	// that falls this far behind is disconnected and resyncs.
+	replBufferLimit = 64 * 1024 * 1024
+
+	defaultBacklogSize = 1024 * 1024 // repl-backlog-size
+	defaultBacklogTTL  = time.Hour   // repl-backlog-ttl
+)
+
+// writeCommands are the commands that change the dataset. They are
+// streamed to replicas and rejected by read-only replicas.
+var writeCommands = map[string]bool{
+	"SET": true, "SETEX": true, "INCR": true, "INCRBY": true, "DEL": true, "MSET": true,
+	"HSET": true, "HDEL": true,
+	"SETBIT": true, "BITOP": true, "BITFIELD": true,
+	"PFADD": true, "PFMERGE": true,
+	"BF.RESERVE": true, "BF.ADD": true, "BF.MADD": true,
+	"CF.RESERVE": true, "CF.ADD": true, "CF.ADDNX": true, "CF.DEL": true,
+	"CMS.INITBYDIM": true, "CMS.INITBYPROB": true, "CMS.INCRBY": true,
+	"GEOADD":   true,
+	"JSON.SET": true, "JSON.DEL": true, "JSON.NUMINCRBY": true, "JSON.ARRAPPEND": true,
+	"TS.CREATE": true, "TS.ADD": true, "TS.MADD": true, "TS.CREATERULE": true,
+	"FT.CREATE": true, "FT.DROPINDEX": true,
+	"RESTORE": true, "RESTORE-ASKING": true, "MIGRATE": true,
+}
+
+var errReadOnly = errors.New("READONLY You can't write against a read only replica.")
+
+// streamHandlers is Handlers, which applies the primary's stream. It is set
+// in init because Handlers itself leads here through REPLICAOF.
+var streamHandlers map[string]CommandHandler
+
+func init() {
+	streamHandlers = Handlers
+}
+
+// replication is a server's replication state: the replicas it feeds and,
+// when it is a replica itself, the link to its primary. Handlers only get
+// the store, so the state is looked up by store with replicationOf.
+type replication struct {
+	store *core.KVStore
+
+	// gate orders writes with the replication stream. Writes hold it for
+	// reading, so a snapshot taken under it exclusively matches an exact
+	// stream offset. While there are replicas to feed, a write also holds
+	// the stripes of its keys from applying it to feeding it, so writes to
+	// the same key reach replicas in the order they were applied while
+	// writes to other keys still run in parallel. Writes whose effects
+	// reach beyond their keys take the gate exclusively instead.
+	gate     sync.RWMutex
+	stripes  [replStripes]sync.Mutex
+	feedMu   sync.Mutex                // Orders feeds made under a shared gate
+	feeding  bool                      // Guarded by gate
+	replicas map[*replicaConn]struct{} // Guarded by gate
+	offset   atomic.Int64              // Stream bytes produced or applied; changed under gate and feedMu
+	lastFeed time.Time                 // Guarded by gate and feedMu
+
+	// The stream is identified by replID; replID2 names the stream this
+	// server followed before a promotion, valid up to secondOffset. Both
+	// are guarded by gate, the backlog by gate and, while it is written,
+	// feedMu.
+	replID       string
+	replID2      string
+	secondOffset int64
+	backlog      *replBacklog // Kept while replicas may reconnect
+	backlogSize  int
+	backlogTTL   time.Duration
+	idleSince    time.Time // When the last replica detached
+
+	syncFull       atomic.Int64
+	syncPartialOK  atomic.Int64
+	syncPartialErr atomic.Int64
+
+	mu       sync.Mutex   // Guards link
+	link     *primaryLink // Set while this server is a replica
+	isRepl   atomic.Bool
+	writable atomic.Bool // replica-read-only is "no"
+
+	port      atomic.Int64  // Listening port, announced to primaries
+	listening chan struct{} // Closed once the port is known
+	listenOne sync.Once
+}
+
+var replications sync.Map // *core.KVStore -> *replication
+
+// replicationOf returns the replication state of the server serving store.
+func replicationOf(store *core.KVStore) *replication {
+	if r, ok := replications.Load(store); ok {
+		return r.(*replication)
+	}
+	r, _ := replications.LoadOrStore(store, &replication{
+		store:        store,
+		replicas:     make(map[*replicaConn]struct{}),
+		replID:       newReplID(),
+		secondOffset: -1,
+		backlogSize:  defaultBacklogSize,
+		backlogTTL:   defaultBacklogTTL,
+		listening:    make(chan struct{}),
+	})
+	return r.(*replication)
+}
+
+// newReplID returns a random 40 character replication ID.
+func newReplID() string {
+	id := make([]byte, 20)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// setListenPort records the port the server accepts clients on.
+func (r *replication) setListenPort(port int) {
+	r.port.Store(int64(port))
+	r.listenOne.Do(func() { close(r.listening) })
+}
+
+// execute runs a command from a client. Writes are rejected on read-only
+// replicas, kept local on writable ones, and otherwise fed to this server's
+// replicas. In Raft mode the Raft log decides instead.
+func execute(conn net.Conn, store *core.KVStore, cmdName string, handler CommandHandler, parts []string) []byte {
+	if rs := raftOf(store); rs != nil {
+		return rs.execute(conn, store, cmdName, handler, parts)
+	}
+	if !writeCommands[cmdName] {
+		return handler(conn, store, parts)
+	}
+	r := replicationOf(store)
+	if r.isRepl.Load() {
+		if !r.writable.Load() {
+			return errorReply(errReadOnly)
+		}
+		return handler(conn, store, parts)
+	}
+
+	r.gate.RLock()
+	if !r.feeding {
+		defer r.gate.RUnlock()
+		return handler(conn, store, parts)
+	}
+	if stripes := keyStripes(cmdName, parts); stripes != nil {
+		defer r.gate.RUnlock()
+		for _, i := range stripes {
+			r.stripes[i].Lock()
+			defer r.stripes[i].Unlock()
+		}
+		reply := handler(conn, store, parts)
+		r.feedReply(store, parts, reply)
+		return reply
+	}
+	r.gate.RUnlock()
+
+	r.gate.Lock()
+	defer r.gate.Unlock()
+	reply := handler(conn, store, parts)
+	if r.feeding {
+		r.feedReply(store, parts, reply)
+	}
+	return reply
+}
+
+// replStripes is the number of key stripes writes lock while feeding.
+const replStripes = 256
+
+// keyStripes returns the sorted, distinct stripes of a write's keys, or
+// nil if the write must exclude all others: it names no keys (FT.CREATE,
+// MIGRATE) or, like TS.ADD writing compacted samples to rule destinations,
+// may change keys it does not name.
+func keyStripes(cmdName string, parts []string) []int {
+	keys := commandKeys(cmdName, parts)
+	if len(keys) == 0 || strings.HasPrefix(cmdName, "TS.") {
+		return nil
+	}
+	stripes := make([]int, 0, len(keys))
+	for _, key := range keys {
+		h := fnv.New32a()
+		h.Write([]byte(key))
+		stripes = append(stripes, int(h.Sum32()%replStripes))
+	}
+	sort.Ints(stripes)
+	return slices.Compact(stripes)
+}
+
+// feedReply feeds a write to the replicas unless it failed.
+func (r *replication) feedReply(store *core.KVStore, parts []string, reply []byte) {
+	if len(reply) > 0 && reply[0] == '-' {
+		return
+	}
+	for _, cmd := range replicatedCommands(store, parts, reply) {
+		r.feed(cmd)
+	}
+}
+
+// replicatedCommands returns the commands to stream for a write. TS.ADD
+// and TS.MADD with an automatic "*" timestamp are sent with the timestamps
+// the primary picked, which the reply carries, so replicas store the same
+// samples. MIGRATE is sent as a DEL of each key it moved away, and
+// RESTORE-ASKING as RESTORE. Other commands are sent as received; relative
+// TTLs therefore start counting when the replica applies them.
+func replicatedCommands(store *core.KVStore, parts []string, reply []byte) [][]string {
+	var stamps []string
+	switch strings.ToUpper(parts[0]) {
+	case "TS.ADD":
+		stamps = []string{string(reply)}
+	case "TS.MADD":
+		lines := strings.Split(string(reply), "\r\n")
+		if len(lines) > 0 && strings.HasPrefix(lines[0], "*") {
+			stamps = lines[1:]
+		}
+	case "RESTORE-ASKING":
+		return [][]string{append([]string{"RESTORE"}, parts[1:]...)}
+	case "MIGRATE":
+		m, _ := parseMigrate(parts)
+		var dels [][]string
+		for _, key := range m.keys {
+			if store.Exists(key) == 0 {
+				dels = append(dels, []string{"DEL", key})
+			}
+		}
+		return dels
+	default:
+		return [][]string{parts}
+	}
+
+	rewritten := append([]string(nil), parts...)
+	for i, stamp := range stamps {
+		pos := 2 + 3*i
+		if pos < len(rewritten) && rewritten[pos] == "*" && strings.HasPrefix(stamp, ":") {
+			rewritten[pos] = strings.TrimSpace(stamp[1:])
+		}
+	}
+	return [][]string{rewritten}
+}
+
+// feed appends a command to the replication stream.
+// Must be called while holding gate, exclusively unless the caller holds
+// the stripes of the command's keys.
+func (r *replication) feed(parts []string) {
+	cmd := bulkStringArray(parts)
+	r.feedMu.Lock()
+	defer r.feedMu.Unlock()
+	r.offset.Add(int64(len(cmd)))
+	r.lastFeed = time.Now()
+	if r.backlog != nil {
+		r.backlog.write(cmd)
+	}
+	for rc := range r.replicas {
+		rc.enqueue(cmd)
+	}
+}
+
+// ping feeds a PING if the stream has been idle for replPingPeriod, so
+// replicas can tell a quiet primary from a dead one.
+func (r *replication) ping() {
+	r.gate.Lock()
+	defer r.gate.Unlock()
+	// A replica passes on its primary's PINGs instead
+	if r.feeding && !r.isRepl.Load() && time.Since(r.lastFeed) >= replPingPeriod {
+		r.feed([]string{"PING"})
+	}
+}
+
+// replicaConn is a replica connected to this server.
+type replicaConn struct {
+	conn   net.Conn
+	addr   string // ip:port the replica listens on
+	synced atomic.Bool
+	ackOff atomic.Int64
+	ackAt  atomic.Int64 // UnixNano of the last ACK or of the sync start
+
+	mu      sync.Mutex
+	pending []byte
+	wake    chan struct{}
+	done    chan struct{}
+	closed  bool
+}
+
+// enqueue queues cmd for the replica, disconnecting it once it is more
+// than replBufferLimit behind.
+func (rc *replicaConn) enqueue(cmd []byte) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if rc.closed {
+		return
+	}
+	if len(rc.pending)+len(cmd) > replBufferLimit {
+		fmt.Printf("Replica %s is too far behind, disconnecting\n", rc.addr)
+		rc.closeLocked()
+		return
+	}
+	rc.pending = append(rc.pending, cmd...)
+	select {
+	case rc.wake <- struct{}{}:
+	default:
+	}
+}
+
+// take returns and clears the queued commands.
+func (rc *replicaConn) take() []byte {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	data := rc.pending
+	rc.pending = nil
+	return data
+}
+
+func (rc *replicaConn) close() {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.closeLocked()
+}
+
+func (rc *replicaConn) closeLocked() {
+	if !rc.closed {
+		rc.closed = true
+		close(rc.done)
+		rc.conn.Close()
+	}
+}
+
+// attachReplica registers a replica and returns what to send it before the
+// live stream. A PSYNC naming this stream and an offset still in the
+// backlog gets +CONTINUE and the missed bytes; anything else gets a full
+// sync, +FULLRESYNC (unless psync is false, for SYNC) and the snapshot as a
+// bulk string. Both happen under the exclusive gate, so the replica then
+// receives exactly the writes that follow.
+func (r *replication) attachReplica(conn net.Conn, port int, psync bool, replID string, from int64) (*replicaConn, []byte, error) {
+	host, _, _ := net.SplitHostPort(conn.RemoteAddr().String())
+	rc := &replicaConn{
+		conn: conn,
+		addr: net.JoinHostPort(host, strconv.Itoa(port)),
+		wake: make(chan struct{}, 1),
+		done: make(chan struct{}),
+	}
+	rc.ackAt.Store(time.Now().UnixNano())
+
+	r.gate.Lock()
+	defer r.gate.Unlock()
+	var reply []byte
+	if psync {
+		if missed, ok := r.continuable(replID, from); ok {
+			reply = append([]byte("+CONTINUE "+r.replID+"\r\n"), missed...)
+			r.syncPartialOK.Add(1)
+		} else if replID != "?" {
+			r.syncPartialErr.Add(1)
+		}
+	}
+	if reply == nil {
+		var snapshot bytes.Buffer
+		if err := r.store.WriteSnapshot(&snapshot); err != nil {
+			return nil, nil, err
+		}
+		if psync {
+			reply = fmt.Appendf(reply, "+FULLRESYNC %s %d\r\n", r.replID, r.offset.Load())
+		}
+		reply = fmt.Appendf(reply, "$%d\r\n", snapshot.Len())
+		reply = append(reply, snapshot.Bytes()...)
+		r.syncFull.Add(1)
+		if r.backlog == nil {
+			r.backlog = newReplBacklog(r.backlogSize, r.offset.Load())
+		}
+	}
+	r.replicas[rc] = struct{}{}
+	if !r.feeding {
+		r.feeding = true
+		r.lastFeed = time.Now()
+	}
+	return rc, reply, nil
+}
+
+// continuable returns the stream a replica missed, if it asks for this
+// stream (or the one before the last promotion) from an offset the backlog
+// still holds. Must be called while holding gate.
+func (r *replication) continuable(replID string, from int64) ([]byte, bool) {
+	if r.backlog == nil {
+		return nil, false
+	}
+	if replID != r.replID && (replID != r.replID2 || from > r.secondOffset) {
+		return nil, false
+	}
+	return r.backlog.since(from)
+}
+
+// detachReplica unregisters a replica. The backlog outlives the last
+// replica by backlogTTL so it can still reconnect.
+func (r *replication) detachReplica(rc *replicaConn) {
+	rc.close()
+	r.gate.Lock()
+	defer r.gate.Unlock()
+	delete(r.replicas, rc)
+	if len(r.replicas) == 0 {
+		r.idleSince = time.Now()
+		if r.backlogTTL > 0 {
+			time.AfterFunc(r.backlogTTL, r.expireBacklog)
+		}
+	}
+}
+
+// expireBacklog frees the backlog once no replica has been attached for
+// backlogTTL. Writes then stop locking their keys' stripes.
+func (r *replication) expireBacklog() {
+	r.gate.Lock()
+	defer r.gate.Unlock()
+	if len(r.replicas) > 0 || r.isRepl.Load() || r.backlogTTL <= 0 || time.Since(r.idleSince) < r.backlogTTL {
+		return
+	}
+	r.backlog = nil
+	r.feeding = false
+}
+
+// setBacklogSize resizes the backlog. A resized backlog starts out empty,
+// so replicas that disconnected before it need a full sync.
+func (r *replication) setBacklogSize(size int) {
+	r.gate.Lock()
+	defer r.gate.Unlock()
+	r.backlogSize = size
+	if r.backlog != nil {
+		r.backlog = newReplBacklog(size, r.offset.Load())
+	}
+}
+
+// serveReplica sends the reply from attachReplica and then the command
+// stream to a replica until it disconnects, reading its REPLCONF ACKs
+// meanwhile.
+func (r *replication) serveReplica(rc *replicaConn, reader *bufio.Reader, initial []byte) {
+	conn := rc.conn
+	readerDone := make(chan struct{})
+	go func() {
+		defer close(readerDone)
+		defer rc.close()
+		for {
+			conn.SetReadDeadline(time.Now().Add(replTimeout))
+			parts, err := readCommand(reader)
+			if err != nil {
+				return
+			}
+			if len(parts) == 3 && strings.EqualFold(parts[0], "REPLCONF") && strings.EqualFold(parts[1], "ACK") {
+				if ack, err := strconv.ParseInt(parts[2], 10, 64); err == nil {
+					rc.ackOff.Store(ack)
+					rc.ackAt.Store(time.Now().UnixNano())
+				}
+			}
+		}
+	}()
+	// The reader shares the client's buffered reader, which handleClient
+	// uses again once this returns
+	defer func() {
+		rc.close()
+		<-readerDone
+	}()
+
+	write := func(data []byte) bool {
+		conn.SetWriteDeadline(time.Now().Add(replTimeout))
+		_, err := conn.Write(data)
+		return err == nil
+	}
+	if !write(initial) {
+		return
+	}
+	rc.synced.Store(true)
+
+	ticker := time.NewTicker(replPingPeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-rc.wake:
+			if data := rc.take(); len(data) > 0 && !write(data) {
+				return
+			}
+		case <-ticker.C:
+			r.ping()
+		case <-rc.done:
+			return
+		}
+	}
+}
+
+// primaryLink is a replica's connection to its primary, re-established
+// until stop is called.
+type primaryLink struct {
+	addr    string
+	stopped chan struct{}
+	syncing atomic.Bool
+	up      atomic.Bool
+	lastIO  atomic.Int64 // UnixNano of the last data from the primary
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+func (l *primaryLink) stop() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	select {
+	case <-l.stopped:
+		return
+	default:
+	}
+	close(l.stopped)
+	if l.conn != nil {
+		l.conn.Close()
+	}
+}
+
+// setConn records the current connection, failing if the link was stopped.
+func (l *primaryLink) setConn(conn net.Conn) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	select {
+	case <-l.stopped:
+		return false
+	default:
+	}
+	l.conn = conn
+	return true
+}
+
+// ReplicaOf makes the server serving store a replica of the primary at addr
+// (host:port), or a primary again if addr is empty. A replica asks to
+// continue the stream it last followed, and otherwise drops its data and
+// loads a snapshot of the primary's; it then applies the primary's command
+// stream, resynchronizing whenever the link breaks. The server must be
+// listening (Start) before the link announces its port.
+func ReplicaOf(store *core.KVStore, addr string) {
+	replicationOf(store).replicaOf(addr)
+}
+
+func (r *replication) replicaOf(addr string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.link != nil {
+		r.link.stop()
+		r.link = nil
+	}
+	if addr == "" {
+		if r.isRepl.Load() {
+			// Replicas of our old primary can continue with us up to
+			// here, under the old ID
+			r.gate.Lock()
+			r.replID2, r.secondOffset = r.replID, r.offset.Load()
+			r.replID = newReplID()
+			r.gate.Unlock()
+		}
+		r.isRepl.Store(false)
+		return
+	}
+	r.isRepl.Store(true)
+
+	link := &primaryLink{addr: addr, stopped: make(chan struct{})}
+	r.link = link
+	go r.runLink(link)
+}
+
+// runLink keeps a replica synchronized with its primary until the link is
+// stopped.
+func (r *replication) runLink(link *primaryLink) {
+	select {
+	case <-r.listening:
+	case <-link.stopped:
+		return
+	}
+	for {
+		err := r.syncFrom(link)
+		link.up.Store(false)
+		link.syncing.Store(false)
+		select {
+		case <-link.stopped:
+			return
+		default:
+		}
+		fmt.Printf("Replication link to %s lost: %v\n", link.addr, err)
+		select {
+		case <-link.stopped:
+			return
+		case <-time.After(replRetryDelay):
+		}
+	}
+}
+
+// syncFrom resynchronizes with the primary, partially or fully, and then
+// applies its stream until the connection fails.
+func (r *replication) syncFrom(link *primaryLink) error {
+	conn, err := net.DialTimeout("tcp", link.addr, 5*time.Second)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if !link.setConn(conn) {
+		return nil
+	}
+	link.syncing.Store(true)
+	reader := bufio.NewReader(conn)
+	conn.SetDeadline(time.Now().Add(replTimeout))
+
+	port := strconv.FormatInt(r.port.Load(), 10)
+	if _, err := conn.Write(bulkStringArray([]string{"REPLCONF", "listening-port", port})); err != nil {
+		return err
+	}
+	if line, err := readLine(reader); err != nil || line != "+OK" {
+		return fmt.Errorf("REPLCONF refused: %q %v", line, err)
+	}
+	// Without a backlog there is no stream worth continuing
+	psync := []string{"PSYNC", "?", "-1"}
+	r.gate.RLock()
+	if r.backlog != nil {
+		psync = []string{"PSYNC", r.replID, strconv.FormatInt(r.offset.Load()+1, 10)}
+	}
+	r.gate.RUnlock()
+	if _, err := conn.Write(bulkStringArray(psync)); err != nil {
+		return err
+	}
+	line, err := readLine(reader)
+	if err != nil {
+		return err
+	}
+	if id, ok := strings.CutPrefix(line, "+CONTINUE "); ok {
+		r.gate.Lock()
+		if id != r.replID {
+			r.replID2, r.secondOffset = r.replID, r.offset.Load()
+			r.replID = id
+		}
+		r.startBacklog()
+		r.gate.Unlock()
+	} else if err := r.fullSync(reader, line); err != nil {
+		return err
+	}
+	link.syncing.Store(false)
+	link.up.Store(true)
+	link.lastIO.Store(time.Now().UnixNano())
+	conn.SetDeadline(time.Time{})
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(replAckPeriod)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				ack := strconv.FormatInt(r.offset.Load(), 10)
+				conn.SetWriteDeadline(time.Now().Add(replTimeout))
+				conn.Write(bulkStringArray([]string{"REPLCONF", "ACK", ack}))
+			}
+		}
+	}()
+
+	for {
+		conn.SetReadDeadline(time.Now().Add(replTimeout))
+		parts, err := readCommand(reader)
+		if err != nil {
+			return err
+		}
+		link.lastIO.Store(time.Now().UnixNano())
+		if len(parts) > 0 {
+			r.apply(parts)
+		}
+	}
+}
+
+// fullSync loads the snapshot following a +FULLRESYNC reply and adopts
+// the primary's stream ID and offset. Our own replicas are disconnected,
+// since they hold the data we just replaced.
+func (r *replication) fullSync(reader *bufio.Reader, line string) error {
+	var id string
+	var offset int64
+	if _, err := fmt.Sscanf(line, "+FULLRESYNC %s %d", &id, &offset); err != nil {
+		return fmt.Errorf("unexpected PSYNC reply %q", line)
+	}
+	line, err := readLine(reader)
+	if err != nil {
+		return err
+	}
+	size, err := strconv.ParseInt(strings.TrimPrefix(line, "$"), 10, 64)
+	if err != nil || !strings.HasPrefix(line, "$") {
+		return fmt.Errorf("unexpected snapshot header %q", line)
+	}
+
+	snapshot := io.LimitReader(reader, size)
+	r.gate.Lock()
+	err = r.store.LoadSnapshot(snapshot)
+	r.offset.Store(offset)
+	r.replID, r.replID2, r.secondOffset = id, "", -1
+	r.backlog = nil
+	r.startBacklog()
+	for rc := range r.replicas {
+		rc.close()
+	}
+	r.gate.Unlock()
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(io.Discard, snapshot)
+	return err
+}
+
+// startBacklog makes a replica keep a backlog of the stream it applies,
+// so its own replicas can continue after it is promoted or after they
+// reconnect. Must be called while holding gate exclusively.
+func (r *replication) startBacklog() {
+	if r.backlog == nil {
+		r.backlog = newReplBacklog(r.backlogSize, r.offset.Load())
+	}
+	r.feeding = true
+}
+
+// apply executes a command from the primary's stream and passes it on to
+// this server's own replicas unchanged, so offsets match along a chain.
+func (r *replication) apply(parts []string) {
+	r.gate.Lock()
+	defer r.gate.Unlock()
+
+	cmdName := strings.ToUpper(parts[0])
+	if handler, ok := streamHandlers[cmdName]; ok && writeCommands[cmdName] {
+		handler(nil, r.store, parts)
+	}
+	if r.feeding {
+		r.feed(parts)
+	} else {
+		r.offset.Add(int64(len(bulkStringArray(parts))))
+	}
+}
+
+// readLine reads a CRLF-terminated line without the terminator.
+func readLine(reader *bufio.Reader) (string, error) {
+	line, err := reader.ReadString('\n')
+	return strings.TrimRight(line, "\r\n"), err
+}
+
+// info renders the "# Replication" INFO section.
+func (r *replication) info() string {
+	var b strings.Builder
+	b.WriteString("# Replication\r\n")
+
+	r.mu.Lock()
+	link := r.link
+	r.mu.Unlock()
+	if link != nil {
+		host, port, _ := net.SplitHostPort(link.addr)
+		status, lastIO := "down", int64(-1)
+		if link.up.Load() {
+			status = "up"
+			lastIO = int64(time.Since(time.Unix(0, link.lastIO.Load())).Seconds())
+		}
+		syncing := 0
+		if link.syncing.Load() {
+			syncing = 1
+		}
+		readOnly := 1
+		if r.writable.Load() {
+			readOnly = 0
+		}
+		fmt.Fprintf(&b, "role:slave\r\nmaster_host:%s\r\nmaster_port:%s\r\nmaster_link_status:%s\r\n", host, port, status)
+		fmt.Fprintf(&b, "master_last_io_seconds_ago:%d\r\nmaster_sync_in_progress:%d\r\n", lastIO, syncing)
+		fmt.Fprintf(&b, "slave_repl_offset:%d\r\nslave_read_only:%d\r\n", r.offset.Load(), readOnly)
+	} else {
+		b.WriteString("role:master\r\n")
+	}
+
+	r.gate.RLock()
+	replicas := make([]*replicaConn, 0, len(r.replicas))
+	for rc := range r.replicas {
+		replicas = append(replicas, rc)
+	}
+	r.gate.RUnlock()
+	sort.Slice(replicas, func(i, j int) bool { return replicas[i].addr < replicas[j].addr })
+	fmt.Fprintf(&b, "connected_slaves:%d\r\n", len(replicas))
+	for i, rc := range replicas {
+		host, port, _ := net.SplitHostPort(rc.addr)
+		state := "send_bulk"
+		if rc.synced.Load() {
+			state = "online"
+		}
+		lag := int64(time.Since(time.Unix(0, rc.ackAt.Load())).Seconds())
+		fmt.Fprintf(&b, "slave%d:ip=%s,port=%s,state=%s,offset=%d,lag=%d\r\n", i, host, port, state, rc.ackOff.Load(), lag)
+	}
+	r.gate.RLock()
+	r.feedMu.Lock()
+	fmt.Fprintf(&b, "master_replid:%s\r\nmaster_replid2:%s\r\n", r.replID, cmp.Or(r.replID2, strings.Repeat("0", 40)))
+	fmt.Fprintf(&b, "master_repl_offset:%d\r\nsecond_repl_offset:%d\r\n", r.offset.Load(), r.secondOffset)
+	if r.backlog != nil {
+		fmt.Fprintf(&b, "repl_backlog_active:1\r\nrepl_backlog_size:%d\r\n", len(r.backlog.buf))
+		fmt.Fprintf(&b, "repl_backlog_first_byte_offset:%d\r\nrepl_backlog_histlen:%d\r\n", r.backlog.start()+1, r.backlog.histlen)
+	} else {
+		fmt.Fprintf(&b, "repl_backlog_active:0\r\nrepl_backlog_size:%d\r\n", r.backlogSize)
+		b.WriteString("repl_backlog_first_byte_offset:0\r\nrepl_backlog_histlen:0\r\n")
+	}
+	r.feedMu.Unlock()
+	r.gate.RUnlock()
+	fmt.Fprintf(&b, "sync_full:%d\r\nsync_partial_ok:%d\r\nsync_partial_err:%d\r\n",
+		r.syncFull.Load(), r.syncPartialOK.Load(), r.syncPartialErr.Load())
+	return b.String()
+}
@@ -0,0 +1,332 @@
+package server
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Syed-Suhaan/SusyDB/pkg/core"
+)
+
+// startServer serves a new store on a local port until the test ends.
+func startServer(t *testing.T) (*core.KVStore, string) {
+	t.Helper()
+	store := core.NewKVStore()
+	listener, err := listen(store, "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen() error = %v", err)
+	}
+	go serve(listener, store)
+	t.Cleanup(func() {
+		ReplicaOf(store, "")
+		listener.Close()
+	})
+	return store, listener.Addr().String()
+}
+
+// dialServer opens a client connection to addr.
+func dialServer(t *testing.T, addr string) (net.Conn, *bufio.Reader) {
+	t.Helper()
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("Dial(%s) error = %v", addr, err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	return conn, bufio.NewReader(conn)
+}
+
+// waitFor polls cond until it holds or five seconds pass.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// infoField returns a field of the replication INFO section.
+func infoField(store *core.KVStore, name string) string {
+	for _, line := range strings.Split(replicationOf(store).info(), "\r\n") {
+		if value, ok := strings.CutPrefix(line, name+":"); ok {
+			return value
+		}
+	}
+	return ""
+}
+
+func TestReplication(t *testing.T) {
+	primary, primaryAddr := startServer(t)
+	replica, replicaAddr := startServer(t)
+	primary.Set("before", "sync", 0)
+	primary.HSet("user:1", "name", "ada")
+	replica.Set("stale", "gone", 0)
+
+	conn, reader := dialServer(t, replicaAddr)
+	host, port, _ := net.SplitHostPort(primaryAddr)
+	conn.Write(bulkStringArray([]string{"REPLICAOF", host, port}))
+	expectReply(t, reader, "+OK\r\n")
+
+	waitFor(t, "full sync", func() bool {
+		v, ok, _ := replica.Get("before")
+		return ok && v == "sync"
+	})
+	if _, ok, _ := replica.Get("stale"); ok {
+		t.Errorf("replica kept a key the primary does not have")
+	}
+
+	pconn, preader := dialServer(t, primaryAddr)
+	pconn.Write([]byte("SET after stream\r\n"))
+	expectReply(t, preader, "+OK\r\n")
+	pconn.Write([]byte("INCRBY counter 5\r\n"))
+	expectReply(t, preader, ":5\r\n")
+	pconn.Write([]byte("HDEL user:1 name\r\n"))
+	expectReply(t, preader, ":1\r\n")
+	pconn.Write([]byte("TS.ADD temp * 21\r\n"))
+	stamp, _ := preader.ReadString('\n')
+
+	waitFor(t, "streamed writes", func() bool {
+		v, _, _ := replica.Get("counter")
+		return v == "5"
+	})
+	if v, _, _ := replica.Get("after"); v != "stream" {
+		t.Errorf("replica after = %q, want %q", v, "stream")
+	}
+	if _, ok, _ := replica.HGet("user:1", "name"); ok {
+		t.Errorf("replica still has user:1 name after HDEL")
+	}
+	samples, _ := replica.TSRange("temp", 0, 1<<62, core.TSRangeOptions{})
+	if len(samples) != 1 || ":"+strconv.FormatInt(samples[0].Timestamp, 10)+"\r\n" != stamp {
+		t.Errorf("replica temp samples = %v, want one at %q", samples, stamp)
+	}
+
+	conn.Write([]byte("SET x 1\r\n"))
+	expectReply(t, reader, "-READONLY You can't write against a read only replica.\r\n")
+	conn.Write([]byte("GET after\r\n"))
+	expectReply(t, reader, "$6\r\nstream\r\n")
+
+	if got := infoField(replica, "role"); got != "slave" {
+		t.Errorf("replica role = %q, want slave", got)
+	}
+	if got := infoField(primary, "connected_slaves"); got != "1" {
+		t.Errorf("primary connected_slaves = %q, want 1", got)
+	}
+	waitFor(t, "replica ACK", func() bool {
+		offset := infoField(primary, "master_repl_offset")
+		return infoField(replica, "slave_repl_offset") == offset &&
+			strings.Contains(infoField(primary, "slave0"), "state=online,offset="+offset)
+	})
+
+	conn.Write([]byte("REPLICAOF NO ONE\r\n"))
+	expectReply(t, reader, "+OK\r\n")
+	conn.Write([]byte("SET x 1\r\n"))
+	expectReply(t, reader, "+OK\r\n")
+	if got := infoField(replica, "role"); got != "master" {
+		t.Errorf("role after REPLICAOF NO ONE = %q, want master", got)
+	}
+	waitFor(t, "replica to disconnect", func() bool {
+		return infoField(primary, "connected_slaves") == "0"
+	})
+}
+
+func TestReplicaReadOnlyConfig(t *testing.T) {
+	store := core.NewKVStore()
+	conn, reader := pipeClient(t, store)
+	replicationOf(store).isRepl.Store(true)
+	t.Cleanup(func() { replicationOf(store).isRepl.Store(false) })
+
+	conn.Write([]byte("SET a 1\r\n"))
+	expectReply(t, reader, "-READONLY You can't write against a read only replica.\r\n")
+	conn.Write([]byte("CONFIG SET replica-read-only no\r\n"))
+	expectReply(t, reader, "+OK\r\n")
+	conn.Write([]byte("SET a 1\r\n"))
+	expectReply(t, reader, "+OK\r\n")
+	conn.Write([]byte("CONFIG GET replica-read-only\r\n"))
+	expectReply(t, reader, "*2\r\n$17\r\nreplica-read-only\r\n$2\r\nno\r\n")
+	conn.Write([]byte("CONFIG SET replica-read-only maybe\r\n"))
+	expectReply(t, reader, "-ERR argument must be 'yes' or 'no'\r\n")
+}
+
+func TestReplBacklog(t *testing.T) {
+	b := newReplBacklog(8, 100)
+	b.write([]byte("abcde"))
+	b.write([]byte("fghij")) // Wraps, dropping "ab"
+	tests := []struct {
+		from int64
+		want string
+		ok   bool
+	}{
+		{100, "", false},
+		{102, "cdefghij", true},
+		{107, "hij", true},
+		{110, "", true},
+		{111, "", false},
+	}
+	for _, tt := range tests {
+		got, ok := b.since(tt.from)
+		if string(got) != tt.want || ok != tt.ok {
+			t.Errorf("since(%d) = %q, %v, want %q, %v", tt.from, got, ok, tt.want, tt.ok)
+		}
+	}
+
+	b.write([]byte("0123456789"))
+	if got, ok := b.since(112); string(got) != "23456789" || !ok {
+		t.Errorf("after an oversized write since(112) = %q, %v, want %q, true", got, ok, "23456789")
+	}
+	if b.start() != 112 || b.end != 120 {
+		t.Errorf("start, end = %d, %d, want 112, 120", b.start(), b.end)
+	}
+}
+
+// dropLink breaks a replica's connection to its primary, as a network
+// failure would. The link reconnects after replRetryDelay.
+func TestConcurrentWritesReplicateInOrder(t *testing.T) {
+	primary, primaryAddr := startServer(t)
+	replica, _ := startServer(t)
+	ReplicaOf(replica, primaryAddr)
+	waitFor(t, "replica link", func() bool { return infoField(replica, "master_link_status") == "up" })
+
+	// Clients race on a few shared keys, so each key sees interleaved
+	// writes that replicas must apply in the same order
+	const clients, writes = 8, 200
+	var wg sync.WaitGroup
+	errs := make(chan error, clients)
+	for c := 0; c < clients; c++ {
+		conn, reader := dialServer(t, primaryAddr)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < writes; i++ {
+				key := strconv.Itoa(i % 4)
+				conn.Write([]byte("SET last:" + key + " " + strconv.Itoa(c*writes+i) + "\r\nINCR count:" + key + "\r\n"))
+				for n := 0; n < 2; n++ {
+					if line, err := reader.ReadString('\n'); err != nil || line[0] == '-' {
+						errs <- fmt.Errorf("write reply %q, %v", line, err)
+						return
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+
+	waitCaughtUp(t, primary, replica)
+	for k := 0; k < 4; k++ {
+		for _, key := range []string{"last:" + strconv.Itoa(k), "count:" + strconv.Itoa(k)} {
+			want, _, _ := primary.Get(key)
+			if got, _, _ := replica.Get(key); got != want {
+				t.Errorf("replica %s = %q, primary has %q", key, got, want)
+			}
+		}
+	}
+	if got, _, _ := replica.Get("count:0"); got != strconv.Itoa(clients*writes/4) {
+		t.Errorf("replica count:0 = %s, want %d", got, clients*writes/4)
+	}
+}
+
+func dropLink(store *core.KVStore) {
+	r := replicationOf(store)
+	r.mu.Lock()
+	link := r.link
+	r.mu.Unlock()
+	link.mu.Lock()
+	link.conn.Close()
+	link.mu.Unlock()
+}
+
+// waitCaughtUp waits until every replica has applied primary's stream.
+func waitCaughtUp(t *testing.T, primary *core.KVStore, replicas ...*core.KVStore) {
+	t.Helper()
+	waitFor(t, "replicas to catch up", func() bool {
+		for _, replica := range replicas {
+			if infoField(replica, "master_link_status") != "up" ||
+				infoField(replica, "slave_repl_offset") != infoField(primary, "master_repl_offset") {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+func TestPartialResync(t *testing.T) {
+	primary, primaryAddr := startServer(t)
+	replica, _ := startServer(t)
+	ReplicaOf(replica, primaryAddr)
+	pconn, preader := dialServer(t, primaryAddr)
+	pconn.Write([]byte("SET a 1\r\n"))
+	expectReply(t, preader, "+OK\r\n")
+	waitCaughtUp(t, primary, replica)
+	if got, want := infoField(replica, "master_replid"), infoField(primary, "master_replid"); got != want {
+		t.Errorf("replica master_replid = %q, want the primary's %q", got, want)
+	}
+
+	dropLink(replica)
+	pconn.Write([]byte("SET b 2\r\n"))
+	expectReply(t, preader, "+OK\r\n")
+	waitCaughtUp(t, primary, replica)
+	if v, _, _ := replica.Get("b"); v != "2" {
+		t.Errorf("replica b = %q after reconnecting, want 2", v)
+	}
+	for field, want := range map[string]string{"sync_full": "1", "sync_partial_ok": "1", "sync_partial_err": "0"} {
+		if got := infoField(primary, field); got != want {
+			t.Errorf("after a partial resync %s = %s, want %s", field, got, want)
+		}
+	}
+
+	// A write larger than the backlog pushes the replica's offset out of it
+	pconn.Write([]byte("CONFIG SET repl-backlog-size 32\r\n"))
+	expectReply(t, preader, "+OK\r\n")
+	dropLink(replica)
+	pconn.Write([]byte("SET big " + strings.Repeat("x", 64) + "\r\n"))
+	expectReply(t, preader, "+OK\r\n")
+	waitCaughtUp(t, primary, replica)
+	if v, _, _ := replica.Get("big"); len(v) != 64 {
+		t.Errorf("replica big has %d bytes after a full resync, want 64", len(v))
+	}
+	for field, want := range map[string]string{"sync_full": "2", "sync_partial_ok": "1", "sync_partial_err": "1"} {
+		if got := infoField(primary, field); got != want {
+			t.Errorf("after falling out of the backlog %s = %s, want %s", field, got, want)
+		}
+	}
+}
+
+func TestPartialResyncAfterPromotion(t *testing.T) {
+	primary, primaryAddr := startServer(t)
+	promoted, promotedAddr := startServer(t)
+	other, _ := startServer(t)
+	ReplicaOf(promoted, primaryAddr)
+	ReplicaOf(other, primaryAddr)
+	pconn, preader := dialServer(t, primaryAddr)
+	pconn.Write([]byte("SET a 1\r\n"))
+	expectReply(t, preader, "+OK\r\n")
+	waitCaughtUp(t, primary, promoted, other)
+
+	oldID := infoField(primary, "master_replid")
+	ReplicaOf(promoted, "")
+	if got := infoField(promoted, "master_replid2"); got != oldID {
+		t.Errorf("promoted master_replid2 = %q, want the old primary's %q", got, oldID)
+	}
+	ReplicaOf(other, promotedAddr)
+	waitCaughtUp(t, promoted, other)
+	if got := infoField(promoted, "sync_partial_ok"); got != "1" {
+		t.Errorf("promoted sync_partial_ok = %s, want 1", got)
+	}
+	if got := infoField(promoted, "sync_full"); got != "0" {
+		t.Errorf("promoted sync_full = %s, want 0", got)
+	}
+	if got, want := infoField(other, "master_replid"), infoField(promoted, "master_replid"); got != want {
+		t.Errorf("other master_replid = %q, want %q", got, want)
+	}
+}
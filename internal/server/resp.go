@@ -0,0 +1,41 @@
+package server
+
+import (
+	"github.com/Syed-Suhaan/SusyDB/pkg/resp"
+)
+
+// The encoding itself lives in pkg/resp, which the Go client shares.
+
+// appendBulkString appends s to buf as a RESP bulk string.
+func appendBulkString(buf []byte, s string) []byte { return resp.AppendBulkString(buf, s) }
+
+// appendArrayHeader appends a RESP array header announcing n elements.
+func appendArrayHeader(buf []byte, n int) []byte { return resp.AppendArrayHeader(buf, n) }
+
+// appendInteger appends a RESP integer.
+func appendInteger(buf []byte, n int64) []byte { return resp.AppendInteger(buf, n) }
+
+// bulkString encodes a single value as a RESP bulk string reply.
+func bulkString(s string) []byte {
+	return appendBulkString(make([]byte, 0, len(s)+16), s)
+}
+
+// bulkStringArray encodes values as a RESP array of bulk strings.
+func bulkStringArray(values []string) []byte {
+	size := 16
+	for _, v := range values {
+		size += len(v) + 16
+	}
+	return resp.AppendCommand(make([]byte, 0, size), values)
+}
+
+// integerReply encodes n as a RESP integer reply.
+func integerReply(n int64) []byte {
+	return appendInteger(make([]byte, 0, 24), n)
+}
+
+// errorReply encodes err as a RESP error. Core errors already carry their
+// Redis error prefix (ERR, WRONGTYPE, ...).
+func errorReply(err error) []byte {
+	return []byte("-" + err.Error() + "\r\n")
+}
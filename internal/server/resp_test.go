@@ -2,6 +2,7 @@ package server
 
 import (
 	"bufio"
+	"strconv"
 	"strings"
 	"testing"
 )
@@ -37,3 +38,39 @@ func TestParseRESP_InvalidHeader(t *testing.T) {
 		t.Error("Expected error for non-array input, got nil")
 	}
 }
+
+func TestParseRESP_BinaryPayload(t *testing.T) {
+	payload := "a\r\nb\x00\"\\c"
+	input := "*3\r\n$3\r\nSET\r\n$3\r\nbin\r\n$" + strconv.Itoa(len(payload)) + "\r\n" + payload + "\r\n"
+	reader := bufio.NewReader(strings.NewReader(input))
+
+	args, err := ParseRESP(reader)
+	if err != nil {
+		t.Fatalf("ParseRESP failed: %v", err)
+	}
+	if len(args) != 3 || args[2] != payload {
+		t.Errorf("Expected binary payload %q, got %q", payload, args)
+	}
+}
+
+func TestParseRESP_InvalidLength(t *testing.T) {
+	inputs := []string{
+		"*-1\r\n",
+		"*1\r\n$-5\r\n",
+		"*1\r\n$3\r\nfooXX",
+	}
+	for _, input := range inputs {
+		reader := bufio.NewReader(strings.NewReader(input))
+		if _, err := ParseRESP(reader); err == nil {
+			t.Errorf("Expected error for %q, got nil", input)
+		}
+	}
+}
+
+func TestBulkString(t *testing.T) {
+	got := string(bulkString("a\r\nb"))
+	want := "$4\r\na\r\nb\r\n"
+	if got != want {
+		t.Errorf("bulkString() = %q, want %q", got, want)
+	}
+}
@@ -2,10 +2,12 @@ package server
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"io"
 	"net"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/Syed-Suhaan/SusyDB/pkg/core"
@@ -13,12 +15,29 @@ import (
 
 // Start initializes the TCP server and listens for incoming connections.
 func Start(store *core.KVStore, addr string) {
-	listener, err := net.Listen("tcp", addr)
+	listener, err := listen(store, addr)
 	if err != nil {
 		fmt.Printf("Failed to bind to port %s: %v\n", addr, err)
 		return
 	}
 	defer listener.Close()
+	serve(listener, store)
+}
+
+// listen opens the server's listener and records its port, which replicas
+// announce to their primary.
+func listen(store *core.KVStore, addr string) (net.Listener, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	replicationOf(store).setListenPort(listener.Addr().(*net.TCPAddr).Port)
+	return listener, nil
+}
+
+// serve accepts clients until the listener is closed.
+func serve(listener net.Listener, store *core.KVStore) {
+	addr := listener.Addr().String()
 
 	// Connection Semaphore to limit concurrency
 	// 5000 concurrent clients is a safe upper bound to prevent OOM
@@ -31,6 +50,9 @@ func Start(store *core.KVStore, addr string) {
 	for {
 		conn, err := listener.Accept()
 		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
 			fmt.Printf("Connection error: %v\n", err)
 			continue
 		}
@@ -51,6 +73,77 @@ func Start(store *core.KVStore, addr string) {
 	}
 }
 
+// clientTimeout is how long a connection may stay idle before it is closed.
+// Subscribed clients are exempt. It is atomic so tests can shorten it while
+// other connections are being served.
+var clientTimeout atomic.Int64 // time.Duration
+
+func init() {
+	clientTimeout.Store(int64(300 * time.Second))
+}
+
+// idleDeadline returns the deadline for a client's next read or write.
+func idleDeadline() time.Time {
+	return time.Now().Add(time.Duration(clientTimeout.Load()))
+}
+
+// clientConn is the connection handed to handlers. Handlers that keep the
+// connection after replying (SUBSCRIBE) read further commands through reader,
+// which may already hold buffered input.
+type clientConn struct {
+	net.Conn
+	reader      *bufio.Reader
+	replicaPort int  // Announced by a replica with REPLCONF listening-port
+	asking      bool // ASKING was sent; cleared by the next command
+
+	multi    bool       // MULTI was sent; commands are queued until EXEC
+	queued   [][]string // Commands queued for EXEC
+	txFailed bool       // A command was refused while queueing
+}
+
+// connReader returns the buffered reader of a client connection.
+func connReader(conn net.Conn) *bufio.Reader {
+	if cc, ok := conn.(*clientConn); ok {
+		return cc.reader
+	}
+	return bufio.NewReader(conn)
+}
+
+// protocolError marks a malformed RESP command, which is reported to the
+// client before the connection is closed.
+type protocolError struct{ err error }
+
+func (e protocolError) Error() string { return "Protocol error: " + e.err.Error() }
+
+// readCommand reads the next command in RESP or inline form. Empty inline
+// lines return no parts.
+func readCommand(reader *bufio.Reader) ([]string, error) {
+	// Peek at the first byte to determine protocol
+	peek, err := reader.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+
+	if peek[0] == '*' {
+		// Binary RESP protocol
+		parts, err := ParseRESP(reader)
+		if err != nil {
+			return nil, protocolError{err}
+		}
+		return parts, nil
+	}
+
+	// Inline text protocol (Telnet)
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		if err != io.EOF {
+			fmt.Println("Read error:", err)
+		}
+		return nil, err
+	}
+	return parseCommand(strings.TrimSpace(line)), nil
+}
+
 func handleClient(conn net.Conn, store *core.KVStore) {
 	defer conn.Close()
 
@@ -61,59 +154,56 @@ func handleClient(conn net.Conn, store *core.KVStore) {
 		}
 	}()
 
-	reader := bufio.NewReader(conn)
+	client := &clientConn{Conn: conn, reader: bufio.NewReader(conn)}
 
 	for {
 		// Set deadline to prevent hanging connections (5 minute timeout)
-		conn.SetDeadline(time.Now().Add(300 * time.Second))
+		conn.SetDeadline(idleDeadline())
 
-		// Peek at the first byte to determine protocol
-		peek, err := reader.Peek(1)
-		if err == io.EOF {
-			return
-		}
+		parts, err := readCommand(client.reader)
 		if err != nil {
-			return
-		}
-
-		var parts []string
-		if peek[0] == '*' {
-			// Binary RESP protocol
-			parts, err = ParseRESP(reader)
-			if err != nil {
-				// Protocol error
-				conn.Write([]byte(fmt.Sprintf("-ERR Protocol error: %v\r\n", err)))
-				return
-			}
-		} else {
-			// Inline text protocol (Telnet)
-			line, err := reader.ReadString('\n')
-			if err != nil {
-				if err != io.EOF {
-					fmt.Println("Read error:", err)
-				}
-				return
-			}
-			line = strings.TrimSpace(line)
-			if len(line) == 0 {
-				continue
+			if perr, ok := err.(protocolError); ok {
+				conn.Write([]byte("-ERR " + perr.Error() + "\r\n"))
 			}
-			parts = parseCommand(line)
+			return
 		}
-
 		if len(parts) == 0 {
 			continue
 		}
 
 		// Dispatch command
 		cmdName := strings.ToUpper(parts[0])
-		if handler, exists := Handlers[cmdName]; exists {
-			response := handler(conn, store, parts)
-			if response != nil {
-				conn.Write(response)
-			}
-		} else {
+		handler, exists := Handlers[cmdName]
+		if !exists {
+			client.txFailed = client.multi
 			conn.Write([]byte("-ERR unknown command\r\n"))
+			continue
+		}
+		var done func()
+		if c := clusterOf(store); c != nil {
+			var redirect []byte
+			if redirect, done = c.route(client, cmdName, parts); redirect != nil {
+				client.txFailed = client.multi
+				conn.Write(redirect)
+				continue
+			}
+		}
+		var response []byte
+		switch {
+		case client.multi && !txControl[cmdName]:
+			response = client.queue(cmdName, parts)
+		case touchesData(cmdName):
+			unlock := txLockOf(store).rlock(txStripes([][]string{parts}))
+			response = execute(client, store, cmdName, handler, parts)
+			unlock()
+		default:
+			response = execute(client, store, cmdName, handler, parts)
+		}
+		if done != nil {
+			done()
+		}
+		if response != nil {
+			conn.Write(response)
 		}
 	}
 }
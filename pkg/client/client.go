@@ -0,0 +1,162 @@
+// Package client is the Go client for SusyDB. A Client keeps a pool of
+// connections to one server and is safe for concurrent use:
+//
+//	c := client.New(client.Options{Addr: "localhost:7379"})
+//	defer c.Close()
+//
+//	if err := c.Set(ctx, "greeting", "hello", 0).Err(); err != nil { ... }
+//	val, err := c.Get(ctx, "greeting").Result() // err is ErrNil if missing
+//
+// Every command method takes a context. Its deadline bounds the round trip
+// (Options.Timeout applies when it has none), and cancelling it abandons
+// the call. Error replies are returned as Error values, whose text keeps
+// the server's prefix (ERR, WRONGTYPE, MOVED, ...).
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"time"
+
+	"github.com/Syed-Suhaan/SusyDB/pkg/resp"
+)
+
+const (
+	defaultAddr        = "localhost:7379"
+	defaultDialTimeout = 5 * time.Second
+	defaultTimeout     = 3 * time.Second
+	// The server closes connections idle for 5 minutes; stop reusing them
+	// well before that.
+	defaultIdleTimeout = 4 * time.Minute
+)
+
+var (
+	// ErrNil is returned for a null reply: a missing key, field or value.
+	ErrNil = errors.New("susydb: nil")
+	// ErrClosed is returned by calls on a closed Client.
+	ErrClosed = errors.New("susydb: client is closed")
+)
+
+// Error is an error reply from the server.
+type Error = resp.Error
+
+// Options configures a Client. Zero values select the defaults.
+type Options struct {
+	Addr        string        // host:port of the server (localhost:7379)
+	DialTimeout time.Duration // Limit for opening a connection (5s)
+	Timeout     time.Duration // Round trip limit when the context has no deadline (3s)
+	PoolSize    int           // Most connections open at once (10 per CPU)
+	IdleTimeout time.Duration // Idle connections older than this are closed (4m)
+}
+
+func (o *Options) setDefaults() {
+	if o.Addr == "" {
+		o.Addr = defaultAddr
+	}
+	if o.DialTimeout <= 0 {
+		o.DialTimeout = defaultDialTimeout
+	}
+	if o.Timeout <= 0 {
+		o.Timeout = defaultTimeout
+	}
+	if o.PoolSize <= 0 {
+		o.PoolSize = 10 * runtime.GOMAXPROCS(0)
+	}
+	if o.IdleTimeout <= 0 {
+		o.IdleTimeout = defaultIdleTimeout
+	}
+}
+
+// Client is a pool of connections to one SusyDB server. The typed command
+// methods come from the embedded commands and run immediately.
+type Client struct {
+	commands
+	opts Options
+	pool *pool
+}
+
+// New returns a Client for the server at opts.Addr. Connections are opened
+// as calls need them.
+func New(opts Options) *Client {
+	opts.setDefaults()
+	c := &Client{opts: opts, pool: newPool(&opts)}
+	c.commands = c.process
+	return c
+}
+
+// Addr returns the address of the server.
+func (c *Client) Addr() string { return c.opts.Addr }
+
+// Close closes the idle connections; connections in use are closed when
+// their call returns. Calls made afterwards fail with ErrClosed.
+func (c *Client) Close() error {
+	c.pool.close()
+	return nil
+}
+
+// PoolStats returns the pool's counters.
+func (c *Client) PoolStats() PoolStats { return c.pool.stats() }
+
+// process runs one command.
+func (c *Client) process(ctx context.Context, cmd command) {
+	c.roundTrip(ctx, []command{cmd}, false)
+}
+
+// roundTrip sends cmds in one write, wrapped in MULTI/EXEC if tx is set,
+// and reads their replies into them. A failure that leaves replies unread
+// is set on every command, and the connection is discarded.
+func (c *Client) roundTrip(ctx context.Context, cmds []command, tx bool) {
+	cn, err := c.pool.get(ctx)
+	if err != nil {
+		setErr(cmds, err)
+		return
+	}
+	stop := cn.watch(ctx, c.opts.Timeout)
+	if tx {
+		err = cn.transact(cmds)
+	} else {
+		err = cn.pipeline(cmds)
+	}
+	// A context that fired may have already broken the deadline
+	reuse := stop() && err == nil
+	c.pool.put(cn, reuse)
+	if err != nil {
+		setErr(cmds, contextErr(ctx, err))
+	}
+}
+
+// contextErr reports a failure caused by ctx as the context's error. The
+// socket deadline can expire a moment before the context's own timer.
+func contextErr(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if deadline, ok := ctx.Deadline(); ok && errors.Is(err, os.ErrDeadlineExceeded) && !time.Now().Before(deadline) {
+		return context.DeadlineExceeded
+	}
+	return err
+}
+
+func setErr(cmds []command, err error) {
+	for _, cmd := range cmds {
+		cmd.setErr(err)
+	}
+}
+
+// firstErr returns the first command error, as Pipeline.Exec reports.
+func firstErr(cmds []command) error {
+	for _, cmd := range cmds {
+		if err := cmd.Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unexpected is the error for a reply of the wrong type.
+func unexpected(v interface{}) error {
+	return fmt.Errorf("susydb: unexpected reply %T", v)
+}
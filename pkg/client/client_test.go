@@ -0,0 +1,216 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Syed-Suhaan/SusyDB/internal/server"
+	"github.com/Syed-Suhaan/SusyDB/pkg/core"
+)
+
+// startServer serves a new store on a free local port and returns its
+// address. The server runs until the test binary exits.
+func startServer(t *testing.T) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+	go server.Start(core.NewKVStore(), addr)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		conn, err := net.Dial("tcp", addr)
+		if err == nil {
+			conn.Close()
+			return addr
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("server on %s did not start: %v", addr, err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func newClient(t *testing.T, addr string) *Client {
+	t.Helper()
+	c := New(Options{Addr: addr, Timeout: 5 * time.Second})
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func TestCommands(t *testing.T) {
+	ctx := context.Background()
+	c := newClient(t, startServer(t))
+
+	if err := c.Set(ctx, "greeting", "hello", 0).Err(); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if val, err := c.Get(ctx, "greeting").Result(); err != nil || val != "hello" {
+		t.Errorf("Get() = %q, %v; want hello", val, err)
+	}
+	if _, err := c.Get(ctx, "missing").Result(); err != ErrNil {
+		t.Errorf("Get(missing) error = %v, want ErrNil", err)
+	}
+	if n, err := c.IncrBy(ctx, "hits", 5).Result(); err != nil || n != 5 {
+		t.Errorf("IncrBy() = %d, %v; want 5", n, err)
+	}
+
+	c.HSet(ctx, "user:1", "name", "ada")
+	c.HSet(ctx, "user:1", "lang", "go")
+	fields, err := c.HGetAll(ctx, "user:1").Result()
+	if err != nil || len(fields) != 2 || fields["name"] != "ada" || fields["lang"] != "go" {
+		t.Errorf("HGetAll() = %v, %v", fields, err)
+	}
+	if ok, err := c.HDel(ctx, "user:1", "lang").Result(); err != nil || !ok {
+		t.Errorf("HDel() = %v, %v; want true", ok, err)
+	}
+
+	var e Error
+	if err := c.Incr(ctx, "user:1").Err(); !errors.As(err, &e) || e.Prefix() != "ERR" {
+		t.Errorf("Incr(hash) error = %v, want an ERR reply", err)
+	}
+
+	c.PFAdd(ctx, "visitors", "a", "b", "c", "a")
+	if n, err := c.PFCount(ctx, "visitors").Result(); err != nil || n != 3 {
+		t.Errorf("PFCount() = %d, %v; want 3", n, err)
+	}
+
+	if val, err := c.Do(ctx, "PING").Result(); err != nil || val != "PONG" {
+		t.Errorf("Do(PING) = %v, %v", val, err)
+	}
+}
+
+func TestPipeline(t *testing.T) {
+	ctx := context.Background()
+	c := newClient(t, startServer(t))
+
+	p := c.Pipeline()
+	set := p.Set(ctx, "a", "1", 0)
+	incr := p.Incr(ctx, "a")
+	get := p.Get(ctx, "a")
+	missing := p.Get(ctx, "b")
+	if err := p.Exec(ctx); err != ErrNil {
+		t.Fatalf("Exec() error = %v, want ErrNil from the missing key", err)
+	}
+	if set.Val() != "OK" || incr.Val() != 2 || get.Val() != "2" || missing.Err() != ErrNil {
+		t.Errorf("results = %q, %d, %q, %v", set.Val(), incr.Val(), get.Val(), missing.Err())
+	}
+	if p.Len() != 0 {
+		t.Errorf("Len() after Exec = %d, want 0", p.Len())
+	}
+}
+
+func TestTxPipeline(t *testing.T) {
+	ctx := context.Background()
+	c := newClient(t, startServer(t))
+	c.HSet(ctx, "h", "f", "v")
+
+	var incr *Result[int64]
+	var bad *Result[int64]
+	err := c.TxPipelined(ctx, func(p *Pipeline) error {
+		incr = p.Incr(ctx, "n")
+		bad = p.Incr(ctx, "h")
+		return nil
+	})
+	var e Error
+	if !errors.As(err, &e) || e.Prefix() != "ERR" {
+		t.Fatalf("TxPipelined() error = %v, want an ERR reply", err)
+	}
+	if incr.Val() != 1 || bad.Err() == nil {
+		t.Errorf("results = %d, %v; want 1 and an error", incr.Val(), bad.Err())
+	}
+
+	// A command refused while queueing aborts the whole transaction
+	p := c.TxPipeline()
+	incr = p.Incr(ctx, "n")
+	unknown := p.Do(ctx, "NOSUCHCOMMAND")
+	if err := p.Exec(ctx); err == nil {
+		t.Fatal("Exec() error = nil, want an error")
+	}
+	if !errors.As(unknown.Err(), &e) || e.Prefix() != "ERR" {
+		t.Errorf("unknown command error = %v, want ERR", unknown.Err())
+	}
+	if !errors.As(incr.Err(), &e) || e.Prefix() != "EXECABORT" {
+		t.Errorf("queued command error = %v, want EXECABORT", incr.Err())
+	}
+	if n, _ := c.Get(ctx, "n").Result(); n != "1" {
+		t.Errorf("n = %q after the aborted transaction, want 1", n)
+	}
+}
+
+// silentServer accepts connections and never replies.
+func silentServer(t *testing.T) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go io.Copy(io.Discard, conn)
+		}
+	}()
+	return l.Addr().String()
+}
+
+func TestContext(t *testing.T) {
+	c := newClient(t, silentServer(t))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := c.Ping(ctx).Err(); err != context.DeadlineExceeded {
+		t.Errorf("Ping() past the deadline error = %v, want DeadlineExceeded", err)
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	if err := c.Ping(ctx).Err(); err != context.Canceled {
+		t.Errorf("Ping() cancelled error = %v, want Canceled", err)
+	}
+
+	// A timed out connection is not reused
+	if stats := c.PoolStats(); stats.IdleConns != 0 {
+		t.Errorf("IdleConns = %d, want 0", stats.IdleConns)
+	}
+}
+
+func TestPool(t *testing.T) {
+	ctx := context.Background()
+	c := New(Options{Addr: startServer(t), PoolSize: 2})
+	defer c.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := c.Incr(ctx, "n").Err(); err != nil {
+				t.Errorf("Incr() error = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n, _ := c.Get(ctx, "n").Result(); n != "20" {
+		t.Errorf("n = %q, want 20", n)
+	}
+	if stats := c.PoolStats(); stats.Misses > 2 || stats.IdleConns > 2 {
+		t.Errorf("PoolStats() = %+v, want at most 2 connections", stats)
+	}
+
+	c.Close()
+	if err := c.Ping(ctx).Err(); err != ErrClosed {
+		t.Errorf("Ping() after Close error = %v, want ErrClosed", err)
+	}
+}
@@ -0,0 +1,292 @@
+package client
+
+import (
+	"context"
+	"strconv"
+	"time"
+)
+
+func formatInt(n int64) string     { return strconv.FormatInt(n, 10) }
+func formatUint(n uint64) string   { return strconv.FormatUint(n, 10) }
+func formatFloat(f float64) string { return strconv.FormatFloat(f, 'f', -1, 64) }
+
+// Set sets key to value, expiring after ttl (rounded up to whole seconds)
+// unless ttl is 0.
+func (c commands) Set(ctx context.Context, key, value string, ttl time.Duration) *Result[string] {
+	args := []string{"SET", key, value}
+	if ttl > 0 {
+		seconds := int64((ttl + time.Second - 1) / time.Second)
+		args = []string{"SETEX", key, formatInt(seconds), value}
+	}
+	r := newResult(parseString, args...)
+	c(ctx, r)
+	return r
+}
+
+// Get returns the value of key, or ErrNil.
+func (c commands) Get(ctx context.Context, key string) *Result[string] {
+	r := newResult(parseString, "GET", key)
+	c(ctx, r)
+	return r
+}
+
+// MGet returns the values of keys in order: a string, or nil for a key
+// that is missing or not a string.
+func (c commands) MGet(ctx context.Context, keys ...string) *Result[[]interface{}] {
+	r := newResult(parseValues, append([]string{"MGET"}, keys...)...)
+	c(ctx, r)
+	return r
+}
+
+// MSet sets keys to values, given as key, value, key, value, ...
+func (c commands) MSet(ctx context.Context, pairs ...string) *Result[string] {
+	r := newResult(parseString, append([]string{"MSET"}, pairs...)...)
+	c(ctx, r)
+	return r
+}
+
+// parseValues reads an array of strings and nulls.
+func parseValues(v interface{}) ([]interface{}, error) {
+	items, err := parseArray(v)
+	if err != nil {
+		return nil, err
+	}
+	for i, item := range items {
+		if item != nil {
+			if items[i], err = parseString(item); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return items, nil
+}
+
+// Incr adds 1 to the integer at key and returns the new value.
+func (c commands) Incr(ctx context.Context, key string) *Result[int64] {
+	r := newResult(parseInt, "INCR", key)
+	c(ctx, r)
+	return r
+}
+
+// IncrBy adds delta to the integer at key and returns the new value.
+func (c commands) IncrBy(ctx context.Context, key string, delta int64) *Result[int64] {
+	r := newResult(parseInt, "INCRBY", key, formatInt(delta))
+	c(ctx, r)
+	return r
+}
+
+// Del deletes key, whatever its type.
+func (c commands) Del(ctx context.Context, key string) *Result[string] {
+	r := newResult(parseString, "DEL", key)
+	c(ctx, r)
+	return r
+}
+
+// Type returns the type of the value at key, or "none".
+func (c commands) Type(ctx context.Context, key string) *Result[string] {
+	r := newResult(parseString, "TYPE", key)
+	c(ctx, r)
+	return r
+}
+
+// ScanPage is one reply to Scan: some keys and the cursor to continue
+// from, 0 when the iteration is complete.
+type ScanPage struct {
+	Keys   []string
+	Cursor uint64
+}
+
+// Scan iterates over the keys: start with cursor 0 and pass each reply's
+// Cursor to the next call. match filters the keys by glob pattern ("" for
+// all), and count is roughly how many keys each call visits (0 for the
+// server default).
+func (c commands) Scan(ctx context.Context, cursor uint64, match string, count int) *Result[ScanPage] {
+	args := []string{"SCAN", formatUint(cursor)}
+	if match != "" {
+		args = append(args, "MATCH", match)
+	}
+	if count > 0 {
+		args = append(args, "COUNT", strconv.Itoa(count))
+	}
+	r := newResult(parseScanPage, args...)
+	c(ctx, r)
+	return r
+}
+
+func parseScanPage(v interface{}) (ScanPage, error) {
+	var page ScanPage
+	items, err := parseArray(v)
+	if err != nil || len(items) != 2 {
+		return page, unexpected(v)
+	}
+	s, err := parseString(items[0])
+	if err != nil {
+		return page, err
+	}
+	if page.Cursor, err = strconv.ParseUint(s, 10, 64); err != nil {
+		return page, unexpected(v)
+	}
+	page.Keys, err = parseStrings(items[1])
+	return page, err
+}
+
+// HSet sets field in the hash at key.
+func (c commands) HSet(ctx context.Context, key, field, value string) *Result[string] {
+	r := newResult(parseString, "HSET", key, field, value)
+	c(ctx, r)
+	return r
+}
+
+// HGet returns field of the hash at key, or ErrNil.
+func (c commands) HGet(ctx context.Context, key, field string) *Result[string] {
+	r := newResult(parseString, "HGET", key, field)
+	c(ctx, r)
+	return r
+}
+
+// HGetAll returns every field of the hash at key.
+func (c commands) HGetAll(ctx context.Context, key string) *Result[map[string]string] {
+	r := newResult(parseStringMap, "HGETALL", key)
+	c(ctx, r)
+	return r
+}
+
+// HDel deletes field from the hash at key and reports whether it existed.
+func (c commands) HDel(ctx context.Context, key, field string) *Result[bool] {
+	r := newResult(parseBool, "HDEL", key, field)
+	c(ctx, r)
+	return r
+}
+
+// SetBit sets the bit at offset and returns its old value.
+func (c commands) SetBit(ctx context.Context, key string, offset uint64, value int) *Result[int64] {
+	r := newResult(parseInt, "SETBIT", key, formatUint(offset), strconv.Itoa(value))
+	c(ctx, r)
+	return r
+}
+
+// GetBit returns the bit at offset.
+func (c commands) GetBit(ctx context.Context, key string, offset uint64) *Result[int64] {
+	r := newResult(parseInt, "GETBIT", key, formatUint(offset))
+	c(ctx, r)
+	return r
+}
+
+// BitRange limits BITCOUNT and BITPOS to bytes Start..End (negative counts
+// from the end), or to bits if Bit is set.
+type BitRange struct {
+	Start, End int64
+	Bit        bool
+}
+
+func (br *BitRange) args() []string {
+	if br == nil {
+		return nil
+	}
+	args := []string{formatInt(br.Start), formatInt(br.End)}
+	if br.Bit {
+		args = append(args, "BIT")
+	}
+	return args
+}
+
+// BitCount counts the set bits of key, within br if it is not nil.
+func (c commands) BitCount(ctx context.Context, key string, br *BitRange) *Result[int64] {
+	r := newResult(parseInt, append([]string{"BITCOUNT", key}, br.args()...)...)
+	c(ctx, r)
+	return r
+}
+
+// BitPos returns the position of the first bit set to bit, within br if it
+// is not nil.
+func (c commands) BitPos(ctx context.Context, key string, bit int, br *BitRange) *Result[int64] {
+	r := newResult(parseInt, append([]string{"BITPOS", key, strconv.Itoa(bit)}, br.args()...)...)
+	c(ctx, r)
+	return r
+}
+
+// BitOp stores the bitwise op (AND, OR, XOR or NOT) of keys in dest and
+// returns its length.
+func (c commands) BitOp(ctx context.Context, op, dest string, keys ...string) *Result[int64] {
+	r := newResult(parseInt, append([]string{"BITOP", op, dest}, keys...)...)
+	c(ctx, r)
+	return r
+}
+
+// BitField runs BITFIELD subcommands, such as "GET", "u8", "0" or
+// "OVERFLOW", "SAT". The reply has an int64 per GET, SET and INCRBY, or nil
+// for an INCRBY that failed with OVERFLOW FAIL.
+func (c commands) BitField(ctx context.Context, key string, args ...string) *Result[[]interface{}] {
+	r := newResult(parseArray, append([]string{"BITFIELD", key}, args...)...)
+	c(ctx, r)
+	return r
+}
+
+// PFAdd adds elements to the HyperLogLog at key and reports whether its
+// estimate changed.
+func (c commands) PFAdd(ctx context.Context, key string, elements ...string) *Result[bool] {
+	r := newResult(parseBool, append([]string{"PFADD", key}, elements...)...)
+	c(ctx, r)
+	return r
+}
+
+// PFCount estimates the number of distinct elements in the union of keys.
+func (c commands) PFCount(ctx context.Context, keys ...string) *Result[int64] {
+	r := newResult(parseInt, append([]string{"PFCOUNT"}, keys...)...)
+	c(ctx, r)
+	return r
+}
+
+// PFMerge stores the union of the HyperLogLogs at keys in dest.
+func (c commands) PFMerge(ctx context.Context, dest string, keys ...string) *Result[string] {
+	r := newResult(parseString, append([]string{"PFMERGE", dest}, keys...)...)
+	c(ctx, r)
+	return r
+}
+
+// Dump returns the value of key serialized for Restore, or ErrNil.
+func (c commands) Dump(ctx context.Context, key string) *Result[string] {
+	r := newResult(parseString, "DUMP", key)
+	c(ctx, r)
+	return r
+}
+
+// Restore creates key from a Dump payload, expiring after ttl unless it is
+// 0. Without replace it fails if the key exists.
+func (c commands) Restore(ctx context.Context, key string, ttl time.Duration, payload string, replace bool) *Result[string] {
+	args := []string{"RESTORE", key, formatInt(ttl.Milliseconds()), payload}
+	if replace {
+		args = append(args, "REPLACE")
+	}
+	r := newResult(parseString, args...)
+	c(ctx, r)
+	return r
+}
+
+// Ping checks the connection; the reply is PONG.
+func (c commands) Ping(ctx context.Context) *Result[string] {
+	r := newResult(parseString, "PING")
+	c(ctx, r)
+	return r
+}
+
+// Info returns the given INFO sections, or all of them.
+func (c commands) Info(ctx context.Context, sections ...string) *Result[string] {
+	r := newResult(parseString, append([]string{"INFO"}, sections...)...)
+	c(ctx, r)
+	return r
+}
+
+// ConfigGet returns the parameters matching pattern and their values.
+func (c commands) ConfigGet(ctx context.Context, pattern string) *Result[map[string]string] {
+	r := newResult(parseStringMap, "CONFIG", "GET", pattern)
+	c(ctx, r)
+	return r
+}
+
+// ConfigSet changes a parameter.
+func (c commands) ConfigSet(ctx context.Context, param, value string) *Result[string] {
+	r := newResult(parseString, "CONFIG", "SET", param, value)
+	c(ctx, r)
+	return r
+}
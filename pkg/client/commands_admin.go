@@ -0,0 +1,339 @@
+package client
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"time"
+)
+
+// Publish sends message on channel and returns how many subscribers got it.
+func (c commands) Publish(ctx context.Context, channel, message string) *Result[int64] {
+	r := newResult(parseInt, "PUBLISH", channel, message)
+	c(ctx, r)
+	return r
+}
+
+// SPublish sends message on a shard channel.
+func (c commands) SPublish(ctx context.Context, channel, message string) *Result[int64] {
+	r := newResult(parseInt, "SPUBLISH", channel, message)
+	c(ctx, r)
+	return r
+}
+
+// PubSubChannels lists the channels with subscribers that match pattern
+// ("" for all).
+func (c commands) PubSubChannels(ctx context.Context, pattern string) *Result[[]string] {
+	return c.pubSubList(ctx, "CHANNELS", pattern)
+}
+
+// PubSubShardChannels lists the shard channels with subscribers that match
+// pattern ("" for all).
+func (c commands) PubSubShardChannels(ctx context.Context, pattern string) *Result[[]string] {
+	return c.pubSubList(ctx, "SHARDCHANNELS", pattern)
+}
+
+func (c commands) pubSubList(ctx context.Context, sub, pattern string) *Result[[]string] {
+	args := []string{"PUBSUB", sub}
+	if pattern != "" {
+		args = append(args, pattern)
+	}
+	r := newResult(parseStrings, args...)
+	c(ctx, r)
+	return r
+}
+
+// PubSubNumSub returns the number of subscribers of each channel.
+func (c commands) PubSubNumSub(ctx context.Context, channels ...string) *Result[map[string]int64] {
+	r := newResult(parseIntMap, append([]string{"PUBSUB", "NUMSUB"}, channels...)...)
+	c(ctx, r)
+	return r
+}
+
+// PubSubShardNumSub returns the number of subscribers of each shard channel.
+func (c commands) PubSubShardNumSub(ctx context.Context, channels ...string) *Result[map[string]int64] {
+	r := newResult(parseIntMap, append([]string{"PUBSUB", "SHARDNUMSUB"}, channels...)...)
+	c(ctx, r)
+	return r
+}
+
+// PubSubNumPat returns the number of pattern subscriptions.
+func (c commands) PubSubNumPat(ctx context.Context) *Result[int64] {
+	r := newResult(parseInt, "PUBSUB", "NUMPAT")
+	c(ctx, r)
+	return r
+}
+
+// ChannelStats counts a channel's messages since the server started.
+type ChannelStats struct {
+	Delivered, Dropped int64
+}
+
+// PubSubStats returns the delivery counts of channels.
+func (c commands) PubSubStats(ctx context.Context, channels ...string) *Result[map[string]ChannelStats] {
+	r := newResult(parseChannelStats, append([]string{"PUBSUB", "STATS"}, channels...)...)
+	c(ctx, r)
+	return r
+}
+
+// parseChannelStats reads [channel, delivered, n, dropped, n] per channel.
+func parseChannelStats(v interface{}) (map[string]ChannelStats, error) {
+	items, err := parseArray(v)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]ChannelStats, len(items))
+	for _, item := range items {
+		fields, err := parseArray(item)
+		if err != nil || len(fields) != 5 {
+			return nil, unexpected(item)
+		}
+		channel, err := parseString(fields[0])
+		if err != nil {
+			return nil, err
+		}
+		var stats ChannelStats
+		if stats.Delivered, err = parseInt(fields[2]); err != nil {
+			return nil, err
+		}
+		if stats.Dropped, err = parseInt(fields[4]); err != nil {
+			return nil, err
+		}
+		out[channel] = stats
+	}
+	return out, nil
+}
+
+// Retain keeps channel's recent messages for RSubscribe: at most maxLen
+// of them and none older than maxAge (0 for no limit).
+func (c commands) Retain(ctx context.Context, channel string, maxLen int, maxAge time.Duration) *Result[string] {
+	args := []string{"RETAIN", channel}
+	if maxLen > 0 {
+		args = append(args, "MAXLEN", strconv.Itoa(maxLen))
+	}
+	if maxAge > 0 {
+		args = append(args, "MAXAGE", formatInt(maxAge.Milliseconds()))
+	}
+	r := newResult(parseString, args...)
+	c(ctx, r)
+	return r
+}
+
+// RetainOff stops retaining channel's messages.
+func (c commands) RetainOff(ctx context.Context, channel string) *Result[string] {
+	r := newResult(parseString, "RETAIN", channel, "OFF")
+	c(ctx, r)
+	return r
+}
+
+// ReplicaOf makes the server a replica of the primary at addr (host:port).
+func (c commands) ReplicaOf(ctx context.Context, addr string) *Result[string] {
+	host, port, err := net.SplitHostPort(addr)
+	r := newResult(parseString, "REPLICAOF", host, port)
+	if err != nil {
+		r.setErr(err)
+		return r
+	}
+	c(ctx, r)
+	return r
+}
+
+// ReplicaOfNoOne promotes a replica to a primary.
+func (c commands) ReplicaOfNoOne(ctx context.Context) *Result[string] {
+	r := newResult(parseString, "REPLICAOF", "NO", "ONE")
+	c(ctx, r)
+	return r
+}
+
+// Migrate moves key to the server at addr, replacing it there if replace
+// is set; the reply is NOKEY if the key does not exist.
+func (c commands) Migrate(ctx context.Context, addr, key string, timeout time.Duration, replace bool) *Result[string] {
+	host, port, err := net.SplitHostPort(addr)
+	args := []string{"MIGRATE", host, port, key, "0", formatInt(timeout.Milliseconds())}
+	if replace {
+		args = append(args, "REPLACE")
+	}
+	r := newResult(parseString, args...)
+	if err != nil {
+		r.setErr(err)
+		return r
+	}
+	c(ctx, r)
+	return r
+}
+
+// ClusterInfo returns the CLUSTER INFO report.
+func (c commands) ClusterInfo(ctx context.Context) *Result[string] {
+	r := newResult(parseString, "CLUSTER", "INFO")
+	c(ctx, r)
+	return r
+}
+
+// ClusterMyID returns the node's ID.
+func (c commands) ClusterMyID(ctx context.Context) *Result[string] {
+	r := newResult(parseString, "CLUSTER", "MYID")
+	c(ctx, r)
+	return r
+}
+
+// ClusterNodes returns the CLUSTER NODES description of every node.
+func (c commands) ClusterNodes(ctx context.Context) *Result[string] {
+	r := newResult(parseString, "CLUSTER", "NODES")
+	c(ctx, r)
+	return r
+}
+
+// ClusterNode is a node serving a slot range.
+type ClusterNode struct {
+	ID   string
+	Addr string
+}
+
+// ClusterSlot is a range of slots and the node serving them.
+type ClusterSlot struct {
+	Start, End int
+	Nodes      []ClusterNode
+}
+
+// ClusterSlots returns the slot ranges and their owners.
+func (c commands) ClusterSlots(ctx context.Context) *Result[[]ClusterSlot] {
+	r := newResult(parseClusterSlots, "CLUSTER", "SLOTS")
+	c(ctx, r)
+	return r
+}
+
+// parseClusterSlots reads [start, end, [host, port, id] ...] per range.
+func parseClusterSlots(v interface{}) ([]ClusterSlot, error) {
+	items, err := parseArray(v)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]ClusterSlot, len(items))
+	for i, item := range items {
+		fields, err := parseArray(item)
+		if err != nil || len(fields) < 2 {
+			return nil, unexpected(item)
+		}
+		start, err1 := parseInt(fields[0])
+		end, err2 := parseInt(fields[1])
+		if err1 != nil || err2 != nil {
+			return nil, unexpected(item)
+		}
+		out[i] = ClusterSlot{Start: int(start), End: int(end)}
+		for _, node := range fields[2:] {
+			info, err := parseStrings(node)
+			if err != nil || len(info) < 2 {
+				return nil, unexpected(node)
+			}
+			n := ClusterNode{Addr: net.JoinHostPort(info[0], info[1])}
+			if len(info) > 2 {
+				n.ID = info[2]
+			}
+			out[i].Nodes = append(out[i].Nodes, n)
+		}
+	}
+	return out, nil
+}
+
+// ClusterKeySlot returns the hash slot of key.
+func (c commands) ClusterKeySlot(ctx context.Context, key string) *Result[int64] {
+	r := newResult(parseInt, "CLUSTER", "KEYSLOT", key)
+	c(ctx, r)
+	return r
+}
+
+// ClusterCountKeysInSlot returns the number of keys in slot on the node.
+func (c commands) ClusterCountKeysInSlot(ctx context.Context, slot int) *Result[int64] {
+	r := newResult(parseInt, "CLUSTER", "COUNTKEYSINSLOT", strconv.Itoa(slot))
+	c(ctx, r)
+	return r
+}
+
+// ClusterGetKeysInSlot returns up to count keys of slot.
+func (c commands) ClusterGetKeysInSlot(ctx context.Context, slot, count int) *Result[[]string] {
+	r := newResult(parseStrings, "CLUSTER", "GETKEYSINSLOT", strconv.Itoa(slot), strconv.Itoa(count))
+	c(ctx, r)
+	return r
+}
+
+// ClusterAddSlots assigns slots to the node.
+func (c commands) ClusterAddSlots(ctx context.Context, slots ...int) *Result[string] {
+	return c.clusterSlots(ctx, "ADDSLOTS", slots)
+}
+
+// ClusterDelSlots unassigns slots from the node.
+func (c commands) ClusterDelSlots(ctx context.Context, slots ...int) *Result[string] {
+	return c.clusterSlots(ctx, "DELSLOTS", slots)
+}
+
+// ClusterAddSlotsRange assigns slots start to end to the node.
+func (c commands) ClusterAddSlotsRange(ctx context.Context, start, end int) *Result[string] {
+	return c.clusterSlots(ctx, "ADDSLOTSRANGE", []int{start, end})
+}
+
+// ClusterDelSlotsRange unassigns slots start to end from the node.
+func (c commands) ClusterDelSlotsRange(ctx context.Context, start, end int) *Result[string] {
+	return c.clusterSlots(ctx, "DELSLOTSRANGE", []int{start, end})
+}
+
+func (c commands) clusterSlots(ctx context.Context, sub string, slots []int) *Result[string] {
+	args := []string{"CLUSTER", sub}
+	for _, slot := range slots {
+		args = append(args, strconv.Itoa(slot))
+	}
+	r := newResult(parseString, args...)
+	c(ctx, r)
+	return r
+}
+
+// ClusterMeet introduces the node at addr (host:port) to the cluster.
+func (c commands) ClusterMeet(ctx context.Context, addr string) *Result[string] {
+	host, port, err := net.SplitHostPort(addr)
+	r := newResult(parseString, "CLUSTER", "MEET", host, port)
+	if err != nil {
+		r.setErr(err)
+		return r
+	}
+	c(ctx, r)
+	return r
+}
+
+// ClusterForget removes a node from this node's view for a minute.
+func (c commands) ClusterForget(ctx context.Context, nodeID string) *Result[string] {
+	r := newResult(parseString, "CLUSTER", "FORGET", nodeID)
+	c(ctx, r)
+	return r
+}
+
+// ClusterSetSlot runs CLUSTER SETSLOT slot state [nodeID], where state is
+// IMPORTING, MIGRATING or NODE with a node ID, or STABLE without one.
+func (c commands) ClusterSetSlot(ctx context.Context, slot int, state, nodeID string) *Result[string] {
+	args := []string{"CLUSTER", "SETSLOT", strconv.Itoa(slot), state}
+	if nodeID != "" {
+		args = append(args, nodeID)
+	}
+	r := newResult(parseString, args...)
+	c(ctx, r)
+	return r
+}
+
+// RaftInfo returns the Raft state report, as in INFO raft.
+func (c commands) RaftInfo(ctx context.Context) *Result[string] {
+	r := newResult(parseString, "RAFT", "INFO")
+	c(ctx, r)
+	return r
+}
+
+// RaftAddNode adds a member to the Raft group; send it to the leader.
+func (c commands) RaftAddNode(ctx context.Context, id, addr string) *Result[string] {
+	r := newResult(parseString, "RAFT", "ADDNODE", id, addr)
+	c(ctx, r)
+	return r
+}
+
+// RaftRemoveNode removes a member from the Raft group; send it to the leader.
+func (c commands) RaftRemoveNode(ctx context.Context, id string) *Result[string] {
+	r := newResult(parseString, "RAFT", "REMOVENODE", id)
+	c(ctx, r)
+	return r
+}
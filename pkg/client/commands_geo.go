@@ -0,0 +1,193 @@
+package client
+
+import (
+	"context"
+	"strconv"
+)
+
+// GeoLocation is a member of a geospatial index. GeoSearch fills in Dist
+// and Hash when asked for them.
+type GeoLocation struct {
+	Name                string
+	Longitude, Latitude float64
+	Dist                float64
+	Hash                int64
+}
+
+// GeoPos is a member's position.
+type GeoPos struct {
+	Longitude, Latitude float64
+}
+
+// GeoAdd adds or moves members and returns how many were added.
+func (c commands) GeoAdd(ctx context.Context, key string, locations ...GeoLocation) *Result[int64] {
+	args := []string{"GEOADD", key}
+	for _, loc := range locations {
+		args = append(args, formatFloat(loc.Longitude), formatFloat(loc.Latitude), loc.Name)
+	}
+	r := newResult(parseInt, args...)
+	c(ctx, r)
+	return r
+}
+
+// GeoPos returns the positions of members, nil for a missing one.
+func (c commands) GeoPos(ctx context.Context, key string, members ...string) *Result[[]*GeoPos] {
+	r := newResult(parseGeoPositions, append([]string{"GEOPOS", key}, members...)...)
+	c(ctx, r)
+	return r
+}
+
+func parseGeoPositions(v interface{}) ([]*GeoPos, error) {
+	items, err := parseArray(v)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*GeoPos, len(items))
+	for i, item := range items {
+		if item == nil {
+			continue
+		}
+		lon, lat, err := parseCoordinates(item)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = &GeoPos{Longitude: lon, Latitude: lat}
+	}
+	return out, nil
+}
+
+func parseCoordinates(v interface{}) (lon, lat float64, err error) {
+	pair, err := parseStrings(v)
+	if err != nil || len(pair) != 2 {
+		return 0, 0, unexpected(v)
+	}
+	if lon, err = strconv.ParseFloat(pair[0], 64); err != nil {
+		return 0, 0, err
+	}
+	lat, err = strconv.ParseFloat(pair[1], 64)
+	return lon, lat, err
+}
+
+// GeoDist returns the distance between two members in unit (m, km, ft or
+// mi; "" for meters), or ErrNil if one is missing.
+func (c commands) GeoDist(ctx context.Context, key, member1, member2, unit string) *Result[float64] {
+	args := []string{"GEODIST", key, member1, member2}
+	if unit != "" {
+		args = append(args, unit)
+	}
+	r := newResult(parseFloat, args...)
+	c(ctx, r)
+	return r
+}
+
+// GeoHash returns the geohash strings of members, "" for a missing one.
+func (c commands) GeoHash(ctx context.Context, key string, members ...string) *Result[[]string] {
+	r := newResult(parseStrings, append([]string{"GEOHASH", key}, members...)...)
+	c(ctx, r)
+	return r
+}
+
+// GeoSearchQuery describes a GEOSEARCH: around Member, or Longitude and
+// Latitude if Member is "", within Radius, or a Width by Height box if
+// Radius is 0.
+type GeoSearchQuery struct {
+	Member              string
+	Longitude, Latitude float64
+	Radius              float64
+	Width, Height       float64
+	Unit                string // m, km, ft or mi ("" for meters)
+	Sort                string // ASC, DESC or "" for unsorted
+	Count               int    // Most results, or 0 for all
+	Any                 bool   // With Count: stop at the first Count matches found
+
+	WithCoord, WithDist, WithHash bool
+}
+
+func (q *GeoSearchQuery) args() []string {
+	var args []string
+	if q.Member != "" {
+		args = append(args, "FROMMEMBER", q.Member)
+	} else {
+		args = append(args, "FROMLONLAT", formatFloat(q.Longitude), formatFloat(q.Latitude))
+	}
+	unit := q.Unit
+	if unit == "" {
+		unit = "m"
+	}
+	if q.Radius > 0 {
+		args = append(args, "BYRADIUS", formatFloat(q.Radius), unit)
+	} else {
+		args = append(args, "BYBOX", formatFloat(q.Width), formatFloat(q.Height), unit)
+	}
+	if q.Sort != "" {
+		args = append(args, q.Sort)
+	}
+	if q.Count > 0 {
+		args = append(args, "COUNT", strconv.Itoa(q.Count))
+		if q.Any {
+			args = append(args, "ANY")
+		}
+	}
+	for _, with := range []struct {
+		set  bool
+		name string
+	}{{q.WithCoord, "WITHCOORD"}, {q.WithDist, "WITHDIST"}, {q.WithHash, "WITHHASH"}} {
+		if with.set {
+			args = append(args, with.name)
+		}
+	}
+	return args
+}
+
+// GeoSearch returns the members matching q.
+func (c commands) GeoSearch(ctx context.Context, key string, q *GeoSearchQuery) *Result[[]GeoLocation] {
+	parse := func(v interface{}) ([]GeoLocation, error) { return parseGeoSearch(v, q) }
+	r := newResult(parse, append([]string{"GEOSEARCH", key}, q.args()...)...)
+	c(ctx, r)
+	return r
+}
+
+// parseGeoSearch reads the members, each followed by the distance, hash
+// and coordinates q asked for, in that order.
+func parseGeoSearch(v interface{}, q *GeoSearchQuery) ([]GeoLocation, error) {
+	items, err := parseArray(v)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]GeoLocation, len(items))
+	for i, item := range items {
+		if !q.WithCoord && !q.WithDist && !q.WithHash {
+			if out[i].Name, err = parseString(item); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		fields, err := parseArray(item)
+		if err != nil || len(fields) == 0 {
+			return nil, unexpected(item)
+		}
+		loc := &out[i]
+		if loc.Name, err = parseString(fields[0]); err != nil {
+			return nil, err
+		}
+		fields = fields[1:]
+		if q.WithDist && len(fields) > 0 {
+			if loc.Dist, err = parseFloat(fields[0]); err != nil {
+				return nil, err
+			}
+			fields = fields[1:]
+		}
+		if q.WithHash && len(fields) > 0 {
+			if loc.Hash, err = parseInt(fields[0]); err != nil {
+				return nil, err
+			}
+			fields = fields[1:]
+		}
+		if q.WithCoord && len(fields) > 0 {
+			if loc.Longitude, loc.Latitude, err = parseCoordinates(fields[0]); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return out, nil
+}
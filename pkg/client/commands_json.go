@@ -0,0 +1,72 @@
+package client
+
+import "context"
+
+// JSONSet sets the JSON value at path in the document at key. mode is ""
+// or NX (only if the path is missing) or XX (only if it exists); when the
+// condition fails the error is ErrNil.
+func (c commands) JSONSet(ctx context.Context, key, path, value, mode string) *Result[string] {
+	args := []string{"JSON.SET", key, path, value}
+	if mode != "" {
+		args = append(args, mode)
+	}
+	r := newResult(parseString, args...)
+	c(ctx, r)
+	return r
+}
+
+// JSONGet returns the JSON at paths (the root if none) in the document at
+// key, or ErrNil.
+func (c commands) JSONGet(ctx context.Context, key string, paths ...string) *Result[string] {
+	r := newResult(parseString, append([]string{"JSON.GET", key}, paths...)...)
+	c(ctx, r)
+	return r
+}
+
+// JSONDel deletes the values at path ("" for the whole document) and
+// returns how many were deleted.
+func (c commands) JSONDel(ctx context.Context, key, path string) *Result[int64] {
+	args := []string{"JSON.DEL", key}
+	if path != "" {
+		args = append(args, path)
+	}
+	r := newResult(parseInt, args...)
+	c(ctx, r)
+	return r
+}
+
+// JSONNumIncrBy adds by to the numbers at path and returns the new value,
+// as JSON.
+func (c commands) JSONNumIncrBy(ctx context.Context, key, path string, by float64) *Result[string] {
+	r := newResult(parseString, "JSON.NUMINCRBY", key, path, formatFloat(by))
+	c(ctx, r)
+	return r
+}
+
+// JSONArrAppend appends JSON values to the arrays at path and returns
+// their new lengths: one for a legacy path, one per match for a JSONPath
+// ($...), with -1 for a match that is not an array.
+func (c commands) JSONArrAppend(ctx context.Context, key, path string, values ...string) *Result[[]int64] {
+	r := newResult(parseArrayLengths, append([]string{"JSON.ARRAPPEND", key, path}, values...)...)
+	c(ctx, r)
+	return r
+}
+
+func parseArrayLengths(v interface{}) ([]int64, error) {
+	if n, ok := v.(int64); ok {
+		return []int64{n}, nil
+	}
+	items, err := parseArray(v)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]int64, len(items))
+	for i, item := range items {
+		if item == nil {
+			out[i] = -1
+		} else if out[i], err = parseInt(item); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,150 @@
+package client
+
+import (
+	"context"
+	"strconv"
+)
+
+// BloomOptions are the optional BF.RESERVE settings.
+type BloomOptions struct {
+	Expansion  uint // Growth factor of added sub-filters (server default 2)
+	NonScaling bool // Fail instead of growing once full
+}
+
+// BFReserve creates a Bloom filter at key.
+func (c commands) BFReserve(ctx context.Context, key string, errorRate float64, capacity uint64, opts *BloomOptions) *Result[string] {
+	args := []string{"BF.RESERVE", key, formatFloat(errorRate), formatUint(capacity)}
+	if opts != nil {
+		if opts.Expansion > 0 {
+			args = append(args, "EXPANSION", formatUint(uint64(opts.Expansion)))
+		}
+		if opts.NonScaling {
+			args = append(args, "NONSCALING")
+		}
+	}
+	r := newResult(parseString, args...)
+	c(ctx, r)
+	return r
+}
+
+// BFAdd adds item to the Bloom filter at key and reports whether it was new.
+func (c commands) BFAdd(ctx context.Context, key, item string) *Result[bool] {
+	r := newResult(parseBool, "BF.ADD", key, item)
+	c(ctx, r)
+	return r
+}
+
+// BFMAdd adds items to the Bloom filter at key.
+func (c commands) BFMAdd(ctx context.Context, key string, items ...string) *Result[[]bool] {
+	r := newResult(parseBools, append([]string{"BF.MADD", key}, items...)...)
+	c(ctx, r)
+	return r
+}
+
+// BFExists reports whether item may be in the Bloom filter at key.
+func (c commands) BFExists(ctx context.Context, key, item string) *Result[bool] {
+	r := newResult(parseBool, "BF.EXISTS", key, item)
+	c(ctx, r)
+	return r
+}
+
+// BFMExists reports for each item whether it may be in the filter.
+func (c commands) BFMExists(ctx context.Context, key string, items ...string) *Result[[]bool] {
+	r := newResult(parseBools, append([]string{"BF.MEXISTS", key}, items...)...)
+	c(ctx, r)
+	return r
+}
+
+// CuckooOptions are the optional CF.RESERVE settings; zero values keep the
+// server defaults.
+type CuckooOptions struct {
+	BucketSize    int
+	MaxIterations int
+	Expansion     int
+}
+
+// CFReserve creates a cuckoo filter at key.
+func (c commands) CFReserve(ctx context.Context, key string, capacity uint64, opts *CuckooOptions) *Result[string] {
+	args := []string{"CF.RESERVE", key, formatUint(capacity)}
+	if opts != nil {
+		for _, o := range []struct {
+			name  string
+			value int
+		}{{"BUCKETSIZE", opts.BucketSize}, {"MAXITERATIONS", opts.MaxIterations}, {"EXPANSION", opts.Expansion}} {
+			if o.value > 0 {
+				args = append(args, o.name, strconv.Itoa(o.value))
+			}
+		}
+	}
+	r := newResult(parseString, args...)
+	c(ctx, r)
+	return r
+}
+
+// CFAdd adds item to the cuckoo filter at key.
+func (c commands) CFAdd(ctx context.Context, key, item string) *Result[bool] {
+	r := newResult(parseBool, "CF.ADD", key, item)
+	c(ctx, r)
+	return r
+}
+
+// CFAddNX adds item unless it may already be in the filter, and reports
+// whether it was added.
+func (c commands) CFAddNX(ctx context.Context, key, item string) *Result[bool] {
+	r := newResult(parseBool, "CF.ADDNX", key, item)
+	c(ctx, r)
+	return r
+}
+
+// CFDel deletes one copy of item and reports whether it was found.
+func (c commands) CFDel(ctx context.Context, key, item string) *Result[bool] {
+	r := newResult(parseBool, "CF.DEL", key, item)
+	c(ctx, r)
+	return r
+}
+
+// CFExists reports whether item may be in the cuckoo filter at key.
+func (c commands) CFExists(ctx context.Context, key, item string) *Result[bool] {
+	r := newResult(parseBool, "CF.EXISTS", key, item)
+	c(ctx, r)
+	return r
+}
+
+// CMSInitByDim creates a count-min sketch of width counters by depth rows.
+func (c commands) CMSInitByDim(ctx context.Context, key string, width, depth uint64) *Result[string] {
+	r := newResult(parseString, "CMS.INITBYDIM", key, formatUint(width), formatUint(depth))
+	c(ctx, r)
+	return r
+}
+
+// CMSInitByProb creates a count-min sketch sized for an error rate and the
+// probability of exceeding it.
+func (c commands) CMSInitByProb(ctx context.Context, key string, errorRate, probability float64) *Result[string] {
+	r := newResult(parseString, "CMS.INITBYPROB", key, formatFloat(errorRate), formatFloat(probability))
+	c(ctx, r)
+	return r
+}
+
+// CMSIncrement is one item of a CMS.INCRBY.
+type CMSIncrement struct {
+	Item   string
+	Amount uint64
+}
+
+// CMSIncrBy increments items and returns their new estimated counts.
+func (c commands) CMSIncrBy(ctx context.Context, key string, incrs ...CMSIncrement) *Result[[]int64] {
+	args := []string{"CMS.INCRBY", key}
+	for _, incr := range incrs {
+		args = append(args, incr.Item, formatUint(incr.Amount))
+	}
+	r := newResult(parseInts, args...)
+	c(ctx, r)
+	return r
+}
+
+// CMSQuery returns the estimated counts of items.
+func (c commands) CMSQuery(ctx context.Context, key string, items ...string) *Result[[]int64] {
+	r := newResult(parseInts, append([]string{"CMS.QUERY", key}, items...)...)
+	c(ctx, r)
+	return r
+}
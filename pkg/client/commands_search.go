@@ -0,0 +1,112 @@
+package client
+
+import (
+	"context"
+	"sort"
+	"strconv"
+)
+
+// FTCreate creates a secondary index over the hashes whose keys start with
+// one of prefixes (all hashes if none). schema lists the fields as FT.CREATE
+// takes them after SCHEMA, such as "category", "TAG", "price", "NUMERIC".
+func (c commands) FTCreate(ctx context.Context, index string, prefixes []string, schema ...string) *Result[string] {
+	args := []string{"FT.CREATE", index, "ON", "HASH"}
+	if len(prefixes) > 0 {
+		args = append(args, "PREFIX", strconv.Itoa(len(prefixes)))
+		args = append(args, prefixes...)
+	}
+	args = append(append(args, "SCHEMA"), schema...)
+	r := newResult(parseString, args...)
+	c(ctx, r)
+	return r
+}
+
+// FTDropIndex deletes an index; the hashes stay.
+func (c commands) FTDropIndex(ctx context.Context, index string) *Result[string] {
+	r := newResult(parseString, "FT.DROPINDEX", index)
+	c(ctx, r)
+	return r
+}
+
+// FTSearchOptions are the optional FT.SEARCH settings.
+type FTSearchOptions struct {
+	NoContent bool              // Return the keys only
+	Params    map[string]string // Values for $name references in the query
+	Offset    int               // Skip this many matches
+	Limit     int               // Most documents returned, or 0 for the server default (10)
+}
+
+// FTDocument is a document found by FTSearch.
+type FTDocument struct {
+	Key    string
+	Fields map[string]string // nil with NoContent
+}
+
+// FTSearchResult is the reply to FTSearch: the number of matches, which may
+// exceed the documents returned.
+type FTSearchResult struct {
+	Total int64
+	Docs  []FTDocument
+}
+
+// FTSearch runs query against index.
+func (c commands) FTSearch(ctx context.Context, index, query string, opts *FTSearchOptions) *Result[FTSearchResult] {
+	args := []string{"FT.SEARCH", index, query}
+	noContent := false
+	if opts != nil {
+		noContent = opts.NoContent
+		if noContent {
+			args = append(args, "NOCONTENT")
+		}
+		if len(opts.Params) > 0 {
+			names := make([]string, 0, len(opts.Params))
+			for name := range opts.Params {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			args = append(args, "PARAMS", strconv.Itoa(2*len(names)))
+			for _, name := range names {
+				args = append(args, name, opts.Params[name])
+			}
+		}
+		if opts.Offset > 0 || opts.Limit > 0 {
+			limit := opts.Limit
+			if limit == 0 {
+				limit = 10
+			}
+			args = append(args, "LIMIT", strconv.Itoa(opts.Offset), strconv.Itoa(limit))
+		}
+	}
+	parse := func(v interface{}) (FTSearchResult, error) { return parseSearch(v, noContent) }
+	r := newResult(parse, args...)
+	c(ctx, r)
+	return r
+}
+
+// parseSearch reads the total, then each key followed by its fields unless
+// noContent is set.
+func parseSearch(v interface{}, noContent bool) (FTSearchResult, error) {
+	var res FTSearchResult
+	items, err := parseArray(v)
+	if err != nil || len(items) == 0 {
+		return res, unexpected(v)
+	}
+	if res.Total, err = parseInt(items[0]); err != nil {
+		return res, err
+	}
+	for items = items[1:]; len(items) > 0; {
+		var doc FTDocument
+		if doc.Key, err = parseString(items[0]); err != nil {
+			return res, err
+		}
+		items = items[1:]
+		if !noContent && len(items) > 0 {
+			if doc.Fields, err = parseStringMap(items[0]); err != nil {
+				return res, err
+			}
+			items = items[1:]
+		}
+		res.Docs = append(res.Docs, doc)
+	}
+	return res, nil
+}
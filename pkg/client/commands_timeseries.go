@@ -0,0 +1,118 @@
+package client
+
+import (
+	"context"
+	"strconv"
+	"time"
+)
+
+// TSSample is a time series sample. Timestamps are Unix milliseconds.
+type TSSample struct {
+	Timestamp int64
+	Value     float64
+}
+
+// TSKeySample is a sample for TS.MADD. A zero Timestamp lets the server
+// pick the current time.
+type TSKeySample struct {
+	Key string
+	TSSample
+}
+
+func formatTimestamp(ts int64) string {
+	if ts == 0 {
+		return "*"
+	}
+	return formatInt(ts)
+}
+
+// TSCreate creates a time series that drops samples older than retention,
+// or keeps them all if retention is 0.
+func (c commands) TSCreate(ctx context.Context, key string, retention time.Duration) *Result[string] {
+	args := []string{"TS.CREATE", key}
+	if retention > 0 {
+		args = append(args, "RETENTION", formatInt(retention.Milliseconds()))
+	}
+	r := newResult(parseString, args...)
+	c(ctx, r)
+	return r
+}
+
+// TSAdd adds a sample, creating the series if needed, and returns its
+// timestamp. A zero timestamp lets the server pick the current time.
+func (c commands) TSAdd(ctx context.Context, key string, timestamp int64, value float64) *Result[int64] {
+	r := newResult(parseInt, "TS.ADD", key, formatTimestamp(timestamp), formatFloat(value))
+	c(ctx, r)
+	return r
+}
+
+// TSMAdd adds samples to existing series. Each sample succeeds or fails on
+// its own: the reply holds its timestamp (int64) or its Error.
+func (c commands) TSMAdd(ctx context.Context, samples ...TSKeySample) *Result[[]interface{}] {
+	args := []string{"TS.MADD"}
+	for _, s := range samples {
+		args = append(args, s.Key, formatTimestamp(s.Timestamp), formatFloat(s.Value))
+	}
+	r := newResult(func(v interface{}) ([]interface{}, error) {
+		items, ok := v.([]interface{})
+		if !ok {
+			return nil, unexpected(v)
+		}
+		return items, nil
+	}, args...)
+	c(ctx, r)
+	return r
+}
+
+// TSRangeOptions are the optional TS.RANGE settings.
+type TSRangeOptions struct {
+	Count       int           // Most samples, or 0 for all
+	Aggregation string        // avg, min, max, sum or count
+	Bucket      time.Duration // Width of the aggregation buckets
+}
+
+// TSRange returns the samples from from to to (Unix milliseconds,
+// inclusive; "-" and "+" are the oldest and newest).
+func (c commands) TSRange(ctx context.Context, key, from, to string, opts *TSRangeOptions) *Result[[]TSSample] {
+	args := []string{"TS.RANGE", key, from, to}
+	if opts != nil {
+		if opts.Count > 0 {
+			args = append(args, "COUNT", strconv.Itoa(opts.Count))
+		}
+		if opts.Aggregation != "" {
+			args = append(args, "AGGREGATION", opts.Aggregation, formatInt(opts.Bucket.Milliseconds()))
+		}
+	}
+	r := newResult(parseSamples, args...)
+	c(ctx, r)
+	return r
+}
+
+func parseSamples(v interface{}) ([]TSSample, error) {
+	items, err := parseArray(v)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]TSSample, len(items))
+	for i, item := range items {
+		pair, err := parseArray(item)
+		if err != nil || len(pair) != 2 {
+			return nil, unexpected(item)
+		}
+		if out[i].Timestamp, err = parseInt(pair[0]); err != nil {
+			return nil, err
+		}
+		if out[i].Value, err = parseFloat(pair[1]); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// TSCreateRule compacts new samples of source into dest with aggregation
+// (avg, min, max, sum or count) over buckets of bucket.
+func (c commands) TSCreateRule(ctx context.Context, source, dest, aggregation string, bucket time.Duration) *Result[string] {
+	r := newResult(parseString, "TS.CREATERULE", source, dest, "AGGREGATION", aggregation, formatInt(bucket.Milliseconds()))
+	c(ctx, r)
+	return r
+}
@@ -0,0 +1,71 @@
+package client
+
+import "context"
+
+// Pipeline queues commands and sends them together in one write, reading
+// all the replies in one go. Each typed method returns its Result at once;
+// the Result is filled in by Exec. A Pipeline is not safe for concurrent
+// use.
+type Pipeline struct {
+	commands
+	exec func(ctx context.Context, cmds []command, tx bool) // Sends the commands
+	tx   bool
+	cmds []command
+}
+
+// Pipeline returns an empty pipeline.
+func (c *Client) Pipeline() *Pipeline {
+	p := &Pipeline{exec: c.roundTrip}
+	p.commands = p.queue
+	return p
+}
+
+// TxPipeline returns an empty pipeline whose commands run as a transaction:
+// they are sent between MULTI and EXEC, and the server runs them together,
+// with no other client's commands in between. If the server refuses one
+// while queueing them, none runs.
+func (c *Client) TxPipeline() *Pipeline {
+	p := c.Pipeline()
+	p.tx = true
+	return p
+}
+
+// Pipelined runs fn on a new pipeline and executes it.
+func (c *Client) Pipelined(ctx context.Context, fn func(p *Pipeline) error) error {
+	return c.pipelined(ctx, c.Pipeline(), fn)
+}
+
+// TxPipelined runs fn on a new transaction pipeline and executes it.
+func (c *Client) TxPipelined(ctx context.Context, fn func(p *Pipeline) error) error {
+	return c.pipelined(ctx, c.TxPipeline(), fn)
+}
+
+func (c *Client) pipelined(ctx context.Context, p *Pipeline, fn func(p *Pipeline) error) error {
+	if err := fn(p); err != nil {
+		return err
+	}
+	return p.Exec(ctx)
+}
+
+// queue adds cmd to the pipeline; the context is the one given to Exec.
+func (p *Pipeline) queue(_ context.Context, cmd command) {
+	p.cmds = append(p.cmds, cmd)
+}
+
+// Len returns the number of queued commands.
+func (p *Pipeline) Len() int { return len(p.cmds) }
+
+// Discard drops the queued commands.
+func (p *Pipeline) Discard() { p.cmds = nil }
+
+// Exec sends the queued commands and fills in their Results. It returns
+// the first command's error, if any, and leaves the pipeline empty.
+func (p *Pipeline) Exec(ctx context.Context) error {
+	cmds := p.cmds
+	p.cmds = nil
+	if len(cmds) == 0 {
+		return nil
+	}
+	p.exec(ctx, cmds, p.tx)
+	return firstErr(cmds)
+}
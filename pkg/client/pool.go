@@ -0,0 +1,203 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Syed-Suhaan/SusyDB/pkg/resp"
+)
+
+// PoolStats counts how calls got their connection.
+type PoolStats struct {
+	Hits      uint64 // An idle connection was reused
+	Misses    uint64 // A new connection was dialed
+	Timeouts  uint64 // The context ended while waiting for a free slot
+	IdleConns int    // Connections idle in the pool now
+}
+
+// pool hands out connections to one server, at most PoolSize at a time.
+// Idle connections are reused newest first.
+type pool struct {
+	opts  *Options
+	slots chan struct{} // Holds a token for each connection in use
+
+	mu     sync.Mutex
+	idle   []*conn
+	closed bool
+
+	hits, misses, timeouts atomic.Uint64
+}
+
+func newPool(opts *Options) *pool {
+	return &pool{opts: opts, slots: make(chan struct{}, opts.PoolSize)}
+}
+
+// get returns an idle connection or dials a new one, waiting for a free
+// slot if PoolSize connections are in use.
+func (p *pool) get(ctx context.Context) (*conn, error) {
+	select {
+	case p.slots <- struct{}{}:
+	case <-ctx.Done():
+		p.timeouts.Add(1)
+		return nil, ctx.Err()
+	}
+
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		<-p.slots
+		return nil, ErrClosed
+	}
+	for len(p.idle) > 0 {
+		cn := p.idle[len(p.idle)-1]
+		p.idle = p.idle[:len(p.idle)-1]
+		if time.Since(cn.usedAt) > p.opts.IdleTimeout {
+			cn.nc.Close()
+			continue
+		}
+		p.mu.Unlock()
+		p.hits.Add(1)
+		return cn, nil
+	}
+	p.mu.Unlock()
+
+	p.misses.Add(1)
+	dialer := net.Dialer{Timeout: p.opts.DialTimeout}
+	nc, err := dialer.DialContext(ctx, "tcp", p.opts.Addr)
+	if err != nil {
+		<-p.slots
+		return nil, err
+	}
+	return &conn{nc: nc, rd: bufio.NewReader(nc)}, nil
+}
+
+// put returns cn to the pool, or closes it unless reuse is set.
+func (p *pool) put(cn *conn, reuse bool) {
+	p.mu.Lock()
+	if reuse && !p.closed {
+		cn.usedAt = time.Now()
+		p.idle = append(p.idle, cn)
+	} else {
+		cn.nc.Close()
+	}
+	p.mu.Unlock()
+	<-p.slots
+}
+
+func (p *pool) close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed = true
+	for _, cn := range p.idle {
+		cn.nc.Close()
+	}
+	p.idle = nil
+}
+
+func (p *pool) stats() PoolStats {
+	p.mu.Lock()
+	idle := len(p.idle)
+	p.mu.Unlock()
+	return PoolStats{Hits: p.hits.Load(), Misses: p.misses.Load(), Timeouts: p.timeouts.Load(), IdleConns: idle}
+}
+
+// conn is a connection to the server. It is used by one call at a time.
+type conn struct {
+	nc     net.Conn
+	rd     *bufio.Reader
+	buf    []byte // Reused for encoding commands
+	usedAt time.Time
+}
+
+// watch applies ctx to the connection: its deadline, or timeout from now
+// if it has none, and its cancellation, which fails pending I/O at once.
+// The returned stop reports whether cancellation left the connection
+// untouched.
+func (cn *conn) watch(ctx context.Context, timeout time.Duration) (stop func() bool) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(timeout)
+	}
+	cn.nc.SetDeadline(deadline)
+	return context.AfterFunc(ctx, func() {
+		cn.nc.SetDeadline(time.Unix(1, 0))
+	})
+}
+
+// pipeline sends cmds and reads a reply for each.
+func (cn *conn) pipeline(cmds []command) error {
+	buf := cn.buf[:0]
+	for _, cmd := range cmds {
+		buf = resp.AppendCommand(buf, cmd.Args())
+	}
+	cn.buf = buf
+	if _, err := cn.nc.Write(buf); err != nil {
+		return err
+	}
+	for _, cmd := range cmds {
+		v, err := resp.ReadReply(cn.rd)
+		if err != nil {
+			return err
+		}
+		cmd.setReply(v)
+	}
+	return nil
+}
+
+// transact sends cmds between MULTI and EXEC and hands each command its
+// entry of the EXEC reply. A command refused while queueing keeps that
+// error, and the others get the EXECABORT error.
+func (cn *conn) transact(cmds []command) error {
+	buf := resp.AppendCommand(cn.buf[:0], []string{"MULTI"})
+	for _, cmd := range cmds {
+		buf = resp.AppendCommand(buf, cmd.Args())
+	}
+	buf = resp.AppendCommand(buf, []string{"EXEC"})
+	cn.buf = buf
+	if _, err := cn.nc.Write(buf); err != nil {
+		return err
+	}
+
+	// MULTI, one +QUEUED per command, then EXEC
+	replies := make([]interface{}, len(cmds)+2)
+	for i := range replies {
+		v, err := resp.ReadReply(cn.rd)
+		if err != nil {
+			return err
+		}
+		replies[i] = v
+	}
+	if e, ok := replies[0].(Error); ok {
+		for _, cmd := range cmds {
+			cmd.setReply(e)
+		}
+		return nil
+	}
+	for i, cmd := range cmds {
+		if e, ok := replies[i+1].(Error); ok {
+			cmd.setReply(e)
+		}
+	}
+	switch exec := replies[len(replies)-1].(type) {
+	case []interface{}:
+		if len(exec) != len(cmds) {
+			return unexpected(exec)
+		}
+		for i, cmd := range cmds {
+			cmd.setReply(exec[i])
+		}
+	case Error:
+		for _, cmd := range cmds {
+			if cmd.Err() == nil {
+				cmd.setReply(exec)
+			}
+		}
+	default:
+		return unexpected(exec)
+	}
+	return nil
+}
@@ -0,0 +1,455 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Syed-Suhaan/SusyDB/pkg/resp"
+)
+
+const (
+	pubsubBuffer     = 100                    // Messages buffered for a slow reader
+	pubsubPing       = 30 * time.Second       // A quiet connection is pinged this often
+	pubsubMinBackoff = 100 * time.Millisecond // First wait before reconnecting
+	pubsubMaxBackoff = 5 * time.Second
+)
+
+// Message is a push received by a PubSub.
+type Message struct {
+	Kind    string // message, pmessage, smessage, rmessage or gap
+	Channel string
+	Pattern string // The matching pattern of a pmessage
+	Payload string
+	// The offset of an rmessage, or for a gap the first offset still
+	// retained: the messages before it were trimmed before they could be
+	// replayed.
+	Offset uint64
+}
+
+// errLost releases a subscription call whose connection dropped; the
+// reconnect subscribes again.
+var errLost = errors.New("susydb: connection lost")
+
+// PubSub is a subscribed connection. Messages arrive on Channel. If the
+// connection drops, PubSub reconnects with backoff and subscribes to
+// everything again; retained channels resume after the last message
+// received, so nothing is lost while they keep it.
+type PubSub struct {
+	client *Client
+	msgs   chan *Message
+	done   chan struct{}
+	once   sync.Once
+
+	calls sync.Mutex // Serializes subscription changes
+
+	mu       sync.Mutex
+	conn     net.Conn            // nil while reconnecting
+	channels map[string]struct{} // SUBSCRIBE
+	patterns map[string]struct{} // PSUBSCRIBE
+	shards   map[string]struct{} // SSUBSCRIBE
+	retained map[string]uint64   // RSUBSCRIBE channel -> next offset
+	skip     int                 // Acks due for the resubscription
+	acks     chan interface{}    // Acks for the call in progress
+}
+
+func (c *Client) newPubSub(ctx context.Context) (*PubSub, error) {
+	ps := &PubSub{
+		client:   c,
+		msgs:     make(chan *Message, pubsubBuffer),
+		done:     make(chan struct{}),
+		channels: make(map[string]struct{}),
+		patterns: make(map[string]struct{}),
+		shards:   make(map[string]struct{}),
+		retained: make(map[string]uint64),
+	}
+	conn, err := ps.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	go ps.run(conn)
+	return ps, nil
+}
+
+// Subscribe opens a PubSub subscribed to channels.
+func (c *Client) Subscribe(ctx context.Context, channels ...string) (*PubSub, error) {
+	return c.subscribe(ctx, func(ps *PubSub) error { return ps.Subscribe(ctx, channels...) })
+}
+
+// PSubscribe opens a PubSub subscribed to glob patterns.
+func (c *Client) PSubscribe(ctx context.Context, patterns ...string) (*PubSub, error) {
+	return c.subscribe(ctx, func(ps *PubSub) error { return ps.PSubscribe(ctx, patterns...) })
+}
+
+// SSubscribe opens a PubSub subscribed to shard channels.
+func (c *Client) SSubscribe(ctx context.Context, channels ...string) (*PubSub, error) {
+	return c.subscribe(ctx, func(ps *PubSub) error { return ps.SSubscribe(ctx, channels...) })
+}
+
+// RSubscribe opens a PubSub on a retained channel, replaying its messages
+// from offset (1 for the oldest retained) before live delivery.
+func (c *Client) RSubscribe(ctx context.Context, channel string, offset uint64) (*PubSub, error) {
+	return c.subscribe(ctx, func(ps *PubSub) error { return ps.RSubscribe(ctx, channel, offset) })
+}
+
+func (c *Client) subscribe(ctx context.Context, fn func(ps *PubSub) error) (*PubSub, error) {
+	ps, err := c.newPubSub(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := fn(ps); err != nil {
+		ps.Close()
+		return nil, err
+	}
+	return ps, nil
+}
+
+// Channel returns the channel messages arrive on. It is closed by Close.
+func (ps *PubSub) Channel() <-chan *Message { return ps.msgs }
+
+// Close unsubscribes by closing the connection and stops reconnecting.
+func (ps *PubSub) Close() error {
+	ps.once.Do(func() {
+		close(ps.done)
+		ps.mu.Lock()
+		if ps.conn != nil {
+			ps.conn.Close()
+		}
+		ps.mu.Unlock()
+	})
+	return nil
+}
+
+// Subscribe adds channels.
+func (ps *PubSub) Subscribe(ctx context.Context, channels ...string) error {
+	return ps.change(ctx, len(channels), func() []string {
+		add(ps.channels, channels)
+		return append([]string{"SUBSCRIBE"}, channels...)
+	})
+}
+
+// PSubscribe adds glob patterns.
+func (ps *PubSub) PSubscribe(ctx context.Context, patterns ...string) error {
+	return ps.change(ctx, len(patterns), func() []string {
+		add(ps.patterns, patterns)
+		return append([]string{"PSUBSCRIBE"}, patterns...)
+	})
+}
+
+// SSubscribe adds shard channels.
+func (ps *PubSub) SSubscribe(ctx context.Context, channels ...string) error {
+	return ps.change(ctx, len(channels), func() []string {
+		add(ps.shards, channels)
+		return append([]string{"SSUBSCRIBE"}, channels...)
+	})
+}
+
+// RSubscribe adds a retained channel, replaying from offset (1 for the
+// oldest retained message).
+func (ps *PubSub) RSubscribe(ctx context.Context, channel string, offset uint64) error {
+	err := ps.change(ctx, 1, func() []string {
+		ps.retained[channel] = offset
+		return []string{"RSUBSCRIBE", channel, "FROM", formatUint(offset)}
+	})
+	var e Error
+	if errors.As(err, &e) {
+		ps.mu.Lock()
+		delete(ps.retained, channel)
+		ps.mu.Unlock()
+	}
+	return err
+}
+
+// Unsubscribe drops channels, retained ones included, or all of them if
+// none are given.
+func (ps *PubSub) Unsubscribe(ctx context.Context, channels ...string) error {
+	return ps.remove(ctx, "UNSUBSCRIBE", ps.channels, channels)
+}
+
+// PUnsubscribe drops patterns, or all of them if none are given.
+func (ps *PubSub) PUnsubscribe(ctx context.Context, patterns ...string) error {
+	return ps.remove(ctx, "PUNSUBSCRIBE", ps.patterns, patterns)
+}
+
+// SUnsubscribe drops shard channels, or all of them if none are given.
+func (ps *PubSub) SUnsubscribe(ctx context.Context, channels ...string) error {
+	return ps.remove(ctx, "SUNSUBSCRIBE", ps.shards, channels)
+}
+
+func (ps *PubSub) remove(ctx context.Context, cmd string, set map[string]struct{}, names []string) error {
+	ps.mu.Lock()
+	if len(names) == 0 {
+		names = keys(set)
+		if cmd == "UNSUBSCRIBE" {
+			names = append(names, keysOf(ps.retained)...)
+		}
+	}
+	ps.mu.Unlock()
+	if len(names) == 0 {
+		return nil
+	}
+	return ps.change(ctx, len(names), func() []string {
+		for _, name := range names {
+			delete(set, name)
+			if cmd == "UNSUBSCRIBE" {
+				delete(ps.retained, name)
+			}
+		}
+		return append([]string{cmd}, names...)
+	})
+}
+
+// change records a subscription change with update, which returns the
+// command to send, then sends it and waits for its acks: one per name, or
+// an error reply. While disconnected the change is only recorded; the
+// reconnect applies it.
+func (ps *PubSub) change(ctx context.Context, acks int, update func() []string) error {
+	if acks == 0 {
+		return errors.New("susydb: nothing to subscribe to")
+	}
+	ps.calls.Lock()
+	defer ps.calls.Unlock()
+
+	ps.mu.Lock()
+	select {
+	case <-ps.done:
+		ps.mu.Unlock()
+		return ErrClosed
+	default:
+	}
+	cmd := update()
+	conn := ps.conn
+	if conn == nil {
+		ps.mu.Unlock()
+		return nil
+	}
+	ch := make(chan interface{}, acks)
+	ps.acks = ch
+	err := ps.write(ctx, conn, cmd)
+	ps.mu.Unlock()
+	defer func() {
+		ps.mu.Lock()
+		ps.acks = nil
+		ps.mu.Unlock()
+	}()
+	if err != nil {
+		return nil // The reader notices and reconnects
+	}
+
+	for i := 0; i < acks; i++ {
+		select {
+		case ack := <-ch:
+			if ack == errLost {
+				return nil
+			}
+			if e, ok := ack.(Error); ok {
+				return e
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// write sends cmd on conn. Must be called while holding mu.
+func (ps *PubSub) write(ctx context.Context, conn net.Conn, cmd []string) error {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(ps.client.opts.Timeout)
+	}
+	conn.SetWriteDeadline(deadline)
+	_, err := conn.Write(resp.AppendCommand(nil, cmd))
+	return err
+}
+
+// connect dials the server and subscribes to everything recorded.
+func (ps *PubSub) connect(ctx context.Context) (net.Conn, error) {
+	dialer := net.Dialer{Timeout: ps.client.opts.DialTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", ps.client.opts.Addr)
+	if err != nil {
+		return nil, err
+	}
+
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	var buf []byte
+	skip := 0
+	for _, set := range []struct {
+		cmd   string
+		names map[string]struct{}
+	}{{"SUBSCRIBE", ps.channels}, {"PSUBSCRIBE", ps.patterns}, {"SSUBSCRIBE", ps.shards}} {
+		if len(set.names) > 0 {
+			buf = resp.AppendCommand(buf, append([]string{set.cmd}, keys(set.names)...))
+			skip += len(set.names)
+		}
+	}
+	for channel, offset := range ps.retained {
+		buf = resp.AppendCommand(buf, []string{"RSUBSCRIBE", channel, "FROM", formatUint(offset)})
+		skip++
+	}
+	if len(buf) > 0 {
+		conn.SetWriteDeadline(time.Now().Add(ps.client.opts.Timeout))
+		if _, err := conn.Write(buf); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	select {
+	case <-ps.done:
+		conn.Close()
+		return nil, ErrClosed
+	default:
+	}
+	ps.conn, ps.skip = conn, skip
+	return conn, nil
+}
+
+// run reads from conn until Close, reconnecting whenever it fails.
+func (ps *PubSub) run(conn net.Conn) {
+	defer close(ps.msgs)
+	backoff := pubsubMinBackoff
+	for {
+		if conn != nil {
+			backoff = pubsubMinBackoff
+			ps.receive(conn)
+			ps.mu.Lock()
+			ps.conn = nil
+			if ps.acks != nil {
+				select {
+				case ps.acks <- errLost:
+				default:
+				}
+			}
+			ps.mu.Unlock()
+			conn.Close()
+		}
+		select {
+		case <-ps.done:
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, pubsubMaxBackoff)
+
+		ctx, cancel := context.WithTimeout(context.Background(), ps.client.opts.DialTimeout)
+		conn, _ = ps.connect(ctx)
+		cancel()
+	}
+}
+
+// receive delivers messages and acks from conn until it fails. A PING
+// every pubsubPing keeps it alive and detects a dead server.
+func (ps *PubSub) receive(conn net.Conn) {
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		ticker := time.NewTicker(pubsubPing)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				ps.mu.Lock()
+				conn.SetWriteDeadline(time.Now().Add(ps.client.opts.Timeout))
+				conn.Write(resp.AppendCommand(nil, []string{"PING"}))
+				ps.mu.Unlock()
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	reader := bufio.NewReader(conn)
+	for {
+		conn.SetReadDeadline(time.Now().Add(pubsubPing + ps.client.opts.Timeout))
+		v, err := resp.ReadReply(reader)
+		if err != nil {
+			return
+		}
+		if msg := ps.message(v); msg != nil {
+			select {
+			case ps.msgs <- msg:
+			case <-ps.done:
+				return
+			}
+		}
+	}
+}
+
+// message returns the Message for a push, or handles an ack or pong and
+// returns nil.
+func (ps *PubSub) message(v interface{}) *Message {
+	items, _ := v.([]interface{})
+	fields := make([]string, len(items))
+	for i, item := range items {
+		fields[i], _ = parseString(item)
+	}
+	kind := ""
+	if len(fields) > 0 {
+		kind = fields[0]
+	}
+	switch {
+	case kind == "message" && len(fields) == 3, kind == "smessage" && len(fields) == 3:
+		return &Message{Kind: kind, Channel: fields[1], Payload: fields[2]}
+	case kind == "pmessage" && len(fields) == 4:
+		return &Message{Kind: kind, Pattern: fields[1], Channel: fields[2], Payload: fields[3]}
+	case kind == "rmessage" && len(fields) == 4:
+		offset, _ := strconv.ParseUint(fields[2], 10, 64)
+		ps.mu.Lock()
+		if _, ok := ps.retained[fields[1]]; ok {
+			ps.retained[fields[1]] = offset + 1
+		}
+		ps.mu.Unlock()
+		return &Message{Kind: kind, Channel: fields[1], Offset: offset, Payload: fields[3]}
+	case kind == "gap" && len(fields) == 3:
+		offset, _ := strconv.ParseUint(fields[2], 10, 64)
+		return &Message{Kind: kind, Channel: fields[1], Offset: offset}
+	case kind == "pong", v == "PONG":
+		return nil
+	}
+
+	// A subscription ack or an error reply
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	if ps.skip > 0 {
+		// Resubscribing after a reconnect; an error, such as a channel no
+		// longer retained, has no caller to go to
+		ps.skip--
+		return nil
+	}
+	if ps.acks != nil {
+		select {
+		case ps.acks <- v:
+		default:
+		}
+	}
+	return nil
+}
+
+func add(set map[string]struct{}, names []string) {
+	for _, name := range names {
+		set[name] = struct{}{}
+	}
+}
+
+func keys(set map[string]struct{}) []string {
+	out := make([]string, 0, len(set))
+	for name := range set {
+		out = append(out, name)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func keysOf(m map[string]uint64) []string {
+	out := make([]string, 0, len(m))
+	for name := range m {
+		out = append(out, name)
+	}
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,172 @@
+package client
+
+import (
+	"context"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// proxy forwards connections to a server until drop cuts them all. While
+// down it closes new connections at once.
+type proxy struct {
+	addr   string
+	target string
+	mu     sync.Mutex
+	conns  []net.Conn
+	down   bool
+}
+
+func startProxy(t *testing.T, target string) *proxy {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	t.Cleanup(func() { l.Close() })
+	p := &proxy{addr: l.Addr().String(), target: target}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			p.mu.Lock()
+			down := p.down
+			p.mu.Unlock()
+			if down {
+				conn.Close()
+				continue
+			}
+			upstream, err := net.Dial("tcp", target)
+			if err != nil {
+				conn.Close()
+				continue
+			}
+			p.mu.Lock()
+			p.conns = append(p.conns, conn, upstream)
+			p.mu.Unlock()
+			go io.Copy(upstream, conn)
+			go io.Copy(conn, upstream)
+		}
+	}()
+	return p
+}
+
+// setDown stops or resumes forwarding.
+func (p *proxy) setDown(down bool) {
+	p.mu.Lock()
+	p.down = down
+	p.mu.Unlock()
+	if down {
+		p.drop()
+	}
+}
+
+func (p *proxy) drop() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, conn := range p.conns {
+		conn.Close()
+	}
+	p.conns = nil
+}
+
+func receive(t *testing.T, ps *PubSub) *Message {
+	t.Helper()
+	select {
+	case msg := <-ps.Channel():
+		return msg
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for a message")
+		return nil
+	}
+}
+
+func TestPubSub(t *testing.T) {
+	ctx := context.Background()
+	c := newClient(t, startServer(t))
+
+	ps, err := c.Subscribe(ctx, "news")
+	if err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+	defer ps.Close()
+	if err := ps.PSubscribe(ctx, "sport.*"); err != nil {
+		t.Fatalf("PSubscribe() error = %v", err)
+	}
+
+	c.Publish(ctx, "news", "hello")
+	if msg := receive(t, ps); msg.Kind != "message" || msg.Channel != "news" || msg.Payload != "hello" {
+		t.Errorf("message = %+v", msg)
+	}
+	c.Publish(ctx, "sport.tennis", "ace")
+	if msg := receive(t, ps); msg.Kind != "pmessage" || msg.Pattern != "sport.*" || msg.Channel != "sport.tennis" {
+		t.Errorf("pmessage = %+v", msg)
+	}
+
+	if err := ps.Unsubscribe(ctx); err != nil {
+		t.Fatalf("Unsubscribe() error = %v", err)
+	}
+	if n, _ := c.Publish(ctx, "news", "unheard").Result(); n != 0 {
+		t.Errorf("Publish() after Unsubscribe reached %d subscribers, want 0", n)
+	}
+
+	ps.Close()
+	select {
+	case _, ok := <-ps.Channel():
+		if ok {
+			t.Error("Channel() delivered after Close")
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("Channel() not closed by Close")
+	}
+}
+
+func TestPubSubReconnect(t *testing.T) {
+	ctx := context.Background()
+	addr := startServer(t)
+	c := newClient(t, addr)
+	p := startProxy(t, addr)
+	sub := newClient(t, p.addr)
+
+	c.Retain(ctx, "orders", 100, 0)
+	ps, err := sub.Subscribe(ctx, "news")
+	if err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+	defer ps.Close()
+	if err := ps.RSubscribe(ctx, "orders", 1); err != nil {
+		t.Fatalf("RSubscribe() error = %v", err)
+	}
+
+	c.Publish(ctx, "orders", "first")
+	if msg := receive(t, ps); msg.Kind != "rmessage" || msg.Offset != 1 || msg.Payload != "first" {
+		t.Errorf("rmessage = %+v", msg)
+	}
+
+	// Messages published while disconnected are replayed from the retained
+	// channel; the plain channel is subscribed again
+	p.drop()
+	c.Publish(ctx, "orders", "second")
+	if msg := receive(t, ps); msg.Offset != 2 || msg.Payload != "second" {
+		t.Errorf("replayed rmessage = %+v", msg)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		n, _ := c.PubSubNumSub(ctx, "news").Result()
+		if n["news"] == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the resubscription")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	c.Publish(ctx, "news", "back")
+	if msg := receive(t, ps); msg.Channel != "news" || msg.Payload != "back" {
+		t.Errorf("message after reconnect = %+v", msg)
+	}
+}
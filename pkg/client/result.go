@@ -0,0 +1,201 @@
+package client
+
+import (
+	"context"
+	"strconv"
+)
+
+// command is a Result of any type, as pipelines hold them.
+type command interface {
+	Args() []string
+	Err() error
+	setReply(v interface{})
+	setErr(err error)
+}
+
+// commands issues typed commands: a Client runs each at once, a Pipeline
+// queues them until Exec.
+type commands func(ctx context.Context, cmd command)
+
+// Result is a command and, once it ran, its reply converted to T.
+type Result[T any] struct {
+	args  []string
+	parse func(v interface{}) (T, error)
+	val   T
+	err   error
+}
+
+func newResult[T any](parse func(v interface{}) (T, error), args ...string) *Result[T] {
+	return &Result[T]{args: args, parse: parse}
+}
+
+// Args returns the command as sent.
+func (r *Result[T]) Args() []string { return r.args }
+
+// Val returns the reply, or the zero value if the command failed.
+func (r *Result[T]) Val() T { return r.val }
+
+// Err returns the command's error: an Error reply, ErrNil for a null
+// reply, or the network or context error that stopped it.
+func (r *Result[T]) Err() error { return r.err }
+
+// Result returns the reply and the error.
+func (r *Result[T]) Result() (T, error) { return r.val, r.err }
+
+func (r *Result[T]) setReply(v interface{}) {
+	if e, ok := v.(Error); ok {
+		r.err = e
+		return
+	}
+	r.val, r.err = r.parse(v)
+}
+
+func (r *Result[T]) setErr(err error) { r.err = err }
+
+// Do runs any command and returns its raw reply: string, int64, nil or
+// []interface{}, with Error values inside arrays.
+func (c commands) Do(ctx context.Context, args ...string) *Result[interface{}] {
+	r := newResult(parseAny, args...)
+	c(ctx, r)
+	return r
+}
+
+func parseAny(v interface{}) (interface{}, error) { return v, nil }
+
+// parseString accepts simple and bulk strings.
+func parseString(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case nil:
+		return "", ErrNil
+	}
+	return "", unexpected(v)
+}
+
+func parseInt(v interface{}) (int64, error) {
+	switch v := v.(type) {
+	case int64:
+		return v, nil
+	case string:
+		return strconv.ParseInt(v, 10, 64)
+	case nil:
+		return 0, ErrNil
+	}
+	return 0, unexpected(v)
+}
+
+func parseBool(v interface{}) (bool, error) {
+	n, err := parseInt(v)
+	return n != 0, err
+}
+
+func parseFloat(v interface{}) (float64, error) {
+	switch v := v.(type) {
+	case string:
+		return strconv.ParseFloat(v, 64)
+	case int64:
+		return float64(v), nil
+	case nil:
+		return 0, ErrNil
+	}
+	return 0, unexpected(v)
+}
+
+// parseArray returns the elements of an array reply, failing on the first
+// error element.
+func parseArray(v interface{}) ([]interface{}, error) {
+	items, ok := v.([]interface{})
+	if !ok {
+		return nil, unexpected(v)
+	}
+	for _, item := range items {
+		if e, ok := item.(Error); ok {
+			return nil, e
+		}
+	}
+	return items, nil
+}
+
+// parseStrings reads an array of strings; null elements become "".
+func parseStrings(v interface{}) ([]string, error) {
+	items, err := parseArray(v)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]string, len(items))
+	for i, item := range items {
+		if item == nil {
+			continue
+		}
+		if out[i], err = parseString(item); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+func parseInts(v interface{}) ([]int64, error) {
+	items, err := parseArray(v)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]int64, len(items))
+	for i, item := range items {
+		if out[i], err = parseInt(item); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+func parseBools(v interface{}) ([]bool, error) {
+	items, err := parseArray(v)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]bool, len(items))
+	for i, item := range items {
+		if out[i], err = parseBool(item); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// parseStringMap reads a flat array of field, value pairs.
+func parseStringMap(v interface{}) (map[string]string, error) {
+	items, err := parseStrings(v)
+	if err != nil {
+		return nil, err
+	}
+	if len(items)%2 != 0 {
+		return nil, unexpected(v)
+	}
+	out := make(map[string]string, len(items)/2)
+	for i := 0; i < len(items); i += 2 {
+		out[items[i]] = items[i+1]
+	}
+	return out, nil
+}
+
+// parseIntMap reads a flat array of name, integer pairs.
+func parseIntMap(v interface{}) (map[string]int64, error) {
+	items, err := parseArray(v)
+	if err != nil || len(items)%2 != 0 {
+		return nil, unexpected(v)
+	}
+	out := make(map[string]int64, len(items)/2)
+	for i := 0; i < len(items); i += 2 {
+		name, err := parseString(items[i])
+		if err != nil {
+			return nil, err
+		}
+		if out[name], err = parseInt(items[i+1]); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
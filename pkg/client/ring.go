@@ -0,0 +1,423 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultVirtualNodes        = 160
+	defaultHealthCheckInterval = time.Second
+	defaultEjectAfter          = 3
+)
+
+// ErrNoNodes is returned when every node of a Ring is down.
+var ErrNoNodes = errors.New("susydb: no live nodes in the ring")
+
+// RingOptions configures a Ring. Zero values select the defaults.
+type RingOptions struct {
+	Addrs               []string      // host:port of each node
+	VirtualNodes        int           // Points per node on the hash ring (160)
+	HealthCheckInterval time.Duration // How often each node is pinged (1s)
+	EjectAfter          int           // Failed pings in a row that take a node off the ring (3)
+	NodeOptions         Options       // Settings of each node's Client; Addr is ignored
+}
+
+func (o *RingOptions) setDefaults() {
+	if o.VirtualNodes <= 0 {
+		o.VirtualNodes = defaultVirtualNodes
+	}
+	if o.HealthCheckInterval <= 0 {
+		o.HealthCheckInterval = defaultHealthCheckInterval
+	}
+	if o.EjectAfter <= 0 {
+		o.EjectAfter = defaultEjectAfter
+	}
+}
+
+// Ring spreads keys over independent servers by consistent hashing. Each
+// node owns VirtualNodes points on a hash ring, and a key belongs to the
+// node owning the first point at or after the key's hash. Only the part
+// of a key between the first { and the following } is hashed when it is
+// not empty, so keys sharing a {hashtag} live on the same node.
+//
+// A node that fails EjectAfter health checks in a row leaves the ring, and
+// its keys move to the remaining nodes until it answers again; nodes don't
+// copy keys between them, so those keys read as missing meanwhile.
+//
+// Commands go to the node of their first key. MGet and MSet split their
+// keys by node and merge the replies; other commands on several keys only
+// work when the keys share a hashtag. Publish goes to the node of its
+// channel, where Node(channel) subscribes. Commands without a key go to a
+// random live node.
+type Ring struct {
+	commands
+	opts  RingOptions
+	nodes []*ringNode
+
+	mu     sync.RWMutex
+	points []ringPoint // Sorted by hash; live nodes only
+
+	done chan struct{}
+	once sync.Once
+}
+
+type ringNode struct {
+	client  *Client
+	failing int // Failed health checks in a row
+	up      bool
+}
+
+type ringPoint struct {
+	hash uint64
+	node *ringNode
+}
+
+// NewRing returns a Ring over opts.Addrs and starts its health checks.
+// Every node starts on the ring.
+func NewRing(opts RingOptions) *Ring {
+	opts.setDefaults()
+	r := &Ring{opts: opts, done: make(chan struct{})}
+	for _, addr := range opts.Addrs {
+		nodeOpts := opts.NodeOptions
+		nodeOpts.Addr = addr
+		r.nodes = append(r.nodes, &ringNode{client: New(nodeOpts), up: true})
+	}
+	r.commands = r.process
+	r.rebuild()
+	go r.healthCheck()
+	return r
+}
+
+// Close stops the health checks and closes every node's Client.
+func (r *Ring) Close() error {
+	r.once.Do(func() {
+		close(r.done)
+		for _, n := range r.nodes {
+			n.client.Close()
+		}
+	})
+	return nil
+}
+
+// Node returns the Client of the node that key, or a pub/sub channel,
+// belongs to.
+func (r *Ring) Node(key string) (*Client, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	n := r.lookup(key)
+	if n == nil {
+		return nil, ErrNoNodes
+	}
+	return n.client, nil
+}
+
+// LiveNodes returns the addresses of the nodes on the ring.
+func (r *Ring) LiveNodes() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var addrs []string
+	for _, n := range r.nodes {
+		if n.up {
+			addrs = append(addrs, n.client.Addr())
+		}
+	}
+	return addrs
+}
+
+// ForEachNode calls fn concurrently with the Client of every live node and
+// returns the first error.
+func (r *Ring) ForEachNode(ctx context.Context, fn func(ctx context.Context, c *Client) error) error {
+	r.mu.RLock()
+	var clients []*Client
+	for _, n := range r.nodes {
+		if n.up {
+			clients = append(clients, n.client)
+		}
+	}
+	r.mu.RUnlock()
+
+	errs := make([]error, len(clients))
+	var wg sync.WaitGroup
+	for i, c := range clients {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = fn(ctx, c)
+		}()
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Pipeline returns an empty pipeline whose commands are sent to their
+// nodes together, one round trip per node, the nodes in parallel.
+func (r *Ring) Pipeline() *Pipeline {
+	p := &Pipeline{exec: func(ctx context.Context, cmds []command, _ bool) { r.run(ctx, cmds) }}
+	p.commands = p.queue
+	return p
+}
+
+// Pipelined runs fn on a new pipeline and executes it.
+func (r *Ring) Pipelined(ctx context.Context, fn func(p *Pipeline) error) error {
+	p := r.Pipeline()
+	if err := fn(p); err != nil {
+		return err
+	}
+	return p.Exec(ctx)
+}
+
+// process runs one command.
+func (r *Ring) process(ctx context.Context, cmd command) {
+	r.run(ctx, []command{cmd})
+}
+
+// run sends each command to its node, MGET and MSET split into one command
+// per node, and merges the split replies once every node has answered.
+func (r *Ring) run(ctx context.Context, cmds []command) {
+	batches := make(map[*Client][]command)
+	var fans []*fanOut
+	r.mu.RLock()
+	for _, cmd := range cmds {
+		args := cmd.Args()
+		switch strings.ToUpper(args[0]) {
+		case "MGET", "MSET":
+			f, err := r.split(cmd)
+			if err != nil {
+				cmd.setErr(err)
+				continue
+			}
+			for c, part := range f.parts {
+				batches[c] = append(batches[c], part)
+			}
+			fans = append(fans, f)
+		default:
+			n := r.route(args)
+			if n == nil {
+				cmd.setErr(ErrNoNodes)
+				continue
+			}
+			batches[n.client] = append(batches[n.client], cmd)
+		}
+	}
+	r.mu.RUnlock()
+
+	var wg sync.WaitGroup
+	for c, batch := range batches {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.roundTrip(ctx, batch, false)
+		}()
+	}
+	wg.Wait()
+	for _, f := range fans {
+		f.merge()
+	}
+}
+
+// fanOut is an MGET or MSET split across nodes.
+type fanOut struct {
+	cmd   command
+	parts map[*Client]*Result[interface{}]
+	index map[*Client][]int // For MGET, the position of each part's keys
+}
+
+// split groups the keys of an MGET, or the pairs of an MSET, by node.
+// Must be called while holding mu for reading.
+func (r *Ring) split(cmd command) (*fanOut, error) {
+	args := cmd.Args()
+	name := strings.ToUpper(args[0])
+	step := 1
+	if name == "MSET" {
+		step = 2
+	}
+	if len(args) < 2 || (len(args)-1)%step != 0 {
+		return nil, Error("ERR wrong number of arguments for '" + strings.ToLower(name) + "' command")
+	}
+	f := &fanOut{cmd: cmd, parts: make(map[*Client]*Result[interface{}]), index: make(map[*Client][]int)}
+	for i := 1; i < len(args); i += step {
+		n := r.lookup(args[i])
+		if n == nil {
+			return nil, ErrNoNodes
+		}
+		part := f.parts[n.client]
+		if part == nil {
+			part = newResult(parseAny, name)
+			f.parts[n.client] = part
+		}
+		part.args = append(part.args, args[i:i+step]...)
+		f.index[n.client] = append(f.index[n.client], (i-1)/step)
+	}
+	return f, nil
+}
+
+// merge sets the reply of the split command: MGET's values in the order of
+// its keys, or MSET's OK. Any part's error fails the whole command.
+func (f *fanOut) merge() {
+	var values []interface{}
+	if strings.EqualFold(f.cmd.Args()[0], "MGET") {
+		values = make([]interface{}, len(f.cmd.Args())-1)
+	}
+	for c, part := range f.parts {
+		if part.err != nil {
+			f.cmd.setErr(part.err)
+			return
+		}
+		if values == nil {
+			continue
+		}
+		got, ok := part.val.([]interface{})
+		if !ok || len(got) != len(f.index[c]) {
+			f.cmd.setErr(unexpected(part.val))
+			return
+		}
+		for i, pos := range f.index[c] {
+			values[pos] = got[i]
+		}
+	}
+	if values != nil {
+		f.cmd.setReply(values)
+	} else {
+		f.cmd.setReply("OK")
+	}
+}
+
+// route returns the node for a command: that of its first key, or a random
+// live node if it has none. Must be called while holding mu for reading.
+func (r *Ring) route(args []string) *ringNode {
+	if key, ok := ringKey(args); ok {
+		return r.lookup(key)
+	}
+	if len(r.points) == 0 {
+		return nil
+	}
+	return r.points[rand.Intn(len(r.points))].node
+}
+
+// ringKey returns the argument a command is routed by.
+func ringKey(args []string) (string, bool) {
+	pos := 1
+	switch strings.ToUpper(args[0]) {
+	case "PING", "INFO", "CONFIG", "PUBSUB", "RETAIN", "REPLICAOF", "SLAVEOF", "CLUSTER", "RAFT",
+		"FT.CREATE", "FT.SEARCH", "FT.DROPINDEX":
+		return "", false
+	case "BITOP":
+		pos = 2
+	case "MIGRATE":
+		pos = 3
+	}
+	if len(args) <= pos {
+		return "", false
+	}
+	return args[pos], true
+}
+
+// lookup returns the node owning key, or nil if the ring is empty. Must be
+// called while holding mu for reading.
+func (r *Ring) lookup(key string) *ringNode {
+	if len(r.points) == 0 {
+		return nil
+	}
+	h := hashKey(hashTag(key))
+	i := sort.Search(len(r.points), func(i int) bool { return r.points[i].hash >= h })
+	if i == len(r.points) {
+		i = 0
+	}
+	return r.points[i].node
+}
+
+// hashTag returns the part of key that is hashed.
+func hashTag(key string) string {
+	if start := strings.IndexByte(key, '{'); start >= 0 {
+		if end := strings.IndexByte(key[start+1:], '}'); end > 0 {
+			return key[start+1 : start+1+end]
+		}
+	}
+	return key
+}
+
+// hashKey hashes with FNV-1a, then mixes the bits (the finalizer of
+// SplitMix64): FNV alone leaves keys that differ in their last bytes
+// close together on the ring.
+func hashKey(s string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	x := h.Sum64()
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+	return x ^ (x >> 31)
+}
+
+// rebuild places the live nodes' points on the ring.
+func (r *Ring) rebuild() {
+	var points []ringPoint
+	for _, n := range r.nodes {
+		if !n.up {
+			continue
+		}
+		for i := 0; i < r.opts.VirtualNodes; i++ {
+			points = append(points, ringPoint{hashKey(n.client.Addr() + "-" + strconv.Itoa(i)), n})
+		}
+	}
+	sort.Slice(points, func(i, j int) bool { return points[i].hash < points[j].hash })
+	r.mu.Lock()
+	r.points = points
+	r.mu.Unlock()
+}
+
+// healthCheck pings every node each HealthCheckInterval. A node leaves the
+// ring after EjectAfter failures in a row and rejoins on its first success.
+func (r *Ring) healthCheck() {
+	ticker := time.NewTicker(r.opts.HealthCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.done:
+			return
+		case <-ticker.C:
+		}
+
+		ok := make([]bool, len(r.nodes))
+		var wg sync.WaitGroup
+		for i, n := range r.nodes {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				ctx, cancel := context.WithTimeout(context.Background(), n.client.opts.Timeout)
+				defer cancel()
+				ok[i] = n.client.Ping(ctx).Err() == nil
+			}()
+		}
+		wg.Wait()
+
+		changed := false
+		r.mu.Lock()
+		for i, n := range r.nodes {
+			if ok[i] {
+				n.failing = 0
+				changed = changed || !n.up
+				n.up = true
+			} else if n.failing++; n.failing >= r.opts.EjectAfter && n.up {
+				n.up = false
+				changed = true
+			}
+		}
+		r.mu.Unlock()
+		if changed {
+			r.rebuild()
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// startRing starts three servers behind proxies and a Ring over the
+// proxies.
+func startRing(t *testing.T) (*Ring, []*proxy) {
+	t.Helper()
+	var proxies []*proxy
+	var addrs []string
+	for i := 0; i < 3; i++ {
+		p := startProxy(t, startServer(t))
+		proxies = append(proxies, p)
+		addrs = append(addrs, p.addr)
+	}
+	r := NewRing(RingOptions{
+		Addrs:               addrs,
+		HealthCheckInterval: 20 * time.Millisecond,
+		EjectAfter:          2,
+		NodeOptions:         Options{Timeout: time.Second},
+	})
+	t.Cleanup(func() { r.Close() })
+	return r, proxies
+}
+
+func TestRingDistribution(t *testing.T) {
+	ctx := context.Background()
+	r, _ := startRing(t)
+
+	perNode := make(map[string]int)
+	for i := 0; i < 300; i++ {
+		key := fmt.Sprintf("key:%d", i)
+		if err := r.Set(ctx, key, "v", 0).Err(); err != nil {
+			t.Fatalf("Set(%s) error = %v", key, err)
+		}
+		node, _ := r.Node(key)
+		perNode[node.Addr()]++
+		if _, err := node.Get(ctx, key).Result(); err != nil {
+			t.Fatalf("%s not on its node: %v", key, err)
+		}
+	}
+	for _, addr := range r.LiveNodes() {
+		if perNode[addr] < 50 {
+			t.Errorf("node %s got %d of 300 keys: %v", addr, perNode[addr], perNode)
+		}
+	}
+
+	// Keys sharing a hashtag share a node
+	first, _ := r.Node("{user:1}:profile")
+	for _, key := range []string{"{user:1}:cart", "{user:1}:visits", "user:1"} {
+		if node, _ := r.Node(key); node != first {
+			t.Errorf("Node(%s) = %s, want %s", key, node.Addr(), first.Addr())
+		}
+	}
+}
+
+func TestRingMGetMSet(t *testing.T) {
+	ctx := context.Background()
+	r, _ := startRing(t)
+
+	var pairs, keys []string
+	var want []interface{}
+	for i := 0; i < 20; i++ {
+		key, val := fmt.Sprintf("k%d", i), fmt.Sprintf("v%d", i)
+		pairs = append(pairs, key, val)
+		keys = append(keys, key)
+		want = append(want, val)
+	}
+	if err := r.MSet(ctx, pairs...).Err(); err != nil {
+		t.Fatalf("MSet() error = %v", err)
+	}
+	keys = append(keys, "missing")
+	want = append(want, nil)
+	got, err := r.MGet(ctx, keys...).Result()
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("MGet() = %v, %v; want %v", got, err, want)
+	}
+
+	// Split commands and plain ones mix in a pipeline
+	var mget *Result[[]interface{}]
+	err = r.Pipelined(ctx, func(p *Pipeline) error {
+		p.Incr(ctx, "k0-counter")
+		mget = p.MGet(ctx, "k3", "k7", "k11")
+		return nil
+	})
+	if err != nil || !reflect.DeepEqual(mget.Val(), []interface{}{"v3", "v7", "v11"}) {
+		t.Errorf("pipelined MGet() = %v, %v", mget.Val(), err)
+	}
+
+	if err := r.MSet(ctx, "odd").Err(); err == nil {
+		t.Error("MSet(odd) error = nil, want an error")
+	}
+}
+
+func TestRingEjection(t *testing.T) {
+	ctx := context.Background()
+	r, proxies := startRing(t)
+	waitForNodes := func(n int) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for len(r.LiveNodes()) != n {
+			if time.Now().After(deadline) {
+				t.Fatalf("LiveNodes() = %v, want %d nodes", r.LiveNodes(), n)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	proxies[0].setDown(true)
+	waitForNodes(2)
+	for i := 0; i < 50; i++ {
+		key := fmt.Sprintf("key:%d", i)
+		if node, _ := r.Node(key); node.Addr() == proxies[0].addr {
+			t.Fatalf("Node(%s) is the ejected node", key)
+		}
+		if err := r.Set(ctx, key, "v", 0).Err(); err != nil {
+			t.Errorf("Set(%s) with a node down error = %v", key, err)
+		}
+	}
+
+	proxies[0].setDown(false)
+	waitForNodes(3)
+
+	for _, p := range proxies {
+		p.setDown(true)
+	}
+	waitForNodes(0)
+	if err := r.Get(ctx, "key:1").Err(); err != ErrNoNodes {
+		t.Errorf("Get() with every node down error = %v, want ErrNoNodes", err)
+	}
+}
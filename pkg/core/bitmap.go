@@ -0,0 +1,627 @@
+package core
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+)
+
+// MaxBitOffset is the highest bit offset accepted by bit operations,
+// which caps bitmaps at 512MB like Redis.
+const MaxBitOffset = 1<<32 - 1
+
+var (
+	// ErrBitOffset is returned for bit offsets outside [0, MaxBitOffset].
+	ErrBitOffset = fmt.Errorf("ERR bit offset is not an integer or out of range")
+	// ErrBitValue is returned when a bit value other than 0 or 1 is given.
+	ErrBitValue = fmt.Errorf("ERR bit is not an integer or out of range")
+	// ErrBitFieldType is returned for unsupported BITFIELD integer types.
+	ErrBitFieldType = fmt.Errorf("ERR Invalid bitfield type. Use something like i16 u8. Note that u64 is not supported but i64 is.")
+)
+
+// BitOperation selects the operator used by BitOp.
+type BitOperation int
+
+const (
+	BitAnd BitOperation = iota
+	BitOr
+	BitXor
+	BitNot
+)
+
+// BitRange restricts BitCount and BitPos to part of a string.
+// Start and End are inclusive and may be negative to count from the end.
+type BitRange struct {
+	Start   int64
+	End     int64
+	BitMode bool // Start/End are bit offsets (BIT) instead of byte offsets (BYTE)
+	NoEnd   bool // End was omitted; BitPos then treats the string as zero-padded
+}
+
+// BitFieldOverflow controls how BITFIELD SET/INCRBY handle overflows.
+type BitFieldOverflow int
+
+const (
+	OverflowWrap BitFieldOverflow = iota
+	OverflowSat
+	OverflowFail
+)
+
+// BitFieldOpKind identifies a BITFIELD sub-command.
+type BitFieldOpKind int
+
+const (
+	BitFieldGet BitFieldOpKind = iota
+	BitFieldSet
+	BitFieldIncrBy
+)
+
+// BitFieldOp is a single BITFIELD sub-command.
+type BitFieldOp struct {
+	Kind     BitFieldOpKind
+	Signed   bool
+	Width    int    // 1-64 for signed fields, 1-63 for unsigned fields
+	Offset   uint64 // Bit offset of the most significant bit of the field
+	Value    int64  // Value for SET, increment for INCRBY
+	Overflow BitFieldOverflow
+}
+
+// BitFieldResult is the reply to a single BITFIELD sub-command.
+type BitFieldResult struct {
+	Value int64
+	Nil   bool // Set when an OVERFLOW FAIL operation was skipped
+}
+
+// byteString lets read-only bit helpers work on both string representations
+// without copying.
+type byteString interface {
+	~string | ~[]byte
+}
+
+// SetBit sets or clears the bit at offset and returns its previous value.
+// The string grows with zero bytes as needed.
+func (s *KVStore) SetBit(key string, offset uint64, value int) (int, error) {
+	if offset > MaxBitOffset {
+		return 0, ErrBitOffset
+	}
+	if value != 0 && value != 1 {
+		return 0, ErrBitValue
+	}
+
+	shard := s.getShard(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	buf, err := s.bitmapForWrite(shard, key, int(offset>>3)+1)
+	if err != nil {
+		return 0, err
+	}
+
+	byteIdx := offset >> 3
+	mask := byte(1) << (7 - offset&7)
+	old := 0
+	if buf[byteIdx]&mask != 0 {
+		old = 1
+	}
+	if value == 1 {
+		buf[byteIdx] |= mask
+	} else {
+		buf[byteIdx] &^= mask
+	}
+	s.notify(notifyString, "setbit", key)
+	return old, nil
+}
+
+// GetBit returns the bit at offset. Bits beyond the end of the string are 0.
+func (s *KVStore) GetBit(key string, offset uint64) (int, error) {
+	if offset > MaxBitOffset {
+		return 0, ErrBitOffset
+	}
+
+	shard := s.getShard(key)
+	shard.mu.RLock()
+	defer shard.mu.RUnlock()
+
+	entry, exists := shard.data[key]
+	if !exists || entry.isExpired() {
+		return 0, nil
+	}
+	switch v := entry.Value.(type) {
+	case string:
+		return bitAt(v, offset), nil
+	case []byte:
+		return bitAt(v, offset), nil
+	}
+	return 0, ErrWrongType
+}
+
+// BitCount counts the set bits in the string at key.
+// A nil range counts the whole string.
+func (s *KVStore) BitCount(key string, r *BitRange) (int64, error) {
+	shard := s.getShard(key)
+	shard.mu.RLock()
+	defer shard.mu.RUnlock()
+
+	entry, exists := shard.data[key]
+	if !exists || entry.isExpired() {
+		return 0, nil
+	}
+	switch v := entry.Value.(type) {
+	case string:
+		return countBits(v, r), nil
+	case []byte:
+		return countBits(v, r), nil
+	}
+	return 0, ErrWrongType
+}
+
+// BitPos returns the position of the first bit set to bit (0 or 1),
+// or -1 if there is none. A nil range searches the whole string.
+func (s *KVStore) BitPos(key string, bit int, r *BitRange) (int64, error) {
+	if bit != 0 && bit != 1 {
+		return 0, fmt.Errorf("ERR The bit argument must be 1 or 0.")
+	}
+
+	shard := s.getShard(key)
+	shard.mu.RLock()
+	defer shard.mu.RUnlock()
+
+	entry, exists := shard.data[key]
+	if !exists || entry.isExpired() {
+		if bit == 1 {
+			return -1, nil
+		}
+		return 0, nil
+	}
+	switch v := entry.Value.(type) {
+	case string:
+		return findBit(v, bit, r), nil
+	case []byte:
+		return findBit(v, bit, r), nil
+	}
+	return 0, ErrWrongType
+}
+
+// BitOp performs a bitwise operation between the source strings and stores
+// the result in destKey, returning the length of the result in bytes.
+// Missing keys are treated as empty strings; an empty result deletes destKey.
+// Sources are read one shard at a time, so the operation is not atomic
+// with respect to concurrent writers of the source keys.
+func (s *KVStore) BitOp(op BitOperation, destKey string, srcKeys ...string) (int64, error) {
+	if len(srcKeys) == 0 {
+		return 0, fmt.Errorf("ERR wrong number of arguments for 'bitop' command")
+	}
+	if op == BitNot && len(srcKeys) != 1 {
+		return 0, fmt.Errorf("ERR BITOP NOT must be called with a single source key.")
+	}
+
+	srcs := make([][]byte, len(srcKeys))
+	maxLen := 0
+	for i, key := range srcKeys {
+		src, err := s.copyStringBytes(key)
+		if err != nil {
+			return 0, err
+		}
+		srcs[i] = src
+		if len(src) > maxLen {
+			maxLen = len(src)
+		}
+	}
+
+	var result []byte
+	if maxLen > 0 {
+		result = make([]byte, maxLen)
+		switch op {
+		case BitNot:
+			for i, b := range srcs[0] {
+				result[i] = ^b
+			}
+		case BitAnd:
+			copy(result, srcs[0])
+			for _, src := range srcs[1:] {
+				for i := range result {
+					if i < len(src) {
+						result[i] &= src[i]
+					} else {
+						result[i] = 0
+					}
+				}
+			}
+		case BitOr, BitXor:
+			for _, src := range srcs {
+				for i, b := range src {
+					if op == BitOr {
+						result[i] |= b
+					} else {
+						result[i] ^= b
+					}
+				}
+			}
+		default:
+			return 0, fmt.Errorf("ERR syntax error")
+		}
+	}
+
+	shard := s.getShard(destKey)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	_, exists := s.liveEntry(shard, destKey)
+	if result == nil {
+		if exists {
+			s.removeEntry(shard, destKey)
+			s.notify(notifyGeneric, "del", destKey)
+		}
+		return 0, nil
+	}
+	if exists {
+		// Replace whatever was there, dropping an indexed hash from its
+		// indexes and the old TTL with it
+		s.removeEntry(shard, destKey)
+	}
+	if err := s.createEntry(shard, destKey, result); err != nil {
+		return 0, err
+	}
+	s.notify(notifyString, "set", destKey)
+	return int64(maxLen), nil
+}
+
+// BitField runs a sequence of GET/SET/INCRBY operations on integer fields
+// of arbitrary width packed into the string at key.
+func (s *KVStore) BitField(key string, ops []BitFieldOp) ([]BitFieldResult, error) {
+	maxBit := uint64(0)
+	writes := false
+	for _, op := range ops {
+		if op.Width < 1 || op.Width > 64 || (!op.Signed && op.Width > 63) {
+			return nil, ErrBitFieldType
+		}
+		end := op.Offset + uint64(op.Width)
+		if op.Offset > MaxBitOffset || end > MaxBitOffset+1 {
+			return nil, ErrBitOffset
+		}
+		if op.Kind != BitFieldGet {
+			writes = true
+			if end > maxBit {
+				maxBit = end
+			}
+		}
+	}
+
+	shard := s.getShard(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	var buf []byte
+	if writes {
+		var err error
+		buf, err = s.bitmapForWrite(shard, key, int((maxBit+7)>>3))
+		if err != nil {
+			return nil, err
+		}
+	} else if entry, exists := s.liveEntry(shard, key); exists {
+		switch v := entry.Value.(type) {
+		case string:
+			buf = []byte(v)
+		case []byte:
+			buf = v
+		default:
+			return nil, ErrWrongType
+		}
+	}
+
+	results := make([]BitFieldResult, 0, len(ops))
+	for _, op := range ops {
+		if op.Kind == BitFieldGet {
+			if op.Signed {
+				results = append(results, BitFieldResult{Value: getSignedField(buf, op.Offset, op.Width)})
+			} else {
+				results = append(results, BitFieldResult{Value: int64(getUnsignedField(buf, op.Offset, op.Width))})
+			}
+			continue
+		}
+
+		var retval int64
+		var newval uint64
+		var overflow bool
+		if op.Signed {
+			oldval := getSignedField(buf, op.Offset, op.Width)
+			if op.Kind == BitFieldIncrBy {
+				wrapped, of := signedFieldOverflow(oldval, op.Value, op.Width, op.Overflow)
+				next := oldval + op.Value
+				if of {
+					next = wrapped
+				}
+				newval, retval, overflow = uint64(next), next, of
+			} else {
+				next := op.Value
+				wrapped, of := signedFieldOverflow(next, 0, op.Width, op.Overflow)
+				if of {
+					next = wrapped
+				}
+				newval, retval, overflow = uint64(next), oldval, of
+			}
+		} else {
+			oldval := getUnsignedField(buf, op.Offset, op.Width)
+			if op.Kind == BitFieldIncrBy {
+				wrapped, of := unsignedFieldOverflow(oldval, op.Value, op.Width, op.Overflow)
+				next := oldval + uint64(op.Value)
+				if of {
+					next = wrapped
+				}
+				newval, retval, overflow = next, int64(next), of
+			} else {
+				next := uint64(op.Value)
+				wrapped, of := unsignedFieldOverflow(next, 0, op.Width, op.Overflow)
+				if of {
+					next = wrapped
+				}
+				newval, retval, overflow = next, int64(oldval), of
+			}
+		}
+
+		if overflow && op.Overflow == OverflowFail {
+			results = append(results, BitFieldResult{Nil: true})
+			continue
+		}
+		setField(buf, op.Offset, op.Width, newval)
+		results = append(results, BitFieldResult{Value: retval})
+	}
+	if writes {
+		s.notify(notifyString, "setbit", key)
+	}
+	return results, nil
+}
+
+// bitmapForWrite returns the mutable bytes of the string at key, creating
+// the key and growing the string with zero bytes to at least size bytes.
+// Must be called while holding the SHARD'S write lock.
+func (s *KVStore) bitmapForWrite(shard *Shard, key string, size int) ([]byte, error) {
+	entry, exists := s.liveEntry(shard, key)
+	var buf []byte
+	if exists {
+		switch v := entry.Value.(type) {
+		case []byte:
+			buf = v
+		case string:
+			// First bit operation on a plain string: switch to the mutable form
+			buf = []byte(v)
+		default:
+			return nil, ErrWrongType
+		}
+	}
+	if len(buf) < size {
+		buf = append(buf, make([]byte, size-len(buf))...)
+	}
+
+	if !exists {
+		return buf, s.createEntry(shard, key, buf)
+	}
+	entry.Value = buf
+	shard.data[key] = entry
+	return buf, nil
+}
+
+// copyStringBytes returns a private copy of the string stored at key,
+// or nil if the key does not exist.
+func (s *KVStore) copyStringBytes(key string) ([]byte, error) {
+	shard := s.getShard(key)
+	shard.mu.RLock()
+	defer shard.mu.RUnlock()
+
+	entry, exists := shard.data[key]
+	if !exists || entry.isExpired() {
+		return nil, nil
+	}
+	switch v := entry.Value.(type) {
+	case string:
+		return []byte(v), nil
+	case []byte:
+		return append([]byte(nil), v...), nil
+	}
+	return nil, ErrWrongType
+}
+
+// bitAt returns the bit at offset, counting from the most significant bit
+// of the first byte.
+func bitAt[T byteString](p T, offset uint64) int {
+	byteIdx := offset >> 3
+	if byteIdx >= uint64(len(p)) {
+		return 0
+	}
+	return int(p[byteIdx]>>(7-offset&7)) & 1
+}
+
+// resolveRange converts a possibly negative inclusive range into absolute
+// indexes within a sequence of length total. ok is false for empty ranges.
+func resolveRange(start, end, total int64) (int64, int64, bool) {
+	if start < 0 {
+		start = total + start
+	}
+	if end < 0 {
+		end = total + end
+	}
+	if start < 0 {
+		start = 0
+	}
+	if end < 0 {
+		end = 0
+	}
+	if end >= total {
+		end = total - 1
+	}
+	return start, end, start <= end
+}
+
+// countBits implements BITCOUNT over an optional range.
+func countBits[T byteString](p T, r *BitRange) int64 {
+	if r == nil {
+		var count int64
+		for i := 0; i < len(p); i++ {
+			count += int64(bits.OnesCount8(p[i]))
+		}
+		return count
+	}
+
+	total := int64(len(p))
+	if r.BitMode {
+		total <<= 3
+	}
+	start, end, ok := resolveRange(r.Start, r.End, total)
+	if !ok {
+		return 0
+	}
+	if !r.BitMode {
+		start <<= 3
+		end = end<<3 | 7
+	}
+
+	var count int64
+	for pos := start; pos <= end; {
+		if pos&7 == 0 && pos+7 <= end {
+			count += int64(bits.OnesCount8(p[pos>>3]))
+			pos += 8
+			continue
+		}
+		count += int64(bitAt(p, uint64(pos)))
+		pos++
+	}
+	return count
+}
+
+// findBit implements BITPOS over an optional range.
+func findBit[T byteString](p T, bit int, r *BitRange) int64 {
+	if r == nil {
+		r = &BitRange{Start: 0, End: -1, NoEnd: true}
+	}
+
+	total := int64(len(p))
+	if r.BitMode {
+		total <<= 3
+	}
+	start, end, ok := resolveRange(r.Start, r.End, total)
+	if !ok {
+		return -1
+	}
+	if !r.BitMode {
+		start <<= 3
+		end = end<<3 | 7
+	}
+
+	// Bytes that cannot contain the wanted bit are skipped whole
+	skip := byte(0x00)
+	if bit == 0 {
+		skip = 0xff
+	}
+	for pos := start; pos <= end; {
+		if pos&7 == 0 && pos+7 <= end && p[pos>>3] == skip {
+			pos += 8
+			continue
+		}
+		if bitAt(p, uint64(pos)) == bit {
+			return pos
+		}
+		pos++
+	}
+
+	// Without an explicit end, the string is considered padded with zeros
+	if bit == 0 && r.NoEnd {
+		return end + 1
+	}
+	return -1
+}
+
+// getUnsignedField reads a width-bit big-endian unsigned integer at offset.
+// Bits past the end of p read as zero.
+func getUnsignedField(p []byte, offset uint64, width int) uint64 {
+	var value uint64
+	for j := 0; j < width; j++ {
+		value = value<<1 | uint64(bitAt(p, offset))
+		offset++
+	}
+	return value
+}
+
+// getSignedField reads a width-bit two's complement integer at offset.
+func getSignedField(p []byte, offset uint64, width int) int64 {
+	value := getUnsignedField(p, offset, width)
+	if width < 64 && value&(1<<(width-1)) != 0 {
+		value |= math.MaxUint64 << width
+	}
+	return int64(value)
+}
+
+// setField writes the low width bits of value at offset, most significant
+// bit first. p must already be large enough.
+func setField(p []byte, offset uint64, width int, value uint64) {
+	for j := 0; j < width; j++ {
+		bitval := byte(value>>(width-1-j)) & 1
+		byteIdx := offset >> 3
+		shift := 7 - offset&7
+		p[byteIdx] = p[byteIdx]&^(1<<shift) | bitval<<shift
+		offset++
+	}
+}
+
+// unsignedFieldOverflow checks value+incr against a width-bit unsigned
+// field and returns the wrapped or saturated result when it overflows.
+func unsignedFieldOverflow(value uint64, incr int64, width int, mode BitFieldOverflow) (uint64, bool) {
+	max := uint64(1)<<width - 1
+	maxincr := int64(max - value)
+	minincr := -int64(value)
+
+	if value > max || (incr > 0 && incr > maxincr) {
+		if mode == OverflowSat {
+			return max, true
+		}
+		return (value + uint64(incr)) & max, true
+	}
+	if incr < 0 && incr < minincr {
+		if mode == OverflowSat {
+			return 0, true
+		}
+		return (value + uint64(incr)) & max, true
+	}
+	return 0, false
+}
+
+// signedFieldOverflow checks value+incr against a width-bit signed field
+// and returns the wrapped or saturated result when it overflows.
+func signedFieldOverflow(value, incr int64, width int, mode BitFieldOverflow) (int64, bool) {
+	max := int64(math.MaxInt64)
+	if width < 64 {
+		max = int64(1)<<(width-1) - 1
+	}
+	min := -max - 1
+	// maxincr/minincr may overflow, but they are only used once value is
+	// known to be in range, where they cannot
+	maxincr := max - value
+	minincr := min - value
+
+	if value > max || (width != 64 && incr > maxincr) || (value >= 0 && incr > 0 && incr > maxincr) {
+		if mode == OverflowSat {
+			return max, true
+		}
+		return wrapSigned(value, incr, width), true
+	}
+	if value < min || (width != 64 && incr < minincr) || (value < 0 && incr < 0 && incr < minincr) {
+		if mode == OverflowSat {
+			return min, true
+		}
+		return wrapSigned(value, incr, width), true
+	}
+	return 0, false
+}
+
+// wrapSigned adds incr to value modulo 2^width and sign-extends the result.
+func wrapSigned(value, incr int64, width int) int64 {
+	c := uint64(value) + uint64(incr)
+	if width < 64 {
+		mask := uint64(math.MaxUint64) << width
+		if c&(1<<(width-1)) != 0 {
+			c |= mask
+		} else {
+			c &^= mask
+		}
+	}
+	return int64(c)
+}
@@ -0,0 +1,209 @@
+package core
+
+import (
+	"testing"
+)
+
+func TestSetBitAndGetBit(t *testing.T) {
+	store := NewKVStore()
+
+	old, err := store.SetBit("bits", 7, 1)
+	if err != nil {
+		t.Fatalf("SetBit() error = %v", err)
+	}
+	if old != 0 {
+		t.Errorf("SetBit() = %d, want 0", old)
+	}
+
+	val, _, _ := store.Get("bits")
+	if val != "\x01" {
+		t.Errorf("Get() = %q, want \\x01", val)
+	}
+
+	if bit, _ := store.GetBit("bits", 7); bit != 1 {
+		t.Errorf("GetBit(7) = %d, want 1", bit)
+	}
+	if bit, _ := store.GetBit("bits", 100); bit != 0 {
+		t.Errorf("GetBit(100) = %d, want 0", bit)
+	}
+
+	old, _ = store.SetBit("bits", 7, 0)
+	if old != 1 {
+		t.Errorf("SetBit() = %d, want 1", old)
+	}
+}
+
+func TestSetBitWrongType(t *testing.T) {
+	store := NewKVStore()
+	store.HSet("hash", "f", "v")
+
+	if _, err := store.SetBit("hash", 0, 1); err != ErrWrongType {
+		t.Errorf("SetBit() error = %v, want ErrWrongType", err)
+	}
+}
+
+func TestBitCount(t *testing.T) {
+	store := NewKVStore()
+	store.Set("mykey", "foobar", 0)
+
+	tests := []struct {
+		name string
+		r    *BitRange
+		want int64
+	}{
+		{"whole string", nil, 26},
+		{"first byte", &BitRange{Start: 0, End: 0}, 4},
+		{"second byte", &BitRange{Start: 1, End: 1}, 6},
+		{"negative range", &BitRange{Start: -2, End: -1}, 7},
+		{"bit range", &BitRange{Start: 5, End: 30, BitMode: true}, 17},
+		{"empty range", &BitRange{Start: 3, End: 1}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := store.BitCount("mykey", tt.r)
+			if err != nil {
+				t.Fatalf("BitCount() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("BitCount() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBitPos(t *testing.T) {
+	store := NewKVStore()
+	store.Set("a", "\xff\xf0\x00", 0)
+	store.Set("b", "\x00\xff\xf0", 0)
+	store.Set("ones", "\xff\xff\xff", 0)
+
+	tests := []struct {
+		name string
+		key  string
+		bit  int
+		r    *BitRange
+		want int64
+	}{
+		{"first clear bit", "a", 0, nil, 12},
+		{"first set bit", "b", 1, nil, 8},
+		{"with start", "b", 1, &BitRange{Start: 2, End: -1, NoEnd: true}, 16},
+		{"bit range", "b", 1, &BitRange{Start: 7, End: 15, BitMode: true}, 8},
+		{"zero padded", "ones", 0, nil, 24},
+		{"explicit end", "ones", 0, &BitRange{Start: 0, End: -1}, -1},
+		{"missing key set bit", "nokey", 1, nil, -1},
+		{"missing key clear bit", "nokey", 0, nil, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := store.BitPos(tt.key, tt.bit, tt.r)
+			if err != nil {
+				t.Fatalf("BitPos() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("BitPos() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBitOp(t *testing.T) {
+	store := NewKVStore()
+	store.Set("key1", "foobar", 0)
+	store.Set("key2", "abcdef", 0)
+
+	n, err := store.BitOp(BitAnd, "dest", "key1", "key2")
+	if err != nil {
+		t.Fatalf("BitOp() error = %v", err)
+	}
+	if n != 6 {
+		t.Errorf("BitOp() = %d, want 6", n)
+	}
+	if val, _, _ := store.Get("dest"); val != "`bc`ab" {
+		t.Errorf("AND result = %q, want `bc`ab", val)
+	}
+
+	store.BitOp(BitOr, "dest", "key1", "missing")
+	if val, _, _ := store.Get("dest"); val != "foobar" {
+		t.Errorf("OR result = %q, want foobar", val)
+	}
+
+	store.BitOp(BitNot, "dest", "key1")
+	if val, _, _ := store.Get("dest"); val[0] != ^byte('f') {
+		t.Errorf("NOT result = %q", val)
+	}
+
+	if _, err := store.BitOp(BitNot, "dest", "key1", "key2"); err == nil {
+		t.Error("BitOp(NOT) with two sources should error")
+	}
+
+	// An empty result removes the destination key
+	store.BitOp(BitAnd, "dest", "missing")
+	if _, ok, _ := store.Get("dest"); ok {
+		t.Error("BitOp() with empty result should delete dest")
+	}
+}
+
+func TestBitField(t *testing.T) {
+	store := NewKVStore()
+
+	res, err := store.BitField("mykey", []BitFieldOp{
+		{Kind: BitFieldIncrBy, Signed: true, Width: 5, Offset: 100, Value: 1},
+		{Kind: BitFieldGet, Width: 4, Offset: 0},
+	})
+	if err != nil {
+		t.Fatalf("BitField() error = %v", err)
+	}
+	if len(res) != 2 || res[0].Value != 1 || res[1].Value != 0 {
+		t.Errorf("BitField() = %+v, want [1 0]", res)
+	}
+
+	res, _ = store.BitField("signed", []BitFieldOp{
+		{Kind: BitFieldSet, Signed: true, Width: 8, Offset: 0, Value: -100},
+		{Kind: BitFieldGet, Signed: true, Width: 8, Offset: 0},
+		{Kind: BitFieldGet, Width: 8, Offset: 0},
+	})
+	if res[0].Value != 0 || res[1].Value != -100 || res[2].Value != 156 {
+		t.Errorf("BitField() = %+v, want [0 -100 156]", res)
+	}
+}
+
+func TestBitFieldOverflow(t *testing.T) {
+	store := NewKVStore()
+
+	ops := []BitFieldOp{
+		{Kind: BitFieldIncrBy, Width: 2, Offset: 100, Value: 1, Overflow: OverflowWrap},
+		{Kind: BitFieldIncrBy, Width: 2, Offset: 102, Value: 1, Overflow: OverflowSat},
+		{Kind: BitFieldIncrBy, Width: 2, Offset: 104, Value: 1, Overflow: OverflowFail},
+	}
+	want := [][3]int64{{1, 1, 1}, {2, 2, 2}, {3, 3, 3}, {0, 3, -1}}
+	for i, w := range want {
+		res, err := store.BitField("counters", ops)
+		if err != nil {
+			t.Fatalf("BitField() error = %v", err)
+		}
+		for j := range ops {
+			if w[j] == -1 {
+				if !res[j].Nil {
+					t.Errorf("round %d op %d = %+v, want nil", i, j, res[j])
+				}
+				continue
+			}
+			if res[j].Nil || res[j].Value != w[j] {
+				t.Errorf("round %d op %d = %+v, want %d", i, j, res[j], w[j])
+			}
+		}
+	}
+
+	res, _ := store.BitField("signed", []BitFieldOp{
+		{Kind: BitFieldIncrBy, Signed: true, Width: 8, Offset: 0, Value: 200, Overflow: OverflowWrap},
+		{Kind: BitFieldSet, Signed: true, Width: 8, Offset: 8, Value: 300, Overflow: OverflowSat},
+		{Kind: BitFieldGet, Signed: true, Width: 8, Offset: 8},
+	})
+	if res[0].Value != -56 || res[2].Value != 127 {
+		t.Errorf("BitField() = %+v, want [-56 _ 127]", res)
+	}
+
+	if _, err := store.BitField("k", []BitFieldOp{{Kind: BitFieldGet, Width: 64}}); err != ErrBitFieldType {
+		t.Errorf("BitField(u64) error = %v, want ErrBitFieldType", err)
+	}
+}
@@ -0,0 +1,241 @@
+package core
+
+import (
+	"fmt"
+	"math"
+)
+
+// Default parameters for Bloom filters created implicitly by BF.ADD.
+const (
+	DefaultBloomErrorRate = 0.01
+	DefaultBloomCapacity  = 100
+	DefaultBloomExpansion = 2
+
+	// Each new sub-filter of a scalable Bloom filter gets an error rate
+	// tightened by this ratio, which bounds the compound error rate.
+	bloomTighteningRatio = 0.5
+)
+
+var (
+	// ErrKeyExists is returned when reserving a filter on an existing key.
+	ErrKeyExists = fmt.Errorf("ERR item exists")
+	// ErrFilterFull is returned when a non-scaling filter reaches capacity.
+	ErrFilterFull = fmt.Errorf("ERR non scaling filter is full")
+)
+
+// BloomConfig configures a scalable Bloom filter.
+type BloomConfig struct {
+	ErrorRate  float64 // Target false positive rate, between 0 and 1
+	Capacity   uint64  // Items the first sub-filter holds before scaling
+	Expansion  uint    // Capacity growth factor of each new sub-filter
+	NonScaling bool    // Reject inserts instead of growing once full
+}
+
+// DefaultBloomConfig returns the configuration used by BF.ADD on a new key.
+func DefaultBloomConfig() BloomConfig {
+	return BloomConfig{
+		ErrorRate: DefaultBloomErrorRate,
+		Capacity:  DefaultBloomCapacity,
+		Expansion: DefaultBloomExpansion,
+	}
+}
+
+func (c BloomConfig) validate() error {
+	if c.ErrorRate <= 0 || c.ErrorRate >= 1 {
+		return fmt.Errorf("ERR (0 < error rate range < 1)")
+	}
+	if c.Capacity == 0 {
+		return fmt.Errorf("ERR (capacity should be larger than 0)")
+	}
+	if !c.NonScaling && c.Expansion == 0 {
+		return fmt.Errorf("ERR expansion should be greater or equal to 1")
+	}
+	return nil
+}
+
+// BloomFilter is a scalable Bloom filter stored as an Entry value.
+// When the newest sub-filter is full, another one with a larger capacity
+// and a tighter error rate is stacked on top of it.
+type BloomFilter struct {
+	config BloomConfig
+	layers []*bloomLayer
+}
+
+type bloomLayer struct {
+	bits     []uint64
+	size     uint64 // Number of bits
+	hashes   uint64 // Number of hash functions
+	capacity uint64
+	count    uint64
+}
+
+func newBloomFilter(cfg BloomConfig) *BloomFilter {
+	bf := &BloomFilter{config: cfg}
+	bf.layers = append(bf.layers, newBloomLayer(cfg.Capacity, cfg.ErrorRate))
+	return bf
+}
+
+// newBloomLayer sizes a sub-filter for capacity items at errorRate.
+func newBloomLayer(capacity uint64, errorRate float64) *bloomLayer {
+	bitsPerItem := -math.Log(errorRate) / (math.Ln2 * math.Ln2)
+	size := uint64(math.Ceil(float64(capacity) * bitsPerItem))
+	if size < 64 {
+		size = 64
+	}
+	hashes := uint64(math.Ceil(math.Ln2 * bitsPerItem))
+	if hashes < 1 {
+		hashes = 1
+	}
+	return &bloomLayer{
+		bits:     make([]uint64, (size+63)/64),
+		size:     size,
+		hashes:   hashes,
+		capacity: capacity,
+	}
+}
+
+// bloomHashes returns the two base hashes used for double hashing.
+func bloomHashes(item string) (uint64, uint64) {
+	h1 := murmurHash64A(item, 0xc6a4a7935bd1e995)
+	h2 := murmurHash64A(item, h1)
+	return h1, h2 | 1
+}
+
+func (l *bloomLayer) test(h1, h2 uint64) bool {
+	for i := uint64(0); i < l.hashes; i++ {
+		pos := (h1 + i*h2) % l.size
+		if l.bits[pos/64]&(1<<(pos%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (l *bloomLayer) add(h1, h2 uint64) {
+	for i := uint64(0); i < l.hashes; i++ {
+		pos := (h1 + i*h2) % l.size
+		l.bits[pos/64] |= 1 << (pos % 64)
+	}
+	l.count++
+}
+
+// contains reports whether item may have been added.
+func (bf *BloomFilter) contains(h1, h2 uint64) bool {
+	for _, l := range bf.layers {
+		if l.test(h1, h2) {
+			return true
+		}
+	}
+	return false
+}
+
+// add inserts item and reports whether it was new to the filter.
+func (bf *BloomFilter) add(item string) (bool, error) {
+	h1, h2 := bloomHashes(item)
+	if bf.contains(h1, h2) {
+		return false, nil
+	}
+
+	last := bf.layers[len(bf.layers)-1]
+	if last.count >= last.capacity {
+		if bf.config.NonScaling {
+			return false, ErrFilterFull
+		}
+		errorRate := bf.config.ErrorRate * math.Pow(bloomTighteningRatio, float64(len(bf.layers)))
+		last = newBloomLayer(last.capacity*uint64(bf.config.Expansion), errorRate)
+		bf.layers = append(bf.layers, last)
+	}
+	last.add(h1, h2)
+	return true, nil
+}
+
+// Count returns the number of items added to the filter.
+func (bf *BloomFilter) Count() uint64 {
+	var count uint64
+	for _, l := range bf.layers {
+		count += l.count
+	}
+	return count
+}
+
+// BFReserve creates an empty Bloom filter at key.
+func (s *KVStore) BFReserve(key string, cfg BloomConfig) error {
+	if err := cfg.validate(); err != nil {
+		return err
+	}
+
+	shard := s.getShard(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	if _, exists := s.liveEntry(shard, key); exists {
+		return ErrKeyExists
+	}
+	if err := s.createEntry(shard, key, newBloomFilter(cfg)); err != nil {
+		return err
+	}
+	s.notify(notifyModule, "bf.reserve", key)
+	return nil
+}
+
+// BFAdd adds items to the Bloom filter at key, creating it with
+// DefaultBloomConfig if needed. For each item, the result reports whether
+// it was newly added (false means it may have been added before).
+func (s *KVStore) BFAdd(key string, items ...string) ([]bool, error) {
+	shard := s.getShard(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	entry, exists := s.liveEntry(shard, key)
+	if !exists {
+		entry.Value = newBloomFilter(DefaultBloomConfig())
+		if err := s.createEntry(shard, key, entry.Value); err != nil {
+			return nil, err
+		}
+	}
+	bf, ok := entry.Value.(*BloomFilter)
+	if !ok {
+		return nil, ErrWrongType
+	}
+
+	added := make([]bool, len(items))
+	changed := !exists
+	for i, item := range items {
+		ok, err := bf.add(item)
+		if err != nil {
+			added = added[:i]
+			if changed {
+				s.notify(notifyModule, "bf.add", key)
+			}
+			return added, err
+		}
+		added[i] = ok
+		changed = changed || ok
+	}
+	if changed {
+		s.notify(notifyModule, "bf.add", key)
+	}
+	return added, nil
+}
+
+// BFExists reports, for each item, whether it may be in the Bloom filter.
+// A missing key holds no items.
+func (s *KVStore) BFExists(key string, items ...string) ([]bool, error) {
+	shard := s.getShard(key)
+	shard.mu.RLock()
+	defer shard.mu.RUnlock()
+
+	found := make([]bool, len(items))
+	entry, exists := shard.data[key]
+	if !exists || entry.isExpired() {
+		return found, nil
+	}
+	bf, ok := entry.Value.(*BloomFilter)
+	if !ok {
+		return nil, ErrWrongType
+	}
+	for i, item := range items {
+		found[i] = bf.contains(bloomHashes(item))
+	}
+	return found, nil
+}
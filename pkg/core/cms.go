@@ -0,0 +1,158 @@
+package core
+
+import (
+	"fmt"
+	"math"
+)
+
+// ErrCMSNotFound is returned when incrementing a sketch that was never initialized.
+var ErrCMSNotFound = fmt.Errorf("ERR CMS: key does not exist")
+
+// CountMinSketch estimates item frequencies in sub-linear space and is
+// stored as an Entry value. Estimates never undercount; they overcount by
+// at most ErrorRate * total count with the configured probability.
+type CountMinSketch struct {
+	width    uint64
+	depth    uint64
+	counters []uint64 // depth rows of width counters
+	count    uint64   // Sum of all increments
+}
+
+// CMSIncrement is a single item/amount pair for CMSIncrBy.
+type CMSIncrement struct {
+	Item   string
+	Amount uint64
+}
+
+func newCountMinSketch(width, depth uint64) *CountMinSketch {
+	return &CountMinSketch{
+		width:    width,
+		depth:    depth,
+		counters: make([]uint64, width*depth),
+	}
+}
+
+// positions returns the counter index of item in each row.
+func (c *CountMinSketch) positions(item string) []uint64 {
+	h1, h2 := bloomHashes(item)
+	pos := make([]uint64, c.depth)
+	for row := uint64(0); row < c.depth; row++ {
+		pos[row] = row*c.width + (h1+row*h2)%c.width
+	}
+	return pos
+}
+
+func (c *CountMinSketch) incr(item string, amount uint64) uint64 {
+	min := uint64(math.MaxUint64)
+	for _, p := range c.positions(item) {
+		c.counters[p] += amount
+		if c.counters[p] < min {
+			min = c.counters[p]
+		}
+	}
+	c.count += amount
+	return min
+}
+
+func (c *CountMinSketch) query(item string) uint64 {
+	min := uint64(math.MaxUint64)
+	for _, p := range c.positions(item) {
+		if c.counters[p] < min {
+			min = c.counters[p]
+		}
+	}
+	return min
+}
+
+// Width returns the number of counters per row.
+func (c *CountMinSketch) Width() uint64 { return c.width }
+
+// Depth returns the number of rows (hash functions).
+func (c *CountMinSketch) Depth() uint64 { return c.depth }
+
+// Count returns the total of all increments.
+func (c *CountMinSketch) Count() uint64 { return c.count }
+
+// CMSInitByDim creates a Count-Min Sketch at key with explicit dimensions.
+func (s *KVStore) CMSInitByDim(key string, width, depth uint64) error {
+	if width == 0 || depth == 0 {
+		return fmt.Errorf("ERR CMS: invalid width/depth")
+	}
+	return s.cmsCreate(key, width, depth)
+}
+
+// CMSInitByProb creates a Count-Min Sketch at key sized so that estimates
+// overcount by at most errorRate (a fraction of the total count) with
+// probability 1 - probability.
+func (s *KVStore) CMSInitByProb(key string, errorRate, probability float64) error {
+	if errorRate <= 0 || errorRate >= 1 {
+		return fmt.Errorf("ERR CMS: invalid overestimation value")
+	}
+	if probability <= 0 || probability >= 1 {
+		return fmt.Errorf("ERR CMS: invalid prob value")
+	}
+	width := uint64(math.Ceil(2 / errorRate))
+	depth := uint64(math.Ceil(math.Log10(probability) / math.Log10(0.5)))
+	return s.cmsCreate(key, width, depth)
+}
+
+func (s *KVStore) cmsCreate(key string, width, depth uint64) error {
+	shard := s.getShard(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	if _, exists := s.liveEntry(shard, key); exists {
+		return fmt.Errorf("ERR CMS: key already exists")
+	}
+	if err := s.createEntry(shard, key, newCountMinSketch(width, depth)); err != nil {
+		return err
+	}
+	s.notify(notifyModule, "cms.init", key)
+	return nil
+}
+
+// CMSIncrBy increases the count of each item and returns the updated
+// estimates in the same order.
+func (s *KVStore) CMSIncrBy(key string, incrs []CMSIncrement) ([]uint64, error) {
+	shard := s.getShard(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	entry, exists := s.liveEntry(shard, key)
+	if !exists {
+		return nil, ErrCMSNotFound
+	}
+	cms, ok := entry.Value.(*CountMinSketch)
+	if !ok {
+		return nil, ErrWrongType
+	}
+
+	counts := make([]uint64, len(incrs))
+	for i, inc := range incrs {
+		counts[i] = cms.incr(inc.Item, inc.Amount)
+	}
+	s.notify(notifyModule, "cms.incrby", key)
+	return counts, nil
+}
+
+// CMSQuery returns the estimated count of each item.
+func (s *KVStore) CMSQuery(key string, items ...string) ([]uint64, error) {
+	shard := s.getShard(key)
+	shard.mu.RLock()
+	defer shard.mu.RUnlock()
+
+	entry, exists := shard.data[key]
+	if !exists || entry.isExpired() {
+		return nil, ErrCMSNotFound
+	}
+	cms, ok := entry.Value.(*CountMinSketch)
+	if !ok {
+		return nil, ErrWrongType
+	}
+
+	counts := make([]uint64, len(items))
+	for i, item := range items {
+		counts[i] = cms.query(item)
+	}
+	return counts, nil
+}
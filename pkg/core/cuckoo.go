@@ -0,0 +1,325 @@
+package core
+
+import "fmt"
+
+// Default parameters for Cuckoo filters created implicitly by CF.ADD.
+const (
+	DefaultCuckooCapacity      = 1024
+	DefaultCuckooBucketSize    = 2
+	DefaultCuckooMaxIterations = 20
+	DefaultCuckooExpansion     = 1
+)
+
+var (
+	// ErrCuckooFull is returned when an insert fails and the filter cannot grow.
+	ErrCuckooFull = fmt.Errorf("ERR Filter is full")
+	// ErrFilterNotFound is returned when deleting from a missing filter.
+	ErrFilterNotFound = fmt.Errorf("ERR Not found")
+)
+
+// CuckooConfig configures a Cuckoo filter.
+type CuckooConfig struct {
+	Capacity      uint64 // Expected number of items in the first sub-filter
+	BucketSize    int    // Fingerprints per bucket (1-255)
+	MaxIterations int    // Relocations attempted before the filter counts as full
+	Expansion     uint   // Capacity growth factor of new sub-filters; 0 disables growth
+}
+
+// DefaultCuckooConfig returns the configuration used by CF.ADD on a new key.
+func DefaultCuckooConfig() CuckooConfig {
+	return CuckooConfig{
+		Capacity:      DefaultCuckooCapacity,
+		BucketSize:    DefaultCuckooBucketSize,
+		MaxIterations: DefaultCuckooMaxIterations,
+		Expansion:     DefaultCuckooExpansion,
+	}
+}
+
+func (c CuckooConfig) validate() error {
+	if c.Capacity < 2 {
+		return fmt.Errorf("ERR Capacity must be at least 2")
+	}
+	if c.BucketSize < 1 || c.BucketSize > 255 {
+		return fmt.Errorf("ERR Bucket size must be between 1 and 255")
+	}
+	if c.MaxIterations < 1 || c.MaxIterations > 65535 {
+		return fmt.Errorf("ERR Max iterations must be between 1 and 65535")
+	}
+	return nil
+}
+
+// CuckooFilter is a Cuckoo filter stored as an Entry value. Unlike a Bloom
+// filter it supports deletion. Items are stored as 8-bit fingerprints in one
+// of two candidate buckets; further sub-filters are added when it fills up.
+type CuckooFilter struct {
+	config CuckooConfig
+	layers []*cuckooLayer
+	count  uint64
+}
+
+type cuckooLayer struct {
+	buckets    []uint8 // numBuckets * bucketSize fingerprints, 0 = empty
+	numBuckets uint64  // Always a power of two
+	bucketSize int
+}
+
+func newCuckooFilter(cfg CuckooConfig) *CuckooFilter {
+	cf := &CuckooFilter{config: cfg}
+	cf.layers = append(cf.layers, newCuckooLayer(cfg.Capacity, cfg.BucketSize))
+	return cf
+}
+
+func newCuckooLayer(capacity uint64, bucketSize int) *cuckooLayer {
+	numBuckets := uint64(1)
+	for numBuckets*uint64(bucketSize) < capacity {
+		numBuckets <<= 1
+	}
+	return &cuckooLayer{
+		buckets:    make([]uint8, numBuckets*uint64(bucketSize)),
+		numBuckets: numBuckets,
+		bucketSize: bucketSize,
+	}
+}
+
+// cuckooHash returns the item's fingerprint (never 0) and primary hash.
+func cuckooHash(item string) (uint8, uint64) {
+	h := murmurHash64A(item, 0x5bd1e995)
+	fp := uint8(h>>56%255) + 1
+	return fp, h
+}
+
+// altIndex returns the other candidate bucket of a fingerprint. Applying it
+// twice yields the original bucket, which is what makes relocation possible.
+func (l *cuckooLayer) altIndex(i uint64, fp uint8) uint64 {
+	return (i ^ uint64(fp)*0x5bd1e995) & (l.numBuckets - 1)
+}
+
+func (l *cuckooLayer) indexes(fp uint8, h uint64) (uint64, uint64) {
+	i1 := h & (l.numBuckets - 1)
+	return i1, l.altIndex(i1, fp)
+}
+
+func (l *cuckooLayer) bucket(i uint64) []uint8 {
+	start := i * uint64(l.bucketSize)
+	return l.buckets[start : start+uint64(l.bucketSize)]
+}
+
+// insertInto places fp in a free slot of bucket i.
+func (l *cuckooLayer) insertInto(i uint64, fp uint8) bool {
+	b := l.bucket(i)
+	for j := range b {
+		if b[j] == 0 {
+			b[j] = fp
+			return true
+		}
+	}
+	return false
+}
+
+func (l *cuckooLayer) countIn(i uint64, fp uint8) int {
+	n := 0
+	for _, v := range l.bucket(i) {
+		if v == fp {
+			n++
+		}
+	}
+	return n
+}
+
+func (l *cuckooLayer) removeFrom(i uint64, fp uint8) bool {
+	b := l.bucket(i)
+	for j := range b {
+		if b[j] == fp {
+			b[j] = 0
+			return true
+		}
+	}
+	return false
+}
+
+// insert stores fp, relocating existing fingerprints if both buckets are
+// full. On failure every relocation is undone, so nothing is lost.
+// Victims are chosen from the fingerprint and the kick count rather than
+// at random, so replicas and Raft members applying the same CF.ADD
+// commands end up with identical filters.
+func (l *cuckooLayer) insert(fp uint8, h uint64, maxIterations int) bool {
+	i1, i2 := l.indexes(fp, h)
+	if l.insertInto(i1, fp) || l.insertInto(i2, fp) {
+		return true
+	}
+
+	type swap struct {
+		slot uint64
+		prev uint8
+	}
+	var path []swap
+	i := i1
+	if fp&1 == 1 {
+		i = i2
+	}
+	for n := 0; n < maxIterations; n++ {
+		slot := i*uint64(l.bucketSize) + (uint64(fp)+uint64(n))%uint64(l.bucketSize)
+		path = append(path, swap{slot, l.buckets[slot]})
+		fp, l.buckets[slot] = l.buckets[slot], fp
+		i = l.altIndex(i, fp)
+		if l.insertInto(i, fp) {
+			return true
+		}
+	}
+
+	for n := len(path) - 1; n >= 0; n-- {
+		l.buckets[path[n].slot] = path[n].prev
+	}
+	return false
+}
+
+// add inserts item, growing the filter if the newest layer is full.
+func (cf *CuckooFilter) add(item string) error {
+	fp, h := cuckooHash(item)
+	last := cf.layers[len(cf.layers)-1]
+	if !last.insert(fp, h, cf.config.MaxIterations) {
+		if cf.config.Expansion == 0 {
+			return ErrCuckooFull
+		}
+		capacity := last.numBuckets * uint64(last.bucketSize) * uint64(cf.config.Expansion)
+		last = newCuckooLayer(capacity, cf.config.BucketSize)
+		cf.layers = append(cf.layers, last)
+		if !last.insert(fp, h, cf.config.MaxIterations) {
+			return ErrCuckooFull
+		}
+	}
+	cf.count++
+	return nil
+}
+
+// occurrences returns how many times the item's fingerprint is stored.
+func (cf *CuckooFilter) occurrences(item string) int {
+	fp, h := cuckooHash(item)
+	n := 0
+	for _, l := range cf.layers {
+		i1, i2 := l.indexes(fp, h)
+		n += l.countIn(i1, fp)
+		if i2 != i1 {
+			n += l.countIn(i2, fp)
+		}
+	}
+	return n
+}
+
+// remove deletes one occurrence of item, newest layer first.
+func (cf *CuckooFilter) remove(item string) bool {
+	fp, h := cuckooHash(item)
+	for n := len(cf.layers) - 1; n >= 0; n-- {
+		l := cf.layers[n]
+		i1, i2 := l.indexes(fp, h)
+		if l.removeFrom(i1, fp) || l.removeFrom(i2, fp) {
+			cf.count--
+			return true
+		}
+	}
+	return false
+}
+
+// Count returns the number of items stored in the filter.
+func (cf *CuckooFilter) Count() uint64 {
+	return cf.count
+}
+
+// CFReserve creates an empty Cuckoo filter at key.
+func (s *KVStore) CFReserve(key string, cfg CuckooConfig) error {
+	if err := cfg.validate(); err != nil {
+		return err
+	}
+
+	shard := s.getShard(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	if _, exists := s.liveEntry(shard, key); exists {
+		return ErrKeyExists
+	}
+	if err := s.createEntry(shard, key, newCuckooFilter(cfg)); err != nil {
+		return err
+	}
+	s.notify(notifyModule, "cf.reserve", key)
+	return nil
+}
+
+// CFAdd adds item to the Cuckoo filter at key, creating it with
+// DefaultCuckooConfig if needed. Items may be added more than once.
+func (s *KVStore) CFAdd(key, item string) error {
+	_, err := s.cfInsert(key, item, false)
+	return err
+}
+
+// CFAddNX adds item only if it does not appear to be in the filter yet.
+// Returns true if the item was added.
+func (s *KVStore) CFAddNX(key, item string) (bool, error) {
+	return s.cfInsert(key, item, true)
+}
+
+func (s *KVStore) cfInsert(key, item string, onlyNew bool) (bool, error) {
+	shard := s.getShard(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	entry, exists := s.liveEntry(shard, key)
+	if !exists {
+		entry.Value = newCuckooFilter(DefaultCuckooConfig())
+		if err := s.createEntry(shard, key, entry.Value); err != nil {
+			return false, err
+		}
+	}
+	cf, ok := entry.Value.(*CuckooFilter)
+	if !ok {
+		return false, ErrWrongType
+	}
+
+	if onlyNew && cf.occurrences(item) > 0 {
+		return false, nil
+	}
+	if err := cf.add(item); err != nil {
+		return false, err
+	}
+	s.notify(notifyModule, "cf.add", key)
+	return true, nil
+}
+
+// CFDel removes one occurrence of item from the Cuckoo filter at key.
+// Returns false if the item was not found.
+func (s *KVStore) CFDel(key, item string) (bool, error) {
+	shard := s.getShard(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	entry, exists := s.liveEntry(shard, key)
+	if !exists {
+		return false, ErrFilterNotFound
+	}
+	cf, ok := entry.Value.(*CuckooFilter)
+	if !ok {
+		return false, ErrWrongType
+	}
+	if !cf.remove(item) {
+		return false, nil
+	}
+	s.notify(notifyModule, "cf.del", key)
+	return true, nil
+}
+
+// CFExists reports whether item may be in the Cuckoo filter at key.
+func (s *KVStore) CFExists(key, item string) (bool, error) {
+	shard := s.getShard(key)
+	shard.mu.RLock()
+	defer shard.mu.RUnlock()
+
+	entry, exists := shard.data[key]
+	if !exists || entry.isExpired() {
+		return false, nil
+	}
+	cf, ok := entry.Value.(*CuckooFilter)
+	if !ok {
+		return false, ErrWrongType
+	}
+	return cf.occurrences(item) > 0, nil
+}
@@ -0,0 +1,425 @@
+package core
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Geo indexes are sorted sets whose scores are 52-bit geohashes, the same
+// encoding Redis uses: longitude and latitude are each quantized to 26 bits
+// (latitude over the Web Mercator range) and interleaved, so nearby points
+// get nearby scores and an area maps to a handful of score ranges.
+const (
+	geoLatMin         = -85.05112878
+	geoLatMax         = 85.05112878
+	geoLonMin         = -180.0
+	geoLonMax         = 180.0
+	geoStepMax        = 26 // 52 bits in total
+	earthRadiusMeters = 6372797.560856
+	mercatorMax       = 20037726.37
+	geoAlphabet       = "0123456789bcdefghjkmnpqrstuvwxyz"
+)
+
+// GeoSort selects the ordering of GeoSearch results.
+type GeoSort int
+
+const (
+	GeoSortNone GeoSort = iota
+	GeoSortAsc
+	GeoSortDesc
+)
+
+// GeoMember is a named location for GeoAdd.
+type GeoMember struct {
+	Member    string
+	Longitude float64
+	Latitude  float64
+}
+
+// GeoAddOptions mirrors the NX, XX and CH flags of GEOADD.
+type GeoAddOptions struct {
+	NX bool // Only add new members
+	XX bool // Only update existing members
+	CH bool // Count changed members as well as added ones
+}
+
+// GeoPosition is a decoded location. Coordinates are the center of the
+// geohash cell, so they may differ slightly from the values added.
+type GeoPosition struct {
+	Longitude float64
+	Latitude  float64
+}
+
+// GeoSearchQuery describes a GEOSEARCH. All distances are in meters.
+type GeoSearchQuery struct {
+	FromMember bool // Center on Member instead of Longitude/Latitude
+	Member     string
+	Longitude  float64
+	Latitude   float64
+	ByBox      bool // Search a Width x Height box instead of a Radius circle
+	Radius     float64
+	Width      float64
+	Height     float64
+	Sort       GeoSort
+	Count      int  // Maximum number of results, 0 for no limit
+	Any        bool // Return as soon as Count matches are found
+}
+
+// GeoResult is a single GeoSearch match.
+type GeoResult struct {
+	Member   string
+	Distance float64 // Meters from the search center
+	Hash     uint64  // Raw 52-bit geohash score
+	GeoPosition
+}
+
+// GeoAdd adds or updates members of the geo index at key and returns the
+// number of added members (or changed ones, with CH).
+func (s *KVStore) GeoAdd(key string, members []GeoMember, opts GeoAddOptions) (int, error) {
+	if opts.NX && opts.XX {
+		return 0, fmt.Errorf("ERR XX and NX options at the same time are not compatible")
+	}
+	for _, m := range members {
+		if m.Longitude < geoLonMin || m.Longitude > geoLonMax || m.Latitude < geoLatMin || m.Latitude > geoLatMax {
+			return 0, fmt.Errorf("ERR invalid longitude,latitude pair %f,%f", m.Longitude, m.Latitude)
+		}
+	}
+
+	shard := s.getShard(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	entry, exists := s.liveEntry(shard, key)
+	if !exists {
+		if opts.XX {
+			return 0, nil
+		}
+		entry.Value = newSortedSet()
+		if err := s.createEntry(shard, key, entry.Value); err != nil {
+			return 0, err
+		}
+	}
+	zset, ok := entry.Value.(*SortedSet)
+	if !ok {
+		return 0, ErrWrongType
+	}
+
+	changed, modified := 0, false
+	for _, m := range members {
+		score := float64(geohashEncode(m.Longitude, m.Latitude, geoLatMin, geoLatMax, geoStepMax))
+		old, exists := zset.dict[m.Member]
+		if (exists && opts.NX) || (!exists && opts.XX) {
+			continue
+		}
+		zset.add(m.Member, score)
+		if !exists || (opts.CH && old != score) {
+			changed++
+		}
+		modified = modified || !exists || old != score
+	}
+	if modified {
+		s.notify(notifyZSet, "zadd", key)
+	}
+	return changed, nil
+}
+
+// GeoPos returns the position of each member, or nil for missing members.
+func (s *KVStore) GeoPos(key string, members ...string) ([]*GeoPosition, error) {
+	positions := make([]*GeoPosition, len(members))
+	err := s.readGeo(key, func(zset *SortedSet) error {
+		for i, m := range members {
+			if score, ok := zset.dict[m]; ok {
+				pos := geohashDecode(uint64(score))
+				positions[i] = &pos
+			}
+		}
+		return nil
+	})
+	return positions, err
+}
+
+// GeoDist returns the distance in meters between two members.
+// ok is false if either member does not exist.
+func (s *KVStore) GeoDist(key, member1, member2 string) (float64, bool, error) {
+	var dist float64
+	var ok bool
+	err := s.readGeo(key, func(zset *SortedSet) error {
+		score1, ok1 := zset.dict[member1]
+		score2, ok2 := zset.dict[member2]
+		if ok1 && ok2 {
+			p1, p2 := geohashDecode(uint64(score1)), geohashDecode(uint64(score2))
+			dist, ok = geoDistance(p1.Longitude, p1.Latitude, p2.Longitude, p2.Latitude), true
+		}
+		return nil
+	})
+	return dist, ok, err
+}
+
+// GeoHash returns the standard 11 character geohash of each member,
+// or an empty string for missing members.
+func (s *KVStore) GeoHash(key string, members ...string) ([]string, error) {
+	hashes := make([]string, len(members))
+	err := s.readGeo(key, func(zset *SortedSet) error {
+		for i, m := range members {
+			if score, ok := zset.dict[m]; ok {
+				hashes[i] = geohashString(geohashDecode(uint64(score)))
+			}
+		}
+		return nil
+	})
+	return hashes, err
+}
+
+// GeoSearch returns the members inside a circle or box.
+func (s *KVStore) GeoSearch(key string, q GeoSearchQuery) ([]GeoResult, error) {
+	var results []GeoResult
+	err := s.readGeo(key, func(zset *SortedSet) error {
+		lon, lat := q.Longitude, q.Latitude
+		if q.FromMember {
+			score, ok := zset.dict[q.Member]
+			if !ok {
+				return fmt.Errorf("ERR could not decode requested zset member")
+			}
+			center := geohashDecode(uint64(score))
+			lon, lat = center.Longitude, center.Latitude
+		}
+
+		// COUNT without ANY has to return the nearest matches
+		sortBy := q.Sort
+		if q.Count > 0 && !q.Any && sortBy == GeoSortNone {
+			sortBy = GeoSortAsc
+		}
+
+		for _, r := range geoSearchRanges(lon, lat, q) {
+			zset.rangeByScore(r[0], r[1], func(member string, score float64) bool {
+				pos := geohashDecode(uint64(score))
+				dist, inside := geoMatch(lon, lat, pos, q)
+				if !inside {
+					return true
+				}
+				results = append(results, GeoResult{Member: member, Distance: dist, Hash: uint64(score), GeoPosition: pos})
+				return !(q.Any && q.Count > 0 && len(results) >= q.Count)
+			})
+			if q.Any && q.Count > 0 && len(results) >= q.Count {
+				break
+			}
+		}
+
+		switch sortBy {
+		case GeoSortAsc:
+			sort.SliceStable(results, func(i, j int) bool { return results[i].Distance < results[j].Distance })
+		case GeoSortDesc:
+			sort.SliceStable(results, func(i, j int) bool { return results[i].Distance > results[j].Distance })
+		}
+		if q.Count > 0 && len(results) > q.Count {
+			results = results[:q.Count]
+		}
+		return nil
+	})
+	return results, err
+}
+
+// readGeo runs fn on the sorted set at key under the shard read lock.
+// A missing key is treated as an empty set.
+func (s *KVStore) readGeo(key string, fn func(zset *SortedSet) error) error {
+	shard := s.getShard(key)
+	shard.mu.RLock()
+	defer shard.mu.RUnlock()
+
+	entry, exists := shard.data[key]
+	if !exists || entry.isExpired() {
+		return fn(newSortedSet())
+	}
+	zset, ok := entry.Value.(*SortedSet)
+	if !ok {
+		return ErrWrongType
+	}
+	return fn(zset)
+}
+
+// geoSearchRanges returns the score ranges of the geohash cells covering
+// the bounding box of the search area. The cell size is the finest one for
+// which at most 3x3 cells are needed.
+func geoSearchRanges(lon, lat float64, q GeoSearchQuery) [][2]float64 {
+	halfWidth, halfHeight := q.Radius, q.Radius
+	radius := q.Radius
+	if q.ByBox {
+		halfWidth, halfHeight = q.Width/2, q.Height/2
+		radius = math.Sqrt(halfWidth*halfWidth + halfHeight*halfHeight)
+	}
+
+	latDelta := radToDeg(halfHeight / earthRadiusMeters)
+	lonDelta := math.Max(
+		radToDeg(halfWidth/earthRadiusMeters/math.Cos(degToRad(lat+latDelta))),
+		radToDeg(halfWidth/earthRadiusMeters/math.Cos(degToRad(lat-latDelta))),
+	)
+	if math.IsNaN(lonDelta) || lonDelta < 0 || lonDelta > 180 {
+		lonDelta = 180
+	}
+	minLon, maxLon := lon-lonDelta, lon+lonDelta
+	minLat, maxLat := lat-latDelta, lat+latDelta
+
+	for step := geoEstimateSteps(radius, lat); step > 0; step-- {
+		n := int64(1) << step
+		lonLo := int64(math.Floor((minLon - geoLonMin) / (geoLonMax - geoLonMin) * float64(n)))
+		lonHi := int64(math.Floor((maxLon - geoLonMin) / (geoLonMax - geoLonMin) * float64(n)))
+		latLo := clampCell(int64(math.Floor((minLat-geoLatMin)/(geoLatMax-geoLatMin)*float64(n))), n)
+		latHi := clampCell(int64(math.Floor((maxLat-geoLatMin)/(geoLatMax-geoLatMin)*float64(n))), n)
+		if lonHi-lonLo >= 3 || latHi-latLo >= 3 {
+			continue
+		}
+
+		shift := uint(2 * (geoStepMax - step))
+		seen := make(map[uint64]bool)
+		var ranges [][2]float64
+		for la := latLo; la <= latHi; la++ {
+			for lo := lonLo; lo <= lonHi; lo++ {
+				wrapped := (lo%n + n) % n // Cells wrap around the antimeridian
+				hash := interleave64(uint32(la), uint32(wrapped))
+				if seen[hash] {
+					continue
+				}
+				seen[hash] = true
+				ranges = append(ranges, [2]float64{float64(hash << shift), float64((hash + 1) << shift)})
+			}
+		}
+		return ranges
+	}
+	return [][2]float64{{0, float64(uint64(1) << (2 * geoStepMax))}}
+}
+
+func clampCell(idx, n int64) int64 {
+	if idx < 0 {
+		return 0
+	}
+	if idx >= n {
+		return n - 1
+	}
+	return idx
+}
+
+// geoEstimateSteps picks the geohash precision whose cells are roughly as
+// large as the search radius (geohashEstimateStepsByRadius in Redis).
+func geoEstimateSteps(radius, lat float64) int {
+	if radius == 0 {
+		return geoStepMax
+	}
+	step := 1
+	for radius < mercatorMax {
+		radius *= 2
+		step++
+	}
+	step -= 2
+	if lat > 66 || lat < -66 {
+		step--
+		if lat > 80 || lat < -80 {
+			step--
+		}
+	}
+	if step < 1 {
+		step = 1
+	}
+	if step > geoStepMax {
+		step = geoStepMax
+	}
+	return step
+}
+
+// geoMatch reports whether pos lies inside the search shape centered on
+// lon/lat, along with its distance from the center.
+func geoMatch(lon, lat float64, pos GeoPosition, q GeoSearchQuery) (float64, bool) {
+	if !q.ByBox {
+		dist := geoDistance(lon, lat, pos.Longitude, pos.Latitude)
+		return dist, dist <= q.Radius
+	}
+	// Latitude distance is cheaper, so check it first
+	if earthRadiusMeters*math.Abs(degToRad(pos.Latitude-lat)) > q.Height/2 {
+		return 0, false
+	}
+	if geoDistance(pos.Longitude, pos.Latitude, lon, pos.Latitude) > q.Width/2 {
+		return 0, false
+	}
+	return geoDistance(lon, lat, pos.Longitude, pos.Latitude), true
+}
+
+// geoDistance returns the haversine distance in meters.
+func geoDistance(lon1, lat1, lon2, lat2 float64) float64 {
+	lat1r, lat2r := degToRad(lat1), degToRad(lat2)
+	u := math.Sin((lat2r - lat1r) / 2)
+	v := math.Sin(degToRad(lon2-lon1) / 2)
+	return 2 * earthRadiusMeters * math.Asin(math.Sqrt(u*u+math.Cos(lat1r)*math.Cos(lat2r)*v*v))
+}
+
+func degToRad(d float64) float64 { return d * math.Pi / 180 }
+func radToDeg(r float64) float64 { return r * 180 / math.Pi }
+
+// geohashEncode quantizes a position to step bits per axis and interleaves
+// them, latitude in the even bits and longitude in the odd bits.
+func geohashEncode(lon, lat, latMin, latMax float64, step uint) uint64 {
+	n := float64(uint64(1) << step)
+	latIdx := math.Min((lat-latMin)/(latMax-latMin)*n, n-1)
+	lonIdx := math.Min((lon-geoLonMin)/(geoLonMax-geoLonMin)*n, n-1)
+	return interleave64(uint32(latIdx), uint32(lonIdx))
+}
+
+// geohashDecode returns the center of the 52-bit geohash cell.
+func geohashDecode(hash uint64) GeoPosition {
+	latIdx, lonIdx := deinterleave64(hash)
+	n := float64(uint64(1) << geoStepMax)
+	latScale, lonScale := geoLatMax-geoLatMin, geoLonMax-geoLonMin
+
+	latLo := geoLatMin + float64(latIdx)/n*latScale
+	latHi := geoLatMin + float64(latIdx+1)/n*latScale
+	lonLo := geoLonMin + float64(lonIdx)/n*lonScale
+	lonHi := geoLonMin + float64(lonIdx+1)/n*lonScale
+
+	return GeoPosition{
+		Longitude: math.Max(geoLonMin, math.Min(geoLonMax, (lonLo+lonHi)/2)),
+		Latitude:  math.Max(geoLatMin, math.Min(geoLatMax, (latLo+latHi)/2)),
+	}
+}
+
+// geohashString renders a position as the standard base32 geohash, which
+// uses the full [-90, 90] latitude range rather than the Mercator one.
+func geohashString(pos GeoPosition) string {
+	hash := geohashEncode(pos.Longitude, pos.Latitude, -90, 90, geoStepMax)
+	buf := make([]byte, 11)
+	for i := 0; i < 10; i++ {
+		buf[i] = geoAlphabet[(hash>>(52-(i+1)*5))&0x1f]
+	}
+	// 52 bits only fill 10.4 characters; the last one is padded with zeros
+	buf[10] = geoAlphabet[0]
+	return string(buf)
+}
+
+// interleave64 spreads the bits of x into the even positions and the bits
+// of y into the odd positions of the result.
+func interleave64(x, y uint32) uint64 {
+	return spreadBits(x) | spreadBits(y)<<1
+}
+
+// deinterleave64 is the inverse of interleave64.
+func deinterleave64(v uint64) (uint32, uint32) {
+	return squashBits(v), squashBits(v >> 1)
+}
+
+func spreadBits(v uint32) uint64 {
+	x := uint64(v)
+	x = (x | x<<16) & 0x0000FFFF0000FFFF
+	x = (x | x<<8) & 0x00FF00FF00FF00FF
+	x = (x | x<<4) & 0x0F0F0F0F0F0F0F0F
+	x = (x | x<<2) & 0x3333333333333333
+	x = (x | x<<1) & 0x5555555555555555
+	return x
+}
+
+func squashBits(x uint64) uint32 {
+	x &= 0x5555555555555555
+	x = (x | x>>1) & 0x3333333333333333
+	x = (x | x>>2) & 0x0F0F0F0F0F0F0F0F
+	x = (x | x>>4) & 0x00FF00FF00FF00FF
+	x = (x | x>>8) & 0x0000FFFF0000FFFF
+	x = (x | x>>16) & 0x00000000FFFFFFFF
+	return uint32(x)
+}
@@ -0,0 +1,198 @@
+package core
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+func sicily(t *testing.T) *KVStore {
+	t.Helper()
+	store := NewKVStore()
+	n, err := store.GeoAdd("Sicily", []GeoMember{
+		{"Palermo", 13.361389, 38.115556},
+		{"Catania", 15.087269, 37.502669},
+	}, GeoAddOptions{})
+	if err != nil {
+		t.Fatalf("GeoAdd() error = %v", err)
+	}
+	if n != 2 {
+		t.Fatalf("GeoAdd() = %d, want 2", n)
+	}
+	return store
+}
+
+func TestGeoDistAndPos(t *testing.T) {
+	store := sicily(t)
+
+	dist, ok, err := store.GeoDist("Sicily", "Palermo", "Catania")
+	if err != nil || !ok {
+		t.Fatalf("GeoDist() = %v, %v, %v", dist, ok, err)
+	}
+	if math.Abs(dist-166274.1516) > 0.01 {
+		t.Errorf("GeoDist() = %.4f, want 166274.1516", dist)
+	}
+	if _, ok, _ := store.GeoDist("Sicily", "Palermo", "Rome"); ok {
+		t.Error("GeoDist() with missing member ok = true, want false")
+	}
+
+	positions, _ := store.GeoPos("Sicily", "Palermo", "Rome")
+	if positions[0] == nil || positions[1] != nil {
+		t.Fatalf("GeoPos() = %v, want [pos nil]", positions)
+	}
+	if math.Abs(positions[0].Longitude-13.361389) > 1e-5 || math.Abs(positions[0].Latitude-38.115556) > 1e-5 {
+		t.Errorf("GeoPos() = %+v, want ~(13.361389, 38.115556)", *positions[0])
+	}
+}
+
+func TestGeoHash(t *testing.T) {
+	store := sicily(t)
+
+	hashes, err := store.GeoHash("Sicily", "Palermo", "Catania", "Rome")
+	if err != nil {
+		t.Fatalf("GeoHash() error = %v", err)
+	}
+	want := []string{"sqc8b49rny0", "sqdtr74hyu0", ""}
+	for i := range want {
+		if hashes[i] != want[i] {
+			t.Errorf("GeoHash()[%d] = %q, want %q", i, hashes[i], want[i])
+		}
+	}
+}
+
+func TestGeoAddValidationAndFlags(t *testing.T) {
+	store := sicily(t)
+
+	if _, err := store.GeoAdd("Sicily", []GeoMember{{"Pole", 0, 89}}, GeoAddOptions{}); err == nil {
+		t.Error("GeoAdd() with latitude 89 should error")
+	}
+	n, _ := store.GeoAdd("Sicily", []GeoMember{{"Palermo", 13.5, 38.1}, {"Rome", 12.5, 41.9}}, GeoAddOptions{XX: true, CH: true})
+	if n != 1 {
+		t.Errorf("GeoAdd(XX, CH) = %d, want 1", n)
+	}
+	if pos, _ := store.GeoPos("Sicily", "Rome"); pos[0] != nil {
+		t.Error("GeoAdd(XX) added a new member")
+	}
+	n, _ = store.GeoAdd("Sicily", []GeoMember{{"Palermo", 0, 0}, {"Rome", 12.5, 41.9}}, GeoAddOptions{NX: true})
+	if n != 1 {
+		t.Errorf("GeoAdd(NX) = %d, want 1", n)
+	}
+
+	store.Set("str", "value", 0)
+	if _, err := store.GeoAdd("str", []GeoMember{{"a", 0, 0}}, GeoAddOptions{}); err != ErrWrongType {
+		t.Errorf("GeoAdd() on string error = %v, want ErrWrongType", err)
+	}
+}
+
+func TestGeoSearch(t *testing.T) {
+	store := sicily(t)
+	store.GeoAdd("Sicily", []GeoMember{
+		{"edge1", 12.758489, 38.788135},
+		{"edge2", 17.241510, 38.788135},
+	}, GeoAddOptions{})
+
+	tests := []struct {
+		name  string
+		query GeoSearchQuery
+		want  []string
+	}{
+		{
+			name:  "radius asc",
+			query: GeoSearchQuery{Longitude: 15, Latitude: 37, Radius: 200000, Sort: GeoSortAsc},
+			want:  []string{"Catania", "Palermo"},
+		},
+		{
+			name:  "radius desc with count",
+			query: GeoSearchQuery{Longitude: 15, Latitude: 37, Radius: 200000, Sort: GeoSortDesc, Count: 1},
+			want:  []string{"Palermo"},
+		},
+		{
+			name:  "count implies nearest",
+			query: GeoSearchQuery{Longitude: 15, Latitude: 37, Radius: 200000, Count: 1},
+			want:  []string{"Catania"},
+		},
+		{
+			name:  "box",
+			query: GeoSearchQuery{Longitude: 15, Latitude: 37, ByBox: true, Width: 400000, Height: 400000, Sort: GeoSortAsc},
+			want:  []string{"Catania", "Palermo", "edge2", "edge1"},
+		},
+		{
+			name:  "from member",
+			query: GeoSearchQuery{FromMember: true, Member: "Palermo", Radius: 100000, Sort: GeoSortAsc},
+			want:  []string{"Palermo", "edge1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := store.GeoSearch("Sicily", tt.query)
+			if err != nil {
+				t.Fatalf("GeoSearch() error = %v", err)
+			}
+			got := make([]string, len(results))
+			for i, r := range results {
+				got[i] = r.Member
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("GeoSearch() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := store.GeoSearch("Sicily", GeoSearchQuery{FromMember: true, Member: "Rome", Radius: 1}); err == nil {
+		t.Error("GeoSearch() from missing member should error")
+	}
+}
+
+func TestGeoSearchMatchesBruteForce(t *testing.T) {
+	store := NewKVStore()
+	var members []GeoMember
+	for i := 0; i < 2000; i++ {
+		// Deterministic spread across the globe, including both sides of the antimeridian
+		lon := math.Mod(float64(i)*37.77, 360) - 180
+		lat := math.Mod(float64(i)*13.31, 170) - 85
+		members = append(members, GeoMember{fmt.Sprintf("m%d", i), lon, lat})
+	}
+	store.GeoAdd("world", members, GeoAddOptions{})
+
+	centers := [][2]float64{{0, 0}, {179.9, 10}, {-179.9, -10}, {13.4, 52.5}, {0, 80}}
+	for _, c := range centers {
+		for _, radius := range []float64{50000, 500000, 3000000} {
+			results, _ := store.GeoSearch("world", GeoSearchQuery{Longitude: c[0], Latitude: c[1], Radius: radius})
+			want := 0
+			for _, m := range members {
+				pos, _ := store.GeoPos("world", m.Member)
+				if geoDistance(c[0], c[1], pos[0].Longitude, pos[0].Latitude) <= radius {
+					want++
+				}
+			}
+			if len(results) != want {
+				t.Errorf("GeoSearch(%v, %v) found %d, brute force found %d", c, radius, len(results), want)
+			}
+		}
+	}
+}
+
+func TestSortedSetRangeByScore(t *testing.T) {
+	z := newSortedSet()
+	for i := 0; i < 100; i++ {
+		z.add(fmt.Sprintf("m%d", i), float64(i%10))
+	}
+	if z.add("m0", 5) {
+		t.Error("add() of existing member = true, want false")
+	}
+	if !z.remove("m1") || z.remove("m1") {
+		t.Error("remove() should succeed exactly once")
+	}
+
+	var got []float64
+	z.rangeByScore(5, 6, func(member string, score float64) bool {
+		got = append(got, score)
+		return true
+	})
+	if len(got) != 11 {
+		t.Errorf("rangeByScore(5, 6) returned %d members, want 11", len(got))
+	}
+	if z.Len() != 99 || z.zsl.length != 99 {
+		t.Errorf("Len() = %d, skip list length = %d, want 99", z.Len(), z.zsl.length)
+	}
+}
@@ -0,0 +1,188 @@
+package core
+
+// GlobMatch reports whether s matches pattern using Redis glob rules:
+// * matches any run of bytes, ? one byte, [abc], [^abc] and [a-z] a class
+// of bytes, and \ escapes the next byte.
+func GlobMatch(pattern, s string) bool {
+	p, i := 0, 0
+	// Position to resume from when the last * has to swallow one more byte
+	starP, starI := -1, 0
+	for i < len(s) {
+		if p < len(pattern) {
+			switch pattern[p] {
+			case '*':
+				starP, starI = p, i
+				p++
+				continue
+			case '?':
+				p++
+				i++
+				continue
+			case '[':
+				if end, ok := matchClass(pattern, p, s[i]); ok {
+					p = end
+					i++
+					continue
+				}
+			case '\\':
+				if p+1 < len(pattern) && pattern[p+1] == s[i] {
+					p += 2
+					i++
+					continue
+				}
+			default:
+				if pattern[p] == s[i] {
+					p++
+					i++
+					continue
+				}
+			}
+		}
+		if starP < 0 {
+			return false
+		}
+		starI++
+		p, i = starP+1, starI
+	}
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
+
+// matchClass matches c against the [...] class starting at pattern[p] and
+// returns the index just past the class. An unterminated class runs to the
+// end of the pattern, as in Redis.
+func matchClass(pattern string, p int, c byte) (int, bool) {
+	p++
+	negate := p < len(pattern) && pattern[p] == '^'
+	if negate {
+		p++
+	}
+	matched := false
+	for p < len(pattern) && pattern[p] != ']' {
+		switch {
+		case pattern[p] == '\\' && p+1 < len(pattern):
+			p++
+			if pattern[p] == c {
+				matched = true
+			}
+		case p+2 < len(pattern) && pattern[p+1] == '-' && pattern[p+2] != ']':
+			lo, hi := pattern[p], pattern[p+2]
+			if lo > hi {
+				lo, hi = hi, lo
+			}
+			if c >= lo && c <= hi {
+				matched = true
+			}
+			p += 2
+		case pattern[p] == c:
+			matched = true
+		}
+		p++
+	}
+	if p < len(pattern) {
+		p++ // Skip ']'
+	}
+	return p, matched != negate
+}
+
+// patternTrie indexes pattern subscriptions by their literal prefix (the
+// bytes before the first wildcard), so a publish only tests the patterns
+// whose prefix the channel starts with.
+type patternTrie struct {
+	root  patternNode
+	count int // Number of distinct patterns
+}
+
+type patternNode struct {
+	children map[byte]*patternNode
+	patterns map[string]map[*Subscriber]struct{} // Patterns ending at this prefix
+}
+
+// literalPrefix returns the part of pattern before its first special byte.
+func literalPrefix(pattern string) string {
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '*', '?', '[', '\\':
+			return pattern[:i]
+		}
+	}
+	return pattern
+}
+
+func (t *patternTrie) add(pattern string, sub *Subscriber) {
+	node := &t.root
+	for _, c := range []byte(literalPrefix(pattern)) {
+		child, ok := node.children[c]
+		if !ok {
+			if node.children == nil {
+				node.children = make(map[byte]*patternNode)
+			}
+			child = &patternNode{}
+			node.children[c] = child
+		}
+		node = child
+	}
+	if node.patterns == nil {
+		node.patterns = make(map[string]map[*Subscriber]struct{})
+	}
+	subs, ok := node.patterns[pattern]
+	if !ok {
+		subs = make(map[*Subscriber]struct{})
+		node.patterns[pattern] = subs
+		t.count++
+	}
+	subs[sub] = struct{}{}
+}
+
+// remove drops sub from pattern and prunes nodes left empty.
+func (t *patternTrie) remove(pattern string, sub *Subscriber) {
+	prefix := literalPrefix(pattern)
+	path := make([]*patternNode, 0, len(prefix)+1)
+	node := &t.root
+	path = append(path, node)
+	for i := 0; i < len(prefix); i++ {
+		node = node.children[prefix[i]]
+		if node == nil {
+			return
+		}
+		path = append(path, node)
+	}
+
+	subs, ok := node.patterns[pattern]
+	if !ok {
+		return
+	}
+	delete(subs, sub)
+	if len(subs) > 0 {
+		return
+	}
+	delete(node.patterns, pattern)
+	t.count--
+
+	for i := len(path) - 1; i > 0; i-- {
+		if len(path[i].patterns) > 0 || len(path[i].children) > 0 {
+			break
+		}
+		delete(path[i-1].children, prefix[i-1])
+	}
+}
+
+// match calls fn for every pattern matching channel.
+func (t *patternTrie) match(channel string, fn func(pattern string, subs map[*Subscriber]struct{})) {
+	node := &t.root
+	for i := 0; ; i++ {
+		for pattern, subs := range node.patterns {
+			if GlobMatch(pattern, channel) {
+				fn(pattern, subs)
+			}
+		}
+		if i == len(channel) {
+			return
+		}
+		if node = node.children[channel[i]]; node == nil {
+			return
+		}
+	}
+}
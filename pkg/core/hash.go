@@ -1,8 +1,6 @@
 package core
 
 import (
-	"fmt"
-	"sync/atomic"
 	"time"
 )
 
@@ -12,46 +10,31 @@ func (s *KVStore) HSet(key, field, value string) error {
 	shard.mu.Lock()
 	defer shard.mu.Unlock()
 
-	entry, exists := shard.data[key]
-	if exists {
-		// Clean up if expired
-		if entry.ExpiresAt > 0 && time.Now().UnixNano() > entry.ExpiresAt {
-			exists = false
-			delete(shard.data, key)
-			shard.removeKey(key)
-			atomic.AddInt64(&s.keyCount, -1)
-		} else {
-			// Verify type is Map
-			_, isMap := entry.Value.(map[string]string)
-			if !isMap {
-				return fmt.Errorf("WRONGTYPE Operation against a key holding the wrong kind of value")
-			}
-		}
-	}
-
+	entry, exists := s.liveEntry(shard, key)
 	if !exists {
-		// New key creation
-		if s.MaxKeys > 0 {
-			currentKeys := atomic.LoadInt64(&s.keyCount)
-			if currentKeys >= int64(s.MaxKeys) {
-				return ErrMaxKeysExceeded
-			}
+		entry.Value = make(map[string]string)
+		if err := s.createEntry(shard, key, entry.Value); err != nil {
+			return err
 		}
-
-		entry = Entry{
-			Value:     make(map[string]string),
-			ExpiresAt: 0,
-		}
-		shard.addKey(key)
-		atomic.AddInt64(&s.keyCount, 1)
+	}
+	hash, isMap := entry.Value.(map[string]string)
+	if !isMap {
+		return ErrWrongType
 	}
 
-	hash := entry.Value.(map[string]string)
 	hash[field] = value
-	shard.data[key] = entry
+	s.indexHash(key, hash)
+	s.notify(notifyHash, "hset", key)
 	return nil
 }
 
+// HSetBytes sets a binary-safe field value in a Hash map stored at key.
+// Hash fields are held as strings, so the bytes are copied once into one;
+// the caller may reuse value afterwards.
+func (s *KVStore) HSetBytes(key, field string, value []byte) error {
+	return s.HSet(key, field, string(value))
+}
+
 // HGet retrieves a specific field from a Hash.
 func (s *KVStore) HGet(key, field string) (string, bool, error) {
 	shard := s.getShard(key)
@@ -67,18 +50,13 @@ func (s *KVStore) HGet(key, field string) (string, bool, error) {
 	if entry.ExpiresAt > 0 && time.Now().UnixNano() > entry.ExpiresAt {
 		shard.mu.Lock()
 		defer shard.mu.Unlock()
-		currentEntry, currentOk := shard.data[key]
-		if currentOk && currentEntry.ExpiresAt > 0 && time.Now().UnixNano() > currentEntry.ExpiresAt {
-			delete(shard.data, key)
-			shard.removeKey(key)
-			atomic.AddInt64(&s.keyCount, -1)
-		}
+		s.liveEntry(shard, key) // Deletes the entry if it is still expired
 		return "", false, nil
 	}
 
 	hash, isMap := entry.Value.(map[string]string)
 	if !isMap {
-		return "", true, fmt.Errorf("WRONGTYPE Operation against a key holding the wrong kind of value")
+		return "", true, ErrWrongType
 	}
 
 	val, ok := hash[field]
@@ -99,18 +77,13 @@ func (s *KVStore) HGetAll(key string) (map[string]string, bool, error) {
 	if entry.ExpiresAt > 0 && time.Now().UnixNano() > entry.ExpiresAt {
 		shard.mu.Lock()
 		defer shard.mu.Unlock()
-		currentEntry, currentOk := shard.data[key]
-		if currentOk && currentEntry.ExpiresAt > 0 && time.Now().UnixNano() > currentEntry.ExpiresAt {
-			delete(shard.data, key)
-			shard.removeKey(key)
-			atomic.AddInt64(&s.keyCount, -1)
-		}
+		s.liveEntry(shard, key) // Deletes the entry if it is still expired
 		return nil, false, nil
 	}
 
 	hash, isMap := entry.Value.(map[string]string)
 	if !isMap {
-		return nil, true, fmt.Errorf("WRONGTYPE Operation against a key holding the wrong kind of value")
+		return nil, true, ErrWrongType
 	}
 
 	// Return a copy to prevent race conditions
@@ -128,26 +101,21 @@ func (s *KVStore) HDel(key, field string) (bool, error) {
 	shard.mu.Lock()
 	defer shard.mu.Unlock()
 
-	entry, exists := shard.data[key]
+	entry, exists := s.liveEntry(shard, key)
 	if !exists {
 		return false, nil
 	}
 
-	if entry.ExpiresAt > 0 && time.Now().UnixNano() > entry.ExpiresAt {
-		delete(shard.data, key)
-		shard.removeKey(key)
-		atomic.AddInt64(&s.keyCount, -1)
-		return false, nil
-	}
-
 	hash, isMap := entry.Value.(map[string]string)
 	if !isMap {
-		return false, fmt.Errorf("WRONGTYPE Operation against a key holding the wrong kind of value")
+		return false, ErrWrongType
 	}
 
 	_, fieldExists := hash[field]
 	if fieldExists {
 		delete(hash, field)
+		s.indexHash(key, hash)
+		s.notify(notifyHash, "hdel", key)
 		// Optimization: If hash is empty, we could delete the key here,
 		// but standard Redis behavior keeps the key until explicitly deleted.
 		return true, nil
@@ -105,3 +105,20 @@ func TestHSetWrongType(t *testing.T) {
 		t.Error("HSet() should error on wrong type")
 	}
 }
+
+func TestHSetBytes(t *testing.T) {
+	store := NewKVStore()
+
+	payload := []byte{0x08, 0x96, 0x01, 0x00, '\n'}
+	if err := store.HSetBytes("proto", "msg", payload); err != nil {
+		t.Fatalf("HSetBytes() error = %v", err)
+	}
+
+	val, ok, err := store.HGet("proto", "msg")
+	if err != nil || !ok {
+		t.Fatalf("HGet() = %q, %v, %v", val, ok, err)
+	}
+	if val != string(payload) {
+		t.Errorf("HGet() = %q, want %q", val, payload)
+	}
+}
@@ -0,0 +1,550 @@
+package core
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+)
+
+// HyperLogLog values are stored as plain strings using the exact byte layout
+// of Redis (see hyperloglog.c), so keys can be moved between the two with
+// GET/SET. The layout is a 16 byte header followed by the registers:
+//
+//	+------+---+-----+----------+
+//	| HYLL | E | N/U | Cardin.  |
+//	+------+---+-----+----------+
+//
+// "E" is the encoding (dense or sparse), followed by 3 unused bytes and the
+// cached cardinality as a little endian uint64. The most significant bit of
+// the last cardinality byte is set when the cache is stale.
+const (
+	hllP              = 14
+	hllQ              = 64 - hllP
+	hllRegisters      = 1 << hllP
+	hllPMask          = hllRegisters - 1
+	hllBits           = 6
+	hllRegisterMax    = 1<<hllBits - 1
+	hllHdrSize        = 16
+	hllDenseSize      = hllHdrSize + (hllRegisters*hllBits+7)/8
+	hllDense          = 0
+	hllSparse         = 1
+	hllSparseMaxBytes = 3000                    // Promote to dense past this size (hll-sparse-max-bytes)
+	hllAlphaInf       = 0.721347520444481703680 // 1 / (2 * ln 2)
+	hllHashSeed       = 0xadc83b19
+
+	// Sparse opcodes:
+	//   ZERO:  00xxxxxx          run of 1-64 zero registers
+	//   XZERO: 01xxxxxx yyyyyyyy run of 1-16384 zero registers
+	//   VAL:   1vvvvvxx          run of 1-4 registers set to 1-32
+	hllSparseXZeroBit  = 0x40
+	hllSparseValBit    = 0x80
+	hllSparseValMax    = 32
+	hllSparseValMaxLen = 4
+	hllSparseZeroMax   = 64
+	hllSparseXZeroMax  = 16384
+)
+
+var (
+	// ErrNotHLL is returned when a string key does not hold a HyperLogLog.
+	ErrNotHLL = fmt.Errorf("WRONGTYPE Key is not a valid HyperLogLog string value.")
+	// ErrHLLCorrupt is returned when a HyperLogLog fails validation.
+	ErrHLLCorrupt = fmt.Errorf("INVALIDOBJ Corrupted HLL object detected")
+)
+
+// PFAdd adds elements to the HyperLogLog at key, creating it if needed.
+// Returns true if the key was created or any register changed.
+func (s *KVStore) PFAdd(key string, elements ...string) (bool, error) {
+	shard := s.getShard(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	entry, exists := s.liveEntry(shard, key)
+	if !exists {
+		hll := newSparseHLL()
+		if err := s.createEntry(shard, key, hll); err != nil {
+			return false, err
+		}
+		entry = shard.data[key]
+	}
+
+	hll, err := hllBytes(entry.Value)
+	if err != nil {
+		return false, err
+	}
+
+	changed := false
+	if hll[4] == hllDense {
+		// Dense registers are updated in place
+		regs := hll[hllHdrSize:]
+		for _, ele := range elements {
+			index, count := hllPatLen(ele)
+			if count > denseRegister(regs, index) {
+				setDenseRegister(regs, index, count)
+				changed = true
+			}
+		}
+	} else {
+		regs, err := hllDecodeSparse(hll)
+		if err != nil {
+			return false, err
+		}
+		for _, ele := range elements {
+			index, count := hllPatLen(ele)
+			if count > regs[index] {
+				regs[index] = count
+				changed = true
+			}
+		}
+		if changed {
+			hll = hllEncode(regs, hllSparse)
+		}
+	}
+
+	if changed {
+		hllInvalidateCache(hll)
+	}
+	entry.Value = hll
+	shard.data[key] = entry
+	if changed || !exists {
+		s.notify(notifyString, "pfadd", key)
+	}
+	return changed || !exists, nil
+}
+
+// PFCount returns the approximate cardinality of the union of the
+// HyperLogLogs at keys. Missing keys count as empty sets.
+// With a single key, the result is cached in the HyperLogLog header.
+func (s *KVStore) PFCount(keys ...string) (int64, error) {
+	if len(keys) == 1 {
+		return s.pfCountCached(keys[0])
+	}
+
+	union := make([]uint8, hllRegisters)
+	for _, key := range keys {
+		if err := s.hllMergeInto(union, key); err != nil {
+			return 0, err
+		}
+	}
+	return int64(hllCount(union)), nil
+}
+
+// PFMerge stores the union of the source HyperLogLogs (and destKey itself,
+// if it exists) in destKey.
+func (s *KVStore) PFMerge(destKey string, srcKeys ...string) error {
+	union := make([]uint8, hllRegisters)
+	dense := false
+	for _, key := range srcKeys {
+		if key == destKey {
+			continue
+		}
+		if err := s.hllMergeInto(union, key); err != nil {
+			return err
+		}
+	}
+
+	shard := s.getShard(destKey)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	entry, exists := s.liveEntry(shard, destKey)
+	if exists {
+		hll, err := hllBytes(entry.Value)
+		if err != nil {
+			return err
+		}
+		regs, err := hllDecode(hll)
+		if err != nil {
+			return err
+		}
+		hllMaxRegisters(union, regs)
+		dense = hll[4] == hllDense
+	}
+
+	encoding := hllSparse
+	if dense {
+		encoding = hllDense
+	}
+	hll := hllEncode(union, encoding)
+	hllInvalidateCache(hll)
+
+	if !exists {
+		if err := s.createEntry(shard, destKey, hll); err != nil {
+			return err
+		}
+	} else {
+		entry.Value = hll
+		shard.data[destKey] = entry
+	}
+	s.notify(notifyString, "pfadd", destKey)
+	return nil
+}
+
+// pfCountCached counts a single key, refreshing the cached cardinality.
+func (s *KVStore) pfCountCached(key string) (int64, error) {
+	shard := s.getShard(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	entry, exists := s.liveEntry(shard, key)
+	if !exists {
+		return 0, nil
+	}
+	hll, err := hllBytes(entry.Value)
+	if err != nil {
+		return 0, err
+	}
+
+	if hll[15]&0x80 == 0 {
+		var card uint64
+		for i := 7; i >= 0; i-- {
+			card = card<<8 | uint64(hll[8+i])
+		}
+		return int64(card), nil
+	}
+
+	regs, err := hllDecode(hll)
+	if err != nil {
+		return 0, err
+	}
+	card := hllCount(regs)
+	for i := 0; i < 8; i++ {
+		hll[8+i] = byte(card >> (8 * i))
+	}
+	entry.Value = hll
+	shard.data[key] = entry
+	return int64(card), nil
+}
+
+// hllMergeInto folds the registers of the HyperLogLog at key into union.
+func (s *KVStore) hllMergeInto(union []uint8, key string) error {
+	shard := s.getShard(key)
+	shard.mu.RLock()
+	defer shard.mu.RUnlock()
+
+	entry, exists := shard.data[key]
+	if !exists || entry.isExpired() {
+		return nil
+	}
+	var raw []byte
+	switch v := entry.Value.(type) {
+	case string:
+		raw = []byte(v)
+	case []byte:
+		raw = v
+	default:
+		return ErrWrongType
+	}
+	if !isHLL(raw) {
+		return ErrNotHLL
+	}
+	regs, err := hllDecode(raw)
+	if err != nil {
+		return err
+	}
+	hllMaxRegisters(union, regs)
+	return nil
+}
+
+// hllBytes returns the mutable bytes of a HyperLogLog value, converting a
+// plain Go string (e.g. written by SET during a migration) on first use.
+func hllBytes(v interface{}) ([]byte, error) {
+	var hll []byte
+	switch val := v.(type) {
+	case []byte:
+		hll = val
+	case string:
+		hll = []byte(val)
+	default:
+		return nil, ErrWrongType
+	}
+	if !isHLL(hll) {
+		return nil, ErrNotHLL
+	}
+	return hll, nil
+}
+
+// isHLL validates the HyperLogLog header and the size of dense values.
+func isHLL(p []byte) bool {
+	if len(p) < hllHdrSize || string(p[:4]) != "HYLL" {
+		return false
+	}
+	switch p[4] {
+	case hllDense:
+		return len(p) == hllDenseSize
+	case hllSparse:
+		return true
+	}
+	return false
+}
+
+// newSparseHLL returns an empty sparse HyperLogLog with a valid zero cache.
+func newSparseHLL() []byte {
+	regs := make([]uint8, hllRegisters)
+	return hllEncode(regs, hllSparse)
+}
+
+func hllInvalidateCache(hll []byte) {
+	hll[15] |= 0x80
+}
+
+// hllPatLen hashes ele and returns the register index together with the
+// length of the 000..1 pattern in the remaining hash bits.
+func hllPatLen(ele string) (int, uint8) {
+	hash := murmurHash64A(ele, hllHashSeed)
+	index := int(hash & hllPMask)
+	hash >>= hllP
+	hash |= 1 << hllQ // Guarantees count <= Q+1
+	return index, uint8(bits.TrailingZeros64(hash) + 1)
+}
+
+// denseRegister reads 6-bit register i from the packed dense layout.
+func denseRegister(regs []byte, i int) uint8 {
+	byteIdx := i * hllBits / 8
+	fb := uint(i*hllBits) & 7
+	b0 := uint(regs[byteIdx])
+	var b1 uint
+	if byteIdx+1 < len(regs) {
+		b1 = uint(regs[byteIdx+1])
+	}
+	return uint8((b0>>fb | b1<<(8-fb)) & hllRegisterMax)
+}
+
+// setDenseRegister writes 6-bit register i in the packed dense layout.
+func setDenseRegister(regs []byte, i int, val uint8) {
+	byteIdx := i * hllBits / 8
+	fb := uint(i*hllBits) & 7
+	v := uint(val)
+	regs[byteIdx] &^= byte(hllRegisterMax << fb)
+	regs[byteIdx] |= byte(v << fb)
+	if byteIdx+1 < len(regs) {
+		regs[byteIdx+1] &^= byte(hllRegisterMax >> (8 - fb))
+		regs[byteIdx+1] |= byte(v >> (8 - fb))
+	}
+}
+
+// hllDecode expands either encoding into one byte per register.
+func hllDecode(hll []byte) ([]uint8, error) {
+	if hll[4] == hllSparse {
+		return hllDecodeSparse(hll)
+	}
+	regs := make([]uint8, hllRegisters)
+	dense := hll[hllHdrSize:]
+	for i := range regs {
+		regs[i] = denseRegister(dense, i)
+	}
+	return regs, nil
+}
+
+// hllDecodeSparse expands the sparse opcode stream into registers.
+func hllDecodeSparse(hll []byte) ([]uint8, error) {
+	regs := make([]uint8, hllRegisters)
+	idx := 0
+	p := hll[hllHdrSize:]
+	for pos := 0; pos < len(p); {
+		op := p[pos]
+		var run int
+		switch {
+		case op&0xc0 == 0:
+			run = int(op&0x3f) + 1
+			pos++
+		case op&0xc0 == hllSparseXZeroBit:
+			if pos+1 >= len(p) {
+				return nil, ErrHLLCorrupt
+			}
+			run = (int(op&0x3f)<<8 | int(p[pos+1])) + 1
+			pos += 2
+		default:
+			run = int(op&0x3) + 1
+			if idx+run > hllRegisters {
+				return nil, ErrHLLCorrupt
+			}
+			val := (op>>2)&0x1f + 1
+			for j := 0; j < run; j++ {
+				regs[idx+j] = val
+			}
+			pos++
+		}
+		idx += run
+		if idx > hllRegisters {
+			return nil, ErrHLLCorrupt
+		}
+	}
+	if idx != hllRegisters {
+		return nil, ErrHLLCorrupt
+	}
+	return regs, nil
+}
+
+// hllEncode serializes registers with the preferred encoding. Sparse
+// encoding falls back to dense when a register does not fit a VAL opcode
+// or the result would exceed hllSparseMaxBytes.
+func hllEncode(regs []uint8, encoding int) []byte {
+	if encoding == hllSparse {
+		if hll, ok := hllEncodeSparse(regs); ok {
+			return hll
+		}
+	}
+
+	hll := make([]byte, hllDenseSize)
+	copy(hll, "HYLL")
+	hll[4] = hllDense
+	dense := hll[hllHdrSize:]
+	for i, v := range regs {
+		if v != 0 {
+			setDenseRegister(dense, i, v)
+		}
+	}
+	return hll
+}
+
+func hllEncodeSparse(regs []uint8) ([]byte, bool) {
+	hll := make([]byte, hllHdrSize, hllHdrSize+64)
+	copy(hll, "HYLL")
+	hll[4] = hllSparse
+
+	for i := 0; i < len(regs); {
+		val := regs[i]
+		run := 1
+		for i+run < len(regs) && regs[i+run] == val {
+			run++
+		}
+		i += run
+
+		if val == 0 {
+			for run > 0 {
+				if run > hllSparseZeroMax {
+					n := min(run, hllSparseXZeroMax)
+					hll = append(hll, hllSparseXZeroBit|byte((n-1)>>8), byte(n-1))
+					run -= n
+				} else {
+					hll = append(hll, byte(run-1))
+					run = 0
+				}
+			}
+			continue
+		}
+
+		if val > hllSparseValMax {
+			return nil, false
+		}
+		for run > 0 {
+			n := min(run, hllSparseValMaxLen)
+			hll = append(hll, hllSparseValBit|(val-1)<<2|byte(n-1))
+			run -= n
+		}
+		if len(hll) > hllHdrSize+hllSparseMaxBytes {
+			return nil, false
+		}
+	}
+	return hll, len(hll) <= hllHdrSize+hllSparseMaxBytes
+}
+
+// hllMaxRegisters sets each register of dst to max(dst, src).
+func hllMaxRegisters(dst, src []uint8) {
+	for i, v := range src {
+		if v > dst[i] {
+			dst[i] = v
+		}
+	}
+}
+
+// hllCount estimates the cardinality from the registers using the
+// improved estimator by Otmar Ertl, as Redis does.
+func hllCount(regs []uint8) uint64 {
+	var histo [64]int
+	for _, v := range regs {
+		histo[v]++
+	}
+
+	m := float64(hllRegisters)
+	z := m * hllTau((m-float64(histo[hllQ+1]))/m)
+	for j := hllQ; j >= 1; j-- {
+		z += float64(histo[j])
+		z *= 0.5
+	}
+	z += m * hllSigma(float64(histo[0])/m)
+	return uint64(math.Round(hllAlphaInf * m * m / z))
+}
+
+func hllSigma(x float64) float64 {
+	if x == 1 {
+		return math.Inf(1)
+	}
+	y := 1.0
+	z := x
+	for {
+		x *= x
+		zPrime := z
+		z += x * y
+		y += y
+		if zPrime == z {
+			return z
+		}
+	}
+}
+
+func hllTau(x float64) float64 {
+	if x == 0 || x == 1 {
+		return 0
+	}
+	y := 1.0
+	z := 1 - x
+	for {
+		x = math.Sqrt(x)
+		zPrime := z
+		y *= 0.5
+		z -= math.Pow(1-x, 2) * y
+		if zPrime == z {
+			return z / 3
+		}
+	}
+}
+
+// murmurHash64A is the 64 bit MurmurHash2 variant used by Redis for
+// HyperLogLog, reading blocks as little endian on every platform.
+func murmurHash64A(key string, seed uint64) uint64 {
+	const m = 0xc6a4a7935bd1e995
+	const r = 47
+
+	h := seed ^ (uint64(len(key)) * m)
+	n := len(key) &^ 7
+	for i := 0; i < n; i += 8 {
+		k := uint64(key[i]) | uint64(key[i+1])<<8 | uint64(key[i+2])<<16 | uint64(key[i+3])<<24 |
+			uint64(key[i+4])<<32 | uint64(key[i+5])<<40 | uint64(key[i+6])<<48 | uint64(key[i+7])<<56
+		k *= m
+		k ^= k >> r
+		k *= m
+		h ^= k
+		h *= m
+	}
+
+	tail := key[n:]
+	switch len(tail) {
+	case 7:
+		h ^= uint64(tail[6]) << 48
+		fallthrough
+	case 6:
+		h ^= uint64(tail[5]) << 40
+		fallthrough
+	case 5:
+		h ^= uint64(tail[4]) << 32
+		fallthrough
+	case 4:
+		h ^= uint64(tail[3]) << 24
+		fallthrough
+	case 3:
+		h ^= uint64(tail[2]) << 16
+		fallthrough
+	case 2:
+		h ^= uint64(tail[1]) << 8
+		fallthrough
+	case 1:
+		h ^= uint64(tail[0])
+		h *= m
+	}
+
+	h ^= h >> r
+	h *= m
+	h ^= h >> r
+	return h
+}
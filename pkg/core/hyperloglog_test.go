@@ -0,0 +1,121 @@
+package core
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestPFAddAndCount(t *testing.T) {
+	store := NewKVStore()
+
+	changed, err := store.PFAdd("hll", "1", "2", "3", "4", "5")
+	if err != nil {
+		t.Fatalf("PFAdd() error = %v", err)
+	}
+	if !changed {
+		t.Error("PFAdd() = false, want true")
+	}
+	if n, _ := store.PFCount("hll"); n != 5 {
+		t.Errorf("PFCount() = %d, want 5", n)
+	}
+
+	// Re-adding known elements does not alter registers
+	changed, _ = store.PFAdd("hll", "1", "2")
+	if changed {
+		t.Error("PFAdd() of existing elements = true, want false")
+	}
+
+	store.PFAdd("hll", "6", "7", "8", "9", "10")
+	if n, _ := store.PFCount("hll"); n != 10 {
+		t.Errorf("PFCount() = %d, want 10", n)
+	}
+
+	// PFADD without elements still creates the key
+	changed, _ = store.PFAdd("empty")
+	if !changed {
+		t.Error("PFAdd() creating a key = false, want true")
+	}
+	if n, _ := store.PFCount("empty"); n != 0 {
+		t.Errorf("PFCount() = %d, want 0", n)
+	}
+}
+
+func TestPFCountAccuracy(t *testing.T) {
+	store := NewKVStore()
+
+	const total = 100000
+	for i := 0; i < total; i += 100 {
+		batch := make([]string, 100)
+		for j := range batch {
+			batch[j] = fmt.Sprintf("visitor:%d", i+j)
+		}
+		store.PFAdd("visitors", batch...)
+	}
+
+	n, err := store.PFCount("visitors")
+	if err != nil {
+		t.Fatalf("PFCount() error = %v", err)
+	}
+	if errRate := math.Abs(float64(n)-total) / total; errRate > 0.02 {
+		t.Errorf("PFCount() = %d, error %.3f exceeds 2%%", n, errRate)
+	}
+
+	// The value was promoted to the dense representation
+	raw, _, _ := store.GetBytes("visitors")
+	if len(raw) != hllDenseSize || raw[4] != hllDense {
+		t.Errorf("HLL size = %d encoding = %d, want dense", len(raw), raw[4])
+	}
+}
+
+func TestPFMerge(t *testing.T) {
+	store := NewKVStore()
+
+	store.PFAdd("a", "foo", "bar", "zap", "a")
+	store.PFAdd("b", "a", "b", "c", "foo")
+
+	if err := store.PFMerge("merged", "a", "b"); err != nil {
+		t.Fatalf("PFMerge() error = %v", err)
+	}
+	if n, _ := store.PFCount("merged"); n != 6 {
+		t.Errorf("PFCount(merged) = %d, want 6", n)
+	}
+	if n, _ := store.PFCount("a", "b"); n != 6 {
+		t.Errorf("PFCount(a, b) = %d, want 6", n)
+	}
+}
+
+func TestPFAddMigratedValue(t *testing.T) {
+	store := NewKVStore()
+	store.PFAdd("src", "x", "y", "z")
+
+	// Copying the raw string keeps a working HyperLogLog
+	raw, _, _ := store.GetBytes("src")
+	store.SetBytes("copy", raw, 0)
+	store.PFAdd("copy", "w")
+	if n, _ := store.PFCount("copy"); n != 4 {
+		t.Errorf("PFCount(copy) = %d, want 4", n)
+	}
+}
+
+func TestPFAddInvalidValue(t *testing.T) {
+	store := NewKVStore()
+
+	store.Set("plain", "hello", 0)
+	if _, err := store.PFAdd("plain", "x"); err != ErrNotHLL {
+		t.Errorf("PFAdd() error = %v, want ErrNotHLL", err)
+	}
+
+	store.HSet("hash", "f", "v")
+	if _, err := store.PFCount("hash"); err != ErrWrongType {
+		t.Errorf("PFCount() error = %v, want ErrWrongType", err)
+	}
+
+	// Trailing garbage after a sparse HLL is detected
+	store.PFAdd("corrupt", "a", "b", "c")
+	raw, _, _ := store.GetBytes("corrupt")
+	store.SetBytes("corrupt", append(raw, "hello"...), 0)
+	if _, err := store.PFCount("corrupt"); err != ErrHLLCorrupt {
+		t.Errorf("PFCount() error = %v, want ErrHLLCorrupt", err)
+	}
+}
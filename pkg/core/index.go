@@ -0,0 +1,516 @@
+package core
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// IndexFieldType is the kind of a secondary index field.
+type IndexFieldType int
+
+const (
+	// IndexTag indexes comma-separated exact values, queried by value or prefix.
+	IndexTag IndexFieldType = iota
+	// IndexNumeric indexes numbers, queried by range.
+	IndexNumeric
+	// IndexVector indexes float32 embeddings, queried by nearest neighbours.
+	IndexVector
+)
+
+var (
+	// ErrIndexExists is returned when creating an index whose name is taken.
+	ErrIndexExists = fmt.Errorf("ERR Index already exists")
+	// ErrNoIndex is returned when an index does not exist.
+	ErrNoIndex = fmt.Errorf("ERR Unknown index name")
+)
+
+// IndexField declares one indexed hash field.
+type IndexField struct {
+	Name   string
+	Type   IndexFieldType
+	Vector *VectorOptions // Required for IndexVector fields
+}
+
+// IndexSchema declares an index over the hashes whose keys start with one
+// of Prefixes (every hash if Prefixes is empty).
+type IndexSchema struct {
+	Name     string
+	Prefixes []string
+	Fields   []IndexField
+}
+
+// QueryClause filters on a single field. Tag clauses match any of Values;
+// a value ending in '*' matches every tag with that prefix. Numeric clauses
+// match Min <= value <= Max, with either bound optionally exclusive.
+type QueryClause struct {
+	Field        string
+	Values       []string
+	Min, Max     float64
+	MinExclusive bool
+	MaxExclusive bool
+}
+
+// SearchDoc is a single search hit.
+type SearchDoc struct {
+	Key    string
+	Fields map[string]string
+	Score  float64 // Distance for vector queries, 0 otherwise
+}
+
+// SearchResult is a page of search hits. Total counts every match, not
+// just the ones on the page.
+type SearchResult struct {
+	Total int
+	Docs  []SearchDoc
+}
+
+// Index is a secondary index over hash fields. It is updated from the
+// shard write path, so it always reflects the hashes it covers.
+// Lock order: shard lock first, then Index.mu.
+type Index struct {
+	mu      sync.RWMutex
+	schema  IndexSchema
+	fields  map[string]IndexField
+	docs    map[string]map[string][]string // key -> tag field -> indexed tags
+	tags    map[string]*tagIndex
+	numeric map[string]*SortedSet // field -> (key scored by value)
+	vectors map[string]vectorIndex
+}
+
+// tagIndex maps tag values to keys and keeps the values sorted for
+// prefix lookups.
+type tagIndex struct {
+	keys   map[string]map[string]struct{}
+	values []string
+}
+
+func (t *tagIndex) add(value, key string) {
+	set, ok := t.keys[value]
+	if !ok {
+		set = make(map[string]struct{})
+		t.keys[value] = set
+		i := sort.SearchStrings(t.values, value)
+		t.values = append(t.values, "")
+		copy(t.values[i+1:], t.values[i:])
+		t.values[i] = value
+	}
+	set[key] = struct{}{}
+}
+
+func (t *tagIndex) remove(value, key string) {
+	set, ok := t.keys[value]
+	if !ok {
+		return
+	}
+	delete(set, key)
+	if len(set) == 0 {
+		delete(t.keys, value)
+		i := sort.SearchStrings(t.values, value)
+		t.values = append(t.values[:i], t.values[i+1:]...)
+	}
+}
+
+// match adds the keys tagged with value (or any tag with its prefix) to out.
+func (t *tagIndex) match(value string, out map[string]struct{}) {
+	if !strings.HasSuffix(value, "*") {
+		for key := range t.keys[value] {
+			out[key] = struct{}{}
+		}
+		return
+	}
+	prefix := strings.TrimSuffix(value, "*")
+	for i := sort.SearchStrings(t.values, prefix); i < len(t.values) && strings.HasPrefix(t.values[i], prefix); i++ {
+		for key := range t.keys[t.values[i]] {
+			out[key] = struct{}{}
+		}
+	}
+}
+
+// indexRegistry holds the store's indexes. Hooks run on every write, so
+// readers load an immutable slice instead of taking a lock; create and drop
+// copy it under mu.
+type indexRegistry struct {
+	mu      sync.Mutex
+	indexes atomic.Pointer[[]*Index]
+}
+
+func (r *indexRegistry) list() []*Index {
+	if p := r.indexes.Load(); p != nil {
+		return *p
+	}
+	return nil
+}
+
+func (r *indexRegistry) get(name string) *Index {
+	for _, idx := range r.list() {
+		if idx.schema.Name == name {
+			return idx
+		}
+	}
+	return nil
+}
+
+func (idx *Index) covers(key string) bool {
+	if len(idx.schema.Prefixes) == 0 {
+		return true
+	}
+	for _, prefix := range idx.schema.Prefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// indexHash re-indexes the hash at key after a write.
+// Must be called while holding the SHARD'S write lock.
+func (s *KVStore) indexHash(key string, hash map[string]string) {
+	for _, idx := range s.indexes.list() {
+		if idx.covers(key) {
+			idx.mu.Lock()
+			idx.removeFilters(key)
+			idx.add(key, hash)
+			idx.mu.Unlock()
+		}
+	}
+}
+
+// unindexHash drops key from every index.
+// Must be called while holding the SHARD'S write lock.
+func (s *KVStore) unindexHash(key string) {
+	for _, idx := range s.indexes.list() {
+		if idx.covers(key) {
+			idx.mu.Lock()
+			idx.remove(key)
+			idx.mu.Unlock()
+		}
+	}
+}
+
+// add indexes the schema fields of hash. Fields that are missing or do
+// not parse are skipped. Must be called with idx.mu held.
+func (idx *Index) add(key string, hash map[string]string) {
+	doc := make(map[string][]string)
+	for name, field := range idx.fields {
+		value, ok := hash[name]
+		if field.Type == IndexVector {
+			// Replaced in place, so an unchanged vector is not re-inserted
+			if vec, err := decodeVector(value, field.Vector.Dim); ok && err == nil {
+				idx.vectors[name].add(key, vec)
+			} else {
+				idx.vectors[name].remove(key)
+			}
+			continue
+		}
+		if !ok {
+			continue
+		}
+		switch field.Type {
+		case IndexTag:
+			var tags []string
+			for _, tag := range strings.Split(value, ",") {
+				if tag = strings.TrimSpace(tag); tag != "" {
+					idx.tags[name].add(tag, key)
+					tags = append(tags, tag)
+				}
+			}
+			doc[name] = tags
+		case IndexNumeric:
+			if f, err := strconv.ParseFloat(value, 64); err == nil && !math.IsNaN(f) {
+				idx.numeric[name].add(key, f)
+			}
+		}
+	}
+	idx.docs[key] = doc
+}
+
+// remove drops key from the index. Must be called with idx.mu held.
+func (idx *Index) remove(key string) {
+	idx.removeFilters(key)
+	for _, vi := range idx.vectors {
+		vi.remove(key)
+	}
+}
+
+// removeFilters drops key from the tag and numeric fields.
+// Must be called with idx.mu held.
+func (idx *Index) removeFilters(key string) {
+	doc, ok := idx.docs[key]
+	if !ok {
+		return
+	}
+	for name, tags := range doc {
+		for _, tag := range tags {
+			idx.tags[name].remove(tag, key)
+		}
+	}
+	for _, zset := range idx.numeric {
+		zset.remove(key)
+	}
+	delete(idx.docs, key)
+}
+
+// CreateIndex declares a secondary index and indexes the hashes that
+// already exist under its prefixes.
+func (s *KVStore) CreateIndex(schema IndexSchema) error {
+	if schema.Name == "" || len(schema.Fields) == 0 {
+		return fmt.Errorf("ERR index needs a name and at least one field")
+	}
+	idx := &Index{
+		schema:  schema,
+		fields:  make(map[string]IndexField),
+		docs:    make(map[string]map[string][]string),
+		tags:    make(map[string]*tagIndex),
+		numeric: make(map[string]*SortedSet),
+		vectors: make(map[string]vectorIndex),
+	}
+	for _, f := range schema.Fields {
+		if _, dup := idx.fields[f.Name]; dup {
+			return fmt.Errorf("ERR Duplicate field in schema - %s", f.Name)
+		}
+		idx.fields[f.Name] = f
+		switch f.Type {
+		case IndexTag:
+			idx.tags[f.Name] = &tagIndex{keys: make(map[string]map[string]struct{})}
+		case IndexNumeric:
+			idx.numeric[f.Name] = newSortedSet()
+		case IndexVector:
+			vi, err := newVectorIndex(f.Vector)
+			if err != nil {
+				return err
+			}
+			idx.vectors[f.Name] = vi
+		default:
+			return fmt.Errorf("ERR unknown field type for '%s'", f.Name)
+		}
+	}
+
+	s.indexes.mu.Lock()
+	if s.indexes.get(schema.Name) != nil {
+		s.indexes.mu.Unlock()
+		return ErrIndexExists
+	}
+	updated := append(append([]*Index{}, s.indexes.list()...), idx)
+	s.indexes.indexes.Store(&updated)
+	s.indexes.mu.Unlock()
+
+	// The index is registered first so that concurrent writes keep it up to
+	// date; the backfill reads each hash under its shard lock, so whichever
+	// of the two runs last sees the latest value.
+	for _, shard := range s.shards {
+		shard.mu.RLock()
+		idx.mu.Lock()
+		for key, entry := range shard.data {
+			if hash, ok := entry.Value.(map[string]string); ok && !entry.isExpired() && idx.covers(key) {
+				idx.remove(key)
+				idx.add(key, hash)
+			}
+		}
+		idx.mu.Unlock()
+		shard.mu.RUnlock()
+	}
+	return nil
+}
+
+// DropIndex removes an index. The indexed hashes are kept.
+func (s *KVStore) DropIndex(name string) error {
+	s.indexes.mu.Lock()
+	defer s.indexes.mu.Unlock()
+
+	var kept []*Index
+	for _, idx := range s.indexes.list() {
+		if idx.schema.Name != name {
+			kept = append(kept, idx)
+		}
+	}
+	if len(kept) == len(s.indexes.list()) {
+		return ErrNoIndex
+	}
+	s.indexes.indexes.Store(&kept)
+	return nil
+}
+
+// Search returns the hashes matching every clause, ordered by key. An empty
+// clause list matches every indexed hash. offset and limit select the page;
+// a limit of 0 returns only the total.
+func (s *KVStore) Search(index string, clauses []QueryClause, offset, limit int) (SearchResult, error) {
+	idx := s.indexes.get(index)
+	if idx == nil {
+		return SearchResult{}, ErrNoIndex
+	}
+
+	idx.mu.RLock()
+	keys, err := idx.match(clauses)
+	idx.mu.RUnlock()
+	if err != nil {
+		return SearchResult{}, err
+	}
+	sort.Strings(keys)
+
+	result := SearchResult{Total: len(keys)}
+	if offset < 0 || offset >= len(keys) || limit <= 0 {
+		return result, nil
+	}
+	keys = keys[offset:]
+	if len(keys) > limit {
+		keys = keys[:limit]
+	}
+	result.Docs = s.loadDocs(keys)
+	return result, nil
+}
+
+// loadDocs fetches the hashes for keys. The index lock is not held here, so
+// a hash deleted since the query simply drops out of the page.
+func (s *KVStore) loadDocs(keys []string) []SearchDoc {
+	docs := make([]SearchDoc, 0, len(keys))
+	for _, key := range keys {
+		fields, ok, err := s.HGetAll(key)
+		if !ok || err != nil {
+			continue
+		}
+		docs = append(docs, SearchDoc{Key: key, Fields: fields})
+	}
+	return docs
+}
+
+// match returns the keys matching every clause. Must be called with idx.mu held.
+func (idx *Index) match(clauses []QueryClause) ([]string, error) {
+	var result map[string]struct{}
+	if len(clauses) == 0 {
+		result = make(map[string]struct{}, len(idx.docs))
+		for key := range idx.docs {
+			result[key] = struct{}{}
+		}
+	}
+
+	for _, c := range clauses {
+		field, ok := idx.fields[c.Field]
+		if !ok {
+			return nil, fmt.Errorf("ERR Unknown field '%s'", c.Field)
+		}
+		matched := make(map[string]struct{})
+		switch field.Type {
+		case IndexTag:
+			for _, v := range c.Values {
+				idx.tags[c.Field].match(v, matched)
+			}
+		case IndexNumeric:
+			// rangeByScore is half-open, so nudge the bounds to the next float
+			min, max := c.Min, math.Nextafter(c.Max, math.Inf(1))
+			if c.MinExclusive {
+				min = math.Nextafter(c.Min, math.Inf(1))
+			}
+			if c.MaxExclusive {
+				max = c.Max
+			}
+			idx.numeric[c.Field].rangeByScore(min, max, func(key string, _ float64) bool {
+				matched[key] = struct{}{}
+				return true
+			})
+		default:
+			return nil, fmt.Errorf("ERR field '%s' cannot be used in a filter", c.Field)
+		}
+
+		if result == nil {
+			result = matched
+			continue
+		}
+		for key := range result {
+			if _, ok := matched[key]; !ok {
+				delete(result, key)
+			}
+		}
+	}
+
+	keys := make([]string, 0, len(result))
+	for key := range result {
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// ParseQuery parses the FT.SEARCH query subset: '*' for every document, or
+// space-separated clauses that must all match:
+//
+//	@field:{value}          exact tag
+//	@field:{a | b}          any of several tags
+//	@field:{pre*}           tag prefix
+//	@field:[min max]        numeric range; '(' before a bound excludes it,
+//	                        -inf and +inf are open bounds
+func ParseQuery(query string) ([]QueryClause, error) {
+	query = strings.TrimSpace(query)
+	var clauses []QueryClause
+	if query == "*" || query == "" {
+		return clauses, nil
+	}
+
+	rest := query
+	for rest != "" {
+		if !strings.HasPrefix(rest, "@") {
+			return nil, fmt.Errorf("ERR Syntax error in query '%s'", query)
+		}
+		colon := strings.IndexByte(rest, ':')
+		if colon < 0 || colon+1 >= len(rest) {
+			return nil, fmt.Errorf("ERR Syntax error in query '%s'", query)
+		}
+		c := QueryClause{Field: rest[1:colon]}
+		open := rest[colon+1]
+		var close byte
+		switch open {
+		case '{':
+			close = '}'
+		case '[':
+			close = ']'
+		default:
+			return nil, fmt.Errorf("ERR Syntax error in query '%s'", query)
+		}
+		end := strings.IndexByte(rest, close)
+		if end < 0 {
+			return nil, fmt.Errorf("ERR Syntax error in query '%s'", query)
+		}
+		body := rest[colon+2 : end]
+		rest = strings.TrimSpace(rest[end+1:])
+
+		if open == '{' {
+			for _, v := range strings.Split(body, "|") {
+				if v = strings.TrimSpace(v); v != "" {
+					c.Values = append(c.Values, v)
+				}
+			}
+			if len(c.Values) == 0 {
+				return nil, fmt.Errorf("ERR Syntax error in query '%s'", query)
+			}
+		} else {
+			bounds := strings.Fields(body)
+			if len(bounds) != 2 {
+				return nil, fmt.Errorf("ERR Syntax error in query '%s'", query)
+			}
+			var err1, err2 error
+			c.Min, c.MinExclusive, err1 = parseQueryBound(bounds[0])
+			c.Max, c.MaxExclusive, err2 = parseQueryBound(bounds[1])
+			if err1 != nil || err2 != nil {
+				return nil, fmt.Errorf("ERR Bad numeric range in query '%s'", query)
+			}
+		}
+		clauses = append(clauses, c)
+	}
+	return clauses, nil
+}
+
+func parseQueryBound(s string) (float64, bool, error) {
+	exclusive := strings.HasPrefix(s, "(")
+	s = strings.TrimPrefix(s, "(")
+	switch strings.ToLower(s) {
+	case "-inf":
+		return math.Inf(-1), exclusive, nil
+	case "+inf", "inf":
+		return math.Inf(1), exclusive, nil
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	return f, exclusive, err
+}
@@ -0,0 +1,178 @@
+package core
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func sessionIndex(t *testing.T, store *KVStore) {
+	t.Helper()
+	err := store.CreateIndex(IndexSchema{
+		Name:     "sessions",
+		Prefixes: []string{"session:"},
+		Fields: []IndexField{
+			{Name: "user_id", Type: IndexTag},
+			{Name: "age", Type: IndexNumeric},
+		},
+	})
+	if err != nil {
+		t.Fatalf("CreateIndex() error = %v", err)
+	}
+}
+
+func searchKeys(t *testing.T, store *KVStore, query string, offset, limit int) (int, []string) {
+	t.Helper()
+	clauses, err := ParseQuery(query)
+	if err != nil {
+		t.Fatalf("ParseQuery(%q) error = %v", query, err)
+	}
+	result, err := store.Search("sessions", clauses, offset, limit)
+	if err != nil {
+		t.Fatalf("Search(%q) error = %v", query, err)
+	}
+	keys := make([]string, len(result.Docs))
+	for i, doc := range result.Docs {
+		keys[i] = doc.Key
+	}
+	return result.Total, keys
+}
+
+func TestIndexQueries(t *testing.T) {
+	store := NewKVStore()
+	// Existing hashes are backfilled when the index is created
+	store.HSet("session:1", "user_id", "alice")
+	store.HSet("session:1", "age", "30")
+	sessionIndex(t, store)
+
+	store.HSet("session:2", "user_id", "bob")
+	store.HSet("session:2", "age", "25")
+	store.HSet("session:3", "user_id", "alfred,admin")
+	store.HSet("session:3", "age", "41")
+	store.HSet("other:1", "user_id", "alice")
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"*", []string{"session:1", "session:2", "session:3"}},
+		{"@user_id:{alice}", []string{"session:1"}},
+		{"@user_id:{alice | bob}", []string{"session:1", "session:2"}},
+		{"@user_id:{admin}", []string{"session:3"}},
+		{"@user_id:{al*}", []string{"session:1", "session:3"}},
+		{"@age:[25 30]", []string{"session:1", "session:2"}},
+		{"@age:[(25 +inf]", []string{"session:1", "session:3"}},
+		{"@age:[-inf (30]", []string{"session:2"}},
+		{"@user_id:{al*} @age:[35 50]", []string{"session:3"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			total, keys := searchKeys(t, store, tt.query, 0, 10)
+			if total != len(tt.want) || fmt.Sprint(keys) != fmt.Sprint(tt.want) {
+				t.Errorf("Search(%q) = %d %v, want %v", tt.query, total, keys, tt.want)
+			}
+		})
+	}
+
+	clauses, _ := ParseQuery("@user_id:{bob}")
+	result, _ := store.Search("sessions", clauses, 0, 10)
+	if result.Docs[0].Fields["age"] != "25" {
+		t.Errorf("Search() doc fields = %v, want age 25", result.Docs[0].Fields)
+	}
+}
+
+func TestIndexPagination(t *testing.T) {
+	store := NewKVStore()
+	sessionIndex(t, store)
+	for i := 0; i < 25; i++ {
+		store.HSet(fmt.Sprintf("session:%02d", i), "user_id", "same")
+	}
+
+	total, keys := searchKeys(t, store, "@user_id:{same}", 20, 10)
+	if total != 25 || len(keys) != 5 || keys[0] != "session:20" {
+		t.Errorf("Search(LIMIT 20 10) = %d %v, want 25 total and 5 keys from session:20", total, keys)
+	}
+	total, keys = searchKeys(t, store, "@user_id:{same}", 0, 0)
+	if total != 25 || len(keys) != 0 {
+		t.Errorf("Search(LIMIT 0 0) = %d %v, want only the total", total, keys)
+	}
+}
+
+func TestIndexFollowsWrites(t *testing.T) {
+	store := NewKVStore()
+	sessionIndex(t, store)
+	store.HSet("session:1", "user_id", "alice")
+	store.HSet("session:2", "user_id", "alice")
+	store.HSet("session:3", "user_id", "alice")
+	store.HSet("session:4", "user_id", "alice")
+
+	store.HSet("session:1", "user_id", "carol") // Update
+	store.HDel("session:2", "user_id")          // Field removed
+	store.Delete("session:3")                   // Key removed
+	store.Set("session:4", "plain string", 0)   // Type replaced
+
+	if total, keys := searchKeys(t, store, "@user_id:{alice}", 0, 10); total != 0 {
+		t.Errorf("Search(alice) = %v, want no matches", keys)
+	}
+	if _, keys := searchKeys(t, store, "@user_id:{carol}", 0, 10); len(keys) != 1 {
+		t.Errorf("Search(carol) = %v, want [session:1]", keys)
+	}
+}
+
+func TestIndexBitOpOverwrite(t *testing.T) {
+	store := NewKVStore()
+	sessionIndex(t, store)
+	store.HSet("session:1", "user_id", "alice")
+	store.Set("src", "x", 0)
+
+	if _, err := store.BitOp(BitOr, "session:1", "src"); err != nil {
+		t.Fatalf("BitOp() error = %v", err)
+	}
+	if total, keys := searchKeys(t, store, "@user_id:{alice}", 0, 10); total != 0 {
+		t.Errorf("Search() after BITOP = %d matches %v, want 0", total, keys)
+	}
+}
+
+func TestIndexExpiry(t *testing.T) {
+	store := NewKVStore()
+	sessionIndex(t, store)
+	store.HSet("session:1", "user_id", "alice")
+
+	shard := store.getShard("session:1")
+	shard.mu.Lock()
+	entry := shard.data["session:1"]
+	entry.ExpiresAt = time.Now().Add(-time.Second).UnixNano()
+	shard.data["session:1"] = entry
+	shard.mu.Unlock()
+
+	store.sampleAndCleanShard(shard)
+	if total, _ := searchKeys(t, store, "@user_id:{alice}", 0, 10); total != 0 {
+		t.Errorf("Search() after expiry = %d matches, want 0", total)
+	}
+}
+
+func TestIndexErrors(t *testing.T) {
+	store := NewKVStore()
+	sessionIndex(t, store)
+
+	if err := store.CreateIndex(IndexSchema{Name: "sessions", Fields: []IndexField{{Name: "x"}}}); err != ErrIndexExists {
+		t.Errorf("CreateIndex() duplicate error = %v, want ErrIndexExists", err)
+	}
+	if _, err := store.Search("nope", nil, 0, 10); err != ErrNoIndex {
+		t.Errorf("Search() unknown index error = %v, want ErrNoIndex", err)
+	}
+	if _, err := store.Search("sessions", []QueryClause{{Field: "nope", Values: []string{"x"}}}, 0, 10); err == nil {
+		t.Error("Search() on unknown field should error")
+	}
+	for _, q := range []string{"alice", "@user_id:alice", "@age:[1]", "@age:[a b]", "@user_id:{}"} {
+		if _, err := ParseQuery(q); err == nil {
+			t.Errorf("ParseQuery(%q) should error", q)
+		}
+	}
+	if err := store.DropIndex("sessions"); err != nil {
+		t.Fatalf("DropIndex() error = %v", err)
+	}
+	if err := store.DropIndex("sessions"); err != ErrNoIndex {
+		t.Errorf("DropIndex() twice error = %v, want ErrNoIndex", err)
+	}
+}
@@ -0,0 +1,562 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ErrJSONNoKey is returned when modifying a JSON document that does not exist.
+var ErrJSONNoKey = fmt.Errorf("ERR could not perform this operation on a key that doesn't exist")
+
+// JSONDocument is a parsed JSON value stored as an Entry value. The tree is
+// built from encoding/json types (map[string]interface{}, []interface{},
+// json.Number, string, bool and nil) so numbers keep their exact text until
+// they are modified. Object keys are serialized in sorted order.
+type JSONDocument struct {
+	root interface{}
+}
+
+// JSONSetOptions mirrors the NX and XX flags of JSON.SET.
+type JSONSetOptions struct {
+	NX bool // Only set paths that do not exist yet
+	XX bool // Only set paths that already exist
+}
+
+// jsonStep is one segment of a parsed path: an object key, an array index
+// or a wildcard matching every child.
+type jsonStep struct {
+	key      string
+	index    int
+	isIndex  bool
+	wildcard bool
+}
+
+// jsonPath is a parsed path expression. Paths starting with '$' are JSONPath
+// and may match several values; anything else is the legacy syntax ('.',
+// '.a.b', 'a[0]'), which always refers to a single value.
+type jsonPath struct {
+	raw    string
+	steps  []jsonStep
+	legacy bool
+}
+
+// parseJSONPath supports the JSONPath subset $, .name, ['name'], [index]
+// (negative indexes count from the end), .* and [*].
+func parseJSONPath(path string) (jsonPath, error) {
+	p := jsonPath{raw: path}
+	rest := path
+	switch {
+	case strings.HasPrefix(rest, "$"):
+		rest = rest[1:]
+	case rest == ".":
+		p.legacy = true
+		return p, nil
+	default:
+		p.legacy = true
+		if !strings.HasPrefix(rest, ".") && !strings.HasPrefix(rest, "[") {
+			rest = "." + rest
+		}
+	}
+
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			name := rest[:end]
+			if name == "" {
+				return p, fmt.Errorf("ERR invalid JSON path '%s'", path)
+			}
+			if name == "*" {
+				p.steps = append(p.steps, jsonStep{wildcard: true})
+			} else {
+				p.steps = append(p.steps, jsonStep{key: name})
+			}
+			rest = rest[end:]
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return p, fmt.Errorf("ERR invalid JSON path '%s'", path)
+			}
+			inner := strings.TrimSpace(rest[1:end])
+			switch {
+			case inner == "*":
+				p.steps = append(p.steps, jsonStep{wildcard: true})
+			case len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0]:
+				p.steps = append(p.steps, jsonStep{key: inner[1 : len(inner)-1]})
+			default:
+				idx, err := strconv.Atoi(inner)
+				if err != nil {
+					return p, fmt.Errorf("ERR invalid JSON path '%s'", path)
+				}
+				p.steps = append(p.steps, jsonStep{index: idx, isIndex: true})
+			}
+			rest = rest[end+1:]
+		default:
+			return p, fmt.Errorf("ERR invalid JSON path '%s'", path)
+		}
+	}
+	return p, nil
+}
+
+// jsonWalk calls fn for every node matched by steps. set replaces the
+// matched node in its parent.
+func jsonWalk(node interface{}, steps []jsonStep, set func(interface{}), fn func(node interface{}, set func(interface{}))) {
+	if len(steps) == 0 {
+		fn(node, set)
+		return
+	}
+	step, rest := steps[0], steps[1:]
+	switch n := node.(type) {
+	case map[string]interface{}:
+		if step.wildcard {
+			for _, k := range sortedJSONKeys(n) {
+				k := k
+				jsonWalk(n[k], rest, func(v interface{}) { n[k] = v }, fn)
+			}
+		} else if !step.isIndex {
+			if child, ok := n[step.key]; ok {
+				jsonWalk(child, rest, func(v interface{}) { n[step.key] = v }, fn)
+			}
+		}
+	case []interface{}:
+		if step.wildcard {
+			for i := range n {
+				i := i
+				jsonWalk(n[i], rest, func(v interface{}) { n[i] = v }, fn)
+			}
+		} else if i, ok := jsonIndex(step, len(n)); ok {
+			jsonWalk(n[i], rest, func(v interface{}) { n[i] = v }, fn)
+		}
+	}
+}
+
+func jsonIndex(step jsonStep, length int) (int, bool) {
+	if !step.isIndex {
+		return 0, false
+	}
+	i := step.index
+	if i < 0 {
+		i += length
+	}
+	return i, i >= 0 && i < length
+}
+
+func sortedJSONKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// parseJSONValue parses a single JSON value, rejecting trailing data.
+func parseJSONValue(data string) (interface{}, error) {
+	dec := json.NewDecoder(strings.NewReader(data))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, fmt.Errorf("ERR invalid JSON: %v", err)
+	}
+	if dec.More() {
+		return nil, fmt.Errorf("ERR invalid JSON: trailing data")
+	}
+	return v, nil
+}
+
+// marshalJSON serializes v without escaping HTML characters.
+func marshalJSON(v interface{}) string {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.Encode(v) // The tree only holds encodable types
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// cloneJSON deep-copies a tree so that one parsed value can be assigned to
+// several matches without sharing mutable containers.
+func cloneJSON(v interface{}) interface{} {
+	switch n := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(n))
+		for k, child := range n {
+			m[k] = cloneJSON(child)
+		}
+		return m
+	case []interface{}:
+		a := make([]interface{}, len(n))
+		for i, child := range n {
+			a[i] = cloneJSON(child)
+		}
+		return a
+	}
+	return v
+}
+
+// addJSONNumbers adds two numbers, staying in integers when both are integral.
+func addJSONNumbers(a, b json.Number) (json.Number, error) {
+	ai, errA := a.Int64()
+	bi, errB := b.Int64()
+	if errA == nil && errB == nil {
+		sum := ai + bi
+		// On overflow the sum wraps and moves the wrong way; fall back to floats
+		if (sum > ai) == (bi > 0) {
+			return json.Number(strconv.FormatInt(sum, 10)), nil
+		}
+	}
+	af, errA := a.Float64()
+	bf, errB := b.Float64()
+	if errA != nil || errB != nil {
+		return "", fmt.Errorf("ERR value is not a number")
+	}
+	sum := af + bf
+	if math.IsInf(sum, 0) || math.IsNaN(sum) {
+		return "", fmt.Errorf("ERR result is not a number or infinity")
+	}
+	out, _ := json.Marshal(sum)
+	return json.Number(out), nil
+}
+
+// JSONSet stores value (a JSON text) at path. New keys must be created at
+// the root path. It returns false if NX/XX prevented every update or the
+// path's parent does not exist.
+func (s *KVStore) JSONSet(key, path, value string, opts JSONSetOptions) (bool, error) {
+	if opts.NX && opts.XX {
+		return false, fmt.Errorf("ERR syntax error")
+	}
+	p, err := parseJSONPath(path)
+	if err != nil {
+		return false, err
+	}
+	v, err := parseJSONValue(value)
+	if err != nil {
+		return false, err
+	}
+
+	shard := s.getShard(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	entry, exists := s.liveEntry(shard, key)
+	if !exists {
+		if len(p.steps) > 0 {
+			return false, fmt.Errorf("ERR new objects must be created at the root")
+		}
+		if opts.XX {
+			return false, nil
+		}
+		if err := s.createEntry(shard, key, &JSONDocument{root: v}); err != nil {
+			return false, err
+		}
+		s.notify(notifyModule, "json.set", key)
+		return true, nil
+	}
+	doc, ok := entry.Value.(*JSONDocument)
+	if !ok {
+		return false, ErrWrongType
+	}
+	if len(p.steps) == 0 {
+		if opts.NX {
+			return false, nil
+		}
+		doc.root = v
+		s.notify(notifyModule, "json.set", key)
+		return true, nil
+	}
+
+	updated := 0
+	assign := func(exists bool, set func(interface{})) {
+		if (exists && opts.NX) || (!exists && opts.XX) {
+			return
+		}
+		if updated == 0 {
+			set(v)
+		} else {
+			set(cloneJSON(v))
+		}
+		updated++
+	}
+
+	last := p.steps[len(p.steps)-1]
+	jsonWalk(doc.root, p.steps[:len(p.steps)-1], func(v interface{}) { doc.root = v }, func(parent interface{}, _ func(interface{})) {
+		switch n := parent.(type) {
+		case map[string]interface{}:
+			if last.wildcard {
+				for _, k := range sortedJSONKeys(n) {
+					k := k
+					assign(true, func(v interface{}) { n[k] = v })
+				}
+			} else if !last.isIndex {
+				_, exists := n[last.key]
+				assign(exists, func(v interface{}) { n[last.key] = v })
+			}
+		case []interface{}:
+			if last.wildcard {
+				for i := range n {
+					i := i
+					assign(true, func(v interface{}) { n[i] = v })
+				}
+			} else if i, ok := jsonIndex(last, len(n)); ok {
+				assign(true, func(v interface{}) { n[i] = v })
+			}
+		}
+	})
+	if updated > 0 {
+		s.notify(notifyModule, "json.set", key)
+	}
+	return updated > 0, nil
+}
+
+// JSONGet returns the values at paths serialized as JSON. With no paths the
+// whole document is returned. A JSONPath yields an array of all matches; a
+// legacy path yields its single value. Several paths yield an object keyed
+// by path. ok is false if the key does not exist.
+func (s *KVStore) JSONGet(key string, paths ...string) (string, bool, error) {
+	parsed := make([]jsonPath, 0, len(paths))
+	for _, path := range paths {
+		p, err := parseJSONPath(path)
+		if err != nil {
+			return "", false, err
+		}
+		parsed = append(parsed, p)
+	}
+	if len(parsed) == 0 {
+		parsed = append(parsed, jsonPath{raw: ".", legacy: true})
+	}
+
+	shard := s.getShard(key)
+	shard.mu.RLock()
+	defer shard.mu.RUnlock()
+
+	entry, exists := shard.data[key]
+	if !exists || entry.isExpired() {
+		return "", false, nil
+	}
+	doc, ok := entry.Value.(*JSONDocument)
+	if !ok {
+		return "", true, ErrWrongType
+	}
+
+	results := make(map[string]interface{}, len(parsed))
+	for _, p := range parsed {
+		matches := []interface{}{}
+		jsonWalk(doc.root, p.steps, nil, func(node interface{}, _ func(interface{})) {
+			matches = append(matches, node)
+		})
+		if !p.legacy {
+			results[p.raw] = matches
+			continue
+		}
+		if len(matches) == 0 {
+			return "", true, fmt.Errorf("ERR Path '%s' does not exist", p.raw)
+		}
+		results[p.raw] = matches[0]
+	}
+
+	if len(parsed) == 1 {
+		return marshalJSON(results[parsed[0].raw]), true, nil
+	}
+	return marshalJSON(results), true, nil
+}
+
+// JSONDel removes the values at path and returns how many were removed.
+// Deleting the root removes the key.
+func (s *KVStore) JSONDel(key, path string) (int, error) {
+	p, err := parseJSONPath(path)
+	if err != nil {
+		return 0, err
+	}
+
+	shard := s.getShard(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	entry, exists := s.liveEntry(shard, key)
+	if !exists {
+		return 0, nil
+	}
+	doc, ok := entry.Value.(*JSONDocument)
+	if !ok {
+		return 0, ErrWrongType
+	}
+	if len(p.steps) == 0 {
+		s.removeEntry(shard, key)
+		s.notify(notifyModule, "json.del", key)
+		return 1, nil
+	}
+
+	deleted := 0
+	last := p.steps[len(p.steps)-1]
+	jsonWalk(doc.root, p.steps[:len(p.steps)-1], func(v interface{}) { doc.root = v }, func(parent interface{}, set func(interface{})) {
+		switch n := parent.(type) {
+		case map[string]interface{}:
+			if last.wildcard {
+				deleted += len(n)
+				for k := range n {
+					delete(n, k)
+				}
+			} else if _, ok := n[last.key]; ok && !last.isIndex {
+				delete(n, last.key)
+				deleted++
+			}
+		case []interface{}:
+			if last.wildcard {
+				deleted += len(n)
+				set([]interface{}{})
+			} else if i, ok := jsonIndex(last, len(n)); ok {
+				kept := make([]interface{}, 0, len(n)-1)
+				kept = append(append(kept, n[:i]...), n[i+1:]...)
+				set(kept)
+				deleted++
+			}
+		}
+	})
+	if deleted > 0 {
+		s.notify(notifyModule, "json.del", key)
+	}
+	return deleted, nil
+}
+
+// JSONNumIncrBy adds by to the numbers at path and returns the new values
+// serialized as JSON: an array (with null for non-numbers) for a JSONPath,
+// or the single new value for a legacy path.
+func (s *KVStore) JSONNumIncrBy(key, path, by string) (string, error) {
+	p, err := parseJSONPath(path)
+	if err != nil {
+		return "", err
+	}
+	v, err := parseJSONValue(by)
+	if err != nil {
+		return "", err
+	}
+	delta, ok := v.(json.Number)
+	if !ok {
+		return "", fmt.Errorf("ERR value is not a number")
+	}
+
+	shard := s.getShard(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	doc, err := s.jsonForWrite(shard, key)
+	if err != nil {
+		return "", err
+	}
+
+	// Compute every sum before applying any, so an overflow leaves the
+	// document unchanged
+	var results []interface{}
+	var setters []func(interface{})
+	var walkErr error
+	jsonWalk(doc.root, p.steps, func(v interface{}) { doc.root = v }, func(node interface{}, set func(interface{})) {
+		num, ok := node.(json.Number)
+		if !ok {
+			results = append(results, nil)
+			setters = append(setters, nil)
+			return
+		}
+		sum, err := addJSONNumbers(num, delta)
+		if err != nil && walkErr == nil {
+			walkErr = err
+		}
+		results = append(results, sum)
+		setters = append(setters, set)
+	})
+	if walkErr != nil {
+		return "", walkErr
+	}
+	changed := false
+	for i, set := range setters {
+		if set != nil {
+			set(results[i])
+			changed = true
+		}
+	}
+	if changed {
+		s.notify(notifyModule, "json.numincrby", key)
+	}
+
+	if !p.legacy {
+		if results == nil {
+			results = []interface{}{}
+		}
+		return marshalJSON(results), nil
+	}
+	if len(results) == 0 || results[0] == nil {
+		return "", fmt.Errorf("WRONGTYPE Path '%s' does not exist or is not a number", path)
+	}
+	return marshalJSON(results[0]), nil
+}
+
+// JSONArrAppend appends values (JSON texts) to the arrays at path and
+// returns the new length of each, or -1 for matches that are not arrays.
+// A legacy path must match an array.
+func (s *KVStore) JSONArrAppend(key, path string, values ...string) ([]int, error) {
+	p, err := parseJSONPath(path)
+	if err != nil {
+		return nil, err
+	}
+	parsed := make([]interface{}, len(values))
+	for i, value := range values {
+		if parsed[i], err = parseJSONValue(value); err != nil {
+			return nil, err
+		}
+	}
+
+	shard := s.getShard(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	doc, err := s.jsonForWrite(shard, key)
+	if err != nil {
+		return nil, err
+	}
+
+	lengths := []int{}
+	jsonWalk(doc.root, p.steps, func(v interface{}) { doc.root = v }, func(node interface{}, set func(interface{})) {
+		arr, ok := node.([]interface{})
+		if !ok {
+			lengths = append(lengths, -1)
+			return
+		}
+		for _, v := range parsed {
+			arr = append(arr, cloneJSON(v))
+		}
+		set(arr)
+		lengths = append(lengths, len(arr))
+	})
+	for _, n := range lengths {
+		if n >= 0 {
+			s.notify(notifyModule, "json.arrappend", key)
+			break
+		}
+	}
+
+	if p.legacy && (len(lengths) == 0 || lengths[0] < 0) {
+		return nil, fmt.Errorf("WRONGTYPE Path '%s' does not exist or is not an array", path)
+	}
+	return lengths, nil
+}
+
+// jsonForWrite returns the document at key for modification.
+// Must be called while holding the shard's write lock.
+func (s *KVStore) jsonForWrite(shard *Shard, key string) (*JSONDocument, error) {
+	entry, exists := s.liveEntry(shard, key)
+	if !exists {
+		return nil, ErrJSONNoKey
+	}
+	doc, ok := entry.Value.(*JSONDocument)
+	if !ok {
+		return nil, ErrWrongType
+	}
+	return doc, nil
+}
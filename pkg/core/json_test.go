@@ -0,0 +1,191 @@
+package core
+
+import (
+	"testing"
+)
+
+const sessionDoc = `{"user":"suhaan","visits":3,"score":1.5,"tags":["a","b"],"cart":[{"qty":1},{"qty":2}]}`
+
+func TestJSONSetAndGet(t *testing.T) {
+	store := NewKVStore()
+
+	if _, err := store.JSONSet("s", "$.user", `"x"`, JSONSetOptions{}); err == nil {
+		t.Error("JSONSet() on missing key at non-root path should error")
+	}
+	if ok, err := store.JSONSet("s", "$", sessionDoc, JSONSetOptions{}); !ok || err != nil {
+		t.Fatalf("JSONSet() = %v, %v", ok, err)
+	}
+
+	tests := []struct {
+		name  string
+		paths []string
+		want  string
+	}{
+		{"whole document", nil, `{"cart":[{"qty":1},{"qty":2}],"score":1.5,"tags":["a","b"],"user":"suhaan","visits":3}`},
+		{"jsonpath field", []string{"$.user"}, `["suhaan"]`},
+		{"legacy field", []string{".user"}, `"suhaan"`},
+		{"legacy without dot", []string{"visits"}, `3`},
+		{"bracket key", []string{"$['tags'][1]"}, `["b"]`},
+		{"negative index", []string{"$.tags[-1]"}, `["b"]`},
+		{"wildcard", []string{"$.cart[*].qty"}, `[1,2]`},
+		{"missing jsonpath", []string{"$.nope"}, `[]`},
+		{"multiple paths", []string{"$.user", "$.visits"}, `{"$.user":["suhaan"],"$.visits":[3]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok, err := store.JSONGet("s", tt.paths...)
+			if err != nil || !ok {
+				t.Fatalf("JSONGet() = %q, %v, %v", got, ok, err)
+			}
+			if got != tt.want {
+				t.Errorf("JSONGet() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	if _, _, err := store.JSONGet("s", ".nope"); err == nil {
+		t.Error("JSONGet() of missing legacy path should error")
+	}
+	if _, ok, _ := store.JSONGet("missing"); ok {
+		t.Error("JSONGet() on missing key ok = true, want false")
+	}
+}
+
+func TestJSONSetPaths(t *testing.T) {
+	store := NewKVStore()
+	store.JSONSet("s", "$", sessionDoc, JSONSetOptions{})
+
+	store.JSONSet("s", "$.cart[*].qty", `5`, JSONSetOptions{})
+	if got, _, _ := store.JSONGet("s", "$.cart"); got != `[[{"qty":5},{"qty":5}]]` {
+		t.Errorf("after wildcard set, cart = %s", got)
+	}
+
+	if ok, _ := store.JSONSet("s", "$.user", `"other"`, JSONSetOptions{NX: true}); ok {
+		t.Error("JSONSet(NX) on existing path = true, want false")
+	}
+	if ok, _ := store.JSONSet("s", "$.new", `{"a":<b>}`, JSONSetOptions{}); ok {
+		t.Error("JSONSet() with invalid JSON should not succeed")
+	}
+	if ok, _ := store.JSONSet("s", "$.html", `"<b>&"`, JSONSetOptions{XX: true}); ok {
+		t.Error("JSONSet(XX) on missing path = true, want false")
+	}
+	if ok, _ := store.JSONSet("s", "$.html", `"<b>&"`, JSONSetOptions{}); !ok {
+		t.Error("JSONSet() of new field = false, want true")
+	}
+	if got, _, _ := store.JSONGet("s", ".html"); got != `"<b>&"` {
+		t.Errorf("JSONGet(.html) = %s, want \"<b>&\"", got)
+	}
+	if ok, _ := store.JSONSet("s", "$.a.b", `1`, JSONSetOptions{}); ok {
+		t.Error("JSONSet() under a missing parent = true, want false")
+	}
+}
+
+func TestJSONDel(t *testing.T) {
+	store := NewKVStore()
+	store.JSONSet("s", "$", sessionDoc, JSONSetOptions{})
+
+	if n, _ := store.JSONDel("s", "$.tags[0]"); n != 1 {
+		t.Errorf("JSONDel($.tags[0]) = %d, want 1", n)
+	}
+	if got, _, _ := store.JSONGet("s", ".tags"); got != `["b"]` {
+		t.Errorf("tags after delete = %s, want [\"b\"]", got)
+	}
+	if n, _ := store.JSONDel("s", "$.cart[*].qty"); n != 2 {
+		t.Errorf("JSONDel($.cart[*].qty) = %d, want 2", n)
+	}
+	if n, _ := store.JSONDel("s", "$.nope"); n != 0 {
+		t.Errorf("JSONDel($.nope) = %d, want 0", n)
+	}
+	if n, _ := store.JSONDel("s", "$"); n != 1 {
+		t.Errorf("JSONDel($) = %d, want 1", n)
+	}
+	if _, ok, _ := store.JSONGet("s"); ok {
+		t.Error("key still exists after deleting the root")
+	}
+}
+
+func TestJSONNumIncrBy(t *testing.T) {
+	store := NewKVStore()
+	store.JSONSet("s", "$", sessionDoc, JSONSetOptions{})
+
+	tests := []struct {
+		path string
+		by   string
+		want string
+	}{
+		{"$.visits", "2", `[5]`},
+		{".visits", "-1", `4`},
+		{"$.score", "1", `[2.5]`},
+		{"$.visits", "0.5", `[4.5]`},
+		{"$.cart[*].qty", "10", `[11,12]`},
+		{"$.user", "1", `[null]`},
+	}
+	for _, tt := range tests {
+		got, err := store.JSONNumIncrBy("s", tt.path, tt.by)
+		if err != nil {
+			t.Fatalf("JSONNumIncrBy(%s, %s) error = %v", tt.path, tt.by, err)
+		}
+		if got != tt.want {
+			t.Errorf("JSONNumIncrBy(%s, %s) = %s, want %s", tt.path, tt.by, got, tt.want)
+		}
+	}
+
+	if _, err := store.JSONNumIncrBy("s", ".user", "1"); err == nil {
+		t.Error("JSONNumIncrBy() on legacy string path should error")
+	}
+	if _, err := store.JSONNumIncrBy("s", "$.score", "1e308"); err != nil {
+		t.Fatalf("JSONNumIncrBy() error = %v", err)
+	}
+	if _, err := store.JSONNumIncrBy("s", "$.score", "1e308"); err == nil {
+		t.Error("JSONNumIncrBy() overflowing to infinity should error")
+	}
+	if _, err := store.JSONNumIncrBy("missing", "$.x", "1"); err != ErrJSONNoKey {
+		t.Errorf("JSONNumIncrBy() on missing key error = %v, want ErrJSONNoKey", err)
+	}
+}
+
+func TestJSONArrAppend(t *testing.T) {
+	store := NewKVStore()
+	store.JSONSet("s", "$", sessionDoc, JSONSetOptions{})
+
+	lengths, err := store.JSONArrAppend("s", "$.tags", `"c"`, `{"d":1}`)
+	if err != nil || len(lengths) != 1 || lengths[0] != 4 {
+		t.Fatalf("JSONArrAppend() = %v, %v, want [4]", lengths, err)
+	}
+	if got, _, _ := store.JSONGet("s", ".tags"); got != `["a","b","c",{"d":1}]` {
+		t.Errorf("tags = %s", got)
+	}
+
+	lengths, _ = store.JSONArrAppend("s", "$.*", `0`)
+	// Sorted keys: cart, score, tags, user, visits
+	want := []int{3, -1, 5, -1, -1}
+	for i := range want {
+		if lengths[i] != want[i] {
+			t.Errorf("JSONArrAppend($.*) = %v, want %v", lengths, want)
+			break
+		}
+	}
+
+	if _, err := store.JSONArrAppend("s", ".user", `1`); err == nil {
+		t.Error("JSONArrAppend() on legacy non-array path should error")
+	}
+}
+
+func TestJSONWrongType(t *testing.T) {
+	store := NewKVStore()
+	store.Set("str", `{"user":"suhaan"}`, 0)
+	store.JSONSet("doc", "$", `{}`, JSONSetOptions{})
+
+	if _, err := store.JSONSet("str", "$", `{}`, JSONSetOptions{}); err != ErrWrongType {
+		t.Errorf("JSONSet() on string error = %v, want ErrWrongType", err)
+	}
+	if _, _, err := store.JSONGet("str"); err != ErrWrongType {
+		t.Errorf("JSONGet() on string error = %v, want ErrWrongType", err)
+	}
+	if _, _, err := store.Get("doc"); err != ErrWrongType {
+		t.Errorf("Get() on JSON document error = %v, want ErrWrongType", err)
+	}
+	if _, err := parseJSONPath("$..deep"); err == nil {
+		t.Error("parseJSONPath() with recursive descent should error")
+	}
+}
@@ -1,6 +1,7 @@
 package core
 
 import (
+	"bytes"
 	"fmt"
 	"strconv"
 	"sync/atomic"
@@ -10,14 +11,28 @@ import (
 // ErrMaxKeysExceeded is returned when the store has reached its key limit.
 var ErrMaxKeysExceeded = fmt.Errorf("ERR max number of keys exceeded")
 
+// ErrWrongType is returned when an operation targets a key holding another type.
+var ErrWrongType = fmt.Errorf("WRONGTYPE Operation against a key holding the wrong kind of value")
+
 // Set stores a key-value pair with an optional Time-To-Live (TTL).
 func (s *KVStore) Set(key string, value string, ttlSeconds int64) error {
+	return s.setString(key, value, ttlSeconds)
+}
+
+// setString stores a string value, held either as a string or, like a
+// bitmap, as a []byte the store owns.
+func (s *KVStore) setString(key string, value interface{}, ttlSeconds int64) error {
 	shard := s.getShard(key)
 	shard.mu.Lock()
 	defer shard.mu.Unlock()
 
-	_, exists := shard.data[key]
-	if !exists {
+	old, exists := shard.data[key]
+	if exists {
+		if _, isHash := old.Value.(map[string]string); isHash {
+			// The hash is replaced by a string, so it leaves every index
+			s.unindexHash(key)
+		}
+	} else {
 		// Strictly enforce atomic MaxKeys check
 		if s.MaxKeys > 0 {
 			currentKeys := atomic.LoadInt64(&s.keyCount)
@@ -39,18 +54,41 @@ func (s *KVStore) Set(key string, value string, ttlSeconds int64) error {
 		Value:     value,
 		ExpiresAt: expiresAt,
 	}
+	s.notify(notifyString, "set", key)
+	if expiresAt > 0 {
+		s.notify(notifyGeneric, "expire", key)
+	}
 	return nil
 }
 
 // Get retrieves a value by its key.
 func (s *KVStore) Get(key string) (string, bool, error) {
+	val, ok, err := s.getString(key, false)
+	if err != nil || !ok {
+		return "", ok, err
+	}
+	return val.(string), true, nil
+}
+
+// getString retrieves a string value. A value held as a []byte (a bitmap)
+// is mutated in place, so it is copied while the shard is still locked: to
+// a []byte if asBytes is set, otherwise to a string. A value held as a
+// string is returned as is.
+func (s *KVStore) getString(key string, asBytes bool) (interface{}, bool, error) {
 	shard := s.getShard(key)
 	shard.mu.RLock()
 	entry, ok := shard.data[key]
+	if b, isBytes := entry.Value.([]byte); isBytes {
+		if asBytes {
+			entry.Value = bytes.Clone(b)
+		} else {
+			entry.Value = string(b)
+		}
+	}
 	shard.mu.RUnlock()
 
 	if !ok {
-		return "", false, nil
+		return nil, false, nil
 	}
 
 	// Check for expiration
@@ -58,21 +96,36 @@ func (s *KVStore) Get(key string) (string, bool, error) {
 		shard.mu.Lock()
 		defer shard.mu.Unlock()
 
-		currentEntry, currentOk := shard.data[key]
-		if currentOk && currentEntry.ExpiresAt > 0 && time.Now().UnixNano() > currentEntry.ExpiresAt {
-			delete(shard.data, key)
-			shard.removeKey(key)
-			atomic.AddInt64(&s.keyCount, -1) // Decrement count
-		}
-		return "", false, nil
+		s.liveEntry(shard, key) // Deletes the entry if it is still expired
+		return nil, false, nil
 	}
 
-	strVal, isString := entry.Value.(string)
-	if !isString {
-		return "", true, fmt.Errorf("WRONGTYPE Operation against a key holding the wrong kind of value")
+	switch entry.Value.(type) {
+	case string, []byte:
+		return entry.Value, true, nil
 	}
+	return nil, true, ErrWrongType
+}
+
+// SetBytes stores a binary-safe value under key.
+// The value is kept as a []byte, like a bitmap, without going through a
+// string. It is copied once, so the caller may reuse value as soon as
+// SetBytes returns.
+func (s *KVStore) SetBytes(key string, value []byte, ttlSeconds int64) error {
+	return s.setString(key, bytes.Clone(value), ttlSeconds)
+}
 
-	return strVal, true, nil
+// GetBytes retrieves a value as a byte slice.
+// The value is copied once, into a slice owned by the caller.
+func (s *KVStore) GetBytes(key string) ([]byte, bool, error) {
+	val, ok, err := s.getString(key, true)
+	if err != nil || !ok {
+		return nil, ok, err
+	}
+	if str, isString := val.(string); isString {
+		return []byte(str), true, nil
+	}
+	return val.([]byte), true, nil
 }
 
 // IncrBy atomically increments the integer value of a key by delta.
@@ -83,18 +136,16 @@ func (s *KVStore) IncrBy(key string, delta int64) (int64, error) {
 
 	entry, ok := shard.data[key]
 
-	if ok && entry.ExpiresAt > 0 && time.Now().UnixNano() > entry.ExpiresAt {
-		delete(shard.data, key)
-		shard.removeKey(key)
-		atomic.AddInt64(&s.keyCount, -1)
+	if ok && entry.isExpired() {
+		s.expireEntry(shard, key)
 		ok = false
 	}
 
 	var currentVal int64 = 0
 	if ok {
-		strVal, isString := entry.Value.(string)
+		strVal, isString := stringValue(entry.Value)
 		if !isString {
-			return 0, fmt.Errorf("WRONGTYPE Operation against a key holding the wrong kind of value")
+			return 0, ErrWrongType
 		}
 		var err error
 		currentVal, err = strconv.ParseInt(strVal, 10, 64)
@@ -117,6 +168,7 @@ func (s *KVStore) IncrBy(key string, delta int64) (int64, error) {
 	newVal := currentVal + delta
 	entry.Value = fmt.Sprintf("%d", newVal)
 	shard.data[key] = entry
+	s.notify(notifyString, "incrby", key)
 
 	return newVal, nil
 }
@@ -126,9 +178,21 @@ func (s *KVStore) Delete(key string) {
 	shard := s.getShard(key)
 	shard.mu.Lock()
 	defer shard.mu.Unlock()
-	if _, exists := shard.data[key]; exists {
-		delete(shard.data, key)
-		shard.removeKey(key)
-		atomic.AddInt64(&s.keyCount, -1)
+	if _, exists := s.liveEntry(shard, key); exists {
+		s.removeEntry(shard, key)
+		s.notify(notifyGeneric, "del", key)
+	}
+}
+
+// stringValue returns the payload of a string-typed value.
+// Strings touched by bit operations are kept as []byte so they can be
+// updated in place; callers must hold the shard lock.
+func stringValue(v interface{}) (string, bool) {
+	switch val := v.(type) {
+	case string:
+		return val, true
+	case []byte:
+		return string(val), true
 	}
+	return "", false
 }
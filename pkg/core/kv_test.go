@@ -164,3 +164,41 @@ func TestMaxKeysLimit(t *testing.T) {
 		t.Errorf("Set() error updating existing key = %v", err)
 	}
 }
+
+func TestSetBytesBinarySafe(t *testing.T) {
+	store := NewKVStore()
+
+	blob := []byte{0x1f, 0x8b, 0x00, '\r', '\n', '"', '\\', 0xff}
+	if err := store.SetBytes("blob", blob, 0); err != nil {
+		t.Fatalf("SetBytes() error = %v", err)
+	}
+
+	// Mutating the caller's buffer must not affect the stored value
+	blob[0] = 0
+
+	got, ok, err := store.GetBytes("blob")
+	if err != nil {
+		t.Fatalf("GetBytes() error = %v", err)
+	}
+	if !ok {
+		t.Fatal("GetBytes() ok = false, want true")
+	}
+	want := []byte{0x1f, 0x8b, 0x00, '\r', '\n', '"', '\\', 0xff}
+	if string(got) != string(want) {
+		t.Errorf("GetBytes() = %v, want %v", got, want)
+	}
+
+	// Nor must mutating the returned slice
+	got[0] = 0
+	if str, _, _ := store.Get("blob"); str != string(want) {
+		t.Errorf("Get() after changing GetBytes() result = %q, want %q", str, want)
+	}
+
+	// The value stays a []byte, which bit operations update in place
+	if _, err := store.SetBit("blob", 0, 1); err != nil {
+		t.Fatalf("SetBit() error = %v", err)
+	}
+	if got, _, _ := store.GetBytes("blob"); got[0] != 0x9f {
+		t.Errorf("GetBytes() after SetBit = %#x, want 0x9f", got[0])
+	}
+}
@@ -0,0 +1,98 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Keyspace notification classes, named after the characters Redis uses in
+// notify-keyspace-events.
+const (
+	notifyKeyspace uint32 = 1 << iota // K: __keyspace@0__:<key> carries the event
+	notifyKeyevent                    // E: __keyevent@0__:<event> carries the key
+	notifyGeneric                     // g: del, expire, restore
+	notifyString                      // $: set, incrby, setbit, pfadd
+	notifyHash                        // h: hset, hdel
+	notifyZSet                        // z: zadd (GEOADD)
+	notifyExpired                     // x: expired
+	notifyEvicted                     // e: evicted (the store never evicts, so unused)
+	notifyModule                      // d: json.*, ts.*, bf.*, cf.*, cms.* events
+
+	notifyAll = notifyGeneric | notifyString | notifyHash | notifyZSet | notifyExpired | notifyEvicted | notifyModule // A
+)
+
+var notifyFlagChars = []struct {
+	char byte
+	flag uint32
+}{
+	{'g', notifyGeneric},
+	{'$', notifyString},
+	{'h', notifyHash},
+	{'z', notifyZSet},
+	{'x', notifyExpired},
+	{'e', notifyEvicted},
+	{'d', notifyModule},
+	{'K', notifyKeyspace},
+	{'E', notifyKeyevent},
+}
+
+// SetNotifyKeyspaceEvents configures keyspace notifications using the
+// notify-keyspace-events syntax: K and/or E select the channel kinds and
+// g, $, h, z, x, e, d (or A for all of them) the event classes. An empty string
+// disables notifications.
+func (s *KVStore) SetNotifyKeyspaceEvents(spec string) error {
+	var flags uint32
+	for i := 0; i < len(spec); i++ {
+		if spec[i] == 'A' {
+			flags |= notifyAll
+			continue
+		}
+		found := false
+		for _, fc := range notifyFlagChars {
+			if fc.char == spec[i] {
+				flags |= fc.flag
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("ERR Invalid event class character '%c'", spec[i])
+		}
+	}
+	s.notifyFlags.Store(flags)
+	return nil
+}
+
+// NotifyKeyspaceEvents returns the current setting in canonical form.
+func (s *KVStore) NotifyKeyspaceEvents() string {
+	flags := s.notifyFlags.Load()
+	var b strings.Builder
+	if flags&notifyAll == notifyAll {
+		b.WriteByte('A')
+		flags &^= notifyAll
+	}
+	for _, fc := range notifyFlagChars {
+		if flags&fc.flag != 0 {
+			b.WriteByte(fc.char)
+		}
+	}
+	return b.String()
+}
+
+// notify publishes a keyspace event if its class is enabled. It is called
+// from write paths while the key's shard lock is held, which keeps events
+// in write order. That is safe since the Hub never takes a store shard
+// lock, and the events never wait for a full subscriber, even under the
+// Block policy, so a slow subscriber cannot stall the shard's writes.
+func (s *KVStore) notify(class uint32, event, key string) {
+	flags := s.notifyFlags.Load()
+	if flags&class == 0 {
+		return
+	}
+	if flags&notifyKeyspace != 0 {
+		s.Hub.publishNoWait("__keyspace@0__:"+key, event)
+	}
+	if flags&notifyKeyevent != 0 {
+		s.Hub.publishNoWait("__keyevent@0__:"+event, key)
+	}
+}
@@ -0,0 +1,177 @@
+package core
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// drain returns the messages queued for sub as "channel payload" strings.
+func drain(sub *Subscriber) []string {
+	var got []string
+	for {
+		select {
+		case msg := <-sub.Messages():
+			got = append(got, msg.Channel+" "+msg.Payload)
+		default:
+			return got
+		}
+	}
+}
+
+func TestKeyspaceNotifications(t *testing.T) {
+	store := NewKVStore()
+	sub := store.Hub.NewSubscriber()
+	store.Hub.AddPatterns(sub, "__key*__:*")
+
+	store.Set("a", "1", 0)
+	if got := drain(sub); len(got) != 0 {
+		t.Errorf("notifications while disabled = %v", got)
+	}
+
+	if err := store.SetNotifyKeyspaceEvents("KEA"); err != nil {
+		t.Fatalf("SetNotifyKeyspaceEvents() error = %v", err)
+	}
+	if got := store.NotifyKeyspaceEvents(); got != "AKE" {
+		t.Errorf("NotifyKeyspaceEvents() = %q, want AKE", got)
+	}
+
+	store.Set("a", "1", 10)
+	store.IncrBy("n", 1)
+	store.HSet("h", "f", "v")
+	store.HDel("h", "f")
+	store.Delete("a")
+	store.Delete("missing")
+	want := []string{
+		"__keyspace@0__:a set", "__keyevent@0__:set a",
+		"__keyspace@0__:a expire", "__keyevent@0__:expire a",
+		"__keyspace@0__:n incrby", "__keyevent@0__:incrby n",
+		"__keyspace@0__:h hset", "__keyevent@0__:hset h",
+		"__keyspace@0__:h hdel", "__keyevent@0__:hdel h",
+		"__keyspace@0__:a del", "__keyevent@0__:del a",
+	}
+	if got := drain(sub); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("notifications =\n%v\nwant\n%v", got, want)
+	}
+
+	// Only expired events, only on the keyevent channel
+	store.SetNotifyKeyspaceEvents("Ex")
+	store.Set("lazy", "v", 1)
+	store.Set("active", "v", 1)
+	for _, key := range []string{"lazy", "active"} {
+		shard := store.getShard(key)
+		shard.mu.Lock()
+		entry := shard.data[key]
+		entry.ExpiresAt = time.Now().Add(-time.Second).UnixNano()
+		shard.data[key] = entry
+		shard.mu.Unlock()
+	}
+	store.Get("lazy")
+	shard := store.getShard("active")
+	for {
+		if _, ok := shard.data["active"]; !ok {
+			break
+		}
+		store.sampleAndCleanShard(shard)
+	}
+	want = []string{"__keyevent@0__:expired lazy", "__keyevent@0__:expired active"}
+	if got := drain(sub); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("expiry notifications = %v, want %v", got, want)
+	}
+
+	if err := store.SetNotifyKeyspaceEvents("Kq"); err == nil {
+		t.Error("SetNotifyKeyspaceEvents(Kq) should error")
+	}
+}
+
+func TestKeyspaceNotificationsForEveryType(t *testing.T) {
+	store := NewKVStore()
+	store.SetNotifyKeyspaceEvents("EA")
+	sub := store.Hub.NewSubscriber()
+	store.Hub.AddPatterns(sub, "__keyevent@0__:*")
+
+	store.Set("src", "x", 0)
+	store.BitOp(BitOr, "bits", "src")
+	store.BitOp(BitAnd, "bits", "missing")
+	store.BitField("bf", []BitFieldOp{{Kind: BitFieldSet, Width: 8, Value: 1}})
+	store.PFAdd("hll", "a")
+	store.PFAdd("hll", "a") // No register changed
+	store.PFMerge("hll2", "hll")
+	store.GeoAdd("geo", []GeoMember{{"rome", 12.5, 41.9}}, GeoAddOptions{})
+	store.GeoAdd("geo", []GeoMember{{"rome", 12.5, 41.9}}, GeoAddOptions{}) // Same position
+	store.JSONSet("doc", "$", `{"a":1,"b":[]}`, JSONSetOptions{})
+	store.JSONNumIncrBy("doc", "$.a", "1")
+	store.JSONArrAppend("doc", "$.b", "2")
+	store.JSONDel("doc", "$.a")
+	store.JSONDel("doc", "$")
+	store.TSCreate("ts", TSCreateOptions{})
+	store.TSAdd("ts", 1, 1, TSCreateOptions{})
+	store.BFReserve("bloom", DefaultBloomConfig())
+	store.BFAdd("bloom", "a")
+	store.BFAdd("bloom", "a") // Already present
+	store.CFReserve("cuckoo", DefaultCuckooConfig())
+	store.CFAdd("cuckoo", "a")
+	store.CFDel("cuckoo", "a")
+	store.CFDel("cuckoo", "a") // Not found
+	store.CMSInitByDim("cms", 10, 2)
+	store.CMSIncrBy("cms", []CMSIncrement{{"a", 1}})
+
+	want := []string{
+		"__keyevent@0__:set src",
+		"__keyevent@0__:set bits",
+		"__keyevent@0__:del bits",
+		"__keyevent@0__:setbit bf",
+		"__keyevent@0__:pfadd hll",
+		"__keyevent@0__:pfadd hll2",
+		"__keyevent@0__:zadd geo",
+		"__keyevent@0__:json.set doc",
+		"__keyevent@0__:json.numincrby doc",
+		"__keyevent@0__:json.arrappend doc",
+		"__keyevent@0__:json.del doc",
+		"__keyevent@0__:json.del doc",
+		"__keyevent@0__:ts.create ts",
+		"__keyevent@0__:ts.add ts",
+		"__keyevent@0__:bf.reserve bloom",
+		"__keyevent@0__:bf.add bloom",
+		"__keyevent@0__:cf.reserve cuckoo",
+		"__keyevent@0__:cf.add cuckoo",
+		"__keyevent@0__:cf.del cuckoo",
+		"__keyevent@0__:cms.init cms",
+		"__keyevent@0__:cms.incrby cms",
+	}
+	if got := drain(sub); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("notifications =\n%v\nwant\n%v", got, want)
+	}
+
+	// Each class can be enabled on its own
+	store.SetNotifyKeyspaceEvents("Ez")
+	store.PFAdd("hll", "b")
+	store.GeoAdd("geo", []GeoMember{{"paris", 2.35, 48.86}}, GeoAddOptions{})
+	if got := drain(sub); fmt.Sprint(got) != "[__keyevent@0__:zadd geo]" {
+		t.Errorf("notifications with Ez = %v, want only zadd", got)
+	}
+}
+
+func TestKeyspaceNotificationsNeverBlock(t *testing.T) {
+	store := NewKVStore()
+	store.Hub = NewHubWithOptions(HubOptions{BufferSize: 1, Policy: Block, BlockTimeout: time.Minute})
+	store.SetNotifyKeyspaceEvents("K$")
+	sub := store.Hub.NewSubscriber()
+	store.Hub.AddChannels(sub, "__keyspace@0__:a")
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 3; i++ {
+			store.Set("a", "1", 0)
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("writes blocked on a full keyspace subscriber")
+	}
+	if got := drain(sub); len(got) != 1 || sub.Dropped() != 2 {
+		t.Errorf("queued %v, dropped %d; want 1 queued and 2 dropped", got, sub.Dropped())
+	}
+}
@@ -0,0 +1,212 @@
+package core
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestBloomFilterAddAndExists(t *testing.T) {
+	store := NewKVStore()
+
+	added, err := store.BFAdd("seen", "req:1")
+	if err != nil {
+		t.Fatalf("BFAdd() error = %v", err)
+	}
+	if !added[0] {
+		t.Error("BFAdd() = false, want true for new item")
+	}
+	added, _ = store.BFAdd("seen", "req:1")
+	if added[0] {
+		t.Error("BFAdd() = true, want false for repeated item")
+	}
+
+	found, _ := store.BFExists("seen", "req:1", "req:2")
+	if !found[0] || found[1] {
+		t.Errorf("BFExists() = %v, want [true false]", found)
+	}
+
+	found, _ = store.BFExists("missing", "req:1")
+	if found[0] {
+		t.Error("BFExists() on missing key = true, want false")
+	}
+}
+
+func TestBloomFilterScaling(t *testing.T) {
+	store := NewKVStore()
+	if err := store.BFReserve("bf", BloomConfig{ErrorRate: 0.01, Capacity: 100, Expansion: 2}); err != nil {
+		t.Fatalf("BFReserve() error = %v", err)
+	}
+	if err := store.BFReserve("bf", DefaultBloomConfig()); err != ErrKeyExists {
+		t.Errorf("BFReserve() on existing key error = %v, want ErrKeyExists", err)
+	}
+
+	for i := 0; i < 1000; i++ {
+		if _, err := store.BFAdd("bf", fmt.Sprintf("item:%d", i)); err != nil {
+			t.Fatalf("BFAdd() error = %v", err)
+		}
+	}
+	for i := 0; i < 1000; i++ {
+		found, _ := store.BFExists("bf", fmt.Sprintf("item:%d", i))
+		if !found[0] {
+			t.Fatalf("BFExists(item:%d) = false, want true", i)
+		}
+	}
+
+	falsePositives := 0
+	for i := 0; i < 10000; i++ {
+		found, _ := store.BFExists("bf", fmt.Sprintf("other:%d", i))
+		if found[0] {
+			falsePositives++
+		}
+	}
+	// Tightening halves the error rate of each new layer, so the compound
+	// rate stays below twice the configured one
+	if rate := float64(falsePositives) / 10000; rate > 0.03 {
+		t.Errorf("false positive rate = %.4f, want <= 0.03", rate)
+	}
+}
+
+func TestBloomFilterNonScaling(t *testing.T) {
+	store := NewKVStore()
+	store.BFReserve("fixed", BloomConfig{ErrorRate: 0.01, Capacity: 10, NonScaling: true})
+
+	var err error
+	for i := 0; i < 20 && err == nil; i++ {
+		_, err = store.BFAdd("fixed", fmt.Sprintf("item:%d", i))
+	}
+	if err != ErrFilterFull {
+		t.Errorf("BFAdd() past capacity error = %v, want ErrFilterFull", err)
+	}
+}
+
+func TestCuckooFilter(t *testing.T) {
+	store := NewKVStore()
+
+	if err := store.CFAdd("cf", "a"); err != nil {
+		t.Fatalf("CFAdd() error = %v", err)
+	}
+	store.CFAdd("cf", "a")
+
+	if ok, _ := store.CFExists("cf", "a"); !ok {
+		t.Error("CFExists() = false, want true")
+	}
+	if added, _ := store.CFAddNX("cf", "a"); added {
+		t.Error("CFAddNX() of existing item = true, want false")
+	}
+
+	// Two copies were added, so it takes two deletes to remove the item
+	store.CFDel("cf", "a")
+	if ok, _ := store.CFExists("cf", "a"); !ok {
+		t.Error("CFExists() after one delete = false, want true")
+	}
+	store.CFDel("cf", "a")
+	if ok, _ := store.CFExists("cf", "a"); ok {
+		t.Error("CFExists() after deleting both copies = true, want false")
+	}
+	if deleted, _ := store.CFDel("cf", "a"); deleted {
+		t.Error("CFDel() of absent item = true, want false")
+	}
+
+	if _, err := store.CFDel("missing", "a"); err != ErrFilterNotFound {
+		t.Errorf("CFDel() on missing key error = %v, want ErrFilterNotFound", err)
+	}
+}
+
+func TestCuckooFilterExpansion(t *testing.T) {
+	store := NewKVStore()
+	store.CFReserve("cf", CuckooConfig{Capacity: 64, BucketSize: 2, MaxIterations: 20, Expansion: 1})
+
+	for i := 0; i < 500; i++ {
+		if err := store.CFAdd("cf", fmt.Sprintf("item:%d", i)); err != nil {
+			t.Fatalf("CFAdd() error = %v", err)
+		}
+	}
+	for i := 0; i < 500; i++ {
+		if ok, _ := store.CFExists("cf", fmt.Sprintf("item:%d", i)); !ok {
+			t.Fatalf("CFExists(item:%d) = false, want true", i)
+		}
+	}
+
+	store.CFReserve("small", CuckooConfig{Capacity: 4, BucketSize: 1, MaxIterations: 5})
+	var err error
+	for i := 0; i < 100 && err == nil; i++ {
+		err = store.CFAdd("small", fmt.Sprintf("item:%d", i))
+	}
+	if err != ErrCuckooFull {
+		t.Errorf("CFAdd() on full non-expanding filter error = %v, want ErrCuckooFull", err)
+	}
+}
+
+func TestCuckooFilterDeterministic(t *testing.T) {
+	// Replicas applying the same commands must build identical filters
+	var dumps [2][]byte
+	for n := range dumps {
+		store := NewKVStore()
+		store.CFReserve("cf", CuckooConfig{Capacity: 64, BucketSize: 2, MaxIterations: 20, Expansion: 1})
+		for i := 0; i < 300; i++ {
+			store.CFAdd("cf", fmt.Sprintf("item:%d", i))
+		}
+		for i := 0; i < 300; i += 3 {
+			store.CFDel("cf", fmt.Sprintf("item:%d", i))
+		}
+		dumps[n], _, _ = store.Dump("cf")
+	}
+	if string(dumps[0]) != string(dumps[1]) {
+		t.Error("two stores given the same CF.ADD and CF.DEL commands hold different filters")
+	}
+}
+
+func TestCountMinSketch(t *testing.T) {
+	store := NewKVStore()
+
+	if _, err := store.CMSIncrBy("cms", []CMSIncrement{{"a", 1}}); err != ErrCMSNotFound {
+		t.Errorf("CMSIncrBy() on missing key error = %v, want ErrCMSNotFound", err)
+	}
+	if err := store.CMSInitByDim("cms", 2000, 5); err != nil {
+		t.Fatalf("CMSInitByDim() error = %v", err)
+	}
+
+	counts, err := store.CMSIncrBy("cms", []CMSIncrement{{"hot", 100}, {"cold", 1}})
+	if err != nil {
+		t.Fatalf("CMSIncrBy() error = %v", err)
+	}
+	if counts[0] != 100 || counts[1] != 1 {
+		t.Errorf("CMSIncrBy() = %v, want [100 1]", counts)
+	}
+
+	store.CMSIncrBy("cms", []CMSIncrement{{"hot", 5}})
+	counts, _ = store.CMSQuery("cms", "hot", "cold", "never")
+	if counts[0] != 105 || counts[1] != 1 || counts[2] != 0 {
+		t.Errorf("CMSQuery() = %v, want [105 1 0]", counts)
+	}
+
+	if err := store.CMSInitByProb("byprob", 0.001, 0.01); err != nil {
+		t.Fatalf("CMSInitByProb() error = %v", err)
+	}
+	if err := store.CMSInitByDim("byprob", 10, 10); err == nil {
+		t.Error("CMSInitByDim() on existing key should error")
+	}
+}
+
+func TestProbabilisticWrongType(t *testing.T) {
+	store := NewKVStore()
+	store.Set("str", "value", 0)
+
+	if _, err := store.BFAdd("str", "x"); err != ErrWrongType {
+		t.Errorf("BFAdd() error = %v, want ErrWrongType", err)
+	}
+	if err := store.CFAdd("str", "x"); err != ErrWrongType {
+		t.Errorf("CFAdd() error = %v, want ErrWrongType", err)
+	}
+	if _, err := store.CMSQuery("str", "x"); err != ErrWrongType {
+		t.Errorf("CMSQuery() error = %v, want ErrWrongType", err)
+	}
+	if _, _, err := store.Get("str"); err != nil {
+		t.Errorf("Get() error = %v", err)
+	}
+
+	store.BFAdd("bf", "x")
+	if _, _, err := store.Get("bf"); err != ErrWrongType {
+		t.Errorf("Get() on bloom filter error = %v, want ErrWrongType", err)
+	}
+}
@@ -1,74 +1,728 @@
 package core
 
 import (
+	"fmt"
+	"hash/fnv"
+	"sort"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
-// Hub manages Pub/Sub channels and subscribers.
+// SlowConsumerPolicy decides what Publish does when a subscriber's buffer
+// is full.
+type SlowConsumerPolicy int
+
+const (
+	DropNewest SlowConsumerPolicy = iota // Discard the message being published (default)
+	DropOldest                           // Discard the subscriber's oldest queued message
+	Disconnect                           // Remove the subscriber; its message channel is closed
+	Block                                // Wait up to BlockTimeout for room, then drop
+)
+
+// ParseSlowConsumerPolicy parses "drop-newest", "drop-oldest",
+// "disconnect" or "block".
+func ParseSlowConsumerPolicy(name string) (SlowConsumerPolicy, error) {
+	switch name {
+	case "drop-newest":
+		return DropNewest, nil
+	case "drop-oldest":
+		return DropOldest, nil
+	case "disconnect":
+		return Disconnect, nil
+	case "block":
+		return Block, nil
+	}
+	return 0, fmt.Errorf("unknown slow consumer policy %q", name)
+}
+
+// HubOptions configures a Hub.
+type HubOptions struct {
+	BufferSize   int // Messages queued per subscriber (default 100)
+	Policy       SlowConsumerPolicy
+	BlockTimeout time.Duration // Longest a Publish waits under the Block policy (default 100ms)
+}
+
+// Message is a published message as delivered to a Subscriber. Pattern is
+// set when it was delivered through a pattern subscription, Offset on a
+// retained channel subscribed to with Hub.Resume, and Sharded when it was
+// published on a shard channel with Hub.SPublish.
+type Message struct {
+	Pattern string
+	Channel string
+	Payload string
+	Offset  uint64
+	Sharded bool
+}
+
+// Subscriber is one client's set of subscriptions. All of its messages are
+// delivered on a single Go channel, whatever channel they were published on.
+type Subscriber struct {
+	ID       uint64
+	messages chan Message
+	legacy   chan string   // Set for subscribers created by Hub.Subscribe
+	removed  chan struct{} // Closed on removal; set along with legacy
+
+	mu            sync.Mutex // Guards the fields below; taken before Hub locks
+	channels      map[string]struct{}
+	shardChannels map[string]struct{}
+	patterns      map[string]struct{}
+	closed        bool
+
+	slow      atomic.Bool // Removed by the Disconnect policy
+	delivered atomic.Uint64
+	dropped   atomic.Uint64
+}
+
+// count returns the number of channels and patterns sub is subscribed to.
+// Must be called while holding sub.mu.
+func (s *Subscriber) count() int {
+	return len(s.channels) + len(s.patterns)
+}
+
+// countFor returns the count a (un)subscribe reply reports: channels and
+// patterns, or shard channels alone, which Redis counts separately. Must be
+// called while holding sub.mu.
+func (s *Subscriber) countFor(sharded bool) int {
+	if sharded {
+		return len(s.shardChannels)
+	}
+	return s.count()
+}
+
+// channelSet returns sub's channels or shard channels. Must be called while
+// holding sub.mu.
+func (s *Subscriber) channelSet(sharded bool) map[string]struct{} {
+	if sharded {
+		return s.shardChannels
+	}
+	return s.channels
+}
+
+// Messages returns the channel messages are delivered on. It is closed by
+// Hub.RemoveSubscriber, and by Publish under the Disconnect policy.
+func (s *Subscriber) Messages() <-chan Message {
+	return s.messages
+}
+
+// Delivered returns the number of messages queued for this subscriber.
+func (s *Subscriber) Delivered() uint64 {
+	return s.delivered.Load()
+}
+
+// Dropped returns the number of messages this subscriber lost because its
+// buffer was full.
+func (s *Subscriber) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// channelState holds a channel's subscribers and delivery counters. A
+// subscriber maps to true if it resumed the channel with Hub.Resume and so
+// receives message offsets.
+type channelState struct {
+	subs      map[*Subscriber]bool
+	delivered atomic.Uint64
+	dropped   atomic.Uint64
+}
+
+// hubShard holds the channels hashing to it, so publishes on different
+// channels don't contend for one lock.
+type hubShard struct {
+	mu       sync.RWMutex
+	subs     map[string]*channelState // Map channel_name -> subscribers
+	sharded  map[string]*channelState // Shard channels, a separate namespace
+	retained map[string]*retainedChannel
+
+	delivered atomic.Uint64
+	dropped   atomic.Uint64
+}
+
+// states returns the shard's channels or shard channels.
+func (s *hubShard) states(sharded bool) map[string]*channelState {
+	if sharded {
+		return s.sharded
+	}
+	return s.subs
+}
+
+// add subscribes sub to channel. Must be called while holding s.mu.
+func (s *hubShard) add(states map[string]*channelState, channel string, sub *Subscriber, offsets bool) {
+	state, ok := states[channel]
+	if !ok {
+		state = &channelState{subs: make(map[*Subscriber]bool)}
+		states[channel] = state
+	}
+	state.subs[sub] = offsets
+}
+
+// remove unsubscribes sub from channel, deleting the channel once it has no
+// subscribers left. Must be called while holding s.mu.
+func (s *hubShard) remove(states map[string]*channelState, channel string, sub *Subscriber) {
+	state, ok := states[channel]
+	if !ok {
+		return
+	}
+	delete(state.subs, sub)
+	if len(state.subs) == 0 {
+		delete(states, channel)
+	}
+}
+
+// HubStats is a snapshot of Hub-wide counters.
+type HubStats struct {
+	Channels      int    // Channels with at least one subscriber
+	ShardChannels int    // Shard channels with at least one subscriber
+	Patterns      int    // Distinct patterns with at least one subscriber
+	Delivered     uint64 // Messages queued for subscribers
+	Dropped       uint64 // Messages lost to full buffers
+	Disconnected  uint64 // Subscribers removed by the Disconnect policy
+}
+
+// Hub manages Pub/Sub channels and subscribers. Like the KVStore's keys,
+// channels are spread over ShardCount shards by hash, each with its own
+// lock. Patterns can match channels in any shard, so they are kept in one
+// trie under patternMu. Locks are taken in the order Subscriber.mu, then a
+// shard's lock or patternMu, then a retained channel's lock; Publish never
+// takes Subscriber.mu.
 type Hub struct {
-	mu   sync.RWMutex
-	subs map[string][]chan string // Map channel_name -> list of client channels
+	opts   HubOptions
+	shards []*hubShard
+	nextID atomic.Uint64
+
+	patternMu   sync.RWMutex
+	patterns    patternTrie
+	numPatterns atomic.Int64 // patterns.count, read by Publish without patternMu
+
+	disconnected atomic.Uint64
 }
 
 // NewHub initializes a new Pub/Sub Hub.
 func NewHub() *Hub {
-	return &Hub{
-		subs: make(map[string][]chan string),
+	return NewHubWithOptions(HubOptions{})
+}
+
+// NewHubWithOptions initializes a Hub with the given buffer size and slow
+// consumer policy. Zero values select the defaults.
+func NewHubWithOptions(opts HubOptions) *Hub {
+	if opts.BufferSize <= 0 {
+		opts.BufferSize = 100
+	}
+	if opts.BlockTimeout <= 0 {
+		opts.BlockTimeout = 100 * time.Millisecond
+	}
+	h := &Hub{
+		opts:   opts,
+		shards: make([]*hubShard, ShardCount),
+	}
+	for i := 0; i < ShardCount; i++ {
+		h.shards[i] = &hubShard{
+			subs:     make(map[string]*channelState),
+			sharded:  make(map[string]*channelState),
+			retained: make(map[string]*retainedChannel),
+		}
+	}
+	return h
+}
+
+// getShard returns the shard for a given channel.
+func (h *Hub) getShard(channel string) *hubShard {
+	hash := fnv.New32a()
+	hash.Write([]byte(channel))
+	return h.shards[hash.Sum32()%ShardCount]
+}
+
+// NewSubscriber returns a subscriber with a unique ID and no subscriptions.
+func (h *Hub) NewSubscriber() *Subscriber {
+	return &Subscriber{
+		ID:            h.nextID.Add(1),
+		messages:      make(chan Message, h.opts.BufferSize),
+		channels:      make(map[string]struct{}),
+		shardChannels: make(map[string]struct{}),
+		patterns:      make(map[string]struct{}),
+	}
+}
+
+// AddChannels subscribes sub to each channel. For every channel it returns
+// the subscriber's subscription count (channels and patterns) after adding
+// it, which is what the SUBSCRIBE reply reports. A removed subscriber is not
+// subscribed again.
+func (h *Hub) AddChannels(sub *Subscriber, channels ...string) []int {
+	return h.addChannels(sub, channels, false)
+}
+
+// AddShardChannels subscribes sub to each shard channel, returning the
+// number of shard channels it is subscribed to after each one, which is
+// what the SSUBSCRIBE reply reports.
+func (h *Hub) AddShardChannels(sub *Subscriber, channels ...string) []int {
+	return h.addChannels(sub, channels, true)
+}
+
+func (h *Hub) addChannels(sub *Subscriber, channels []string, sharded bool) []int {
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+
+	joined := sub.channelSet(sharded)
+	counts := make([]int, len(channels))
+	for i, channel := range channels {
+		if _, ok := joined[channel]; !ok && !sub.closed {
+			joined[channel] = struct{}{}
+			shard := h.getShard(channel)
+			shard.mu.Lock()
+			shard.add(shard.states(sharded), channel, sub, false)
+			shard.mu.Unlock()
+		}
+		counts[i] = sub.countFor(sharded)
+	}
+	return counts
+}
+
+// RemoveChannels unsubscribes sub from each channel, or from all of its
+// channels if none are given. It returns the channels processed (sorted
+// when removing all) and the subscription count after each one.
+func (h *Hub) RemoveChannels(sub *Subscriber, channels ...string) ([]string, []int) {
+	return h.removeChannels(sub, channels, false)
+}
+
+// RemoveShardChannels unsubscribes sub from each shard channel, or from all
+// of them if none are given, like RemoveChannels.
+func (h *Hub) RemoveShardChannels(sub *Subscriber, channels ...string) ([]string, []int) {
+	return h.removeChannels(sub, channels, true)
+}
+
+func (h *Hub) removeChannels(sub *Subscriber, channels []string, sharded bool) ([]string, []int) {
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+
+	if len(channels) == 0 {
+		for channel := range sub.channelSet(sharded) {
+			channels = append(channels, channel)
+		}
+		sort.Strings(channels)
+	}
+	counts := make([]int, len(channels))
+	for i, channel := range channels {
+		h.removeChannel(sub, channel, sharded)
+		counts[i] = sub.countFor(sharded)
+	}
+	return channels, counts
+}
+
+// removeChannel drops one subscription. Must be called while holding
+// sub.mu.
+func (h *Hub) removeChannel(sub *Subscriber, channel string, sharded bool) {
+	joined := sub.channelSet(sharded)
+	if _, ok := joined[channel]; !ok {
+		return
+	}
+	delete(joined, channel)
+	shard := h.getShard(channel)
+	shard.mu.Lock()
+	shard.remove(shard.states(sharded), channel, sub)
+	shard.mu.Unlock()
+}
+
+// AddPatterns subscribes sub to each glob-style pattern, returning the
+// subscription counts like AddChannels.
+func (h *Hub) AddPatterns(sub *Subscriber, patterns ...string) []int {
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+	h.patternMu.Lock()
+	defer h.patternMu.Unlock()
+
+	counts := make([]int, len(patterns))
+	for i, pattern := range patterns {
+		if _, ok := sub.patterns[pattern]; !ok && !sub.closed {
+			sub.patterns[pattern] = struct{}{}
+			h.patterns.add(pattern, sub)
+		}
+		counts[i] = sub.count()
+	}
+	h.numPatterns.Store(int64(h.patterns.count))
+	return counts
+}
+
+// RemovePatterns unsubscribes sub from each pattern, or from all of its
+// patterns if none are given, like RemoveChannels.
+func (h *Hub) RemovePatterns(sub *Subscriber, patterns ...string) ([]string, []int) {
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+	h.patternMu.Lock()
+	defer h.patternMu.Unlock()
+
+	if len(patterns) == 0 {
+		for pattern := range sub.patterns {
+			patterns = append(patterns, pattern)
+		}
+		sort.Strings(patterns)
+	}
+	counts := make([]int, len(patterns))
+	for i, pattern := range patterns {
+		if _, ok := sub.patterns[pattern]; ok {
+			delete(sub.patterns, pattern)
+			h.patterns.remove(pattern, sub)
+		}
+		counts[i] = sub.count()
+	}
+	h.numPatterns.Store(int64(h.patterns.count))
+	return patterns, counts
+}
+
+// SubscriptionCount returns the number of channels and patterns sub is
+// subscribed to.
+func (h *Hub) SubscriptionCount(sub *Subscriber) int {
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+	return sub.count()
+}
+
+// ShardSubscriptionCount returns the number of shard channels sub is
+// subscribed to.
+func (h *Hub) ShardSubscriptionCount(sub *Subscriber) int {
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+	return len(sub.shardChannels)
+}
+
+// RemoveSubscriber drops all of sub's subscriptions and closes its message
+// channel. It must be called once the subscriber is no longer used; later
+// calls do nothing.
+func (h *Hub) RemoveSubscriber(sub *Subscriber) {
+	h.removeSubscriber(sub)
+}
+
+// removeSubscriber is RemoveSubscriber, reporting whether this call removed
+// the subscriber.
+func (h *Hub) removeSubscriber(sub *Subscriber) bool {
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+
+	if sub.closed {
+		return false
+	}
+	sub.closed = true
+	for channel := range sub.channels {
+		h.removeChannel(sub, channel, false)
+	}
+	for channel := range sub.shardChannels {
+		h.removeChannel(sub, channel, true)
+	}
+	h.patternMu.Lock()
+	for pattern := range sub.patterns {
+		delete(sub.patterns, pattern)
+		h.patterns.remove(pattern, sub)
 	}
+	h.numPatterns.Store(int64(h.patterns.count))
+	h.patternMu.Unlock()
+
+	// Publish only sends while holding the lock sub was removed under, so
+	// nothing can send on messages any more
+	close(sub.messages)
+	if sub.removed != nil {
+		close(sub.removed)
+	}
+	return true
 }
 
 // Subscribe adds a client to a channel and returns a go-channel for messages.
+//
+// Deprecated: Use SubscribeContext, which can be stopped and also delivers
+// the channel name.
 func (h *Hub) Subscribe(channel string) <-chan string {
-	h.mu.Lock()
-	defer h.mu.Unlock()
+	sub := h.NewSubscriber()
+	sub.legacy = make(chan string, 100)
+	sub.removed = make(chan struct{})
+	h.AddChannels(sub, channel)
+	go func() {
+		// Waits while the caller is behind, so the Hub's slow consumer
+		// policy applies once both buffers are full, but gives up as soon
+		// as the subscriber is removed
+		defer close(sub.legacy)
+		for msg := range sub.messages {
+			select {
+			case sub.legacy <- msg.Payload:
+			case <-sub.removed:
+				return
+			}
+		}
+	}()
+	return sub.legacy
+}
 
-	// Create a new channel for this client
-	// Buffer 10 messages to prevent slow clients from blocking publishers immediately
-	clientCh := make(chan string, 100)
-	h.subs[channel] = append(h.subs[channel], clientCh)
+// Publish sends a message to all subscribers of a channel and of every
+// pattern matching it. Returns the number of deliveries, so a client
+// subscribed through two matching patterns is counted twice. On a retained
+// channel the message is also kept for replay. What happens
+// when a subscriber's buffer is full depends on the Hub's policy; under
+// Block the channel's shard stays read-locked while waiting, which delays
+// subscription changes in that shard for up to BlockTimeout.
+func (h *Hub) Publish(channel, message string) int {
+	return h.publish(channel, message, false)
+}
 
-	return clientCh
+// publishNoWait is Publish without ever waiting for room: under the Block
+// policy a full subscriber misses the message as under DropNewest. The
+// store publishes keyspace events this way while holding a shard lock, so
+// a slow subscriber cannot stall writes.
+func (h *Hub) publishNoWait(channel, message string) int {
+	return h.publish(channel, message, true)
 }
 
-// Publish sends a message to all subscribers of a channel.
-// Returns the number of clients that received the message.
-func (h *Hub) Publish(channel, message string) int {
-	h.mu.RLock()
-	defer h.mu.RUnlock()
+func (h *Hub) publish(channel, message string, noWait bool) int {
+	shard := h.getShard(channel)
+	p := publication{hub: h, shard: shard, noWait: noWait}
+	shard.mu.RLock()
+	var offset uint64
+	rc := shard.retained[channel]
+	if rc != nil {
+		// Held while delivering so subscribers see offsets in order
+		rc.mu.Lock()
+		offset = rc.append(message, time.Now())
+	}
+	if state, ok := shard.subs[channel]; ok {
+		msg := Message{Channel: channel, Payload: message}
+		for sub, offsets := range state.subs {
+			msg.Offset = 0
+			if offsets {
+				msg.Offset = offset
+			}
+			p.deliverTo(state, sub, msg)
+		}
+	}
+	if rc != nil {
+		rc.mu.Unlock()
+	}
+	shard.mu.RUnlock()
 
-	clients, ok := h.subs[channel]
-	if !ok {
-		return 0
+	// Skips the shared pattern lock entirely while no patterns are in use
+	if h.numPatterns.Load() > 0 {
+		h.patternMu.RLock()
+		h.patterns.match(channel, func(pattern string, subs map[*Subscriber]struct{}) {
+			msg := Message{Pattern: pattern, Channel: channel, Payload: message}
+			for sub := range subs {
+				p.deliver(sub, msg)
+			}
+		})
+		h.patternMu.RUnlock()
+	}
+
+	p.finish()
+	return p.count
+}
+
+// SPublish sends a message to the subscribers of a shard channel and
+// returns the number of deliveries. Shard channels are a namespace of their
+// own: they are not matched by patterns nor retained, so SPublish only
+// takes the channel's shard lock and publishes on different channels run
+// in parallel.
+func (h *Hub) SPublish(channel, message string) int {
+	shard := h.getShard(channel)
+	p := publication{hub: h, shard: shard}
+	shard.mu.RLock()
+	if state, ok := shard.sharded[channel]; ok {
+		msg := Message{Channel: channel, Payload: message, Sharded: true}
+		for sub := range state.subs {
+			p.deliverTo(state, sub, msg)
+		}
 	}
+	shard.mu.RUnlock()
 
-	count := 0
-	for _, clientCh := range clients {
-		// Non-blocking send: if client is too slow (buffer full), drop message
+	p.finish()
+	return p.count
+}
+
+// publication is the state of one Publish call.
+type publication struct {
+	hub     *Hub
+	shard   *hubShard // Shard of the channel, which counts the deliveries
+	count   int
+	slow    []*Subscriber // Subscribers to disconnect once the lock is released
+	timer   *time.Timer   // Shared Block deadline, started on first use
+	expired bool
+	noWait  bool // Drop instead of waiting under Block
+}
+
+// deliverTo delivers msg to a direct subscriber of a channel and updates
+// the channel's counters.
+func (p *publication) deliverTo(state *channelState, sub *Subscriber, msg Message) {
+	if p.deliver(sub, msg) {
+		state.delivered.Add(1)
+	} else {
+		state.dropped.Add(1)
+	}
+}
+
+// deliver queues msg for sub, applying the slow consumer policy when its
+// buffer is full, and reports whether msg was queued.
+func (p *publication) deliver(sub *Subscriber, msg Message) bool {
+	select {
+	case sub.messages <- msg:
+		p.delivered(sub)
+		return true
+	default:
+	}
+
+	h := p.hub
+	switch h.opts.Policy {
+	case DropOldest:
 		select {
-		case clientCh <- message:
-			count++
+		case <-sub.messages:
+			sub.dropped.Add(1)
+			p.shard.dropped.Add(1)
 		default:
-			// Dropped message (slow consumer)
+		}
+		select {
+		case sub.messages <- msg:
+			p.delivered(sub)
+			return true
+		default:
+		}
+	case Disconnect:
+		p.slow = append(p.slow, sub)
+	case Block:
+		if !p.expired && !p.noWait {
+			if p.timer == nil {
+				p.timer = time.NewTimer(h.opts.BlockTimeout)
+			}
+			select {
+			case sub.messages <- msg:
+				p.delivered(sub)
+				return true
+			case <-p.timer.C:
+				p.expired = true
+			}
+		}
+	}
+	sub.dropped.Add(1)
+	p.shard.dropped.Add(1)
+	return false
+}
+
+func (p *publication) delivered(sub *Subscriber) {
+	p.count++
+	sub.delivered.Add(1)
+	p.shard.delivered.Add(1)
+}
+
+// finish stops the Block timer and disconnects the subscribers found slow.
+// Must be called after releasing all locks.
+func (p *publication) finish() {
+	if p.timer != nil {
+		p.timer.Stop()
+	}
+	for _, sub := range p.slow {
+		sub.slow.Store(true)
+		if p.hub.removeSubscriber(sub) {
+			p.hub.disconnected.Add(1)
 		}
 	}
-	return count
 }
 
-// Unsubscribe removes a client channel from the list.
-// Note: accurate unsubscription requires tracking specific channels.
-// For v1, we rely on the channel closing naturally or GC, but ideally we'd pass the chan back.
+// Unsubscribe removes a client channel returned by Subscribe from the list
+// and closes it.
 func (h *Hub) Unsubscribe(channel string, clientCh <-chan string) {
-	h.mu.Lock()
-	defer h.mu.Unlock()
+	shard := h.getShard(channel)
+	shard.mu.RLock()
+	var found *Subscriber
+	if state, ok := shard.subs[channel]; ok {
+		for sub := range state.subs {
+			if sub.legacy != nil && (<-chan string)(sub.legacy) == clientCh {
+				found = sub
+				break
+			}
+		}
+	}
+	shard.mu.RUnlock()
+
+	if found != nil {
+		h.RemoveSubscriber(found)
+	}
+}
+
+// Channels returns the channels with at least one subscriber, optionally
+// only those matching a glob pattern, sorted.
+func (h *Hub) Channels(pattern string) []string {
+	return h.channels(pattern, false)
+}
 
-	_, ok := h.subs[channel]
+// ShardChannels returns the shard channels with at least one subscriber,
+// like Channels.
+func (h *Hub) ShardChannels(pattern string) []string {
+	return h.channels(pattern, true)
+}
+
+func (h *Hub) channels(pattern string, sharded bool) []string {
+	channels := []string{}
+	for _, shard := range h.shards {
+		shard.mu.RLock()
+		for channel := range shard.states(sharded) {
+			if pattern == "" || GlobMatch(pattern, channel) {
+				channels = append(channels, channel)
+			}
+		}
+		shard.mu.RUnlock()
+	}
+	sort.Strings(channels)
+	return channels
+}
+
+// NumSub returns the number of subscribers of each channel, not counting
+// pattern subscribers.
+func (h *Hub) NumSub(channels ...string) []int {
+	return h.numSub(channels, false)
+}
+
+// ShardNumSub returns the number of subscribers of each shard channel.
+func (h *Hub) ShardNumSub(channels ...string) []int {
+	return h.numSub(channels, true)
+}
+
+func (h *Hub) numSub(channels []string, sharded bool) []int {
+	counts := make([]int, len(channels))
+	for i, channel := range channels {
+		shard := h.getShard(channel)
+		shard.mu.RLock()
+		if state, ok := shard.states(sharded)[channel]; ok {
+			counts[i] = len(state.subs)
+		}
+		shard.mu.RUnlock()
+	}
+	return counts
+}
+
+// NumPat returns the number of distinct patterns subscribed to.
+func (h *Hub) NumPat() int {
+	return int(h.numPatterns.Load())
+}
+
+// ChannelStats returns the messages delivered to and dropped for a
+// channel's direct subscribers. Counters live as long as the channel has
+// subscribers; ok is false for channels without any.
+func (h *Hub) ChannelStats(channel string) (delivered, dropped uint64, ok bool) {
+	shard := h.getShard(channel)
+	shard.mu.RLock()
+	defer shard.mu.RUnlock()
+
+	state, ok := shard.subs[channel]
 	if !ok {
-		return
+		return 0, 0, false
 	}
+	return state.delivered.Load(), state.dropped.Load(), true
+}
 
-	// Filter out the specific client channel
-	// Since we returned <-chan (recv only), we might need to change signature or cast.
-	// For simplicity in this lightweight implementation, let's skip explicit unsub for now
-	// and assume the http handler loop exiting is enough (garbage collection).
-	// Proper implementation would require a unique Client ID.
+// Stats returns a snapshot of the Hub-wide counters, summed over the
+// shards.
+func (h *Hub) Stats() HubStats {
+	stats := HubStats{
+		Patterns:     h.NumPat(),
+		Disconnected: h.disconnected.Load(),
+	}
+	for _, shard := range h.shards {
+		shard.mu.RLock()
+		stats.Channels += len(shard.subs)
+		stats.ShardChannels += len(shard.sharded)
+		shard.mu.RUnlock()
+		stats.Delivered += shard.delivered.Load()
+		stats.Dropped += shard.dropped.Load()
+	}
+	return stats
 }
@@ -0,0 +1,347 @@
+package core
+
+import (
+	"fmt"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHubSubscriptions(t *testing.T) {
+	hub := NewHub()
+	sub := hub.NewSubscriber()
+
+	if counts := hub.AddChannels(sub, "a", "b", "a"); fmt.Sprint(counts) != "[1 2 2]" {
+		t.Errorf("AddChannels() = %v, want [1 2 2]", counts)
+	}
+	if n := hub.Publish("a", "hello"); n != 1 {
+		t.Errorf("Publish() = %d, want 1", n)
+	}
+	if msg := <-sub.Messages(); msg != (Message{Channel: "a", Payload: "hello"}) {
+		t.Errorf("Messages() = %+v, want hello on a", msg)
+	}
+
+	channels, counts := hub.RemoveChannels(sub, "a", "nope")
+	if fmt.Sprint(channels, counts) != "[a nope] [1 1]" {
+		t.Errorf("RemoveChannels(a, nope) = %v %v, want [a nope] [1 1]", channels, counts)
+	}
+	if n := hub.Publish("a", "hello"); n != 0 {
+		t.Errorf("Publish() after unsubscribe = %d, want 0", n)
+	}
+
+	hub.AddChannels(sub, "c")
+	channels, counts = hub.RemoveChannels(sub)
+	if fmt.Sprint(channels, counts) != "[b c] [1 0]" {
+		t.Errorf("RemoveChannels() = %v %v, want [b c] [1 0]", channels, counts)
+	}
+	if channels := hub.Channels(""); len(channels) != 0 {
+		t.Errorf("hub still tracks channels %v after all unsubscribed", channels)
+	}
+}
+
+func TestHubRemoveSubscriber(t *testing.T) {
+	hub := NewHub()
+	sub := hub.NewSubscriber()
+	hub.AddChannels(sub, "a", "b")
+	other := hub.NewSubscriber()
+	hub.AddChannels(other, "a")
+	if sub.ID == other.ID {
+		t.Errorf("NewSubscriber() IDs are both %d", sub.ID)
+	}
+
+	hub.RemoveSubscriber(sub)
+	hub.RemoveSubscriber(sub)
+	if _, ok := <-sub.Messages(); ok {
+		t.Error("Messages() still open after RemoveSubscriber")
+	}
+	if n := hub.Publish("a", "x"); n != 1 {
+		t.Errorf("Publish() = %d, want 1", n)
+	}
+	if _, ok := hub.getShard("b").subs["b"]; ok {
+		t.Error("channel b still tracked after its only subscriber was removed")
+	}
+}
+
+func TestHubLegacySubscribe(t *testing.T) {
+	hub := NewHub()
+	ch := hub.Subscribe("news")
+	hub.Publish("news", "hi")
+	if msg := <-ch; msg != "hi" {
+		t.Errorf("Subscribe() received %q, want hi", msg)
+	}
+
+	hub.Unsubscribe("news", ch)
+	if _, ok := <-ch; ok {
+		t.Error("Unsubscribe() did not close the channel")
+	}
+	if n := hub.Publish("news", "hi"); n != 0 {
+		t.Errorf("Publish() after Unsubscribe = %d, want 0", n)
+	}
+}
+
+func TestHubLegacySubscribeStalledReader(t *testing.T) {
+	hub := NewHub()
+	before := runtime.NumGoroutine()
+	ch := hub.Subscribe("news")
+	for i := 0; i < 300; i++ {
+		hub.Publish("news", fmt.Sprint(i))
+	}
+	if stats := hub.Stats(); stats.Dropped == 0 {
+		t.Error("Publish() to a stalled legacy subscriber dropped nothing")
+	}
+
+	// The forwarding goroutine exits although nobody reads ch
+	hub.Unsubscribe("news", ch)
+	deadline := time.Now().Add(5 * time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			t.Fatal("forwarding goroutine still running after Unsubscribe")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	for range ch {
+		// Messages forwarded before Unsubscribe can still be read, then ch
+		// is closed
+	}
+}
+
+func TestGlobMatch(t *testing.T) {
+	tests := []struct {
+		pattern, s string
+		want       bool
+	}{
+		{"*", "", true},
+		{"*", "anything", true},
+		{"orders.*.created", "orders.eu.created", true},
+		{"orders.*.created", "orders.eu.us.created", true},
+		{"orders.*.created", "orders.eu.deleted", false},
+		{"h?llo", "hello", true},
+		{"h?llo", "hllo", false},
+		{"h[ae]llo", "hallo", true},
+		{"h[ae]llo", "hillo", false},
+		{"h[^e]llo", "hallo", true},
+		{"h[^e]llo", "hello", false},
+		{"h[a-c]llo", "hbllo", true},
+		{"h[a-c]llo", "hdllo", false},
+		{`h\*llo`, "h*llo", true},
+		{`h\*llo`, "hello", false},
+		{"a*b*c", "aXXbYYc", true},
+		{"a*b*c", "aXXbYY", false},
+		{"news", "news", true},
+		{"news", "newsy", false},
+	}
+	for _, tt := range tests {
+		if got := GlobMatch(tt.pattern, tt.s); got != tt.want {
+			t.Errorf("GlobMatch(%q, %q) = %v, want %v", tt.pattern, tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestHubPatterns(t *testing.T) {
+	hub := NewHub()
+	sub := hub.NewSubscriber()
+	hub.AddChannels(sub, "orders.eu.created")
+	if counts := hub.AddPatterns(sub, "orders.*.created", "orders.*"); fmt.Sprint(counts) != "[2 3]" {
+		t.Errorf("AddPatterns() = %v, want [2 3]", counts)
+	}
+	other := hub.NewSubscriber()
+	hub.AddPatterns(other, "*")
+
+	// One channel delivery, two pattern deliveries to sub and one to other
+	if n := hub.Publish("orders.eu.created", "x"); n != 4 {
+		t.Errorf("Publish() = %d, want 4", n)
+	}
+	patterns := make(map[string]bool)
+	for i := 0; i < 3; i++ {
+		msg := <-sub.Messages()
+		patterns[msg.Pattern] = true
+		if msg.Channel != "orders.eu.created" || msg.Payload != "x" {
+			t.Errorf("Messages() = %+v", msg)
+		}
+	}
+	if !patterns[""] || !patterns["orders.*.created"] || !patterns["orders.*"] {
+		t.Errorf("delivered patterns = %v, want channel and both patterns", patterns)
+	}
+	if n := hub.Publish("users.1", "x"); n != 1 {
+		t.Errorf("Publish(users.1) = %d, want 1", n)
+	}
+
+	patternsLeft, counts := hub.RemovePatterns(sub)
+	if fmt.Sprint(patternsLeft, counts) != "[orders.* orders.*.created] [2 1]" {
+		t.Errorf("RemovePatterns() = %v %v", patternsLeft, counts)
+	}
+	hub.RemoveSubscriber(other)
+	if hub.patterns.count != 0 || len(hub.patterns.root.children) != 0 || len(hub.patterns.root.patterns) != 0 {
+		t.Errorf("pattern trie not pruned: %d patterns, root %+v", hub.patterns.count, hub.patterns.root)
+	}
+}
+
+func TestHubSlowConsumerPolicies(t *testing.T) {
+	tests := []struct {
+		policy    SlowConsumerPolicy
+		delivered int // Publish results summed over three messages
+		queued    []string
+	}{
+		{DropNewest, 2, []string{"1", "2"}},
+		{DropOldest, 3, []string{"2", "3"}},
+		{Block, 2, []string{"1", "2"}},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.policy), func(t *testing.T) {
+			hub := NewHubWithOptions(HubOptions{BufferSize: 2, Policy: tt.policy, BlockTimeout: time.Millisecond})
+			sub := hub.NewSubscriber()
+			hub.AddChannels(sub, "c")
+
+			delivered := 0
+			for _, msg := range []string{"1", "2", "3"} {
+				delivered += hub.Publish("c", msg)
+			}
+			if delivered != tt.delivered {
+				t.Errorf("Publish() delivered %d, want %d", delivered, tt.delivered)
+			}
+			var queued []string
+			for len(queued) < 2 {
+				queued = append(queued, (<-sub.Messages()).Payload)
+			}
+			if fmt.Sprint(queued) != fmt.Sprint(tt.queued) {
+				t.Errorf("queued messages = %v, want %v", queued, tt.queued)
+			}
+			if sub.Dropped() != 1 || hub.Stats().Dropped != 1 {
+				t.Errorf("Dropped() = %d, hub %d, want 1", sub.Dropped(), hub.Stats().Dropped)
+			}
+		})
+	}
+
+	hub := NewHubWithOptions(HubOptions{BufferSize: 1, Policy: Disconnect})
+	sub := hub.NewSubscriber()
+	hub.AddChannels(sub, "c")
+	hub.AddPatterns(sub, "c*")
+	hub.Publish("c", "1")
+	hub.Publish("c", "2")
+	<-sub.Messages()
+	if _, ok := <-sub.Messages(); ok {
+		t.Error("Messages() still open after a Disconnect")
+	}
+	if stats := hub.Stats(); stats.Disconnected != 1 || stats.Channels != 0 || stats.Patterns != 0 {
+		t.Errorf("Stats() = %+v, want 1 disconnect and nothing subscribed", stats)
+	}
+	if counts := hub.AddChannels(sub, "c"); counts[0] != 0 {
+		t.Errorf("AddChannels() on a disconnected subscriber = %v, want [0]", counts)
+	}
+}
+
+func TestHubIntrospection(t *testing.T) {
+	hub := NewHubWithOptions(HubOptions{BufferSize: 1})
+	a, b := hub.NewSubscriber(), hub.NewSubscriber()
+	hub.AddChannels(a, "news.eu", "news.us")
+	hub.AddChannels(b, "news.eu", "sports")
+	hub.AddPatterns(a, "news.*")
+	hub.AddPatterns(b, "news.*", "*")
+
+	if got := hub.Channels(""); fmt.Sprint(got) != "[news.eu news.us sports]" {
+		t.Errorf("Channels() = %v", got)
+	}
+	if got := hub.Channels("news.*"); fmt.Sprint(got) != "[news.eu news.us]" {
+		t.Errorf("Channels(news.*) = %v", got)
+	}
+	if got := hub.NumSub("news.eu", "sports", "nope"); fmt.Sprint(got) != "[2 1 0]" {
+		t.Errorf("NumSub() = %v, want [2 1 0]", got)
+	}
+	if got := hub.NumPat(); got != 2 {
+		t.Errorf("NumPat() = %d, want 2", got)
+	}
+
+	hub.Publish("sports", "1")
+	hub.Publish("sports", "2") // b's buffer is full
+	if delivered, dropped, ok := hub.ChannelStats("sports"); !ok || delivered != 1 || dropped != 1 {
+		t.Errorf("ChannelStats(sports) = %d %d %v, want 1 1 true", delivered, dropped, ok)
+	}
+	if _, _, ok := hub.ChannelStats("nope"); ok {
+		t.Error("ChannelStats() of a channel without subscribers should not be ok")
+	}
+}
+
+func TestHubShardChannels(t *testing.T) {
+	hub := NewHub()
+	sub := hub.NewSubscriber()
+	hub.AddChannels(sub, "a")
+	hub.AddPatterns(sub, "*")
+	if counts := hub.AddShardChannels(sub, "a", "b", "a"); fmt.Sprint(counts) != "[1 2 2]" {
+		t.Errorf("AddShardChannels() = %v, want [1 2 2]", counts)
+	}
+	if n := hub.SubscriptionCount(sub); n != 2 {
+		t.Errorf("SubscriptionCount() = %d, want 2 without shard channels", n)
+	}
+
+	// Shard channels are not seen by channel or pattern subscribers
+	if n := hub.SPublish("a", "x"); n != 1 {
+		t.Errorf("SPublish() = %d, want 1", n)
+	}
+	if msg := <-sub.Messages(); msg != (Message{Channel: "a", Payload: "x", Sharded: true}) {
+		t.Errorf("Messages() = %+v, want a sharded message on a", msg)
+	}
+	if n := hub.SPublish("c", "x"); n != 0 {
+		t.Errorf("SPublish(c) = %d, want 0", n)
+	}
+	if n := hub.Publish("b", "x"); n != 1 {
+		t.Errorf("Publish(b) = %d, want 1 through the pattern only", n)
+	}
+	<-sub.Messages()
+
+	if got := hub.ShardChannels(""); fmt.Sprint(got) != "[a b]" {
+		t.Errorf("ShardChannels() = %v, want [a b]", got)
+	}
+	if got := hub.ShardNumSub("a", "c"); fmt.Sprint(got) != "[1 0]" {
+		t.Errorf("ShardNumSub() = %v, want [1 0]", got)
+	}
+	if stats := hub.Stats(); stats.Channels != 1 || stats.ShardChannels != 2 {
+		t.Errorf("Stats() = %+v, want 1 channel and 2 shard channels", stats)
+	}
+
+	channels, counts := hub.RemoveShardChannels(sub)
+	if fmt.Sprint(channels, counts) != "[a b] [1 0]" {
+		t.Errorf("RemoveShardChannels() = %v %v, want [a b] [1 0]", channels, counts)
+	}
+	hub.AddShardChannels(sub, "a")
+	hub.RemoveSubscriber(sub)
+	if stats := hub.Stats(); stats.Channels != 0 || stats.ShardChannels != 0 || stats.Patterns != 0 {
+		t.Errorf("Stats() after RemoveSubscriber = %+v, want nothing subscribed", stats)
+	}
+}
+
+// BenchmarkHubPublish publishes from parallel goroutines, each to its own
+// channel, so its results across -cpu 1,2,4,8 show how publishing scales
+// with cores. Subscriber buffers fill up after the first message, so it
+// measures the Hub's locking rather than channel sends.
+func BenchmarkHubPublish(b *testing.B) {
+	b.Run("publish", func(b *testing.B) { benchmarkHubPublish(b, false) })
+	b.Run("spublish", func(b *testing.B) { benchmarkHubPublish(b, true) })
+}
+
+func benchmarkHubPublish(b *testing.B, sharded bool) {
+	hub := NewHubWithOptions(HubOptions{BufferSize: 1})
+	channels := make([]string, 256)
+	for i := range channels {
+		channels[i] = fmt.Sprintf("bench:%d", i)
+		sub := hub.NewSubscriber()
+		if sharded {
+			hub.AddShardChannels(sub, channels[i])
+		} else {
+			hub.AddChannels(sub, channels[i])
+		}
+	}
+
+	var next atomic.Uint64
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		channel := channels[next.Add(1)%uint64(len(channels))]
+		for pb.Next() {
+			if sharded {
+				hub.SPublish(channel, "x")
+			} else {
+				hub.Publish(channel, "x")
+			}
+		}
+	})
+}
@@ -0,0 +1,202 @@
+package core
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrNotRetained is returned when resuming a channel without retention.
+var ErrNotRetained = fmt.Errorf("ERR channel is not retained")
+
+// RetentionOptions bounds the messages kept for a retained channel. At
+// least one limit must be set.
+type RetentionOptions struct {
+	MaxLen int           // Keep at most this many messages
+	MaxAge time.Duration // Drop messages older than this
+}
+
+// ReplayFrom selects where a resumed subscription starts: at message Offset
+// if it is non-zero, otherwise at the first message published at or after
+// Since.
+type ReplayFrom struct {
+	Offset uint64
+	Since  time.Time
+}
+
+// Replay is the result of Hub.Resume.
+type Replay struct {
+	Messages []Message // Retained messages from the requested point, oldest first
+	// Gap is set when messages after the requested point were already
+	// trimmed; FirstOffset is then the oldest offset still retained.
+	Gap         bool
+	FirstOffset uint64
+	NextOffset  uint64 // Offset the next published message will get
+}
+
+// retainedMessage is a published message kept for replay.
+type retainedMessage struct {
+	offset  uint64
+	at      time.Time
+	payload string
+}
+
+// retainedChannel is a ring buffer of a channel's recent messages.
+// Offsets start at 1 and increase by one per message.
+type retainedChannel struct {
+	mu      sync.Mutex
+	opts    RetentionOptions
+	ring    []retainedMessage // Grows by doubling while MaxLen allows
+	head    int               // Index of the oldest message
+	size    int
+	next    uint64    // Offset of the next message
+	trimmed bool      // Whether any message has been dropped
+	lastAt  time.Time // Publish time of the newest dropped message
+}
+
+func newRetainedChannel(opts RetentionOptions) *retainedChannel {
+	capacity := 16
+	if opts.MaxLen > 0 && opts.MaxLen < capacity {
+		capacity = opts.MaxLen
+	}
+	return &retainedChannel{opts: opts, ring: make([]retainedMessage, capacity), next: 1}
+}
+
+func (rc *retainedChannel) at(i int) *retainedMessage {
+	return &rc.ring[(rc.head+i)%len(rc.ring)]
+}
+
+// append stores a message and returns its offset. Must be called with
+// rc.mu held.
+func (rc *retainedChannel) append(payload string, now time.Time) uint64 {
+	rc.trim(now)
+	// A loop because Retain may have lowered MaxLen
+	for rc.opts.MaxLen > 0 && rc.size >= rc.opts.MaxLen {
+		rc.dropOldest()
+	}
+	if rc.size == len(rc.ring) {
+		capacity := 2 * len(rc.ring)
+		if rc.opts.MaxLen > rc.size && capacity > rc.opts.MaxLen {
+			capacity = rc.opts.MaxLen
+		}
+		grown := make([]retainedMessage, capacity)
+		for i := 0; i < rc.size; i++ {
+			grown[i] = *rc.at(i)
+		}
+		rc.ring, rc.head = grown, 0
+	}
+	offset := rc.next
+	rc.next++
+	*rc.at(rc.size) = retainedMessage{offset: offset, at: now, payload: payload}
+	rc.size++
+	return offset
+}
+
+func (rc *retainedChannel) dropOldest() {
+	rc.lastAt = rc.at(0).at
+	*rc.at(0) = retainedMessage{}
+	rc.head = (rc.head + 1) % len(rc.ring)
+	rc.size--
+	rc.trimmed = true
+}
+
+// trim drops messages older than MaxAge. Must be called with rc.mu held.
+func (rc *retainedChannel) trim(now time.Time) {
+	if rc.opts.MaxAge <= 0 {
+		return
+	}
+	cutoff := now.Add(-rc.opts.MaxAge)
+	for rc.size > 0 && rc.at(0).at.Before(cutoff) {
+		rc.dropOldest()
+	}
+}
+
+// replay collects the messages from the requested point. Must be called
+// with rc.mu held.
+func (rc *retainedChannel) replay(channel string, from ReplayFrom, now time.Time) Replay {
+	rc.trim(now)
+	first := rc.next
+	if rc.size > 0 {
+		first = rc.at(0).offset
+	}
+	result := Replay{FirstOffset: first, NextOffset: rc.next}
+
+	start := rc.size
+	if from.Offset > 0 {
+		if from.Offset < first {
+			result.Gap = true
+			start = 0
+		} else if from.Offset < rc.next {
+			start = int(from.Offset - first)
+		}
+	} else {
+		start = 0
+		for start < rc.size && rc.at(start).at.Before(from.Since) {
+			start++
+		}
+		result.Gap = rc.trimmed && !rc.lastAt.Before(from.Since)
+	}
+
+	for i := start; i < rc.size; i++ {
+		m := rc.at(i)
+		result.Messages = append(result.Messages, Message{Channel: channel, Payload: m.payload, Offset: m.offset})
+	}
+	return result
+}
+
+// Retain keeps the recent messages published on channel so subscribers can
+// resume from an offset or a point in time. Calling it again changes the
+// limits; already retained messages are kept until the next publish trims
+// them.
+func (h *Hub) Retain(channel string, opts RetentionOptions) error {
+	if opts.MaxLen < 0 || opts.MaxAge < 0 || (opts.MaxLen == 0 && opts.MaxAge == 0) {
+		return fmt.Errorf("ERR retention needs a positive MAXLEN or MAXAGE")
+	}
+	shard := h.getShard(channel)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	if rc, ok := shard.retained[channel]; ok {
+		rc.mu.Lock()
+		rc.opts = opts
+		rc.mu.Unlock()
+		return nil
+	}
+	shard.retained[channel] = newRetainedChannel(opts)
+	return nil
+}
+
+// StopRetaining discards channel's retained messages and offsets.
+func (h *Hub) StopRetaining(channel string) {
+	shard := h.getShard(channel)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	delete(shard.retained, channel)
+}
+
+// Resume subscribes sub to a retained channel and returns the retained
+// messages from the requested point. Both happen under the write lock of
+// the channel's shard, so every later message is delivered live and none is missed or
+// repeated between the replay and the subscription. Live messages on
+// this channel carry their Offset.
+func (h *Hub) Resume(sub *Subscriber, channel string, from ReplayFrom) (Replay, error) {
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+	shard := h.getShard(channel)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	rc, ok := shard.retained[channel]
+	if !ok {
+		return Replay{}, ErrNotRetained
+	}
+	rc.mu.Lock()
+	result := rc.replay(channel, from, time.Now())
+	rc.mu.Unlock()
+
+	if !sub.closed {
+		sub.channels[channel] = struct{}{}
+		shard.add(shard.subs, channel, sub, true)
+	}
+	return result, nil
+}
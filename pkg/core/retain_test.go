@@ -0,0 +1,112 @@
+package core
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func payloads(msgs []Message) string {
+	var out []string
+	for _, msg := range msgs {
+		out = append(out, fmt.Sprintf("%d:%s", msg.Offset, msg.Payload))
+	}
+	return fmt.Sprint(out)
+}
+
+func TestRetainedReplay(t *testing.T) {
+	hub := NewHub()
+	if err := hub.Retain("orders", RetentionOptions{MaxLen: 3}); err != nil {
+		t.Fatalf("Retain() error = %v", err)
+	}
+	// Published with no subscribers at all
+	for i := 1; i <= 5; i++ {
+		hub.Publish("orders", fmt.Sprint("m", i))
+	}
+
+	tests := []struct {
+		from ReplayFrom
+		want string
+		gap  bool
+	}{
+		{ReplayFrom{Offset: 4}, "[4:m4 5:m5]", false},
+		{ReplayFrom{Offset: 3}, "[3:m3 4:m4 5:m5]", false},
+		{ReplayFrom{Offset: 1}, "[3:m3 4:m4 5:m5]", true},
+		{ReplayFrom{Offset: 6}, "[]", false},
+		{ReplayFrom{Since: time.Now().Add(-time.Hour)}, "[3:m3 4:m4 5:m5]", true},
+		{ReplayFrom{Since: time.Now().Add(time.Hour)}, "[]", false},
+	}
+	for _, tt := range tests {
+		sub := hub.NewSubscriber()
+		replay, err := hub.Resume(sub, "orders", tt.from)
+		if err != nil {
+			t.Fatalf("Resume(%+v) error = %v", tt.from, err)
+		}
+		if got := payloads(replay.Messages); got != tt.want || replay.Gap != tt.gap {
+			t.Errorf("Resume(%+v) = %s gap %v, want %s gap %v", tt.from, got, replay.Gap, tt.want, tt.gap)
+		}
+		if replay.FirstOffset != 3 || replay.NextOffset != 6 {
+			t.Errorf("Resume() offsets = %d..%d, want 3..6", replay.FirstOffset, replay.NextOffset)
+		}
+		hub.RemoveSubscriber(sub)
+	}
+}
+
+func TestRetainedLiveOffsets(t *testing.T) {
+	hub := NewHub()
+	hub.Retain("c", RetentionOptions{MaxLen: 100})
+	hub.Publish("c", "a")
+
+	resumed, plain := hub.NewSubscriber(), hub.NewSubscriber()
+	replay, _ := hub.Resume(resumed, "c", ReplayFrom{Offset: 1})
+	hub.AddChannels(plain, "c")
+	hub.Publish("c", "b")
+
+	if got := payloads(replay.Messages) + payloads([]Message{<-resumed.Messages()}); got != "[1:a][2:b]" {
+		t.Errorf("replay then live = %s, want [1:a][2:b]", got)
+	}
+	if msg := <-plain.Messages(); msg.Offset != 0 {
+		t.Errorf("plain subscriber got offset %d, want 0", msg.Offset)
+	}
+}
+
+func TestRetainedMaxAge(t *testing.T) {
+	hub := NewHub()
+	hub.Retain("c", RetentionOptions{MaxAge: time.Minute})
+	hub.Publish("c", "old")
+	rc := hub.getShard("c").retained["c"]
+	rc.at(0).at = time.Now().Add(-2 * time.Minute)
+	hub.Publish("c", "new")
+
+	replay, _ := hub.Resume(hub.NewSubscriber(), "c", ReplayFrom{Offset: 1})
+	if got := payloads(replay.Messages); got != "[2:new]" || !replay.Gap {
+		t.Errorf("Resume() = %s gap %v, want [2:new] with a gap", got, replay.Gap)
+	}
+}
+
+func TestRetainedRingGrowth(t *testing.T) {
+	hub := NewHub()
+	hub.Retain("c", RetentionOptions{MaxLen: 40})
+	for i := 1; i <= 100; i++ {
+		hub.Publish("c", fmt.Sprint(i))
+	}
+	replay, _ := hub.Resume(hub.NewSubscriber(), "c", ReplayFrom{Offset: 61})
+	if len(replay.Messages) != 40 || replay.Messages[0].Payload != "61" || replay.Messages[39].Payload != "100" {
+		t.Errorf("Resume() = %s, want 61..100", payloads(replay.Messages))
+	}
+}
+
+func TestRetainErrors(t *testing.T) {
+	hub := NewHub()
+	if err := hub.Retain("c", RetentionOptions{}); err == nil {
+		t.Error("Retain() without limits should error")
+	}
+	if _, err := hub.Resume(hub.NewSubscriber(), "c", ReplayFrom{Offset: 1}); err != ErrNotRetained {
+		t.Errorf("Resume() error = %v, want ErrNotRetained", err)
+	}
+	hub.Retain("c", RetentionOptions{MaxLen: 1})
+	hub.StopRetaining("c")
+	if _, err := hub.Resume(hub.NewSubscriber(), "c", ReplayFrom{Offset: 1}); err != ErrNotRetained {
+		t.Errorf("Resume() after StopRetaining error = %v, want ErrNotRetained", err)
+	}
+}
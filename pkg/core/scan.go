@@ -0,0 +1,74 @@
+package core
+
+// scanTop marks a cursor position at the top of a shard's key list.
+const scanTop = 1<<32 - 1
+
+// Scan returns about count keys starting from cursor and the cursor to
+// continue from, which is 0 once every shard has been visited. Start with
+// cursor 0. A key present for the whole iteration is returned at least
+// once; keys added or removed meanwhile may or may not be.
+//
+// The cursor holds a shard number and a position in that shard's key list,
+// which is walked from the end. Removing a key moves the last key into its
+// place, so the keys not yet visited always stay below the position.
+func (s *KVStore) Scan(cursor uint64, count int) (uint64, []string) {
+	if count <= 0 {
+		count = 10
+	}
+	shardIdx, pos := cursor>>32, cursor&scanTop
+	if cursor == 0 {
+		pos = scanTop
+	}
+	var keys []string
+	for shardIdx < ShardCount {
+		shard := s.shards[shardIdx]
+		shard.mu.RLock()
+		pos = min(pos, uint64(len(shard.keys)))
+		for ; pos > 0 && len(keys) < count; pos-- {
+			key := shard.keys[pos-1]
+			if !shard.data[key].isExpired() {
+				keys = append(keys, key)
+			}
+		}
+		shard.mu.RUnlock()
+		if pos > 0 {
+			return shardIdx<<32 | pos, keys
+		}
+		shardIdx, pos = shardIdx+1, scanTop
+		if len(keys) >= count && shardIdx < ShardCount {
+			return shardIdx<<32 | pos, keys
+		}
+	}
+	return 0, keys
+}
+
+// Type returns the type of the value at key, with the names Redis uses, or
+// "none" if the key does not exist.
+func (s *KVStore) Type(key string) string {
+	shard := s.getShard(key)
+	shard.mu.RLock()
+	defer shard.mu.RUnlock()
+	entry, ok := shard.data[key]
+	if !ok || entry.isExpired() {
+		return "none"
+	}
+	switch entry.Value.(type) {
+	case string, []byte:
+		return "string"
+	case map[string]string:
+		return "hash"
+	case *SortedSet:
+		return "zset"
+	case *BloomFilter:
+		return "MBbloom--"
+	case *CuckooFilter:
+		return "MBbloomCF"
+	case *CountMinSketch:
+		return "CMSk-TYPE"
+	case *JSONDocument:
+		return "ReJSON-RL"
+	case *TimeSeries:
+		return "TSDB-TYPE"
+	}
+	return "unknown"
+}
@@ -0,0 +1,58 @@
+package core
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestScan(t *testing.T) {
+	store := NewKVStore()
+	for i := 0; i < 1000; i++ {
+		store.Set(fmt.Sprintf("key:%d", i), "v", 0)
+	}
+
+	seen := make(map[string]bool)
+	cursor, calls := uint64(0), 0
+	for {
+		next, keys := store.Scan(cursor, 50)
+		for _, key := range keys {
+			seen[key] = true
+		}
+		// Deleting keys mid-scan must not hide the ones that stay
+		if calls == 3 {
+			for i := 900; i < 1000; i++ {
+				store.Delete(fmt.Sprintf("key:%d", i))
+			}
+		}
+		calls++
+		if cursor = next; cursor == 0 {
+			break
+		}
+	}
+	for i := 0; i < 900; i++ {
+		if key := fmt.Sprintf("key:%d", i); !seen[key] {
+			t.Fatalf("Scan() never returned %s", key)
+		}
+	}
+	if calls < 10 {
+		t.Errorf("Scan() took %d calls for 1000 keys at COUNT 50", calls)
+	}
+
+	if _, keys := NewKVStore().Scan(0, 10); len(keys) != 0 {
+		t.Errorf("Scan() on an empty store = %v", keys)
+	}
+}
+
+func TestType(t *testing.T) {
+	store := NewKVStore()
+	store.Set("s", "v", 0)
+	store.HSet("h", "f", "v")
+	store.SetBit("b", 3, 1)
+	store.JSONSet("j", "$", `{"a":1}`, JSONSetOptions{})
+
+	for key, want := range map[string]string{"s": "string", "h": "hash", "b": "string", "j": "ReJSON-RL", "missing": "none"} {
+		if got := store.Type(key); got != want {
+			t.Errorf("Type(%s) = %q, want %q", key, got, want)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package core
+
+import "strings"
+
+// SlotCount is the number of hash slots keys are spread over in cluster
+// mode.
+const SlotCount = 16384
+
+// crc16Table is the CRC16-CCITT (XMODEM) table used by Redis Cluster.
+var crc16Table = func() (table [256]uint16) {
+	for i := range table {
+		crc := uint16(i) << 8
+		for range 8 {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+		table[i] = crc
+	}
+	return table
+}()
+
+func crc16(s string) uint16 {
+	var crc uint16
+	for i := 0; i < len(s); i++ {
+		crc = crc<<8 ^ crc16Table[byte(crc>>8)^s[i]]
+	}
+	return crc
+}
+
+// KeySlot returns the hash slot of key, the same one Redis Cluster uses.
+// If the key contains a non-empty {hashtag}, only the tag is hashed, so
+// "{user:1}.name" and "{user:1}.email" share a slot.
+func KeySlot(key string) int {
+	if start := strings.IndexByte(key, '{'); start >= 0 {
+		if end := strings.IndexByte(key[start+1:], '}'); end > 0 {
+			key = key[start+1 : start+1+end]
+		}
+	}
+	return int(crc16(key)) % SlotCount
+}
+
+// KeysInSlot returns up to count live keys that hash to slot.
+func (s *KVStore) KeysInSlot(slot, count int) []string {
+	var keys []string
+	for _, shard := range s.shards {
+		shard.mu.RLock()
+		for key, entry := range shard.data {
+			if len(keys) == count {
+				break
+			}
+			if !entry.isExpired() && KeySlot(key) == slot {
+				keys = append(keys, key)
+			}
+		}
+		shard.mu.RUnlock()
+	}
+	return keys
+}
+
+// CountKeysInSlot returns the number of live keys that hash to slot.
+func (s *KVStore) CountKeysInSlot(slot int) int {
+	n := 0
+	for _, shard := range s.shards {
+		shard.mu.RLock()
+		for key, entry := range shard.data {
+			if !entry.isExpired() && KeySlot(key) == slot {
+				n++
+			}
+		}
+		shard.mu.RUnlock()
+	}
+	return n
+}
+
+// Exists returns how many of keys exist.
+func (s *KVStore) Exists(keys ...string) int {
+	n := 0
+	for _, key := range keys {
+		shard := s.getShard(key)
+		shard.mu.RLock()
+		if entry, ok := shard.data[key]; ok && !entry.isExpired() {
+			n++
+		}
+		shard.mu.RUnlock()
+	}
+	return n
+}
@@ -0,0 +1,48 @@
+package core
+
+import "testing"
+
+func TestKeySlot(t *testing.T) {
+	tests := []struct {
+		key  string
+		want int
+	}{
+		{"123456789", 0x31C3 % SlotCount},
+		{"foo", 12182},
+		{"bar", 5061},
+		{"{user1000}.following", 3443},
+		{"{user1000}.followers", 3443},
+		{"user1000", 3443},
+		{"foo{}{bar}", KeySlot("foo{}{bar}")},
+		{"foo{{bar}}zap", KeySlot("{bar")},
+		{"foo{bar}{zap}", KeySlot("bar")},
+		{"", 0},
+	}
+	for _, tt := range tests {
+		if got := KeySlot(tt.key); got != tt.want {
+			t.Errorf("KeySlot(%q) = %d, want %d", tt.key, got, tt.want)
+		}
+	}
+	if KeySlot("foo{}{bar}") == KeySlot("bar") {
+		t.Errorf("an empty {} must not make the next tag count")
+	}
+}
+
+func TestKeysInSlot(t *testing.T) {
+	store := NewKVStore()
+	store.Set("{a}1", "x", 0)
+	store.Set("{a}2", "x", 0)
+	store.HSet("{a}3", "f", "v")
+	store.Set("b", "x", 0)
+
+	slot := KeySlot("a")
+	if got := store.CountKeysInSlot(slot); got != 3 {
+		t.Errorf("CountKeysInSlot() = %d, want 3", got)
+	}
+	if got := store.KeysInSlot(slot, 2); len(got) != 2 {
+		t.Errorf("KeysInSlot(count 2) = %v, want 2 keys", got)
+	}
+	if got := store.Exists("{a}1", "b", "missing"); got != 2 {
+		t.Errorf("Exists() = %d, want 2", got)
+	}
+}
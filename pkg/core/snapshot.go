@@ -0,0 +1,559 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// A snapshot is the magic header followed by one record per key and per
+// index, and an end marker. Integers are varints, strings and blobs are
+// length-prefixed and floats are their IEEE 754 bits:
+//
+//	"SUSYSNAP" version
+//	type key expires-at value   (one per key)
+//	'I' index-schema            (one per index)
+//	0xFF
+const (
+	snapshotMagic   = "SUSYSNAP"
+	snapshotVersion = 1
+
+	snapString    byte = 's'
+	snapBytes     byte = 'b'
+	snapHash      byte = 'h'
+	snapSortedSet byte = 'z'
+	snapBloom     byte = 'B'
+	snapCuckoo    byte = 'C'
+	snapCMS       byte = 'M'
+	snapJSON      byte = 'J'
+	snapTimeSer   byte = 'T'
+	snapIndex     byte = 'I'
+	snapEOF       byte = 0xFF
+)
+
+var (
+	// ErrBadSnapshot is returned when loading data that is not a valid snapshot.
+	ErrBadSnapshot = errors.New("ERR invalid snapshot")
+	// ErrBadDump is returned by Restore for a payload Dump did not produce.
+	ErrBadDump = errors.New("ERR DUMP payload version or checksum are wrong")
+	// ErrBusyKey is returned by Restore when the key exists and may not be
+	// replaced.
+	ErrBusyKey = errors.New("BUSYKEY Target key name already exists.")
+)
+
+// WriteSnapshot writes every live key and index definition to w. Each shard
+// is read under its read lock, so the snapshot is only consistent across
+// shards if writers are paused while it is taken.
+func (s *KVStore) WriteSnapshot(w io.Writer) error {
+	enc := &snapshotEncoder{w: bufio.NewWriter(w)}
+	enc.w.WriteString(snapshotMagic)
+	enc.uvarint(snapshotVersion)
+
+	for _, shard := range s.shards {
+		shard.mu.RLock()
+		for key, entry := range shard.data {
+			if !entry.isExpired() {
+				enc.entry(key, entry)
+			}
+		}
+		shard.mu.RUnlock()
+	}
+	for _, idx := range s.indexes.list() {
+		enc.index(idx.schema)
+	}
+	enc.w.WriteByte(snapEOF)
+	if enc.err != nil {
+		return enc.err
+	}
+	return enc.w.Flush()
+}
+
+// LoadSnapshot replaces the store's keys and indexes with the snapshot read
+// from r. On error the store holds whatever was loaded before it.
+func (s *KVStore) LoadSnapshot(r io.Reader) error {
+	dec := &snapshotDecoder{r: bufio.NewReader(r)}
+	magic := make([]byte, len(snapshotMagic))
+	if _, err := io.ReadFull(dec.r, magic); err != nil || string(magic) != snapshotMagic {
+		return ErrBadSnapshot
+	}
+	if version := dec.uvarint(); dec.err == nil && version != snapshotVersion {
+		return fmt.Errorf("ERR unsupported snapshot version %d", version)
+	}
+
+	s.flush()
+	for dec.err == nil {
+		kind := dec.byte()
+		switch {
+		case dec.err != nil:
+		case kind == snapEOF:
+			return nil
+		case kind == snapIndex:
+			schema := dec.index()
+			if dec.err == nil {
+				dec.err = s.CreateIndex(schema)
+			}
+		default:
+			key := dec.string()
+			expiresAt := dec.varint()
+			value := dec.value(kind)
+			if dec.err == nil {
+				dec.err = s.restoreEntry(key, Entry{Value: value, ExpiresAt: expiresAt})
+			}
+		}
+	}
+	if dec.err == io.EOF || dec.err == io.ErrUnexpectedEOF {
+		return ErrBadSnapshot
+	}
+	return dec.err
+}
+
+// flush deletes every key and drops every index.
+func (s *KVStore) flush() {
+	s.indexes.mu.Lock()
+	s.indexes.indexes.Store(nil)
+	s.indexes.mu.Unlock()
+
+	for _, shard := range s.shards {
+		shard.mu.Lock()
+		for key := range shard.data {
+			s.removeEntry(shard, key)
+		}
+		shard.mu.Unlock()
+	}
+}
+
+// restoreEntry stores a loaded key, replacing any existing value.
+func (s *KVStore) restoreEntry(key string, entry Entry) error {
+	shard := s.getShard(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	s.removeEntry(shard, key)
+	if err := s.createEntry(shard, key, entry.Value); err != nil {
+		return err
+	}
+	shard.data[key] = entry
+	if hash, ok := entry.Value.(map[string]string); ok {
+		s.indexHash(key, hash)
+	}
+	return nil
+}
+
+// Dump serializes the value at key, for Restore on this or another store.
+// The payload is the snapshot version and a snapshot record without key or
+// expiry; the remaining TTL is returned separately, zero meaning none.
+func (s *KVStore) Dump(key string) ([]byte, time.Duration, bool) {
+	shard := s.getShard(key)
+	shard.mu.RLock()
+	defer shard.mu.RUnlock()
+	entry, ok := shard.data[key]
+	if !ok || entry.isExpired() {
+		return nil, 0, false
+	}
+	var ttl time.Duration
+	if entry.ExpiresAt > 0 {
+		ttl = max(time.Until(time.Unix(0, entry.ExpiresAt)), time.Millisecond)
+	}
+
+	var buf bytes.Buffer
+	enc := &snapshotEncoder{w: bufio.NewWriter(&buf)}
+	enc.uvarint(snapshotVersion)
+	enc.entry("", Entry{Value: entry.Value})
+	if enc.err != nil || enc.w.Flush() != nil {
+		return nil, 0, false
+	}
+	return buf.Bytes(), ttl, true
+}
+
+// Restore creates key from a Dump payload, expiring after ttl unless ttl
+// is zero. An existing key is only overwritten if replace is set.
+func (s *KVStore) Restore(key string, payload []byte, ttl time.Duration, replace bool) error {
+	dec := &snapshotDecoder{r: bufio.NewReader(bytes.NewReader(payload))}
+	if dec.uvarint() != snapshotVersion {
+		return ErrBadDump
+	}
+	kind := dec.byte()
+	dec.string()
+	dec.varint()
+	value := dec.value(kind)
+	if _, err := dec.r.ReadByte(); dec.err != nil || err != io.EOF {
+		return ErrBadDump
+	}
+	entry := Entry{Value: value}
+	if ttl > 0 {
+		entry.ExpiresAt = time.Now().Add(ttl).UnixNano()
+	}
+
+	shard := s.getShard(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	if _, exists := s.liveEntry(shard, key); exists && !replace {
+		return ErrBusyKey
+	}
+	s.removeEntry(shard, key)
+	if err := s.createEntry(shard, key, value); err != nil {
+		return err
+	}
+	shard.data[key] = entry
+	if hash, ok := value.(map[string]string); ok {
+		s.indexHash(key, hash)
+	}
+	s.notify(notifyGeneric, "restore", key)
+	return nil
+}
+
+type snapshotEncoder struct {
+	w   *bufio.Writer
+	buf [binary.MaxVarintLen64]byte
+	err error
+}
+
+func (e *snapshotEncoder) uvarint(v uint64) {
+	n := binary.PutUvarint(e.buf[:], v)
+	e.write(e.buf[:n])
+}
+
+func (e *snapshotEncoder) varint(v int64) {
+	n := binary.PutVarint(e.buf[:], v)
+	e.write(e.buf[:n])
+}
+
+func (e *snapshotEncoder) float(f float64) {
+	e.uvarint(math.Float64bits(f))
+}
+
+func (e *snapshotEncoder) string(s string) {
+	e.uvarint(uint64(len(s)))
+	if _, err := e.w.WriteString(s); err != nil && e.err == nil {
+		e.err = err
+	}
+}
+
+func (e *snapshotEncoder) write(b []byte) {
+	if _, err := e.w.Write(b); err != nil && e.err == nil {
+		e.err = err
+	}
+}
+
+func (e *snapshotEncoder) entry(key string, entry Entry) {
+	switch v := entry.Value.(type) {
+	case string:
+		e.header(snapString, key, entry)
+		e.string(v)
+	case []byte:
+		e.header(snapBytes, key, entry)
+		e.string(string(v))
+	case map[string]string:
+		e.header(snapHash, key, entry)
+		e.uvarint(uint64(len(v)))
+		for field, value := range v {
+			e.string(field)
+			e.string(value)
+		}
+	case *SortedSet:
+		e.header(snapSortedSet, key, entry)
+		e.uvarint(uint64(len(v.dict)))
+		for member, score := range v.dict {
+			e.string(member)
+			e.float(score)
+		}
+	case *BloomFilter:
+		e.header(snapBloom, key, entry)
+		e.float(v.config.ErrorRate)
+		e.uvarint(v.config.Capacity)
+		e.uvarint(uint64(v.config.Expansion))
+		e.bool(v.config.NonScaling)
+		e.uvarint(uint64(len(v.layers)))
+		for _, layer := range v.layers {
+			e.uvarint(layer.size)
+			e.uvarint(layer.hashes)
+			e.uvarint(layer.capacity)
+			e.uvarint(layer.count)
+			e.uint64s(layer.bits)
+		}
+	case *CuckooFilter:
+		e.header(snapCuckoo, key, entry)
+		e.uvarint(v.config.Capacity)
+		e.uvarint(uint64(v.config.BucketSize))
+		e.uvarint(uint64(v.config.MaxIterations))
+		e.uvarint(uint64(v.config.Expansion))
+		e.uvarint(v.count)
+		e.uvarint(uint64(len(v.layers)))
+		for _, layer := range v.layers {
+			e.uvarint(layer.numBuckets)
+			e.uvarint(uint64(layer.bucketSize))
+			e.string(string(layer.buckets))
+		}
+	case *CountMinSketch:
+		e.header(snapCMS, key, entry)
+		e.uvarint(v.width)
+		e.uvarint(v.depth)
+		e.uvarint(v.count)
+		e.uint64s(v.counters)
+	case *JSONDocument:
+		e.header(snapJSON, key, entry)
+		e.string(marshalJSON(v.root))
+	case *TimeSeries:
+		e.header(snapTimeSer, key, entry)
+		e.varint(v.retention)
+		e.uvarint(uint64(len(v.samples)))
+		for _, sample := range v.samples {
+			e.varint(sample.Timestamp)
+			e.float(sample.Value)
+		}
+		e.uvarint(uint64(len(v.rules)))
+		for _, rule := range v.rules {
+			e.string(rule.destKey)
+			e.uvarint(uint64(rule.aggregation))
+			e.varint(rule.duration)
+			e.varint(rule.bucketStart)
+			e.float(rule.acc.sum)
+			e.float(rule.acc.min)
+			e.float(rule.acc.max)
+			e.varint(rule.acc.count)
+		}
+	default:
+		if e.err == nil {
+			e.err = fmt.Errorf("snapshot: key %q has unsupported type %T", key, v)
+		}
+	}
+}
+
+func (e *snapshotEncoder) header(kind byte, key string, entry Entry) {
+	e.write([]byte{kind})
+	e.string(key)
+	e.varint(entry.ExpiresAt)
+}
+
+func (e *snapshotEncoder) bool(b bool) {
+	if b {
+		e.uvarint(1)
+	} else {
+		e.uvarint(0)
+	}
+}
+
+func (e *snapshotEncoder) uint64s(words []uint64) {
+	e.uvarint(uint64(len(words)))
+	for _, w := range words {
+		e.uvarint(w)
+	}
+}
+
+func (e *snapshotEncoder) index(schema IndexSchema) {
+	e.write([]byte{snapIndex})
+	e.string(schema.Name)
+	e.uvarint(uint64(len(schema.Prefixes)))
+	for _, prefix := range schema.Prefixes {
+		e.string(prefix)
+	}
+	e.uvarint(uint64(len(schema.Fields)))
+	for _, f := range schema.Fields {
+		e.string(f.Name)
+		e.uvarint(uint64(f.Type))
+		if f.Type == IndexVector {
+			v := f.Vector
+			e.uvarint(uint64(v.Algorithm))
+			e.uvarint(uint64(v.Dim))
+			e.uvarint(uint64(v.Metric))
+			e.uvarint(uint64(v.M))
+			e.uvarint(uint64(v.EFConstruction))
+			e.uvarint(uint64(v.EFRuntime))
+		}
+	}
+}
+
+type snapshotDecoder struct {
+	r   *bufio.Reader
+	err error
+}
+
+// maxSnapshotLen bounds a single length prefix so that a corrupt snapshot
+// fails instead of allocating without limit.
+const maxSnapshotLen = 1 << 32
+
+func (d *snapshotDecoder) byte() byte {
+	if d.err != nil {
+		return 0
+	}
+	b, err := d.r.ReadByte()
+	d.err = err
+	return b
+}
+
+func (d *snapshotDecoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	v, err := binary.ReadUvarint(d.r)
+	d.err = err
+	return v
+}
+
+func (d *snapshotDecoder) varint() int64 {
+	if d.err != nil {
+		return 0
+	}
+	v, err := binary.ReadVarint(d.r)
+	d.err = err
+	return v
+}
+
+func (d *snapshotDecoder) float() float64 {
+	return math.Float64frombits(d.uvarint())
+}
+
+func (d *snapshotDecoder) bool() bool {
+	return d.uvarint() != 0
+}
+
+// length reads a count or size prefix.
+func (d *snapshotDecoder) length() int {
+	n := d.uvarint()
+	if n > maxSnapshotLen && d.err == nil {
+		d.err = ErrBadSnapshot
+	}
+	if d.err != nil {
+		return 0
+	}
+	return int(n)
+}
+
+func (d *snapshotDecoder) string() string {
+	n := d.length()
+	if d.err != nil {
+		return ""
+	}
+	buf := make([]byte, n)
+	_, d.err = io.ReadFull(d.r, buf)
+	return string(buf)
+}
+
+func (d *snapshotDecoder) uint64s() []uint64 {
+	words := make([]uint64, d.length())
+	for i := range words {
+		words[i] = d.uvarint()
+	}
+	return words
+}
+
+func (d *snapshotDecoder) value(kind byte) interface{} {
+	switch kind {
+	case snapString:
+		return d.string()
+	case snapBytes:
+		return []byte(d.string())
+	case snapHash:
+		n := d.length()
+		hash := make(map[string]string, n)
+		for i := 0; i < n && d.err == nil; i++ {
+			field := d.string()
+			hash[field] = d.string()
+		}
+		return hash
+	case snapSortedSet:
+		zset := newSortedSet()
+		n := d.length()
+		for i := 0; i < n && d.err == nil; i++ {
+			member := d.string()
+			zset.add(member, d.float())
+		}
+		return zset
+	case snapBloom:
+		bf := &BloomFilter{config: BloomConfig{
+			ErrorRate:  d.float(),
+			Capacity:   d.uvarint(),
+			Expansion:  uint(d.uvarint()),
+			NonScaling: d.bool(),
+		}}
+		n := d.length()
+		for i := 0; i < n && d.err == nil; i++ {
+			layer := &bloomLayer{size: d.uvarint(), hashes: d.uvarint(), capacity: d.uvarint(), count: d.uvarint()}
+			layer.bits = d.uint64s()
+			if d.err == nil && uint64(len(layer.bits)) != (layer.size+63)/64 {
+				d.err = ErrBadSnapshot
+			}
+			bf.layers = append(bf.layers, layer)
+		}
+		return bf
+	case snapCuckoo:
+		cf := &CuckooFilter{config: CuckooConfig{
+			Capacity:      d.uvarint(),
+			BucketSize:    int(d.uvarint()),
+			MaxIterations: int(d.uvarint()),
+			Expansion:     uint(d.uvarint()),
+		}}
+		cf.count = d.uvarint()
+		n := d.length()
+		for i := 0; i < n && d.err == nil; i++ {
+			layer := &cuckooLayer{numBuckets: d.uvarint(), bucketSize: int(d.uvarint())}
+			layer.buckets = []byte(d.string())
+			if d.err == nil && uint64(len(layer.buckets)) != layer.numBuckets*uint64(layer.bucketSize) {
+				d.err = ErrBadSnapshot
+			}
+			cf.layers = append(cf.layers, layer)
+		}
+		return cf
+	case snapCMS:
+		cms := &CountMinSketch{width: d.uvarint(), depth: d.uvarint(), count: d.uvarint()}
+		cms.counters = d.uint64s()
+		if d.err == nil && uint64(len(cms.counters)) != cms.width*cms.depth {
+			d.err = ErrBadSnapshot
+		}
+		return cms
+	case snapJSON:
+		data := d.string()
+		if d.err != nil {
+			return nil
+		}
+		root, err := parseJSONValue(data)
+		d.err = err
+		return &JSONDocument{root: root}
+	case snapTimeSer:
+		ts := &TimeSeries{retention: d.varint()}
+		n := d.length()
+		for i := 0; i < n && d.err == nil; i++ {
+			ts.samples = append(ts.samples, TSSample{Timestamp: d.varint(), Value: d.float()})
+		}
+		n = d.length()
+		for i := 0; i < n && d.err == nil; i++ {
+			rule := &tsRule{destKey: d.string(), aggregation: TSAggregation(d.uvarint()), duration: d.varint(), bucketStart: d.varint()}
+			rule.acc = tsAccumulator{sum: d.float(), min: d.float(), max: d.float(), count: d.varint()}
+			ts.rules = append(ts.rules, rule)
+		}
+		return ts
+	}
+	if d.err == nil {
+		d.err = ErrBadSnapshot
+	}
+	return nil
+}
+
+func (d *snapshotDecoder) index() IndexSchema {
+	schema := IndexSchema{Name: d.string()}
+	n := d.length()
+	for i := 0; i < n && d.err == nil; i++ {
+		schema.Prefixes = append(schema.Prefixes, d.string())
+	}
+	n = d.length()
+	for i := 0; i < n && d.err == nil; i++ {
+		f := IndexField{Name: d.string(), Type: IndexFieldType(d.uvarint())}
+		if f.Type == IndexVector {
+			f.Vector = &VectorOptions{
+				Algorithm:      VectorAlgorithm(d.uvarint()),
+				Dim:            int(d.uvarint()),
+				Metric:         VectorMetric(d.uvarint()),
+				M:              int(d.uvarint()),
+				EFConstruction: int(d.uvarint()),
+				EFRuntime:      int(d.uvarint()),
+			}
+		}
+		schema.Fields = append(schema.Fields, f)
+	}
+	return schema
+}
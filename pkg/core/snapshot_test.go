@@ -0,0 +1,119 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	src := NewKVStore()
+	src.Set("str", "hello", 0)
+	src.Set("ttl", "soon", 100)
+	src.SetBit("bits", 9, 1)
+	src.HSet("user:1", "city", "paris")
+	src.PFAdd("hll", "a", "b", "c")
+	src.BFAdd("bloom", "x")
+	src.CFAdd("cuckoo", "y")
+	src.CMSInitByDim("cms", 100, 4)
+	src.CMSIncrBy("cms", []CMSIncrement{{Item: "z", Amount: 3}})
+	src.GeoAdd("geo", []GeoMember{{Member: "rome", Longitude: 12.5, Latitude: 41.9}}, GeoAddOptions{})
+	src.JSONSet("doc", "$", `{"a":[1,2],"b":"c"}`, JSONSetOptions{})
+	src.TSAdd("temp", 1000, 21.5, TSCreateOptions{})
+	src.CreateIndex(IndexSchema{Name: "users", Prefixes: []string{"user:"}, Fields: []IndexField{{Name: "city", Type: IndexTag}}})
+
+	var buf bytes.Buffer
+	if err := src.WriteSnapshot(&buf); err != nil {
+		t.Fatalf("WriteSnapshot() error = %v", err)
+	}
+	dst := NewKVStore()
+	dst.Set("stale", "gone", 0)
+	if err := dst.LoadSnapshot(bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatalf("LoadSnapshot() error = %v", err)
+	}
+
+	checks := []struct {
+		name string
+		got  func(s *KVStore) interface{}
+	}{
+		{"string", func(s *KVStore) interface{} { v, ok, _ := s.Get("str"); return fmt.Sprint(v, ok) }},
+		{"stale key", func(s *KVStore) interface{} { _, ok, _ := s.Get("stale"); return ok }},
+		{"bitmap", func(s *KVStore) interface{} { v, _ := s.GetBit("bits", 9); return v }},
+		{"hash", func(s *KVStore) interface{} { v, _, _ := s.HGet("user:1", "city"); return v }},
+		{"hyperloglog", func(s *KVStore) interface{} { v, _ := s.PFCount("hll"); return v }},
+		{"bloom", func(s *KVStore) interface{} { v, _ := s.BFExists("bloom", "x", "nope"); return fmt.Sprint(v) }},
+		{"cuckoo", func(s *KVStore) interface{} { v, _ := s.CFExists("cuckoo", "y"); return v }},
+		{"cms", func(s *KVStore) interface{} { v, _ := s.CMSQuery("cms", "z"); return fmt.Sprint(v) }},
+		{"geo", func(s *KVStore) interface{} { v, _ := s.GeoHash("geo", "rome"); return fmt.Sprint(v) }},
+		{"json", func(s *KVStore) interface{} { v, _, _ := s.JSONGet("doc", "$"); return v }},
+		{"timeseries", func(s *KVStore) interface{} {
+			v, _ := s.TSRange("temp", 0, 2000, TSRangeOptions{})
+			return fmt.Sprint(v)
+		}},
+		{"index", func(s *KVStore) interface{} {
+			r, err := s.Search("users", []QueryClause{{Field: "city", Values: []string{"paris"}}}, 0, 10)
+			return fmt.Sprint(r.Total, err)
+		}},
+	}
+	for _, c := range checks {
+		if got, want := c.got(dst), c.got(src); got != want {
+			t.Errorf("%s after load = %v, want %v", c.name, got, want)
+		}
+	}
+
+	dst.HSet("user:2", "city", "paris")
+	if r, _ := dst.Search("users", []QueryClause{{Field: "city", Values: []string{"paris"}}}, 0, 10); r.Total != 2 {
+		t.Errorf("loaded index did not pick up a new hash: total = %d, want 2", r.Total)
+	}
+}
+
+func TestLoadSnapshotErrors(t *testing.T) {
+	store := NewKVStore()
+	if err := store.LoadSnapshot(bytes.NewReader([]byte("garbage"))); err != ErrBadSnapshot {
+		t.Errorf("LoadSnapshot(garbage) = %v, want ErrBadSnapshot", err)
+	}
+
+	src := NewKVStore()
+	src.Set("a", "1", 0)
+	var buf bytes.Buffer
+	src.WriteSnapshot(&buf)
+	truncated := buf.Bytes()[:buf.Len()-1]
+	if err := store.LoadSnapshot(bytes.NewReader(truncated)); err != ErrBadSnapshot {
+		t.Errorf("LoadSnapshot(truncated) = %v, want ErrBadSnapshot", err)
+	}
+}
+
+func TestDumpRestore(t *testing.T) {
+	src := NewKVStore()
+	src.HSet("h", "f", "v")
+	src.Set("ttl", "x", 100)
+	dst := NewKVStore()
+
+	payload, ttl, ok := src.Dump("h")
+	if !ok || ttl != 0 {
+		t.Fatalf("Dump(h) = ok %v, ttl %v, want a payload without TTL", ok, ttl)
+	}
+	if err := dst.Restore("copy", payload, 0, false); err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+	if v, _, _ := dst.HGet("copy", "f"); v != "v" {
+		t.Errorf("restored hash f = %q, want v", v)
+	}
+	if err := dst.Restore("copy", payload, 0, false); err != ErrBusyKey {
+		t.Errorf("Restore(existing) = %v, want ErrBusyKey", err)
+	}
+	if err := dst.Restore("copy", payload, 0, true); err != nil {
+		t.Errorf("Restore(existing, replace) = %v, want nil", err)
+	}
+	if err := dst.Restore("bad", payload[:len(payload)-1], 0, false); err != ErrBadDump {
+		t.Errorf("Restore(truncated) = %v, want ErrBadDump", err)
+	}
+
+	if _, ttl, _ := src.Dump("ttl"); ttl <= 0 || ttl > 100*time.Second {
+		t.Errorf("Dump(ttl) TTL = %v, want within 100s", ttl)
+	}
+	if _, _, ok := src.Dump("missing"); ok {
+		t.Errorf("Dump(missing) ok = true")
+	}
+}
@@ -32,6 +32,9 @@ type KVStore struct {
 	startTime time.Time
 	MaxKeys   int
 	keyCount  int64 // Atomic counter for total keys
+	indexes   indexRegistry
+
+	notifyFlags atomic.Uint32 // notify-keyspace-events classes
 }
 
 // NewKVStore initializes a new sharded Key-Value Store.
@@ -72,6 +75,11 @@ func NewKVStoreWithLimit(maxKeys int) *KVStore {
 	return s
 }
 
+// isExpired reports whether the entry has a TTL that already passed.
+func (e Entry) isExpired() bool {
+	return e.ExpiresAt > 0 && time.Now().UnixNano() > e.ExpiresAt
+}
+
 // getShard returns the specific shard for a given key.
 func (s *KVStore) getShard(key string) *Shard {
 	h := fnv.New32a()
@@ -103,9 +111,60 @@ func (s *Shard) removeKey(key string) {
 	delete(s.keyIndex, key)
 }
 
+// liveEntry returns the entry at key, lazily deleting it if it has expired.
+// Must be called while holding the SHARD'S write lock.
+func (s *KVStore) liveEntry(shard *Shard, key string) (Entry, bool) {
+	entry, exists := shard.data[key]
+	if exists && entry.isExpired() {
+		s.expireEntry(shard, key)
+		return Entry{}, false
+	}
+	return entry, exists
+}
+
+// expireEntry removes a key whose TTL passed and emits the expired event.
+// Must be called while holding the SHARD'S write lock.
+func (s *KVStore) expireEntry(shard *Shard, key string) {
+	s.removeEntry(shard, key)
+	s.notify(notifyExpired, "expired", key)
+}
+
+// createEntry stores value under a key that does not exist yet,
+// enforcing the MaxKeys limit.
+// Must be called while holding the SHARD'S write lock.
+func (s *KVStore) createEntry(shard *Shard, key string, value interface{}) error {
+	if s.MaxKeys > 0 && atomic.LoadInt64(&s.keyCount) >= int64(s.MaxKeys) {
+		return ErrMaxKeysExceeded
+	}
+	shard.data[key] = Entry{Value: value}
+	shard.addKey(key)
+	atomic.AddInt64(&s.keyCount, 1)
+	return nil
+}
+
+// removeEntry deletes an existing key, updates the key counter and drops
+// the key from any secondary index. Every deletion path (DEL, lazy and
+// active expiry) goes through here.
+// Must be called while holding the SHARD'S write lock.
+func (s *KVStore) removeEntry(shard *Shard, key string) {
+	entry, exists := shard.data[key]
+	if !exists {
+		return
+	}
+	if _, isHash := entry.Value.(map[string]string); isHash {
+		s.unindexHash(key)
+	}
+	delete(shard.data, key)
+	shard.removeKey(key)
+	atomic.AddInt64(&s.keyCount, -1)
+}
+
 // Info aggregates stats.
 func (s *KVStore) Info() string {
 	uptime := time.Since(s.startTime).Seconds()
 	totalKeys := atomic.LoadInt64(&s.keyCount)
-	return fmt.Sprintf("# Server\r\nsubydb_version:1.3.0\r\nuptime_in_seconds:%.0f\r\n\r\n# Stats\r\nkeys:%d\r\n", uptime, totalKeys)
+	pubsub := s.Hub.Stats()
+	return fmt.Sprintf("# Server\r\nsubydb_version:1.3.0\r\nuptime_in_seconds:%.0f\r\n\r\n# Stats\r\nkeys:%d\r\n"+
+		"\r\n# Pubsub\r\npubsub_channels:%d\r\npubsub_patterns:%d\r\npubsubshard_channels:%d\r\npubsub_delivered:%d\r\npubsub_dropped:%d\r\npubsub_slow_disconnects:%d\r\n",
+		uptime, totalKeys, pubsub.Channels, pubsub.Patterns, pubsub.ShardChannels, pubsub.Delivered, pubsub.Dropped, pubsub.Disconnected)
 }
@@ -0,0 +1,145 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+var (
+	// ErrSubscriptionClosed is returned when changing a closed Subscription.
+	ErrSubscriptionClosed = errors.New("subscription closed")
+	// ErrSlowConsumer is reported by Subscription.Err when the Hub's
+	// Disconnect policy removed a subscriber that fell behind.
+	ErrSlowConsumer = errors.New("subscription disconnected: slow consumer")
+)
+
+// Subscription is an in-process subscription to any number of channels and
+// patterns, for using the Hub as an event bus. Messages arrive on C until
+// the subscription is closed, its context is cancelled, or the Hub drops it
+// as a slow consumer; C is then closed and Err reports why.
+type Subscription struct {
+	hub  *Hub
+	sub  *Subscriber
+	done chan struct{}
+
+	mu  sync.Mutex
+	err error
+}
+
+// NewSubscription returns a Subscription with no channels that closes when
+// ctx is done. Add channels and patterns with Subscribe and PSubscribe.
+func (h *Hub) NewSubscription(ctx context.Context) *Subscription {
+	s := &Subscription{hub: h, sub: h.NewSubscriber(), done: make(chan struct{})}
+	if ctx.Done() != nil {
+		go func() {
+			select {
+			case <-ctx.Done():
+				s.close(ctx.Err())
+			case <-s.done:
+			}
+		}()
+	}
+	return s
+}
+
+// SubscribeContext subscribes to channels until ctx is done or the
+// Subscription is closed.
+func (h *Hub) SubscribeContext(ctx context.Context, channels ...string) *Subscription {
+	s := h.NewSubscription(ctx)
+	s.Subscribe(channels...)
+	return s
+}
+
+// PSubscribeContext subscribes to glob patterns until ctx is done or the
+// Subscription is closed.
+func (h *Hub) PSubscribeContext(ctx context.Context, patterns ...string) *Subscription {
+	s := h.NewSubscription(ctx)
+	s.PSubscribe(patterns...)
+	return s
+}
+
+// C returns the channel messages are delivered on.
+func (s *Subscription) C() <-chan Message {
+	return s.sub.Messages()
+}
+
+// Subscribe adds channels to the subscription.
+func (s *Subscription) Subscribe(channels ...string) error {
+	if s.closed() {
+		return ErrSubscriptionClosed
+	}
+	s.hub.AddChannels(s.sub, channels...)
+	return nil
+}
+
+// PSubscribe adds glob patterns to the subscription.
+func (s *Subscription) PSubscribe(patterns ...string) error {
+	if s.closed() {
+		return ErrSubscriptionClosed
+	}
+	s.hub.AddPatterns(s.sub, patterns...)
+	return nil
+}
+
+// Unsubscribe removes channels, or all channels if none are given. The
+// subscription stays open even with nothing subscribed.
+func (s *Subscription) Unsubscribe(channels ...string) {
+	s.hub.RemoveChannels(s.sub, channels...)
+}
+
+// PUnsubscribe removes patterns, or all patterns if none are given.
+func (s *Subscription) PUnsubscribe(patterns ...string) {
+	s.hub.RemovePatterns(s.sub, patterns...)
+}
+
+// Handle calls fn for every message, in order, until the subscription ends,
+// and returns Err. Run it in its own goroutine for callback-style handling.
+func (s *Subscription) Handle(fn func(Message)) error {
+	for msg := range s.C() {
+		fn(msg)
+	}
+	return s.Err()
+}
+
+// Close ends the subscription and closes C. Messages already queued can
+// still be read from C. Closing twice is harmless.
+func (s *Subscription) Close() {
+	s.close(nil)
+}
+
+// Err returns nil while the subscription is open or after Close, the
+// context's error after cancellation, and ErrSlowConsumer if the Hub
+// disconnected it.
+func (s *Subscription) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err == nil && s.sub.slow.Load() {
+		return ErrSlowConsumer
+	}
+	return s.err
+}
+
+func (s *Subscription) close(err error) {
+	s.mu.Lock()
+	select {
+	case <-s.done:
+		s.mu.Unlock()
+		return
+	default:
+	}
+	s.err = err
+	close(s.done)
+	s.mu.Unlock()
+
+	s.hub.RemoveSubscriber(s.sub)
+}
+
+func (s *Subscription) closed() bool {
+	select {
+	case <-s.done:
+		return true
+	default:
+		return s.sub.slow.Load()
+	}
+}
@@ -0,0 +1,100 @@
+package core
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSubscription(t *testing.T) {
+	hub := NewHub()
+	s := hub.SubscribeContext(context.Background(), "a", "b")
+	s.PSubscribe("user.*")
+
+	hub.Publish("b", "1")
+	hub.Publish("user.7", "2")
+	if msg := <-s.C(); msg.Channel != "b" || msg.Payload != "1" {
+		t.Errorf("C() = %+v, want 1 on b", msg)
+	}
+	if msg := <-s.C(); msg.Pattern != "user.*" || msg.Channel != "user.7" {
+		t.Errorf("C() = %+v, want user.7 through user.*", msg)
+	}
+
+	s.Unsubscribe("a", "b")
+	if n := hub.Publish("a", "x"); n != 0 {
+		t.Errorf("Publish() after Unsubscribe = %d, want 0", n)
+	}
+
+	s.Close()
+	s.Close()
+	if _, ok := <-s.C(); ok {
+		t.Error("C() still open after Close")
+	}
+	if err := s.Err(); err != nil {
+		t.Errorf("Err() after Close = %v, want nil", err)
+	}
+	if err := s.Subscribe("a"); err != ErrSubscriptionClosed {
+		t.Errorf("Subscribe() after Close = %v, want ErrSubscriptionClosed", err)
+	}
+	if stats := hub.Stats(); stats.Channels != 0 || stats.Patterns != 0 {
+		t.Errorf("Stats() after Close = %+v, want nothing subscribed", stats)
+	}
+}
+
+func TestSubscriptionContext(t *testing.T) {
+	hub := NewHub()
+	ctx, cancel := context.WithCancel(context.Background())
+	s := hub.PSubscribeContext(ctx, "*")
+	cancel()
+
+	select {
+	case _, ok := <-s.C():
+		if ok {
+			t.Error("C() delivered a message, want it closed")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("C() not closed after the context was cancelled")
+	}
+	if err := s.Err(); err != context.Canceled {
+		t.Errorf("Err() = %v, want context.Canceled", err)
+	}
+}
+
+func TestSubscriptionHandle(t *testing.T) {
+	hub := NewHub()
+	s := hub.SubscribeContext(context.Background(), "events")
+
+	var got []string
+	done := make(chan error)
+	go func() {
+		done <- s.Handle(func(msg Message) {
+			got = append(got, msg.Payload)
+			if len(got) == 3 {
+				s.Close()
+			}
+		})
+	}()
+	for _, p := range []string{"a", "b", "c"} {
+		hub.Publish("events", p)
+	}
+	if err := <-done; err != nil {
+		t.Errorf("Handle() = %v, want nil", err)
+	}
+	if len(got) != 3 || got[0] != "a" || got[2] != "c" {
+		t.Errorf("Handle() saw %v, want [a b c]", got)
+	}
+}
+
+func TestSubscriptionSlowConsumer(t *testing.T) {
+	hub := NewHubWithOptions(HubOptions{BufferSize: 1, Policy: Disconnect})
+	s := hub.SubscribeContext(context.Background(), "c")
+	hub.Publish("c", "1")
+	hub.Publish("c", "2")
+
+	if err := s.Handle(func(Message) {}); err != ErrSlowConsumer {
+		t.Errorf("Handle() = %v, want ErrSlowConsumer", err)
+	}
+	if err := s.Subscribe("d"); err != ErrSubscriptionClosed {
+		t.Errorf("Subscribe() after disconnect = %v, want ErrSubscriptionClosed", err)
+	}
+}
@@ -0,0 +1,368 @@
+package core
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+var (
+	// ErrTSNotFound is returned when a time series operation targets a missing key.
+	ErrTSNotFound = fmt.Errorf("ERR TSDB: the key does not exist")
+	// ErrTSDuplicate is returned when adding a second sample with the same timestamp.
+	ErrTSDuplicate = fmt.Errorf("ERR TSDB: duplicate sample for timestamp")
+	// ErrTSTooOld is returned when a sample falls outside the retention window.
+	ErrTSTooOld = fmt.Errorf("ERR TSDB: Timestamp is older than retention")
+)
+
+// TSAggregation selects how TSRange and compaction rules summarize a bucket.
+type TSAggregation int
+
+const (
+	TSAggNone TSAggregation = iota
+	TSAggAvg
+	TSAggMin
+	TSAggMax
+	TSAggSum
+	TSAggCount
+)
+
+// TSSample is a single data point. Timestamps are Unix milliseconds.
+type TSSample struct {
+	Timestamp int64
+	Value     float64
+}
+
+// TSCreateOptions configures a new time series.
+type TSCreateOptions struct {
+	Retention int64 // Milliseconds of history to keep, 0 keeps everything
+}
+
+// TSKeySample is a sample addressed to a key, for TSMAdd.
+type TSKeySample struct {
+	Key string
+	TSSample
+}
+
+// TSRangeOptions configures TSRange.
+type TSRangeOptions struct {
+	Count          int // Maximum number of samples returned, 0 for no limit
+	Aggregation    TSAggregation
+	BucketDuration int64 // Bucket width in milliseconds when aggregating
+}
+
+// TimeSeries is a list of samples ordered by timestamp, stored as an Entry
+// value. Samples may arrive out of order but timestamps must be unique.
+type TimeSeries struct {
+	samples   []TSSample
+	retention int64
+	rules     []*tsRule
+}
+
+// tsRule downsamples a source series into destKey. The open bucket is
+// accumulated here and written to the destination once a sample for a
+// later bucket arrives.
+type tsRule struct {
+	destKey     string
+	aggregation TSAggregation
+	duration    int64
+	bucketStart int64
+	acc         tsAccumulator
+}
+
+type tsAccumulator struct {
+	sum, min, max float64
+	count         int64
+}
+
+func (a *tsAccumulator) add(v float64) {
+	if a.count == 0 || v < a.min {
+		a.min = v
+	}
+	if a.count == 0 || v > a.max {
+		a.max = v
+	}
+	a.sum += v
+	a.count++
+}
+
+func (a *tsAccumulator) value(agg TSAggregation) float64 {
+	switch agg {
+	case TSAggAvg:
+		return a.sum / float64(a.count)
+	case TSAggMin:
+		return a.min
+	case TSAggMax:
+		return a.max
+	case TSAggSum:
+		return a.sum
+	case TSAggCount:
+		return float64(a.count)
+	}
+	return math.NaN()
+}
+
+// bucketStart aligns ts down to a multiple of duration.
+func bucketStart(ts, duration int64) int64 {
+	start := ts - ts%duration
+	if ts < 0 && ts%duration != 0 {
+		start -= duration
+	}
+	return start
+}
+
+// Len returns the number of samples.
+func (ts *TimeSeries) Len() int {
+	return len(ts.samples)
+}
+
+// insert adds a sample, keeping samples sorted. With overwrite an existing
+// sample at the same timestamp is replaced instead of rejected.
+func (ts *TimeSeries) insert(sample TSSample, overwrite bool) error {
+	n := len(ts.samples)
+	if n > 0 && ts.retention > 0 && sample.Timestamp < ts.samples[n-1].Timestamp-ts.retention {
+		return ErrTSTooOld
+	}
+
+	// Fast path: samples almost always arrive in order
+	if n == 0 || sample.Timestamp > ts.samples[n-1].Timestamp {
+		ts.samples = append(ts.samples, sample)
+	} else {
+		i := sort.Search(n, func(i int) bool { return ts.samples[i].Timestamp >= sample.Timestamp })
+		if ts.samples[i].Timestamp == sample.Timestamp {
+			if !overwrite {
+				return ErrTSDuplicate
+			}
+			ts.samples[i] = sample
+			return nil
+		}
+		ts.samples = append(ts.samples, TSSample{})
+		copy(ts.samples[i+1:], ts.samples[i:])
+		ts.samples[i] = sample
+	}
+
+	if ts.retention > 0 {
+		cutoff := ts.samples[len(ts.samples)-1].Timestamp - ts.retention
+		if i := sort.Search(len(ts.samples), func(i int) bool { return ts.samples[i].Timestamp >= cutoff }); i > 0 {
+			ts.samples = ts.samples[i:]
+		}
+	}
+	return nil
+}
+
+// compact feeds a new sample to every rule and returns the buckets that it
+// closed, keyed by destination. Samples for buckets that were already
+// closed are not compacted again.
+func (ts *TimeSeries) compact(sample TSSample) []tsPending {
+	var closed []tsPending
+	for _, rule := range ts.rules {
+		start := bucketStart(sample.Timestamp, rule.duration)
+		switch {
+		case rule.acc.count == 0:
+			rule.bucketStart = start
+		case start > rule.bucketStart:
+			closed = append(closed, tsPending{rule.destKey, TSSample{rule.bucketStart, rule.acc.value(rule.aggregation)}})
+			rule.acc = tsAccumulator{}
+			rule.bucketStart = start
+		case start < rule.bucketStart:
+			continue
+		}
+		rule.acc.add(sample.Value)
+	}
+	return closed
+}
+
+type tsPending struct {
+	key    string
+	sample TSSample
+}
+
+// TSCreate creates an empty time series at key.
+func (s *KVStore) TSCreate(key string, opts TSCreateOptions) error {
+	if opts.Retention < 0 {
+		return fmt.Errorf("ERR TSDB: invalid retention")
+	}
+	shard := s.getShard(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	if _, exists := s.liveEntry(shard, key); exists {
+		return fmt.Errorf("ERR TSDB: key already exists")
+	}
+	if err := s.createEntry(shard, key, &TimeSeries{retention: opts.Retention}); err != nil {
+		return err
+	}
+	s.notify(notifyModule, "ts.create", key)
+	return nil
+}
+
+// TSAdd appends a sample to the series at key, creating it with opts if it
+// does not exist, and returns the sample's timestamp.
+func (s *KVStore) TSAdd(key string, timestamp int64, value float64, opts TSCreateOptions) (int64, error) {
+	if math.IsNaN(value) {
+		return 0, fmt.Errorf("ERR TSDB: invalid value")
+	}
+	if err := s.tsAdd(key, TSSample{timestamp, value}, &opts, false); err != nil {
+		return 0, err
+	}
+	return timestamp, nil
+}
+
+// TSMAdd appends each sample to its existing series. Samples succeed or
+// fail independently; the returned error for a sample is nil on success.
+func (s *KVStore) TSMAdd(samples []TSKeySample) []error {
+	errs := make([]error, len(samples))
+	for i, ks := range samples {
+		if math.IsNaN(ks.Value) {
+			errs[i] = fmt.Errorf("ERR TSDB: invalid value")
+			continue
+		}
+		errs[i] = s.tsAdd(ks.Key, ks.TSSample, nil, false)
+	}
+	return errs
+}
+
+// tsAdd inserts sample and then writes any compacted buckets to their
+// destination series. Destinations are written after the source's shard
+// lock is released, since they may live in the same shard. A nil opts
+// means the series must already exist.
+func (s *KVStore) tsAdd(key string, sample TSSample, opts *TSCreateOptions, overwrite bool) error {
+	shard := s.getShard(key)
+	shard.mu.Lock()
+
+	entry, exists := s.liveEntry(shard, key)
+	if !exists {
+		if opts == nil {
+			shard.mu.Unlock()
+			return ErrTSNotFound
+		}
+		entry.Value = &TimeSeries{retention: opts.Retention}
+		if err := s.createEntry(shard, key, entry.Value); err != nil {
+			shard.mu.Unlock()
+			return err
+		}
+	}
+	ts, ok := entry.Value.(*TimeSeries)
+	if !ok {
+		shard.mu.Unlock()
+		return ErrWrongType
+	}
+	if err := ts.insert(sample, overwrite); err != nil {
+		shard.mu.Unlock()
+		return err
+	}
+	closed := ts.compact(sample)
+	s.notify(notifyModule, "ts.add", key)
+	shard.mu.Unlock()
+
+	for _, p := range closed {
+		// A deleted or replaced destination just drops the bucket
+		s.tsAdd(p.key, p.sample, nil, true)
+	}
+	return nil
+}
+
+// TSCreateRule downsamples every new sample of sourceKey into destKey,
+// which must be an existing time series. Each bucket of duration
+// milliseconds is written to destKey once a sample for a later bucket
+// arrives.
+func (s *KVStore) TSCreateRule(sourceKey, destKey string, agg TSAggregation, duration int64) error {
+	if sourceKey == destKey {
+		return fmt.Errorf("ERR TSDB: the source key and destination key should be different")
+	}
+	if agg == TSAggNone || duration <= 0 {
+		return fmt.Errorf("ERR TSDB: invalid aggregation or bucket duration")
+	}
+	// The destination may share the source's shard, so check it first
+	if err := s.tsCheck(destKey); err != nil {
+		return err
+	}
+
+	shard := s.getShard(sourceKey)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	entry, exists := s.liveEntry(shard, sourceKey)
+	if !exists {
+		return ErrTSNotFound
+	}
+	ts, ok := entry.Value.(*TimeSeries)
+	if !ok {
+		return ErrWrongType
+	}
+	for _, rule := range ts.rules {
+		if rule.destKey == destKey {
+			return fmt.Errorf("ERR TSDB: the destination key already has a rule from this source")
+		}
+	}
+	ts.rules = append(ts.rules, &tsRule{destKey: destKey, aggregation: agg, duration: duration})
+	s.notify(notifyModule, "ts.createrule", sourceKey)
+	return nil
+}
+
+// tsCheck returns an error unless key holds a time series.
+func (s *KVStore) tsCheck(key string) error {
+	shard := s.getShard(key)
+	shard.mu.RLock()
+	defer shard.mu.RUnlock()
+
+	entry, exists := shard.data[key]
+	if !exists || entry.isExpired() {
+		return ErrTSNotFound
+	}
+	if _, ok := entry.Value.(*TimeSeries); !ok {
+		return ErrWrongType
+	}
+	return nil
+}
+
+// TSRange returns the samples with from <= timestamp <= to, optionally
+// aggregated into buckets aligned to multiples of the bucket duration.
+func (s *KVStore) TSRange(key string, from, to int64, opts TSRangeOptions) ([]TSSample, error) {
+	if opts.Aggregation != TSAggNone && opts.BucketDuration <= 0 {
+		return nil, fmt.Errorf("ERR TSDB: bucket duration must be positive")
+	}
+
+	shard := s.getShard(key)
+	shard.mu.RLock()
+	defer shard.mu.RUnlock()
+
+	entry, exists := shard.data[key]
+	if !exists || entry.isExpired() {
+		return nil, ErrTSNotFound
+	}
+	ts, ok := entry.Value.(*TimeSeries)
+	if !ok {
+		return nil, ErrWrongType
+	}
+
+	lo := sort.Search(len(ts.samples), func(i int) bool { return ts.samples[i].Timestamp >= from })
+	hi := sort.Search(len(ts.samples), func(i int) bool { return ts.samples[i].Timestamp > to })
+	if lo >= hi {
+		return []TSSample{}, nil
+	}
+	window := ts.samples[lo:hi]
+
+	var result []TSSample
+	if opts.Aggregation == TSAggNone {
+		result = make([]TSSample, len(window))
+		copy(result, window)
+	} else {
+		var acc tsAccumulator
+		start := bucketStart(window[0].Timestamp, opts.BucketDuration)
+		for _, sample := range window {
+			if b := bucketStart(sample.Timestamp, opts.BucketDuration); b != start {
+				result = append(result, TSSample{start, acc.value(opts.Aggregation)})
+				acc = tsAccumulator{}
+				start = b
+			}
+			acc.add(sample.Value)
+		}
+		result = append(result, TSSample{start, acc.value(opts.Aggregation)})
+	}
+
+	if opts.Count > 0 && len(result) > opts.Count {
+		result = result[:opts.Count]
+	}
+	return result, nil
+}
@@ -0,0 +1,136 @@
+package core
+
+import (
+	"math"
+	"testing"
+)
+
+func TestTimeSeriesAddAndRange(t *testing.T) {
+	store := NewKVStore()
+
+	for _, ts := range []int64{1000, 3000, 2000, 4000} {
+		if _, err := store.TSAdd("cpu", ts, float64(ts/1000), TSCreateOptions{}); err != nil {
+			t.Fatalf("TSAdd(%d) error = %v", ts, err)
+		}
+	}
+	if _, err := store.TSAdd("cpu", 2000, 9, TSCreateOptions{}); err != ErrTSDuplicate {
+		t.Errorf("TSAdd() duplicate error = %v, want ErrTSDuplicate", err)
+	}
+
+	samples, err := store.TSRange("cpu", 0, math.MaxInt64, TSRangeOptions{})
+	if err != nil {
+		t.Fatalf("TSRange() error = %v", err)
+	}
+	for i, want := range []int64{1000, 2000, 3000, 4000} {
+		if samples[i].Timestamp != want || samples[i].Value != float64(want/1000) {
+			t.Errorf("TSRange()[%d] = %+v, want {%d %d}", i, samples[i], want, want/1000)
+		}
+	}
+
+	samples, _ = store.TSRange("cpu", 2000, 3000, TSRangeOptions{})
+	if len(samples) != 2 {
+		t.Errorf("TSRange(2000, 3000) returned %d samples, want 2", len(samples))
+	}
+	samples, _ = store.TSRange("cpu", 0, math.MaxInt64, TSRangeOptions{Count: 3})
+	if len(samples) != 3 {
+		t.Errorf("TSRange(COUNT 3) returned %d samples, want 3", len(samples))
+	}
+
+	if _, err := store.TSRange("missing", 0, 1, TSRangeOptions{}); err != ErrTSNotFound {
+		t.Errorf("TSRange() on missing key error = %v, want ErrTSNotFound", err)
+	}
+}
+
+func TestTimeSeriesAggregation(t *testing.T) {
+	store := NewKVStore()
+	store.TSCreate("temp", TSCreateOptions{})
+	for i, v := range []float64{1, 5, 3, 10, 20} {
+		store.TSAdd("temp", int64(i)*400, v, TSCreateOptions{}) // 0, 400, 800 | 1200, 1600
+	}
+
+	tests := []struct {
+		agg  TSAggregation
+		want []float64
+	}{
+		{TSAggAvg, []float64{3, 15}},
+		{TSAggMin, []float64{1, 10}},
+		{TSAggMax, []float64{5, 20}},
+		{TSAggSum, []float64{9, 30}},
+		{TSAggCount, []float64{3, 2}},
+	}
+	for _, tt := range tests {
+		samples, err := store.TSRange("temp", 0, math.MaxInt64, TSRangeOptions{Aggregation: tt.agg, BucketDuration: 1000})
+		if err != nil {
+			t.Fatalf("TSRange() error = %v", err)
+		}
+		if len(samples) != 2 || samples[0].Timestamp != 0 || samples[1].Timestamp != 1000 {
+			t.Fatalf("TSRange(agg %d) = %+v, want buckets at 0 and 1000", tt.agg, samples)
+		}
+		for i := range tt.want {
+			if samples[i].Value != tt.want[i] {
+				t.Errorf("TSRange(agg %d)[%d] = %v, want %v", tt.agg, i, samples[i].Value, tt.want[i])
+			}
+		}
+	}
+}
+
+func TestTimeSeriesRetention(t *testing.T) {
+	store := NewKVStore()
+	store.TSCreate("r", TSCreateOptions{Retention: 1000})
+
+	for _, ts := range []int64{0, 500, 1000, 1500, 2000} {
+		store.TSAdd("r", ts, 1, TSCreateOptions{})
+	}
+	samples, _ := store.TSRange("r", 0, math.MaxInt64, TSRangeOptions{})
+	if len(samples) != 3 || samples[0].Timestamp != 1000 {
+		t.Errorf("TSRange() after retention = %+v, want samples from 1000", samples)
+	}
+	if _, err := store.TSAdd("r", 500, 1, TSCreateOptions{}); err != ErrTSTooOld {
+		t.Errorf("TSAdd() outside retention error = %v, want ErrTSTooOld", err)
+	}
+}
+
+func TestTimeSeriesCompaction(t *testing.T) {
+	store := NewKVStore()
+	store.TSCreate("raw", TSCreateOptions{})
+	store.TSCreate("raw:avg", TSCreateOptions{})
+
+	if err := store.TSCreateRule("raw", "missing", TSAggAvg, 1000); err != ErrTSNotFound {
+		t.Errorf("TSCreateRule() to missing key error = %v, want ErrTSNotFound", err)
+	}
+	if err := store.TSCreateRule("raw", "raw:avg", TSAggAvg, 1000); err != nil {
+		t.Fatalf("TSCreateRule() error = %v", err)
+	}
+
+	for i, v := range []float64{2, 4, 6, 8} {
+		store.TSAdd("raw", int64(i)*500, v, TSCreateOptions{}) // 0, 500 | 1000, 1500
+	}
+	samples, _ := store.TSRange("raw:avg", 0, math.MaxInt64, TSRangeOptions{})
+	if len(samples) != 1 || samples[0] != (TSSample{0, 3}) {
+		t.Errorf("compacted series = %+v, want [{0 3}] while the second bucket is open", samples)
+	}
+
+	store.TSAdd("raw", 2000, 0, TSCreateOptions{})
+	samples, _ = store.TSRange("raw:avg", 0, math.MaxInt64, TSRangeOptions{})
+	if len(samples) != 2 || samples[1] != (TSSample{1000, 7}) {
+		t.Errorf("compacted series = %+v, want second bucket {1000 7}", samples)
+	}
+}
+
+func TestTimeSeriesMAddAndWrongType(t *testing.T) {
+	store := NewKVStore()
+	store.TSCreate("a", TSCreateOptions{})
+	store.Set("str", "value", 0)
+
+	errs := store.TSMAdd([]TSKeySample{
+		{"a", TSSample{1, 1}},
+		{"missing", TSSample{1, 1}},
+		{"str", TSSample{1, 1}},
+	})
+	if errs[0] != nil || errs[1] != ErrTSNotFound || errs[2] != ErrWrongType {
+		t.Errorf("TSMAdd() errors = %v, want [nil ErrTSNotFound ErrWrongType]", errs)
+	}
+	if _, _, err := store.Get("a"); err != ErrWrongType {
+		t.Errorf("Get() on time series error = %v, want ErrWrongType", err)
+	}
+}
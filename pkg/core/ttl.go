@@ -2,7 +2,6 @@ package core
 
 import (
 	"math/rand"
-	"sync/atomic"
 	"time"
 )
 
@@ -64,9 +63,7 @@ func (s *KVStore) sampleAndCleanShard(shard *Shard) int {
 
 		entry, exists := shard.data[key]
 		if exists && entry.ExpiresAt > 0 && now > entry.ExpiresAt {
-			delete(shard.data, key)
-			shard.removeKey(key)
-			atomic.AddInt64(&s.keyCount, -1) // Decrement count
+			s.expireEntry(shard, key)
 			expired++
 			keyCount = len(shard.keys)
 			if keyCount == 0 {
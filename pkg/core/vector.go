@@ -0,0 +1,529 @@
+package core
+
+import (
+	"container/heap"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// VectorAlgorithm selects how a vector field is indexed.
+type VectorAlgorithm int
+
+const (
+	// VectorFlat compares the query with every vector. Exact, O(n) per query.
+	VectorFlat VectorAlgorithm = iota
+	// VectorHNSW navigates a Hierarchical Navigable Small World graph.
+	// Approximate, roughly O(log n) per query.
+	VectorHNSW
+)
+
+// VectorMetric is the distance function of a vector field. Smaller
+// distances are closer for every metric.
+type VectorMetric int
+
+const (
+	// VectorL2 is the squared Euclidean distance.
+	VectorL2 VectorMetric = iota
+	// VectorCosine is 1 - cosine similarity.
+	VectorCosine
+	// VectorIP is 1 - inner product.
+	VectorIP
+)
+
+// VectorOptions configures a vector index field. Vectors are stored in
+// hash fields as little-endian float32 blobs of Dim values.
+type VectorOptions struct {
+	Algorithm      VectorAlgorithm
+	Dim            int
+	Metric         VectorMetric
+	M              int // HNSW: links per node (default 16)
+	EFConstruction int // HNSW: candidate list size while inserting (default 200)
+	EFRuntime      int // HNSW: candidate list size while searching (default 10)
+}
+
+// EncodeVector returns the little-endian float32 blob for vec.
+func EncodeVector(vec []float32) []byte {
+	buf := make([]byte, 4*len(vec))
+	for i, f := range vec {
+		binary.LittleEndian.PutUint32(buf[4*i:], math.Float32bits(f))
+	}
+	return buf
+}
+
+// DecodeVector parses a little-endian float32 blob.
+func DecodeVector(blob []byte) ([]float32, error) {
+	if len(blob)%4 != 0 {
+		return nil, fmt.Errorf("ERR vector blob length must be a multiple of 4")
+	}
+	vec := make([]float32, len(blob)/4)
+	for i := range vec {
+		vec[i] = math.Float32frombits(binary.LittleEndian.Uint32(blob[4*i:]))
+	}
+	return vec, nil
+}
+
+// decodeVector parses a blob stored in a hash field.
+func decodeVector(value string, dim int) ([]float32, error) {
+	if len(value) != 4*dim {
+		return nil, fmt.Errorf("ERR vector must have %d dimensions", dim)
+	}
+	vec := make([]float32, dim)
+	for i := range vec {
+		b := value[4*i:]
+		vec[i] = math.Float32frombits(uint32(b[0]) | uint32(b[1])<<8 | uint32(b[2])<<16 | uint32(b[3])<<24)
+	}
+	return vec, nil
+}
+
+// HSetVector stores vec in a hash field as a float32 blob, which indexes
+// with a matching VECTOR field pick up.
+func (s *KVStore) HSetVector(key, field string, vec []float32) error {
+	return s.HSetBytes(key, field, EncodeVector(vec))
+}
+
+// VectorSearch returns the k hashes whose vector field is closest to query,
+// nearest first, with the distance in SearchDoc.Score. Only hashes matching
+// every filter clause are considered.
+func (s *KVStore) VectorSearch(index, field string, query []float32, k int, filter []QueryClause) ([]SearchDoc, error) {
+	idx := s.indexes.get(index)
+	if idx == nil {
+		return nil, ErrNoIndex
+	}
+	if k <= 0 {
+		return []SearchDoc{}, nil
+	}
+
+	idx.mu.RLock()
+	hits, err := idx.knn(field, query, k, filter)
+	idx.mu.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, len(hits))
+	for i, h := range hits {
+		keys[i] = h.key
+	}
+	docs := s.loadDocs(keys)
+	// Hashes deleted since the query are skipped, so match scores by key
+	scores := make(map[string]float32, len(hits))
+	for _, h := range hits {
+		scores[h.key] = h.dist
+	}
+	for i := range docs {
+		docs[i].Score = float64(scores[docs[i].Key])
+	}
+	return docs, nil
+}
+
+// knn runs a nearest-neighbour query. Must be called with idx.mu held.
+func (idx *Index) knn(field string, query []float32, k int, filter []QueryClause) ([]vectorHit, error) {
+	f, ok := idx.fields[field]
+	if !ok || f.Type != IndexVector {
+		return nil, fmt.Errorf("ERR '%s' is not a vector field", field)
+	}
+	if len(query) != f.Vector.Dim {
+		return nil, fmt.Errorf("ERR query vector must have %d dimensions", f.Vector.Dim)
+	}
+
+	accept := func(string) bool { return true }
+	if len(filter) > 0 {
+		keys, err := idx.match(filter)
+		if err != nil {
+			return nil, err
+		}
+		allowed := make(map[string]struct{}, len(keys))
+		for _, key := range keys {
+			allowed[key] = struct{}{}
+		}
+		accept = func(key string) bool {
+			_, ok := allowed[key]
+			return ok
+		}
+	}
+	return idx.vectors[field].search(query, k, accept), nil
+}
+
+type vectorHit struct {
+	key  string
+	dist float32
+}
+
+// vectorIndex is implemented by flatIndex and hnswIndex.
+type vectorIndex interface {
+	add(key string, vec []float32)
+	remove(key string)
+	search(query []float32, k int, accept func(key string) bool) []vectorHit
+}
+
+func newVectorIndex(opts *VectorOptions) (vectorIndex, error) {
+	if opts == nil || opts.Dim <= 0 {
+		return nil, fmt.Errorf("ERR vector field needs a positive DIM")
+	}
+	switch opts.Algorithm {
+	case VectorFlat:
+		return &flatIndex{metric: opts.Metric, vectors: make(map[string][]float32)}, nil
+	case VectorHNSW:
+		return newHNSWIndex(*opts), nil
+	}
+	return nil, fmt.Errorf("ERR unknown vector algorithm")
+}
+
+// prepareVector copies vec, normalizing it for the cosine metric so that
+// cosine distance reduces to an inner product.
+func prepareVector(vec []float32, metric VectorMetric) []float32 {
+	out := make([]float32, len(vec))
+	copy(out, vec)
+	if metric == VectorCosine {
+		var norm float32
+		for _, f := range out {
+			norm += f * f
+		}
+		if norm > 0 {
+			inv := float32(1 / math.Sqrt(float64(norm)))
+			for i := range out {
+				out[i] *= inv
+			}
+		}
+	}
+	return out
+}
+
+func equalVectors(a, b []float32) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func vectorDistance(metric VectorMetric, a, b []float32) float32 {
+	if metric == VectorL2 {
+		var sum float32
+		for i := range a {
+			d := a[i] - b[i]
+			sum += d * d
+		}
+		return sum
+	}
+	var dot float32
+	for i := range a {
+		dot += a[i] * b[i]
+	}
+	return 1 - dot
+}
+
+// flatIndex is the brute-force vector index.
+type flatIndex struct {
+	metric  VectorMetric
+	vectors map[string][]float32
+}
+
+func (f *flatIndex) add(key string, vec []float32) {
+	f.vectors[key] = prepareVector(vec, f.metric)
+}
+
+func (f *flatIndex) remove(key string) {
+	delete(f.vectors, key)
+}
+
+func (f *flatIndex) search(query []float32, k int, accept func(string) bool) []vectorHit {
+	q := prepareVector(query, f.metric)
+	// Max-heap of the k best so far, so the worst is evicted first
+	best := &candidateHeap{max: true}
+	for key, vec := range f.vectors {
+		if !accept(key) {
+			continue
+		}
+		d := vectorDistance(f.metric, q, vec)
+		if best.Len() < k {
+			heap.Push(best, candidate{key: key, dist: d})
+		} else if d < best.items[0].dist {
+			best.items[0] = candidate{key: key, dist: d}
+			heap.Fix(best, 0)
+		}
+	}
+	return best.sortedHits()
+}
+
+// candidate is a search candidate; node is only set by HNSW.
+type candidate struct {
+	key  string
+	node *hnswNode
+	dist float32
+}
+
+// candidateHeap is a min-heap on distance, or a max-heap if max is set.
+type candidateHeap struct {
+	items []candidate
+	max   bool
+}
+
+func (h *candidateHeap) Len() int { return len(h.items) }
+func (h *candidateHeap) Less(i, j int) bool {
+	if h.max {
+		return h.items[i].dist > h.items[j].dist
+	}
+	return h.items[i].dist < h.items[j].dist
+}
+func (h *candidateHeap) Swap(i, j int)      { h.items[i], h.items[j] = h.items[j], h.items[i] }
+func (h *candidateHeap) Push(x interface{}) { h.items = append(h.items, x.(candidate)) }
+func (h *candidateHeap) Pop() interface{} {
+	last := h.items[len(h.items)-1]
+	h.items = h.items[:len(h.items)-1]
+	return last
+}
+
+// sortedHits returns the heap's contents nearest first. Ties are broken by
+// key so results are deterministic.
+func (h *candidateHeap) sortedHits() []vectorHit {
+	hits := make([]vectorHit, len(h.items))
+	for i, c := range h.items {
+		hits[i] = vectorHit{key: c.key, dist: c.dist}
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].dist != hits[j].dist {
+			return hits[i].dist < hits[j].dist
+		}
+		return hits[i].key < hits[j].key
+	})
+	return hits
+}
+
+// hnswIndex is a Hierarchical Navigable Small World graph (Malkov &
+// Yashunin). Removed vectors stay in the graph as tombstones so that
+// paths through them keep working; the graph is rebuilt once tombstones
+// outnumber live nodes.
+type hnswIndex struct {
+	opts       VectorOptions
+	nodes      map[string]*hnswNode
+	entry      *hnswNode
+	maxLevel   int
+	levelMult  float64
+	tombstones int
+	rng        *rand.Rand
+}
+
+type hnswNode struct {
+	key     string
+	vec     []float32
+	level   int
+	friends [][]*hnswNode // Neighbours per layer
+	deleted bool
+}
+
+func newHNSWIndex(opts VectorOptions) *hnswIndex {
+	if opts.M <= 0 {
+		opts.M = 16
+	}
+	if opts.EFConstruction <= 0 {
+		opts.EFConstruction = 200
+	}
+	if opts.EFRuntime <= 0 {
+		opts.EFRuntime = 10
+	}
+	return &hnswIndex{
+		opts:      opts,
+		nodes:     make(map[string]*hnswNode),
+		levelMult: 1 / math.Log(float64(max(opts.M, 2))),
+		rng:       rand.New(rand.NewSource(1)),
+	}
+}
+
+func (h *hnswIndex) dist(a, b []float32) float32 {
+	return vectorDistance(h.opts.Metric, a, b)
+}
+
+// maxFriends is the neighbour limit of a layer; layer 0 is denser.
+func (h *hnswIndex) maxFriends(layer int) int {
+	if layer == 0 {
+		return 2 * h.opts.M
+	}
+	return h.opts.M
+}
+
+func (h *hnswIndex) add(key string, vec []float32) {
+	prepared := prepareVector(vec, h.opts.Metric)
+	if old, ok := h.nodes[key]; ok {
+		if equalVectors(old.vec, prepared) {
+			return
+		}
+		h.remove(key)
+	}
+	node := &hnswNode{key: key, vec: prepared}
+	node.level = int(-math.Log(1-h.rng.Float64()) * h.levelMult)
+	node.friends = make([][]*hnswNode, node.level+1)
+	h.nodes[key] = node
+	h.insert(node)
+}
+
+func (h *hnswIndex) insert(node *hnswNode) {
+	if h.entry == nil {
+		h.entry, h.maxLevel = node, node.level
+		return
+	}
+
+	ep := h.entry
+	for layer := h.maxLevel; layer > node.level; layer-- {
+		ep = h.greedy(node.vec, ep, layer)
+	}
+	entries := []*hnswNode{ep}
+	for layer := min(node.level, h.maxLevel); layer >= 0; layer-- {
+		found := h.searchLayer(node.vec, entries, h.opts.EFConstruction, layer)
+		neighbours := found
+		if len(neighbours) > h.opts.M {
+			neighbours = neighbours[:h.opts.M]
+		}
+		for _, c := range neighbours {
+			node.friends[layer] = append(node.friends[layer], c.node)
+			c.node.friends[layer] = append(c.node.friends[layer], node)
+			h.shrink(c.node, layer)
+		}
+		entries = entries[:0]
+		for _, c := range found {
+			entries = append(entries, c.node)
+		}
+	}
+
+	if node.level > h.maxLevel {
+		h.entry, h.maxLevel = node, node.level
+	}
+}
+
+// shrink keeps only the closest neighbours of node on layer.
+func (h *hnswIndex) shrink(node *hnswNode, layer int) {
+	friends := node.friends[layer]
+	limit := h.maxFriends(layer)
+	if len(friends) <= limit {
+		return
+	}
+	sort.Slice(friends, func(i, j int) bool {
+		return h.dist(node.vec, friends[i].vec) < h.dist(node.vec, friends[j].vec)
+	})
+	node.friends[layer] = friends[:limit:limit]
+}
+
+// greedy walks layer towards q and returns the closest node it reaches.
+func (h *hnswIndex) greedy(q []float32, ep *hnswNode, layer int) *hnswNode {
+	best, bestDist := ep, h.dist(q, ep.vec)
+	for changed := true; changed; {
+		changed = false
+		for _, f := range best.friends[layer] {
+			if d := h.dist(q, f.vec); d < bestDist {
+				best, bestDist, changed = f, d, true
+			}
+		}
+	}
+	return best
+}
+
+// searchLayer returns up to ef nodes of layer closest to q, nearest first.
+func (h *hnswIndex) searchLayer(q []float32, entries []*hnswNode, ef, layer int) []candidate {
+	visited := make(map[*hnswNode]bool)
+	frontier := &candidateHeap{}
+	results := &candidateHeap{max: true}
+	for _, ep := range entries {
+		visited[ep] = true
+		c := candidate{key: ep.key, node: ep, dist: h.dist(q, ep.vec)}
+		heap.Push(frontier, c)
+		heap.Push(results, c)
+	}
+	for results.Len() > ef {
+		heap.Pop(results)
+	}
+
+	for frontier.Len() > 0 {
+		c := heap.Pop(frontier).(candidate)
+		if results.Len() >= ef && c.dist > results.items[0].dist {
+			break
+		}
+		for _, f := range c.node.friends[layer] {
+			if visited[f] {
+				continue
+			}
+			visited[f] = true
+			d := h.dist(q, f.vec)
+			if results.Len() < ef || d < results.items[0].dist {
+				next := candidate{key: f.key, node: f, dist: d}
+				heap.Push(frontier, next)
+				heap.Push(results, next)
+				if results.Len() > ef {
+					heap.Pop(results)
+				}
+			}
+		}
+	}
+
+	found := make([]candidate, len(results.items))
+	for i := len(found) - 1; i >= 0; i-- {
+		found[i] = heap.Pop(results).(candidate)
+	}
+	return found
+}
+
+func (h *hnswIndex) remove(key string) {
+	node, ok := h.nodes[key]
+	if !ok {
+		return
+	}
+	node.deleted = true
+	delete(h.nodes, key)
+	h.tombstones++
+	if h.tombstones > len(h.nodes) {
+		h.rebuild()
+	}
+}
+
+// rebuild re-inserts the live nodes into a fresh graph.
+func (h *hnswIndex) rebuild() {
+	live := make([]*hnswNode, 0, len(h.nodes))
+	for _, n := range h.nodes {
+		live = append(live, n)
+	}
+	sort.Slice(live, func(i, j int) bool { return live[i].key < live[j].key })
+
+	h.entry, h.maxLevel, h.tombstones = nil, 0, 0
+	for _, n := range live {
+		for i := range n.friends {
+			n.friends[i] = nil
+		}
+		h.insert(n)
+	}
+}
+
+func (h *hnswIndex) search(query []float32, k int, accept func(string) bool) []vectorHit {
+	if h.entry == nil {
+		return []vectorHit{}
+	}
+	q := prepareVector(query, h.opts.Metric)
+	ep := h.entry
+	for layer := h.maxLevel; layer > 0; layer-- {
+		ep = h.greedy(q, ep, layer)
+	}
+
+	// Tombstones and filtered-out nodes use up candidate slots, so widen
+	// the search until k results are found or the whole graph was seen
+	total := len(h.nodes) + h.tombstones
+	for ef := max(h.opts.EFRuntime, k); ; ef *= 2 {
+		best := &candidateHeap{max: true}
+		for _, c := range h.searchLayer(q, []*hnswNode{ep}, ef, 0) {
+			if c.node.deleted || !accept(c.key) {
+				continue
+			}
+			heap.Push(best, c)
+			if best.Len() > k {
+				heap.Pop(best)
+			}
+		}
+		if best.Len() >= k || ef >= total {
+			return best.sortedHits()
+		}
+	}
+}
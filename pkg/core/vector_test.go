@@ -0,0 +1,183 @@
+package core
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+func vectorIndexSchema(t *testing.T, store *KVStore, opts VectorOptions) {
+	t.Helper()
+	err := store.CreateIndex(IndexSchema{
+		Name:     "docs",
+		Prefixes: []string{"doc:"},
+		Fields: []IndexField{
+			{Name: "kind", Type: IndexTag},
+			{Name: "embedding", Type: IndexVector, Vector: &opts},
+		},
+	})
+	if err != nil {
+		t.Fatalf("CreateIndex() error = %v", err)
+	}
+}
+
+func knnKeys(t *testing.T, store *KVStore, query []float32, k int, filter []QueryClause) []string {
+	t.Helper()
+	docs, err := store.VectorSearch("docs", "embedding", query, k, filter)
+	if err != nil {
+		t.Fatalf("VectorSearch() error = %v", err)
+	}
+	keys := make([]string, len(docs))
+	for i, doc := range docs {
+		keys[i] = doc.Key
+	}
+	return keys
+}
+
+func TestVectorEncoding(t *testing.T) {
+	vec := []float32{1.5, -2, 0, 3.25}
+	got, err := DecodeVector(EncodeVector(vec))
+	if err != nil || fmt.Sprint(got) != fmt.Sprint(vec) {
+		t.Errorf("DecodeVector(EncodeVector(%v)) = %v, %v", vec, got, err)
+	}
+	if _, err := DecodeVector([]byte{1, 2, 3}); err == nil {
+		t.Error("DecodeVector() of a 3-byte blob should error")
+	}
+}
+
+func TestVectorFlatMetrics(t *testing.T) {
+	vectors := map[string][]float32{
+		"doc:a": {1, 0},
+		"doc:b": {10, 1},
+		"doc:c": {0, 1},
+		"doc:d": {-1, -1},
+	}
+	tests := []struct {
+		metric VectorMetric
+		want   []string
+	}{
+		// Nearest by distance to (2, 0)
+		{VectorL2, []string{"doc:a", "doc:c", "doc:d", "doc:b"}},
+		// Smallest angle to (2, 0)
+		{VectorCosine, []string{"doc:a", "doc:b", "doc:c", "doc:d"}},
+		// Largest dot product with (2, 0)
+		{VectorIP, []string{"doc:b", "doc:a", "doc:c", "doc:d"}},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.metric), func(t *testing.T) {
+			store := NewKVStore()
+			vectorIndexSchema(t, store, VectorOptions{Algorithm: VectorFlat, Dim: 2, Metric: tt.metric})
+			for key, vec := range vectors {
+				if err := store.HSetVector(key, "embedding", vec); err != nil {
+					t.Fatalf("HSetVector() error = %v", err)
+				}
+			}
+			keys := knnKeys(t, store, []float32{2, 0}, 4, nil)
+			if fmt.Sprint(keys) != fmt.Sprint(tt.want) {
+				t.Errorf("VectorSearch() = %v, want %v", keys, tt.want)
+			}
+		})
+	}
+
+	store := NewKVStore()
+	vectorIndexSchema(t, store, VectorOptions{Algorithm: VectorFlat, Dim: 2, Metric: VectorL2})
+	store.HSetVector("doc:a", "embedding", []float32{1, 0})
+	docs, _ := store.VectorSearch("docs", "embedding", []float32{4, 4}, 1, nil)
+	if len(docs) != 1 || docs[0].Score != 25 {
+		t.Errorf("VectorSearch() L2 score = %v, want 25", docs)
+	}
+}
+
+func TestVectorHNSWRecall(t *testing.T) {
+	const (
+		n   = 2000
+		dim = 16
+		k   = 10
+	)
+	rng := rand.New(rand.NewSource(1))
+	randomVector := func() []float32 {
+		vec := make([]float32, dim)
+		for i := range vec {
+			vec[i] = rng.Float32()*2 - 1
+		}
+		return vec
+	}
+
+	flat, hnsw := NewKVStore(), NewKVStore()
+	vectorIndexSchema(t, flat, VectorOptions{Algorithm: VectorFlat, Dim: dim, Metric: VectorCosine})
+	vectorIndexSchema(t, hnsw, VectorOptions{Algorithm: VectorHNSW, Dim: dim, Metric: VectorCosine})
+	for i := 0; i < n; i++ {
+		vec := randomVector()
+		key := fmt.Sprintf("doc:%d", i)
+		flat.HSetVector(key, "embedding", vec)
+		hnsw.HSetVector(key, "embedding", vec)
+	}
+
+	found, total := 0, 0
+	for q := 0; q < 50; q++ {
+		query := randomVector()
+		exact := make(map[string]bool)
+		for _, key := range knnKeys(t, flat, query, k, nil) {
+			exact[key] = true
+		}
+		for _, key := range knnKeys(t, hnsw, query, k, nil) {
+			if exact[key] {
+				found++
+			}
+		}
+		total += k
+	}
+	if recall := float64(found) / float64(total); recall < 0.9 {
+		t.Errorf("HNSW recall = %.2f, want at least 0.90", recall)
+	}
+}
+
+func TestVectorFollowsWrites(t *testing.T) {
+	for _, algo := range []VectorAlgorithm{VectorFlat, VectorHNSW} {
+		store := NewKVStore()
+		vectorIndexSchema(t, store, VectorOptions{Algorithm: algo, Dim: 2, Metric: VectorL2})
+		store.HSetVector("doc:1", "embedding", []float32{0, 0})
+		store.HSetVector("doc:2", "embedding", []float32{1, 1})
+		store.HSetVector("doc:3", "embedding", []float32{5, 5})
+		store.HSet("doc:3", "kind", "draft")
+
+		store.HSetVector("doc:2", "embedding", []float32{9, 9}) // Update
+		store.Delete("doc:1")                                   // Key removed
+		store.HSet("doc:4", "embedding", "not a vector")        // Skipped
+
+		keys := knnKeys(t, store, []float32{0, 0}, 3, nil)
+		if fmt.Sprint(keys) != "[doc:3 doc:2]" {
+			t.Errorf("VectorSearch() algorithm %d = %v, want [doc:3 doc:2]", algo, keys)
+		}
+
+		filter, _ := ParseQuery("@kind:{draft}")
+		keys = knnKeys(t, store, []float32{9, 9}, 3, filter)
+		if fmt.Sprint(keys) != "[doc:3]" {
+			t.Errorf("VectorSearch() algorithm %d with filter = %v, want [doc:3]", algo, keys)
+		}
+	}
+}
+
+func TestVectorErrors(t *testing.T) {
+	store := NewKVStore()
+	vectorIndexSchema(t, store, VectorOptions{Algorithm: VectorFlat, Dim: 2, Metric: VectorL2})
+
+	if _, err := store.VectorSearch("docs", "embedding", []float32{1, 2, 3}, 1, nil); err == nil {
+		t.Error("VectorSearch() with the wrong dimension should error")
+	}
+	if _, err := store.VectorSearch("docs", "kind", []float32{1, 2}, 1, nil); err == nil {
+		t.Error("VectorSearch() on a TAG field should error")
+	}
+	if _, err := store.VectorSearch("nope", "embedding", []float32{1, 2}, 1, nil); err != ErrNoIndex {
+		t.Errorf("VectorSearch() unknown index error = %v, want ErrNoIndex", err)
+	}
+	if _, err := store.Search("docs", []QueryClause{{Field: "embedding", Values: []string{"x"}}}, 0, 10); err == nil {
+		t.Error("Search() filtering on a vector field should error")
+	}
+	err := store.CreateIndex(IndexSchema{Name: "bad", Fields: []IndexField{
+		{Name: "v", Type: IndexVector, Vector: &VectorOptions{Algorithm: VectorFlat}},
+	}})
+	if err == nil {
+		t.Error("CreateIndex() with DIM 0 should error")
+	}
+}
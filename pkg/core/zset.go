@@ -0,0 +1,158 @@
+package core
+
+import (
+	"math/rand"
+)
+
+const (
+	skipListMaxLevel = 32
+	skipListP        = 0.25
+)
+
+// SortedSet is a Redis-style sorted set stored as an Entry value: a map for
+// O(1) member lookups plus a skip list ordered by (score, member) for
+// O(log n) range queries. Geo indexes are sorted sets scored by geohash.
+type SortedSet struct {
+	dict map[string]float64
+	zsl  *skipList
+}
+
+type skipList struct {
+	header *skipListNode
+	level  int
+	length int
+}
+
+type skipListNode struct {
+	member  string
+	score   float64
+	forward []*skipListNode
+}
+
+func newSortedSet() *SortedSet {
+	return &SortedSet{
+		dict: make(map[string]float64),
+		zsl: &skipList{
+			header: &skipListNode{forward: make([]*skipListNode, skipListMaxLevel)},
+			level:  1,
+		},
+	}
+}
+
+// Len returns the number of members.
+func (z *SortedSet) Len() int {
+	return len(z.dict)
+}
+
+// Score returns the score of member.
+func (z *SortedSet) Score(member string) (float64, bool) {
+	score, ok := z.dict[member]
+	return score, ok
+}
+
+// add inserts or updates member. Returns true if the member is new.
+func (z *SortedSet) add(member string, score float64) bool {
+	if old, exists := z.dict[member]; exists {
+		if old != score {
+			z.zsl.remove(member, old)
+			z.zsl.insert(member, score)
+			z.dict[member] = score
+		}
+		return false
+	}
+	z.zsl.insert(member, score)
+	z.dict[member] = score
+	return true
+}
+
+// remove deletes member. Returns true if it was present.
+func (z *SortedSet) remove(member string) bool {
+	score, exists := z.dict[member]
+	if !exists {
+		return false
+	}
+	z.zsl.remove(member, score)
+	delete(z.dict, member)
+	return true
+}
+
+// rangeByScore calls fn for members with min <= score < max in ascending
+// order, stopping early if fn returns false.
+func (z *SortedSet) rangeByScore(min, max float64, fn func(member string, score float64) bool) {
+	x := z.zsl.header
+	for i := z.zsl.level - 1; i >= 0; i-- {
+		for x.forward[i] != nil && x.forward[i].score < min {
+			x = x.forward[i]
+		}
+	}
+	for x = x.forward[0]; x != nil && x.score < max; x = x.forward[0] {
+		if !fn(x.member, x.score) {
+			return
+		}
+	}
+}
+
+func randomSkipListLevel() int {
+	level := 1
+	for level < skipListMaxLevel && rand.Float64() < skipListP {
+		level++
+	}
+	return level
+}
+
+// less orders nodes by score, then lexicographically by member.
+func (n *skipListNode) less(member string, score float64) bool {
+	return n.score < score || (n.score == score && n.member < member)
+}
+
+func (zsl *skipList) insert(member string, score float64) {
+	var update [skipListMaxLevel]*skipListNode
+	x := zsl.header
+	for i := zsl.level - 1; i >= 0; i-- {
+		for x.forward[i] != nil && x.forward[i].less(member, score) {
+			x = x.forward[i]
+		}
+		update[i] = x
+	}
+
+	level := randomSkipListLevel()
+	if level > zsl.level {
+		for i := zsl.level; i < level; i++ {
+			update[i] = zsl.header
+		}
+		zsl.level = level
+	}
+
+	node := &skipListNode{member: member, score: score, forward: make([]*skipListNode, level)}
+	for i := 0; i < level; i++ {
+		node.forward[i] = update[i].forward[i]
+		update[i].forward[i] = node
+	}
+	zsl.length++
+}
+
+func (zsl *skipList) remove(member string, score float64) {
+	var update [skipListMaxLevel]*skipListNode
+	x := zsl.header
+	for i := zsl.level - 1; i >= 0; i-- {
+		for x.forward[i] != nil && x.forward[i].less(member, score) {
+			x = x.forward[i]
+		}
+		update[i] = x
+	}
+
+	x = x.forward[0]
+	if x == nil || x.score != score || x.member != member {
+		return
+	}
+	for i := 0; i < zsl.level; i++ {
+		if update[i].forward[i] != x {
+			break
+		}
+		update[i].forward[i] = x.forward[i]
+	}
+	for zsl.level > 1 && zsl.header.forward[zsl.level-1] == nil {
+		zsl.level--
+	}
+	zsl.length--
+}
@@ -0,0 +1,194 @@
+// Package resp encodes and decodes the Redis serialization protocol (RESP2)
+// spoken by SusyDB. The server and the Go client share it.
+package resp
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// MaxBulkLength caps a single bulk string (same default as Redis).
+const MaxBulkLength = 512 * 1024 * 1024
+
+// MaxArrayLength caps the number of elements in an array.
+const MaxArrayLength = 1024 * 1024
+
+// Error is an error reply (-ERR ..., -MOVED ...). The text keeps its
+// prefix, so the kind of error is its first word.
+type Error string
+
+func (e Error) Error() string { return string(e) }
+
+// Prefix returns the first word of the error, such as ERR or MOVED.
+func (e Error) Prefix() string {
+	prefix, _, _ := strings.Cut(string(e), " ")
+	return prefix
+}
+
+// AppendBulkString appends s to buf as a bulk string. The payload is
+// length-prefixed, so it may contain CR, LF or any other byte.
+func AppendBulkString(buf []byte, s string) []byte {
+	buf = append(buf, '$')
+	buf = strconv.AppendInt(buf, int64(len(s)), 10)
+	buf = append(buf, '\r', '\n')
+	buf = append(buf, s...)
+	return append(buf, '\r', '\n')
+}
+
+// AppendArrayHeader appends an array header announcing n elements.
+func AppendArrayHeader(buf []byte, n int) []byte {
+	buf = append(buf, '*')
+	buf = strconv.AppendInt(buf, int64(n), 10)
+	return append(buf, '\r', '\n')
+}
+
+// AppendInteger appends an integer.
+func AppendInteger(buf []byte, n int64) []byte {
+	buf = append(buf, ':')
+	buf = strconv.AppendInt(buf, n, 10)
+	return append(buf, '\r', '\n')
+}
+
+// AppendCommand appends args as an array of bulk strings, the form of a
+// command sent to the server.
+func AppendCommand(buf []byte, args []string) []byte {
+	buf = AppendArrayHeader(buf, len(args))
+	for _, arg := range args {
+		buf = AppendBulkString(buf, arg)
+	}
+	return buf
+}
+
+// ReadCommand reads an array of bulk strings.
+// Format: *<count>\r\n$<len>\r\n<content>\r\n...
+// Payloads are read by length, so arguments are binary-safe.
+func ReadCommand(reader *bufio.Reader) ([]string, error) {
+	line, err := readLine(reader)
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 || line[0] != '*' {
+		return nil, fmt.Errorf("invalid RESP array header: %s", line)
+	}
+	count, err := strconv.Atoi(line[1:])
+	if err != nil {
+		return nil, fmt.Errorf("invalid RESP array count: %v", err)
+	}
+	if count < 0 || count > MaxArrayLength {
+		return nil, fmt.Errorf("invalid RESP array count: %d", count)
+	}
+
+	args := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		line, err = readLine(reader)
+		if err != nil {
+			return nil, err
+		}
+		if len(line) == 0 || line[0] != '$' {
+			return nil, fmt.Errorf("invalid RESP bulk string header: %s", line)
+		}
+		arg, err := readBulk(reader, line)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+	return args, nil
+}
+
+// Status is a simple string reply (+OK), as ReadStatusReply returns it.
+type Status string
+
+// ReadReply reads one reply of any type: a string for simple and bulk
+// strings, int64 for integers, []interface{} for arrays, nil for null bulk
+// strings and arrays, and an Error value (not err) for error replies.
+func ReadReply(reader *bufio.Reader) (interface{}, error) {
+	return readReply(reader, false)
+}
+
+// ReadStatusReply is ReadReply for callers that show simple strings
+// differently from bulk strings: it returns them as Status values.
+func ReadStatusReply(reader *bufio.Reader) (interface{}, error) {
+	return readReply(reader, true)
+}
+
+func readReply(reader *bufio.Reader, status bool) (interface{}, error) {
+	line, err := readLine(reader)
+	if err != nil {
+		return nil, err
+	}
+	if line == "" {
+		return nil, errors.New("invalid RESP reply: empty line")
+	}
+	switch line[0] {
+	case '+':
+		if status {
+			return Status(line[1:]), nil
+		}
+		return line[1:], nil
+	case '-':
+		return Error(line[1:]), nil
+	case ':':
+		n, err := strconv.ParseInt(line[1:], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid RESP integer: %v", err)
+		}
+		return n, nil
+	case '$':
+		if line == "$-1" {
+			return nil, nil
+		}
+		return readBulk(reader, line)
+	case '*':
+		if line == "*-1" {
+			return nil, nil
+		}
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 || n > MaxArrayLength {
+			return nil, fmt.Errorf("invalid RESP array count: %s", line[1:])
+		}
+		items := make([]interface{}, n)
+		for i := range items {
+			if items[i], err = readReply(reader, status); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("invalid RESP reply: %q", line)
+}
+
+// readLine reads a CRLF-terminated line without the terminator. Only the
+// terminator is removed: spaces are part of simple string and error
+// payloads. A bare LF is accepted as a terminator too.
+func readLine(reader *bufio.Reader) (string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	line = strings.TrimSuffix(line, "\n")
+	return strings.TrimSuffix(line, "\r"), nil
+}
+
+// readBulk reads the payload announced by header ($<len>).
+func readBulk(reader *bufio.Reader, header string) (string, error) {
+	length, err := strconv.Atoi(header[1:])
+	if err != nil {
+		return "", fmt.Errorf("invalid RESP bulk string length: %v", err)
+	}
+	if length < 0 || length > MaxBulkLength {
+		return "", fmt.Errorf("invalid RESP bulk string length: %d", length)
+	}
+	data := make([]byte, length+2)
+	if _, err := io.ReadFull(reader, data); err != nil {
+		return "", err
+	}
+	if data[length] != '\r' || data[length+1] != '\n' {
+		return "", errors.New("invalid RESP bulk string terminator")
+	}
+	return string(data[:length]), nil
+}
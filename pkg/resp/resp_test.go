@@ -0,0 +1,75 @@
+package resp
+
+import (
+	"bufio"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCommandRoundTrip(t *testing.T) {
+	args := []string{"SET", "key", "line\r\nbreak", ""}
+	buf := AppendCommand(nil, args)
+	got, err := ReadCommand(bufio.NewReader(strings.NewReader(string(buf))))
+	if err != nil {
+		t.Fatalf("ReadCommand() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, args) {
+		t.Errorf("ReadCommand() = %q, want %q", got, args)
+	}
+}
+
+func TestReadReply(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  interface{}
+	}{
+		{"simple string", "+OK\r\n", "OK"},
+		{"error", "-WRONGTYPE bad\r\n", Error("WRONGTYPE bad")},
+		{"spaces kept", "+ two  spaces \r\n", " two  spaces "},
+		{"error spaces kept", "-ERR bad value \r\n", Error("ERR bad value ")},
+		{"integer", ":-42\r\n", int64(-42)},
+		{"bulk string", "$5\r\nhello\r\n", "hello"},
+		{"null bulk", "$-1\r\n", nil},
+		{"null array", "*-1\r\n", nil},
+		{"nested array", "*3\r\n:1\r\n*2\r\n+a\r\n$-1\r\n-ERR x\r\n",
+			[]interface{}{int64(1), []interface{}{"a", nil}, Error("ERR x")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ReadReply(bufio.NewReader(strings.NewReader(tt.input)))
+			if err != nil {
+				t.Fatalf("ReadReply() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ReadReply() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadStatusReply(t *testing.T) {
+	input := "*2\r\n+OK\r\n$2\r\nOK\r\n"
+	got, err := ReadStatusReply(bufio.NewReader(strings.NewReader(input)))
+	if err != nil {
+		t.Fatalf("ReadStatusReply() error = %v", err)
+	}
+	if want := []interface{}{Status("OK"), "OK"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadStatusReply() = %#v, want %#v", got, want)
+	}
+}
+
+func TestReadReplyErrors(t *testing.T) {
+	for _, input := range []string{"", "?x\r\n", ":abc\r\n", "$5\r\nhi\r\n", "*2\r\n:1\r\n"} {
+		if _, err := ReadReply(bufio.NewReader(strings.NewReader(input))); err == nil {
+			t.Errorf("ReadReply(%q) error = nil, want an error", input)
+		}
+	}
+}
+
+func TestErrorPrefix(t *testing.T) {
+	if got := Error("MOVED 3999 127.0.0.1:6381").Prefix(); got != "MOVED" {
+		t.Errorf("Prefix() = %q, want MOVED", got)
+	}
+}